@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/handlers"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/routes"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/config"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/database"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
@@ -93,12 +95,15 @@ func main() {
 	router := server.NewRouter(cfg.FrontendDir)
 
 	// Pass config to routes for auth service and certificate service
-	if err := routes.Register(router, db, cfg); err != nil {
+	reconciler, err := routes.Register(router, db, cfg)
+	if err != nil {
 		log.Fatalf("register routes: %v", err)
 	}
 
-	// Register import handler with config dependencies
-	routes.RegisterImportHandler(router, db, cfg.CaddyBinary, cfg.ImportDir)
+	// Caddy starts with an empty config; push the persisted state before serving
+	if result, _ := reconciler.ApplyNow(context.Background(), "startup"); result.State != caddy.ApplyStateApplied {
+		log.Printf("WARNING: initial caddy config apply failed: %s", result.Error)
+	}
 
 	// Check for mounted Caddyfile on startup
 	if err := handlers.CheckMountedImport(db, cfg.ImportCaddyfile, cfg.CaddyBinary, cfg.ImportDir); err != nil {
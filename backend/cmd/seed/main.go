@@ -22,10 +22,13 @@ func main() {
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.ProxyHost{},
+		&models.RedirectionHost{},
+		&models.Stream{},
 		&models.CaddyConfig{},
 		&models.RemoteServer{},
 		&models.SSLCertificate{},
 		&models.AccessList{},
+		&models.AccessListUser{},
 		&models.Setting{},
 		&models.ImportSession{},
 	); err != nil {
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// AccessListHandler handles CRUD operations for access lists.
+type AccessListHandler struct {
+	service *services.AccessListService
+}
+
+// NewAccessListHandler creates a new access list handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewAccessListHandler(db *gorm.DB, notifier services.ConfigNotifier) *AccessListHandler {
+	service := services.NewAccessListService(db)
+	service.SetNotifier(notifier)
+
+	return &AccessListHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers access list routes.
+func (h *AccessListHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/access-lists", h.List)
+	router.POST("/access-lists", h.Create)
+	router.GET("/access-lists/:uuid", h.Get)
+	router.PUT("/access-lists/:uuid", h.Update)
+	router.DELETE("/access-lists/:uuid", h.Delete)
+	router.POST("/access-lists/:uuid/check", h.Check)
+	router.GET("/access-lists/:uuid/users", h.ListUsers)
+	router.POST("/access-lists/:uuid/users", h.AddUser)
+	router.PUT("/access-lists/:uuid/users/:userUUID", h.UpdateUser)
+	router.DELETE("/access-lists/:uuid/users/:userUUID", h.DeleteUser)
+}
+
+// forwardAuthCheckRequest describes the request a forward_auth check simulates.
+type forwardAuthCheckRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url" binding:"required"`
+	Headers map[string]string `json:"headers"`
+}
+
+// accessListUserRequest carries the credentials of a basic_auth account.
+type accessListUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// List retrieves all access lists.
+func (h *AccessListHandler) List(c *gin.Context) {
+	lists, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lists)
+}
+
+// Create creates a new access list.
+func (h *AccessListHandler) Create(c *gin.Context) {
+	// Defaults for fields the request leaves out; an explicit false still wins
+	list := models.AccessList{Enabled: true}
+	if err := c.ShouldBindJSON(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list.ID = 0
+	list.UUID = uuid.NewString()
+	list.Users = nil
+
+	if err := h.service.Create(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// Get retrieves an access list by UUID.
+func (h *AccessListHandler) Get(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// Update updates an existing access list; hosts using it are reconfigured.
+func (h *AccessListHandler) Update(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	id, listUUID, users := list.ID, list.UUID, list.Users
+	if err := c.ShouldBindJSON(list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list.ID, list.UUID, list.Users = id, listUUID, users
+
+	if err := h.service.Update(list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// Delete removes an access list that is not in use.
+func (h *AccessListHandler) Delete(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	if err := h.service.Delete(list.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "access list deleted"})
+}
+
+// Check asks the auth service of a forward_auth list whether it would admit a request.
+func (h *AccessListHandler) Check(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	var req forwardAuthCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	check, err := h.service.CheckForwardAuth(list, req.Method, req.URL, req.Headers)
+	if errors.Is(err, services.ErrAuthServiceUnreachable) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, check)
+}
+
+// ListUsers retrieves the accounts of a basic_auth access list.
+func (h *AccessListHandler) ListUsers(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list.Users)
+}
+
+// AddUser adds an account to a basic_auth access list.
+func (h *AccessListHandler) AddUser(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+
+	var req accessListUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.AddUser(list, req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser changes the password of an access list account.
+func (h *AccessListHandler) UpdateUser(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+	user, err := h.service.GetUser(list, c.Param("userUUID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var req accessListUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetUserPassword(user, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser removes an account from an access list.
+func (h *AccessListHandler) DeleteUser(c *gin.Context) {
+	list, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access list not found"})
+		return
+	}
+	user, err := h.service.GetUser(list, c.Param("userUUID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := h.service.DeleteUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
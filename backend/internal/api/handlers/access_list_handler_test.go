@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestAccessListLifecycle(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewAccessListHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/access-lists", `{"name":"LAN","type":"allow","rules":["192.168.1.0/24","fd00::1"]}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var created models.AccessList
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+	require.Equal(t, []string{"192.168.1.0/24", "fd00::1"}, created.Rules)
+
+	resp = do(http.MethodPost, "/api/v1/access-lists", `{"name":"Bad","type":"deny","rules":["not-an-ip"]}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	hostBody := `{"domain_names":"nas.example.com","forward_host":"nas","forward_port":5000,"enabled":true,"access_list_ids":[` + strconv.FormatUint(uint64(created.ID), 10) + `]}`
+	resp = do(http.MethodPost, "/api/v1/proxy-hosts", hostBody)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var host models.ProxyHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &host))
+	require.Equal(t, []uint{created.ID}, host.AccessListIDs)
+	require.Equal(t, models.AccessSatisfyAll, host.AccessSatisfy)
+
+	path := "/api/v1/access-lists/" + created.UUID
+	resp = do(http.MethodPut, path, `{"name":"LAN","type":"allow","rules":["192.168.0.0/16"]}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.AccessList
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Equal(t, created.UUID, updated.UUID)
+	require.Equal(t, []string{"192.168.0.0/16"}, updated.Rules)
+
+	resp = do(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = do(http.MethodDelete, "/api/v1/proxy-hosts/"+host.UUID, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = do(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = do(http.MethodGet, path, "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestAccessListCreate_Enabled(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewAccessListHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	for body, want := range map[string]bool{
+		`{"name":"On","type":"allow","rules":["10.0.0.0/8"]}`:                  true,
+		`{"name":"Off","type":"allow","rules":["10.0.0.0/8"],"enabled":false}`: false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/access-lists", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		var created models.AccessList
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+		var stored models.AccessList
+		require.NoError(t, db.First(&stored, created.ID).Error)
+		require.Equal(t, want, stored.Enabled, body)
+	}
+}
+
+func TestAccessListUsers(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewAccessListHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/access-lists", `{"name":"Staff","type":"basic_auth","realm":"Staff only","users":[{"username":"sneaky"}]}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var list models.AccessList
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	require.Equal(t, "Staff only", list.Realm)
+	require.Empty(t, list.Users)
+
+	users := "/api/v1/access-lists/" + list.UUID + "/users"
+	resp = do(http.MethodPost, users, `{"username":"alice","password":"s3cret"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	require.NotContains(t, resp.Body.String(), "s3cret")
+	require.NotContains(t, resp.Body.String(), "$2a$")
+	var alice models.AccessListUser
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &alice))
+	require.NotEmpty(t, alice.UUID)
+
+	resp = do(http.MethodPost, users, `{"username":"alice","password":"again"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = do(http.MethodGet, "/api/v1/access-lists/"+list.UUID, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Body.String(), `"username":"alice"`)
+	require.NotContains(t, resp.Body.String(), "$2a$")
+
+	resp = do(http.MethodPut, users+"/"+alice.UUID, `{"password":"n3w"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var stored models.AccessListUser
+	require.NoError(t, db.Where("uuid = ?", alice.UUID).First(&stored).Error)
+	require.True(t, stored.CheckPassword("n3w"))
+
+	resp = do(http.MethodPut, users+"/missing", `{"password":"n3w"}`)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = do(http.MethodDelete, users+"/"+alice.UUID, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = do(http.MethodGet, users, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.JSONEq(t, `[]`, resp.Body.String())
+}
+
+func TestAccessListForwardAuthCheck(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewAccessListHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Remote-User", "alice")
+	}))
+	defer auth.Close()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/access-lists", `{"name":"Portal","type":"forward_auth","forward_auth_url":"`+auth.URL+`/verify"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var list models.AccessList
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	require.Contains(t, list.CopyHeaders, "Remote-User")
+
+	check := "/api/v1/access-lists/" + list.UUID + "/check"
+	resp = do(http.MethodPost, check, `{"url":"https://app.example.com/"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.JSONEq(t, `{"status":401,"authorized":false,"headers":{}}`, resp.Body.String())
+
+	resp = do(http.MethodPost, check, `{"url":"https://app.example.com/","headers":{"Authorization":"Bearer token"}}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.JSONEq(t, `{"status":200,"authorized":true,"headers":{"Remote-User":"alice"}}`, resp.Body.String())
+
+	resp = do(http.MethodPost, check, `{}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	auth.Close()
+	resp = do(http.MethodPost, check, `{"url":"https://app.example.com/"}`)
+	require.Equal(t, http.StatusBadGateway, resp.Code)
+}
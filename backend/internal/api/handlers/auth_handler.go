@@ -9,15 +9,22 @@ import (
 
 type AuthHandler struct {
 	authService *services.AuthService
+	sso         *services.SSOService
 }
 
 func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 	return &AuthHandler{authService: authService}
 }
 
+// SetSSO enables single sign-on sessions for proxied apps on login.
+func (h *AuthHandler) SetSSO(sso *services.SSOService) {
+	h.sso = sso
+}
+
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	Redirect string `json:"redirect"` // App to return to after a single sign-on login
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -36,7 +43,41 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Set cookie
 	c.SetCookie("auth_token", token, 3600*24, "/", "", false, true) // Secure should be true in prod
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	resp := gin.H{"token": token}
+	if domain := h.ssoCookieDomain(); domain != "" {
+		ssoToken, err := h.sso.SessionToken(token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.setSSOCookie(c, domain, ssoToken, 3600*24)
+		if h.sso.AllowedRedirect(req.Redirect) {
+			resp["redirect"] = req.Redirect
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) ssoCookieDomain() string {
+	if h.sso == nil {
+		return ""
+	}
+	return h.sso.CookieDomain()
+}
+
+// setSSOCookie sets the single sign-on session for every host below domain.
+func (h *AuthHandler) setSSOCookie(c *gin.Context, domain, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     services.SSOCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   domain,
+		MaxAge:   maxAge,
+		Secure:   h.sso.SecureCookie(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
 }
 
 type RegisterRequest struct {
@@ -63,6 +104,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 func (h *AuthHandler) Logout(c *gin.Context) {
 	c.SetCookie("auth_token", "", -1, "/", "", false, true)
+	if domain := h.ssoCookieDomain(); domain != "" {
+		h.setSSOCookie(c, domain, "", -1)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+)
+
+// CaddyHandler exposes the state of the running Caddy configuration.
+type CaddyHandler struct {
+	reconciler *caddy.Reconciler
+	client     *caddy.Client
+	exporter   caddy.ConfigExporter
+}
+
+// NewCaddyHandler creates a new Caddy handler.
+func NewCaddyHandler(reconciler *caddy.Reconciler) *CaddyHandler {
+	return &CaddyHandler{reconciler: reconciler}
+}
+
+// SetClient sets the admin API client used to read Caddy's internal CA.
+func (h *CaddyHandler) SetClient(client *caddy.Client) {
+	h.client = client
+}
+
+// SetExporter sets what renders the managed configuration for export.
+func (h *CaddyHandler) SetExporter(exporter caddy.ConfigExporter) {
+	h.exporter = exporter
+}
+
+// RegisterRoutes registers Caddy routes.
+func (h *CaddyHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/caddy/status", h.Status)
+	router.POST("/caddy/apply", h.Apply)
+	router.GET("/caddy/pki/root.crt", h.RootCertificate)
+	router.GET("/caddy/export", h.Export)
+}
+
+// Status reports whether an apply is pending and how the last one ended.
+func (h *CaddyHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, h.reconciler.Status())
+}
+
+// Apply forces a reconciliation and waits for its result.
+func (h *CaddyHandler) Apply(c *gin.Context) {
+	result, err := h.reconciler.ApplyNow(c.Request.Context(), "manual apply")
+	if err != nil {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if result.State != caddy.ApplyStateApplied {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, result)
+}
+
+// RootCertificate downloads the root of Caddy's internal CA so it can be
+// installed on clients that visit hosts using the internal issuer.
+func (h *CaddyHandler) RootCertificate(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "caddy admin API is not configured"})
+		return
+	}
+
+	rootPEM, err := h.client.RootCertificate(c.Request.Context(), "local")
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="cpm-internal-root-ca.crt"`)
+	c.Data(http.StatusOK, "application/x-pem-file", []byte(rootPEM))
+}
+
+// Export downloads the managed configuration as a Caddyfile (the default) or
+// as the Caddy JSON CPM loads, for use with plain Caddy or review in Git.
+func (h *CaddyHandler) Export(c *gin.Context) {
+	if h.exporter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config export is not available"})
+		return
+	}
+
+	format := c.DefaultQuery("format", caddy.ExportFormatCaddyfile)
+	var filename, contentType string
+	switch format {
+	case caddy.ExportFormatCaddyfile:
+		filename, contentType = "Caddyfile", "text/plain; charset=utf-8"
+	case caddy.ExportFormatJSON:
+		filename, contentType = "caddy.json", "application/json"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be caddyfile or json"})
+		return
+	}
+
+	data, err := h.exporter.Export(format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
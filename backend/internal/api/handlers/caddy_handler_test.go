@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/handlers"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+)
+
+type stubApplier struct {
+	err error
+}
+
+func (s *stubApplier) ApplyConfig(ctx context.Context) error {
+	return s.err
+}
+
+func setupCaddyRouter(applier caddy.ConfigApplier) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	reconciler := caddy.NewReconciler(applier, time.Millisecond)
+	handler := handlers.NewCaddyHandler(reconciler)
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+	return router
+}
+
+func TestCaddyHandler_ApplyAndStatus(t *testing.T) {
+	router := setupCaddyRouter(&stubApplier{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/caddy/status", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"pending":false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/v1/caddy/apply", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result caddy.ApplyResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, caddy.ApplyStateApplied, result.State)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/caddy/status", nil)
+	router.ServeHTTP(w, req)
+
+	var status caddy.ReconcilerStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.Last)
+	assert.Equal(t, caddy.ApplyStateApplied, status.Last.State)
+	assert.Equal(t, []string{"manual apply"}, status.Last.Reasons)
+}
+
+func TestCaddyHandler_ApplyFailure(t *testing.T) {
+	router := setupCaddyRouter(&stubApplier{err: errors.New("caddy unreachable")})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/caddy/apply", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	var result caddy.ApplyResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, caddy.ApplyStateFailed, result.State)
+	assert.Equal(t, "caddy unreachable", result.Error)
+}
+
+func TestCaddyHandler_RootCertificate(t *testing.T) {
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pki/ca/local" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"id":               "local",
+			"root_certificate": "-----BEGIN CERTIFICATE-----\nROOT\n-----END CERTIFICATE-----\n",
+		})
+	}))
+	defer admin.Close()
+
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewCaddyHandler(caddy.NewReconciler(&stubApplier{}, time.Millisecond))
+	handler.SetClient(caddy.NewClient(admin.URL))
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/caddy/pki/root.crt", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-pem-file", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "cpm-internal-root-ca.crt")
+	assert.Contains(t, w.Body.String(), "ROOT")
+
+	// Without an admin client there is nothing to download
+	router = setupCaddyRouter(&stubApplier{})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+type stubExporter struct {
+	formats []string
+	err     error
+}
+
+func (s *stubExporter) Export(format string) ([]byte, error) {
+	s.formats = append(s.formats, format)
+	return []byte("exported " + format), s.err
+}
+
+func TestCaddyHandler_Export(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := &stubExporter{}
+	handler := handlers.NewCaddyHandler(caddy.NewReconciler(&stubApplier{}, time.Millisecond))
+	handler.SetExporter(exporter)
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/caddy/export")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="Caddyfile"`)
+	assert.Equal(t, "exported caddyfile", w.Body.String())
+
+	w = get("/api/v1/caddy/export?format=json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="caddy.json"`)
+
+	w = get("/api/v1/caddy/export?format=nginx")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []string{"caddyfile", "json"}, exporter.formats)
+
+	exporter.err = errors.New("fetch proxy hosts: database is locked")
+	w = get("/api/v1/caddy/export")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database is locked")
+
+	// Without an exporter there is nothing to download
+	router = setupCaddyRouter(&stubApplier{})
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/v1/caddy/export").Code)
+}
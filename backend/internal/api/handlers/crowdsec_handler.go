@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// CrowdSecHandler shows CrowdSec decisions and manages manual bans.
+type CrowdSecHandler struct {
+	service *services.CrowdSecService
+}
+
+// NewCrowdSecHandler creates a new CrowdSec handler.
+func NewCrowdSecHandler(service *services.CrowdSecService) *CrowdSecHandler {
+	return &CrowdSecHandler{service: service}
+}
+
+// RegisterRoutes registers CrowdSec routes.
+func (h *CrowdSecHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/crowdsec/decisions", h.Decisions)
+	router.POST("/crowdsec/decisions", h.Ban)
+	router.DELETE("/crowdsec/decisions", h.Unban)
+}
+
+// crowdSecErrorStatus maps service errors to a response status.
+func crowdSecErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCrowdSecUnreachable):
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrCrowdSecNotConfigured), errors.Is(err, services.ErrCrowdSecNoMachine):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Decisions returns the integration state and the decisions of the last poll,
+// polling first when none has happened yet.
+func (h *CrowdSecHandler) Decisions(c *gin.Context) {
+	if !h.service.Polled() {
+		h.service.Poll(c.Request.Context())
+	}
+	c.JSON(http.StatusOK, h.service.Status())
+}
+
+// BanRequest is the body of a manual ban.
+type BanRequest struct {
+	IP       string `json:"ip" binding:"required"` // Address or CIDR range
+	Duration string `json:"duration"`              // Go duration such as 24h; default 4h
+	Reason   string `json:"reason"`
+}
+
+// Ban adds a ban decision through the LAPI.
+func (h *CrowdSecHandler) Ban(c *gin.Context) {
+	var req BanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duration (expected e.g. 24h)"})
+			return
+		}
+	}
+
+	if err := h.service.Ban(c.Request.Context(), req.IP, duration, req.Reason); err != nil {
+		c.JSON(crowdSecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "ip banned"})
+}
+
+// Unban deletes the decisions for the address or range in the ip query parameter.
+func (h *CrowdSecHandler) Unban(c *gin.Context) {
+	ip := c.Query("ip")
+	if ip == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ip is required"})
+		return
+	}
+
+	deleted, err := h.service.Unban(c.Request.Context(), ip)
+	if err != nil {
+		c.JSON(crowdSecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "decision not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "decisions deleted", "deleted": deleted})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+func TestCrowdSecDecisions(t *testing.T) {
+	// Stub LAPI with one decision that refuses machine logins
+	lapi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/decisions":
+			w.Write([]byte(`[{"id": 3, "origin": "crowdsec", "type": "ban", "scope": "Ip", "value": "203.0.113.9", "duration": "1h", "scenario": "crowdsecurity/ssh-bf"}]`))
+		default:
+			http.Error(w, `{"message":"incorrect Username or Password"}`, http.StatusUnauthorized)
+		}
+	}))
+	defer lapi.Close()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewCrowdSecHandler(services.NewCrowdSecService(db, nil)).RegisterRoutes(r.Group("/api/v1"))
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Not configured yet
+	w := do(http.MethodGet, "/api/v1/crowdsec/decisions", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"configured":false`)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/crowdsec/decisions", `{"ip": "198.51.100.4"}`).Code)
+
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecLAPIURLSettingKey, Value: lapi.URL}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecBouncerKeySettingKey, Value: "bouncer-key"}).Error)
+
+	w = do(http.MethodGet, "/api/v1/crowdsec/decisions", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status services.CrowdSecStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Configured)
+	require.Len(t, status.Decisions, 1)
+	assert.Equal(t, "203.0.113.9", status.Decisions[0].Value)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/crowdsec/decisions", `{"ip": "198.51.100.4", "duration": "forever"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/crowdsec/decisions", `{"ip": "nope"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/crowdsec/decisions", `{"ip": "198.51.100.4"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/api/v1/crowdsec/decisions", "").Code)
+
+	// The LAPI refusing the machine is a gateway error
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecMachineSettingKey, Value: `{"machine_id": "cpm", "password": "wrong"}`}).Error)
+	w = do(http.MethodPost, "/api/v1/crowdsec/decisions", `{"ip": "198.51.100.4", "duration": "24h"}`)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "status 401")
+}
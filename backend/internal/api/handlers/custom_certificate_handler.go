@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// certificateUpload is the request body for uploading or replacing a custom certificate.
+// The private key is write-only and never returned.
+type certificateUpload struct {
+	Name        string `json:"name"`
+	Certificate string `json:"certificate" binding:"required"`
+	PrivateKey  string `json:"private_key" binding:"required"`
+	Chain       string `json:"chain"`
+}
+
+// CustomCertificateHandler handles uploaded certificates stored in the database.
+type CustomCertificateHandler struct {
+	service *services.SSLCertificateService
+}
+
+// NewCustomCertificateHandler creates a new custom certificate handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewCustomCertificateHandler(db *gorm.DB, cipher *secrets.Cipher, notifier services.ConfigNotifier) *CustomCertificateHandler {
+	service := services.NewSSLCertificateService(db, cipher)
+	service.SetNotifier(notifier)
+
+	return &CustomCertificateHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers custom certificate routes.
+func (h *CustomCertificateHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/certificates/custom", h.List)
+	router.POST("/certificates/custom", h.Create)
+	router.GET("/certificates/custom/:uuid", h.Get)
+	router.PUT("/certificates/custom/:uuid", h.Update)
+	router.DELETE("/certificates/custom/:uuid", h.Delete)
+}
+
+// List retrieves all custom certificates.
+func (h *CustomCertificateHandler) List(c *gin.Context) {
+	certs, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, certs)
+}
+
+// Create uploads a new custom certificate.
+func (h *CustomCertificateHandler) Create(c *gin.Context) {
+	var req certificateUpload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cert := models.SSLCertificate{
+		UUID:        uuid.NewString(),
+		Name:        req.Name,
+		Certificate: req.Certificate,
+		Chain:       req.Chain,
+	}
+
+	if err := h.service.Create(&cert, req.PrivateKey); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cert)
+}
+
+// Get retrieves a custom certificate by UUID.
+func (h *CustomCertificateHandler) Get(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	cert, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cert)
+}
+
+// Update replaces the certificate material while keeping its UUID, so hosts
+// using it pick up the renewed certificate.
+func (h *CustomCertificateHandler) Update(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	cert, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		return
+	}
+
+	var req certificateUpload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != "" {
+		cert.Name = req.Name
+	}
+	cert.Certificate = req.Certificate
+	cert.Chain = req.Chain
+
+	if err := h.service.Update(cert, req.PrivateKey); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cert)
+}
+
+// Delete removes a custom certificate that is not in use.
+func (h *CustomCertificateHandler) Delete(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	cert, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		return
+	}
+
+	if err := h.service.Delete(cert.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "certificate deleted"})
+}
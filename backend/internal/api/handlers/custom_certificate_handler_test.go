@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+)
+
+func setupCustomCertificateRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.SSLCertificate{}))
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+
+	r := gin.New()
+	api := r.Group("/api/v1")
+	NewCustomCertificateHandler(db, cipher, nil).RegisterRoutes(api)
+	NewProxyHostHandler(db, nil).RegisterRoutes(api)
+
+	return r, db
+}
+
+func selfSignedPEM(t *testing.T, domain string) (certPEM, keyPEM string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestCustomCertificateLifecycle(t *testing.T) {
+	router, _ := setupCustomCertificateRouter(t)
+
+	certPEM, keyPEM := selfSignedPEM(t, "*.example.com")
+	body, err := json.Marshal(map[string]string{"name": "Wildcard", "certificate": certPEM, "private_key": keyPEM})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/certificates/custom", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.NotContains(t, resp.Body.String(), "PRIVATE KEY")
+	require.NotContains(t, resp.Body.String(), "private_key")
+
+	var created models.SSLCertificate
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+	require.Equal(t, "*.example.com", created.Domains)
+
+	// Hosts under the wildcard can use the certificate
+	hostBody := fmt.Sprintf(`{"domain_names":"app.example.com","forward_host":"10.0.0.1","forward_port":80,"certificate_id":%d}`, created.ID)
+	hostReq := httptest.NewRequest(http.MethodPost, "/api/v1/proxy-hosts", strings.NewReader(hostBody))
+	hostReq.Header.Set("Content-Type", "application/json")
+	hostResp := httptest.NewRecorder()
+	router.ServeHTTP(hostResp, hostReq)
+	require.Equal(t, http.StatusCreated, hostResp.Code)
+
+	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/certificates/custom/"+created.UUID, nil)
+	deleteResp := httptest.NewRecorder()
+	router.ServeHTTP(deleteResp, deleteReq)
+	require.Equal(t, http.StatusConflict, deleteResp.Code)
+
+	// Replacing keeps the UUID
+	renewedPEM, renewedKey := selfSignedPEM(t, "*.example.com")
+	body, err = json.Marshal(map[string]string{"certificate": renewedPEM, "private_key": renewedKey})
+	require.NoError(t, err)
+	updateReq := httptest.NewRequest(http.MethodPut, "/api/v1/certificates/custom/"+created.UUID, strings.NewReader(string(body)))
+	updateReq.Header.Set("Content-Type", "application/json")
+	updateResp := httptest.NewRecorder()
+	router.ServeHTTP(updateResp, updateReq)
+	require.Equal(t, http.StatusOK, updateResp.Code)
+
+	var updated models.SSLCertificate
+	require.NoError(t, json.Unmarshal(updateResp.Body.Bytes(), &updated))
+	require.Equal(t, created.UUID, updated.UUID)
+	require.Equal(t, "Wildcard", updated.Name)
+
+	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/certificates/custom", nil)
+	listResp := httptest.NewRecorder()
+	router.ServeHTTP(listResp, listReq)
+	require.Equal(t, http.StatusOK, listResp.Code)
+
+	var certs []models.SSLCertificate
+	require.NoError(t, json.Unmarshal(listResp.Body.Bytes(), &certs))
+	require.Len(t, certs, 1)
+}
+
+func TestCustomCertificateCreate_MismatchedKey(t *testing.T) {
+	router, _ := setupCustomCertificateRouter(t)
+
+	certPEM, _ := selfSignedPEM(t, "example.com")
+	_, otherKey := selfSignedPEM(t, "example.com")
+	body, err := json.Marshal(map[string]string{"certificate": certPEM, "private_key": otherKey})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/certificates/custom", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "does not match")
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// GeoIPHandler answers Caddy's country checks for country access lists.
+type GeoIPHandler struct {
+	service *services.GeoIPService
+}
+
+// NewGeoIPHandler creates a new GeoIP handler.
+func NewGeoIPHandler(service *services.GeoIPService) *GeoIPHandler {
+	return &GeoIPHandler{service: service}
+}
+
+// RegisterRoutes registers GeoIP routes. The verify route must stay outside the
+// auth middleware: Caddy calls it on behalf of every client.
+func (h *GeoIPHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/geoip/verify", h.Verify)
+}
+
+func splitCountries(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+// Verify answers 200 when the client in X-Client-IP passes the allow and deny
+// countries of the query, and 403 otherwise. Without a usable database it fails
+// closed with a 503.
+func (h *GeoIPHandler) Verify(c *gin.Context) {
+	ip := c.GetHeader("X-Client-IP")
+	if ip == "" {
+		ip = c.ClientIP()
+	}
+
+	if _, err := netip.ParseAddr(ip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client address"})
+		return
+	}
+
+	country, allowed, err := h.service.Allowed(ip, splitCountries(c.Query("allow")), splitCountries(c.Query("deny")))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access from your country is not allowed"})
+		return
+	}
+
+	c.Header("Remote-Country", country)
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/geoip/geoiptest"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+func TestGeoIPVerify(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewGeoIPHandler(services.NewGeoIPService(db)).RegisterRoutes(r.Group("/api/v1"))
+
+	verify := func(ip, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/geoip/verify?"+query, nil)
+		req.Header.Set("X-Client-IP", ip)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a database every check fails closed
+	assert.Equal(t, http.StatusServiceUnavailable, verify("81.2.69.160", "allow=GB").Code)
+
+	path := geoiptest.WriteDatabase(t, map[string]string{"81.2.69.0/24": "GB", "89.160.0.0/16": "SE"})
+	require.NoError(t, db.Create(&models.Setting{Key: services.GeoIPDatabaseSettingKey, Value: path}).Error)
+
+	w := verify("81.2.69.160", "allow=GB,IE")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "GB", w.Header().Get("Remote-Country"))
+
+	assert.Equal(t, http.StatusForbidden, verify("89.160.20.112", "allow=GB,IE").Code)
+	assert.Equal(t, http.StatusForbidden, verify("89.160.20.112", "deny=SE").Code)
+	assert.Equal(t, http.StatusOK, verify("81.2.69.160", "deny=SE").Code)
+	assert.Equal(t, http.StatusBadRequest, verify("bogus", "deny=SE").Code)
+}
@@ -28,6 +28,7 @@ func setupTestDB() *gorm.DB {
 	db.AutoMigrate(
 		&models.ProxyHost{},
 		&models.Location{},
+		&models.RedirectionHost{},
 		&models.RemoteServer{},
 		&models.ImportSession{},
 	)
@@ -259,7 +260,7 @@ func TestProxyHostHandler_List(t *testing.T) {
 	}
 	db.Create(host)
 
-	handler := handlers.NewProxyHostHandler(db)
+	handler := handlers.NewProxyHostHandler(db, nil)
 	router := gin.New()
 	handler.RegisterRoutes(router.Group("/api/v1"))
 
@@ -281,7 +282,7 @@ func TestProxyHostHandler_Create(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupTestDB()
 
-	handler := handlers.NewProxyHostHandler(db)
+	handler := handlers.NewProxyHostHandler(db, nil)
 	router := gin.New()
 	handler.RegisterRoutes(router.Group("/api/v1"))
 
@@ -26,10 +26,14 @@ type ImportHandler struct {
 }
 
 // NewImportHandler creates a new import handler.
-func NewImportHandler(db *gorm.DB, caddyBinary, importDir string) *ImportHandler {
+// Committed hosts are reported to notifier, which may be nil.
+func NewImportHandler(db *gorm.DB, caddyBinary, importDir string, notifier services.ConfigNotifier) *ImportHandler {
+	proxyHostSvc := services.NewProxyHostService(db)
+	proxyHostSvc.SetNotifier(notifier)
+
 	return &ImportHandler{
 		db:              db,
-		proxyHostSvc:    services.NewProxyHostService(db),
+		proxyHostSvc:    proxyHostSvc,
 		importerservice: caddy.NewImporter(caddyBinary),
 		importDir:       importDir,
 	}
@@ -275,7 +279,7 @@ func CheckMountedImport(db *gorm.DB, mountPath, caddyBinary, importDir string) e
 		return nil // Already processed
 	}
 
-	handler := NewImportHandler(db, caddyBinary, importDir)
+	handler := NewImportHandler(db, caddyBinary, importDir, nil)
 	return handler.processImport(mountPath, mountPath)
 }
 
@@ -25,7 +25,7 @@ func setupImportTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		panic("failed to connect to test database")
 	}
-	db.AutoMigrate(&models.ImportSession{}, &models.ProxyHost{}, &models.Location{})
+	db.AutoMigrate(&models.ImportSession{}, &models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{})
 	return db
 }
 
@@ -34,7 +34,7 @@ func TestImportHandler_GetStatus(t *testing.T) {
 	db := setupImportTestDB(t)
 
 	// Case 1: No active session
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	router.GET("/import/status", handler.GetStatus)
 
@@ -68,7 +68,7 @@ func TestImportHandler_GetStatus(t *testing.T) {
 func TestImportHandler_GetPreview(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupImportTestDB(t)
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	router.GET("/import/preview", handler.GetPreview)
 
@@ -105,7 +105,7 @@ func TestImportHandler_GetPreview(t *testing.T) {
 func TestImportHandler_Cancel(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupImportTestDB(t)
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	router.DELETE("/import/cancel", handler.Cancel)
 
@@ -129,7 +129,7 @@ func TestImportHandler_Cancel(t *testing.T) {
 func TestImportHandler_Commit(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupImportTestDB(t)
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	router.POST("/import/commit", handler.Commit)
 
@@ -176,7 +176,7 @@ func TestImportHandler_Upload(t *testing.T) {
 	os.Chmod(fakeCaddy, 0755)
 
 	tmpDir := t.TempDir()
-	handler := handlers.NewImportHandler(db, fakeCaddy, tmpDir)
+	handler := handlers.NewImportHandler(db, fakeCaddy, tmpDir, nil)
 	router := gin.New()
 	router.POST("/import/upload", handler.Upload)
 
@@ -210,7 +210,7 @@ func TestImportHandler_Upload(t *testing.T) {
 
 func TestImportHandler_RegisterRoutes(t *testing.T) {
 	db := setupImportTestDB(t)
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	api := router.Group("/api/v1")
 	handler.RegisterRoutes(api)
@@ -225,7 +225,7 @@ func TestImportHandler_RegisterRoutes(t *testing.T) {
 func TestImportHandler_Errors(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupImportTestDB(t)
-	handler := handlers.NewImportHandler(db, "echo", "/tmp")
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", nil)
 	router := gin.New()
 	router.POST("/import/upload", handler.Upload)
 	router.POST("/import/commit", handler.Commit)
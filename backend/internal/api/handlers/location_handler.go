@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// LocationHandler handles CRUD operations for the custom locations of a proxy host.
+type LocationHandler struct {
+	hosts   *services.ProxyHostService
+	service *services.LocationService
+}
+
+// NewLocationHandler creates a new location handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewLocationHandler(db *gorm.DB, notifier services.ConfigNotifier) *LocationHandler {
+	service := services.NewLocationService(db)
+	service.SetNotifier(notifier)
+
+	return &LocationHandler{
+		hosts:   services.NewProxyHostService(db),
+		service: service,
+	}
+}
+
+// SetModules sets the IDs of the modules in the running Caddy build.
+func (h *LocationHandler) SetModules(modules []string) {
+	h.service.SetModules(modules)
+}
+
+// RegisterRoutes registers location routes below their proxy host.
+func (h *LocationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/proxy-hosts/:uuid/locations", h.List)
+	router.POST("/proxy-hosts/:uuid/locations", h.Create)
+	router.GET("/proxy-hosts/:uuid/locations/:locationUUID", h.Get)
+	router.PUT("/proxy-hosts/:uuid/locations/:locationUUID", h.Update)
+	router.DELETE("/proxy-hosts/:uuid/locations/:locationUUID", h.Delete)
+}
+
+// host resolves the proxy host of the request, answering 404 when it does not exist.
+func (h *LocationHandler) host(c *gin.Context) (*models.ProxyHost, bool) {
+	host, err := h.hosts.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proxy host not found"})
+		return nil, false
+	}
+	return host, true
+}
+
+// location resolves the location of the request, answering 404 when it does not exist.
+func (h *LocationHandler) location(c *gin.Context) (*models.ProxyHost, *models.Location, bool) {
+	host, ok := h.host(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	loc, err := h.service.GetByUUID(host.ID, c.Param("locationUUID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+		return nil, nil, false
+	}
+	return host, loc, true
+}
+
+// List retrieves the locations of a proxy host.
+func (h *LocationHandler) List(c *gin.Context) {
+	host, ok := h.host(c)
+	if !ok {
+		return
+	}
+
+	locs, err := h.service.List(host.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, locs)
+}
+
+// Create adds a location to a proxy host.
+func (h *LocationHandler) Create(c *gin.Context) {
+	host, ok := h.host(c)
+	if !ok {
+		return
+	}
+
+	var loc models.Location
+	if err := c.ShouldBindJSON(&loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc.ID = 0
+	loc.UUID = uuid.NewString()
+
+	if err := h.service.Create(host, &loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, loc)
+}
+
+// Get retrieves a location by UUID.
+func (h *LocationHandler) Get(c *gin.Context) {
+	_, loc, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, loc)
+}
+
+// Update updates an existing location.
+func (h *LocationHandler) Update(c *gin.Context) {
+	host, loc, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	id, locUUID := loc.ID, loc.UUID
+	if err := c.ShouldBindJSON(loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc.ID, loc.UUID = id, locUUID
+
+	if err := h.service.Update(host, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, loc)
+}
+
+// Delete removes a location.
+func (h *LocationHandler) Delete(c *gin.Context) {
+	_, loc, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(loc.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "location deleted"})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestLocationLifecycle(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewLocationHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	host := models.ProxyHost{UUID: "host-uuid", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 8080}
+	require.NoError(t, db.Create(&host).Error)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/proxy-hosts/missing/locations", `{}`)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+
+	body := `{"path":"/api","forward_scheme":"https","forward_host":"api","forward_port":8443,"strip_path_prefix":true,"websocket_support":false,"request_headers":{"X-Gateway":"cpm"}}`
+	resp = do(http.MethodPost, "/api/v1/proxy-hosts/host-uuid/locations", body)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var created models.Location
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+	require.Equal(t, host.ID, created.ProxyHostID)
+	require.NotNil(t, created.WebsocketSupport)
+	require.False(t, *created.WebsocketSupport)
+
+	resp = do(http.MethodPost, "/api/v1/proxy-hosts/host-uuid/locations", `{"path":"api","forward_host":"api","forward_port":80}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = do(http.MethodGet, "/api/v1/proxy-hosts/host-uuid/locations", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var locs []models.Location
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &locs))
+	require.Len(t, locs, 1)
+
+	path := "/api/v1/proxy-hosts/host-uuid/locations/" + created.UUID
+	resp = do(http.MethodPut, path, `{"path":"/api","forward_host":"api-v2","forward_port":9000,"rewrite_uri":"/v2{http.request.uri}"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var updated models.Location
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Equal(t, created.UUID, updated.UUID)
+	require.Equal(t, "api-v2", updated.ForwardHost)
+	require.Equal(t, "/v2{http.request.uri}", updated.RewriteURI)
+
+	resp = do(http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = do(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = do(http.MethodGet, path, "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestProxyHostUpdateAssignsLocationUUIDs(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	host := models.ProxyHost{UUID: "host-uuid", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 8080}
+	require.NoError(t, db.Create(&host).Error)
+
+	body := `{"domain_names":"app.example.com","forward_host":"app","forward_port":8080,"locations":[{"path":"/api","forward_host":"api","forward_port":9000}]}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/proxy-hosts/host-uuid", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var updated models.ProxyHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Len(t, updated.Locations, 1)
+	require.NotEmpty(t, updated.Locations[0].UUID)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// PageTemplateHandler handles the maintenance and error page templates.
+type PageTemplateHandler struct {
+	service *services.PageTemplateService
+}
+
+// NewPageTemplateHandler creates a new page template handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewPageTemplateHandler(db *gorm.DB, notifier services.ConfigNotifier) *PageTemplateHandler {
+	service := services.NewPageTemplateService(db)
+	service.SetNotifier(notifier)
+
+	return &PageTemplateHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers page template routes.
+func (h *PageTemplateHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/page-templates", h.List)
+	router.POST("/page-templates", h.Create)
+	router.GET("/page-templates/:uuid", h.Get)
+	router.PUT("/page-templates/:uuid", h.Update)
+	router.DELETE("/page-templates/:uuid", h.Delete)
+}
+
+// List retrieves all page templates.
+func (h *PageTemplateHandler) List(c *gin.Context) {
+	templates, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// Create creates a new page template.
+func (h *PageTemplateHandler) Create(c *gin.Context) {
+	var template models.PageTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template.ID = 0
+	template.UUID = uuid.NewString()
+
+	if err := h.service.Create(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// Get retrieves a page template by UUID.
+func (h *PageTemplateHandler) Get(c *gin.Context) {
+	template, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "page template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// Update updates an existing page template.
+func (h *PageTemplateHandler) Update(c *gin.Context) {
+	template, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "page template not found"})
+		return
+	}
+
+	id, templateUUID := template.ID, template.UUID
+	if err := c.ShouldBindJSON(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID, template.UUID = id, templateUUID
+
+	if err := h.service.Update(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// Delete removes a page template that is not in use.
+func (h *PageTemplateHandler) Delete(c *gin.Context) {
+	template, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "page template not found"})
+		return
+	}
+
+	if err := h.service.Delete(template.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "page template deleted"})
+}
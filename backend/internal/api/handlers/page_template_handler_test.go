@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestPageTemplateLifecycle(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewPageTemplateHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/page-templates", `{"name":"Upgrade","content":"<h1>Back soon</h1>"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var created models.PageTemplate
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+
+	resp = do(http.MethodPost, "/api/v1/page-templates", `{"name":"Empty","content":"  "}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = do(http.MethodPost, "/api/v1/page-templates", `{"name":"Upgrade","content":"<p>again</p>"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Put a host into maintenance with the template through the proxy host API
+	id := strconv.FormatUint(uint64(created.ID), 10)
+	hostBody := `{"domain_names":"app.example.com","forward_host":"app","forward_port":80,"enabled":true,` +
+		`"maintenance":{"enabled":true,"template_id":` + id + `,"bypass_ips":["10.0.0.0/8"]},` +
+		`"error_pages":[{"status":"5XX","template_id":` + id + `}]}`
+	resp = do(http.MethodPost, "/api/v1/proxy-hosts", hostBody)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var host models.ProxyHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &host))
+	require.True(t, host.Maintenance.Enabled)
+	require.Equal(t, []models.ErrorPage{{Status: "5xx", TemplateID: created.ID}}, host.ErrorPages)
+
+	path := "/api/v1/page-templates/" + created.UUID
+	resp = do(http.MethodPut, path, `{"name":"Upgrade","content":"<h1>Back at 10:00</h1>"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.PageTemplate
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Equal(t, created.UUID, updated.UUID)
+	require.Equal(t, "<h1>Back at 10:00</h1>", updated.Content)
+
+	resp = do(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusConflict, resp.Code)
+	require.Contains(t, resp.Body.String(), "used by 1 proxy host")
+
+	resp = do(http.MethodGet, "/api/v1/page-templates/missing", "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
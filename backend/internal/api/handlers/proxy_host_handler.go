@@ -2,27 +2,47 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
 )
 
+// Advanced configs can inject any Caddy handler, so only admins may set them.
+const errAdvancedRequiresAdmin = "advanced configs require the admin role"
+
 // ProxyHostHandler handles CRUD operations for proxy hosts.
 type ProxyHostHandler struct {
 	service *services.ProxyHostService
 }
 
 // NewProxyHostHandler creates a new proxy host handler.
-func NewProxyHostHandler(db *gorm.DB) *ProxyHostHandler {
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewProxyHostHandler(db *gorm.DB, notifier services.ConfigNotifier) *ProxyHostHandler {
+	service := services.NewProxyHostService(db)
+	service.SetNotifier(notifier)
+
 	return &ProxyHostHandler{
-		service: services.NewProxyHostService(db),
+		service: service,
 	}
 }
 
+// SetAdapter registers the Caddy binary checking advanced configs on save.
+func (h *ProxyHostHandler) SetAdapter(adapter caddy.SnippetAdapter, modules []string) {
+	h.service.SetAdapter(adapter, modules)
+}
+
+// SetCipher sets the cipher encrypting upstream client keys on save.
+func (h *ProxyHostHandler) SetCipher(cipher *secrets.Cipher) {
+	h.service.SetCipher(cipher)
+}
+
 // RegisterRoutes registers proxy host routes.
 func (h *ProxyHostHandler) RegisterRoutes(router *gin.RouterGroup) {
 	router.GET("/proxy-hosts", h.List)
@@ -45,12 +65,18 @@ func (h *ProxyHostHandler) List(c *gin.Context) {
 
 // Create creates a new proxy host.
 func (h *ProxyHostHandler) Create(c *gin.Context) {
-	var host models.ProxyHost
+	// Defaults for fields the request leaves out; an explicit false still wins
+	host := models.ProxyHost{HTTP2Support: true, BlockExploits: true, Enabled: true}
 	if err := c.ShouldBindJSON(&host); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if strings.TrimSpace(host.Advanced.Content) != "" && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errAdvancedRequiresAdmin})
+		return
+	}
+
 	host.UUID = uuid.NewString()
 
 	// Assign UUIDs to locations
@@ -81,18 +107,28 @@ func (h *ProxyHostHandler) Get(c *gin.Context) {
 
 // Update updates an existing proxy host.
 func (h *ProxyHostHandler) Update(c *gin.Context) {
-	uuid := c.Param("uuid")
-
-	host, err := h.service.GetByUUID(uuid)
+	host, err := h.service.GetByUUID(c.Param("uuid"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "proxy host not found"})
 		return
 	}
 
+	advanced := host.Advanced
 	if err := c.ShouldBindJSON(host); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if (host.Advanced.Format != advanced.Format || host.Advanced.Content != advanced.Content) && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errAdvancedRequiresAdmin})
+		return
+	}
+
+	// Locations added in the payload need UUIDs too
+	for i := range host.Locations {
+		if host.Locations[i].UUID == "" {
+			host.Locations[i].UUID = uuid.NewString()
+		}
+	}
 
 	if err := h.service.Update(host); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -119,3 +155,9 @@ func (h *ProxyHostHandler) Delete(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "proxy host deleted"})
 }
+
+// isAdmin reports whether the authenticated user has the admin role.
+func isAdmin(c *gin.Context) bool {
+	role, _ := c.Get("role")
+	return role == "admin"
+}
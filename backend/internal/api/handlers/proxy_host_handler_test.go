@@ -21,9 +21,9 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
 	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}))
 
-	h := NewProxyHostHandler(db)
+	h := NewProxyHostHandler(db, nil)
 	r := gin.New()
 	api := r.Group("/api/v1")
 	h.RegisterRoutes(api)
@@ -139,3 +139,73 @@ func TestProxyHostValidation(t *testing.T) {
 	router.ServeHTTP(resp, req)
 	require.Equal(t, http.StatusBadRequest, resp.Code)
 }
+
+func TestProxyHostAdvancedRequiresAdmin(t *testing.T) {
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}))
+
+	h := NewProxyHostHandler(db, nil)
+	r := gin.New()
+	api := r.Group("/api/v1")
+	api.Use(func(c *gin.Context) {
+		c.Set("role", c.GetHeader("X-Test-Role"))
+	})
+	h.RegisterRoutes(api)
+
+	send := func(method, path, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Role", role)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	advanced := `{"domain_names":"app.example.com","forward_host":"app","forward_port":80,"advanced":{"content":"{\"handler\": \"static_response\", \"body\": \"pong\"}"}}`
+	resp := send(http.MethodPost, "/api/v1/proxy-hosts", "user", advanced)
+	require.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = send(http.MethodPost, "/api/v1/proxy-hosts", "admin", advanced)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created models.ProxyHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+	// Other settings stay editable for users as long as the advanced config is left alone
+	path := "/api/v1/proxy-hosts/" + created.UUID
+	resp = send(http.MethodPut, path, "user", `{"name":"App"}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = send(http.MethodPut, path, "user", `{"advanced":{"content":""}}`)
+	require.Equal(t, http.StatusForbidden, resp.Code)
+
+	var stored models.ProxyHost
+	require.NoError(t, db.First(&stored, created.ID).Error)
+	require.Equal(t, "App", stored.Name)
+	require.NotEmpty(t, stored.Advanced.Routes)
+}
+
+func TestProxyHostCreate_Defaults(t *testing.T) {
+	router, db := setupTestRouter(t)
+
+	for body, want := range map[string]bool{
+		`{"domain_names":"on.example.com","forward_host":"app","forward_port":80}`:                                                               true,
+		`{"domain_names":"off.example.com","forward_host":"app","forward_port":80,"http2_support":false,"block_exploits":false,"enabled":false}`: false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/proxy-hosts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusCreated, resp.Code)
+
+		var created models.ProxyHost
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+		var stored models.ProxyHost
+		require.NoError(t, db.First(&stored, created.ID).Error)
+		require.Equal(t, want, stored.HTTP2Support, body)
+		require.Equal(t, want, stored.BlockExploits, body)
+		require.Equal(t, want, stored.Enabled, body)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// RedirectionHostHandler handles CRUD operations for redirection hosts.
+type RedirectionHostHandler struct {
+	service *services.RedirectionHostService
+}
+
+// NewRedirectionHostHandler creates a new redirection host handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewRedirectionHostHandler(db *gorm.DB, notifier services.ConfigNotifier) *RedirectionHostHandler {
+	service := services.NewRedirectionHostService(db)
+	service.SetNotifier(notifier)
+
+	return &RedirectionHostHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers redirection host routes.
+func (h *RedirectionHostHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/redirection-hosts", h.List)
+	router.POST("/redirection-hosts", h.Create)
+	router.GET("/redirection-hosts/:uuid", h.Get)
+	router.PUT("/redirection-hosts/:uuid", h.Update)
+	router.DELETE("/redirection-hosts/:uuid", h.Delete)
+}
+
+// List retrieves all redirection hosts.
+func (h *RedirectionHostHandler) List(c *gin.Context) {
+	hosts, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hosts)
+}
+
+// Create creates a new redirection host.
+func (h *RedirectionHostHandler) Create(c *gin.Context) {
+	// Defaults for fields the request leaves out; an explicit false still wins
+	host := models.RedirectionHost{PreservePath: true, Enabled: true}
+	if err := c.ShouldBindJSON(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host.UUID = uuid.NewString()
+
+	if err := h.service.Create(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, host)
+}
+
+// Get retrieves a redirection host by UUID.
+func (h *RedirectionHostHandler) Get(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	host, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "redirection host not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, host)
+}
+
+// Update updates an existing redirection host.
+func (h *RedirectionHostHandler) Update(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	host, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "redirection host not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, host)
+}
+
+// Delete removes a redirection host.
+func (h *RedirectionHostHandler) Delete(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	host, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "redirection host not found"})
+		return
+	}
+
+	if err := h.service.Delete(host.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "redirection host deleted"})
+}
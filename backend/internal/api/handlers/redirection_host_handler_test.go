@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func setupRedirectionHostRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}))
+
+	r := gin.New()
+	api := r.Group("/api/v1")
+	NewRedirectionHostHandler(db, nil).RegisterRoutes(api)
+	NewProxyHostHandler(db, nil).RegisterRoutes(api)
+
+	return r, db
+}
+
+func TestRedirectionHostLifecycle(t *testing.T) {
+	router, _ := setupRedirectionHostRouter(t)
+
+	body := `{"name":"Old site","domain_names":"old.example.com","forward_scheme":"https","forward_domain":"new.example.com","status_code":308,"preserve_path":true,"enabled":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/redirection-hosts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	var created models.RedirectionHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+	require.Equal(t, 308, created.StatusCode)
+
+	updateReq := httptest.NewRequest(http.MethodPut, "/api/v1/redirection-hosts/"+created.UUID, strings.NewReader(`{"status_code":302}`))
+	updateReq.Header.Set("Content-Type", "application/json")
+	updateResp := httptest.NewRecorder()
+	router.ServeHTTP(updateResp, updateReq)
+	require.Equal(t, http.StatusOK, updateResp.Code)
+
+	getReq := httptest.NewRequest(http.MethodGet, "/api/v1/redirection-hosts/"+created.UUID, nil)
+	getResp := httptest.NewRecorder()
+	router.ServeHTTP(getResp, getReq)
+	require.Equal(t, http.StatusOK, getResp.Code)
+
+	var fetched models.RedirectionHost
+	require.NoError(t, json.Unmarshal(getResp.Body.Bytes(), &fetched))
+	require.Equal(t, 302, fetched.StatusCode)
+	require.Equal(t, "new.example.com", fetched.ForwardDomain)
+
+	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/redirection-hosts", nil)
+	listResp := httptest.NewRecorder()
+	router.ServeHTTP(listResp, listReq)
+	require.Equal(t, http.StatusOK, listResp.Code)
+
+	var hosts []models.RedirectionHost
+	require.NoError(t, json.Unmarshal(listResp.Body.Bytes(), &hosts))
+	require.Len(t, hosts, 1)
+
+	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/redirection-hosts/"+created.UUID, nil)
+	deleteResp := httptest.NewRecorder()
+	router.ServeHTTP(deleteResp, deleteReq)
+	require.Equal(t, http.StatusOK, deleteResp.Code)
+
+	missingReq := httptest.NewRequest(http.MethodGet, "/api/v1/redirection-hosts/"+created.UUID, nil)
+	missingResp := httptest.NewRecorder()
+	router.ServeHTTP(missingResp, missingReq)
+	require.Equal(t, http.StatusNotFound, missingResp.Code)
+}
+
+func TestRedirectionHostCreate_ConflictsWithProxyHost(t *testing.T) {
+	router, _ := setupRedirectionHostRouter(t)
+
+	proxyBody := `{"domain_names":"app.example.com","forward_scheme":"http","forward_host":"app","forward_port":8080,"enabled":true}`
+	proxyReq := httptest.NewRequest(http.MethodPost, "/api/v1/proxy-hosts", strings.NewReader(proxyBody))
+	proxyReq.Header.Set("Content-Type", "application/json")
+	proxyResp := httptest.NewRecorder()
+	router.ServeHTTP(proxyResp, proxyReq)
+	require.Equal(t, http.StatusCreated, proxyResp.Code)
+
+	body := `{"domain_names":"app.example.com","forward_domain":"example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/redirection-hosts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "domain already exists")
+}
+
+func TestRedirectionHostCreate_Defaults(t *testing.T) {
+	router, db := setupRedirectionHostRouter(t)
+
+	for body, want := range map[string]bool{
+		`{"domain_names":"keep.example.com","forward_domain":"example.com"}`:                                       true,
+		`{"domain_names":"drop.example.com","forward_domain":"example.com","preserve_path":false,"enabled":false}`: false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/redirection-hosts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusCreated, resp.Code)
+
+		var created models.RedirectionHost
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+		var stored models.RedirectionHost
+		require.NoError(t, db.First(&stored, created.ID).Error)
+		require.Equal(t, want, stored.PreservePath, body)
+		require.Equal(t, want, stored.Enabled, body)
+	}
+}
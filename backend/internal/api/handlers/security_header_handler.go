@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// SecurityHeaderHandler handles security header profiles and the host report.
+type SecurityHeaderHandler struct {
+	service *services.SecurityHeaderService
+}
+
+// NewSecurityHeaderHandler creates a new security header handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewSecurityHeaderHandler(db *gorm.DB, notifier services.ConfigNotifier) *SecurityHeaderHandler {
+	service := services.NewSecurityHeaderService(db)
+	service.SetNotifier(notifier)
+
+	return &SecurityHeaderHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers security header routes.
+func (h *SecurityHeaderHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/security-header-profiles", h.List)
+	router.POST("/security-header-profiles", h.Create)
+	router.GET("/security-header-profiles/:uuid", h.Get)
+	router.PUT("/security-header-profiles/:uuid", h.Update)
+	router.DELETE("/security-header-profiles/:uuid", h.Delete)
+	router.GET("/security-headers/report", h.Report)
+}
+
+// List retrieves all security header profiles.
+func (h *SecurityHeaderHandler) List(c *gin.Context) {
+	profiles, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// Create creates a new security header profile.
+func (h *SecurityHeaderHandler) Create(c *gin.Context) {
+	var profile models.SecurityHeaderProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile.ID = 0
+	profile.UUID = uuid.NewString()
+
+	if err := h.service.Create(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// Get retrieves a security header profile by UUID.
+func (h *SecurityHeaderHandler) Get(c *gin.Context) {
+	profile, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "security header profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// Update updates an existing security header profile.
+func (h *SecurityHeaderHandler) Update(c *gin.Context) {
+	profile, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "security header profile not found"})
+		return
+	}
+
+	id, profileUUID := profile.ID, profile.UUID
+	if err := c.ShouldBindJSON(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile.ID, profile.UUID = id, profileUUID
+
+	if err := h.service.Update(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// Delete removes a security header profile that is not in use.
+func (h *SecurityHeaderHandler) Delete(c *gin.Context) {
+	profile, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "security header profile not found"})
+		return
+	}
+
+	if err := h.service.Delete(profile.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "security header profile deleted"})
+}
+
+// Report grades the effective response headers of every proxy host.
+func (h *SecurityHeaderHandler) Report(c *gin.Context) {
+	reports, err := h.service.Report()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+func TestSecurityHeaderProfileLifecycle(t *testing.T) {
+	router, db := setupTestRouter(t)
+	NewSecurityHeaderHandler(db, nil).RegisterRoutes(router.Group("/api/v1"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/security-header-profiles", `{"name":"Strict","preset":"strict"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var created models.SecurityHeaderProfile
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+	require.Equal(t, "DENY", created.XFrameOptions)
+
+	resp = do(http.MethodPost, "/api/v1/security-header-profiles", `{"name":"Bad","x_frame_options":"ALLOWALL"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Attach the profile to a host through the proxy host API
+	hostBody := `{"domain_names":"app.example.com","forward_host":"app","forward_port":80,"enabled":true,"hsts_enabled":true,"security_header_profile_id":` + strconv.FormatUint(uint64(created.ID), 10) + `}`
+	resp = do(http.MethodPost, "/api/v1/proxy-hosts", hostBody)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	resp = do(http.MethodGet, "/api/v1/security-headers/report", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var reports []services.SecurityHeaderReport
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &reports))
+	require.Len(t, reports, 1)
+	require.Equal(t, "A", reports[0].Grade)
+	require.Equal(t, "Strict", reports[0].Profile)
+
+	path := "/api/v1/security-header-profiles/" + created.UUID
+	resp = do(http.MethodPut, path, `{"name":"Strict","preset":"relaxed"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.SecurityHeaderProfile
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Equal(t, created.UUID, updated.UUID)
+	require.Equal(t, "SAMEORIGIN", updated.XFrameOptions)
+
+	resp = do(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = do(http.MethodGet, "/api/v1/security-header-profiles/missing", "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -2,19 +2,43 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
 )
 
 type SettingsHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	notifier services.ConfigNotifier
+	cipher   *secrets.Cipher
+	crowdSec *services.CrowdSecService
 }
 
-func NewSettingsHandler(db *gorm.DB) *SettingsHandler {
-	return &SettingsHandler{DB: db}
+// secretSettingMask replaces secret values in responses.
+const secretSettingMask = "********"
+
+// caddySettingPrefixes lists the setting key prefixes read by caddy.Manager when generating config.
+var caddySettingPrefixes = []string{"caddy."}
+
+func NewSettingsHandler(db *gorm.DB, notifier services.ConfigNotifier) *SettingsHandler {
+	return &SettingsHandler{DB: db, notifier: notifier}
+}
+
+// SetCipher sets the cipher that encrypts secret settings such as DNS provider
+// credentials and the ZeroSSL EAB.
+func (h *SettingsHandler) SetCipher(cipher *secrets.Cipher) {
+	h.cipher = cipher
+}
+
+// SetCrowdSec registers the service whose decision poller follows the CrowdSec settings.
+func (h *SettingsHandler) SetCrowdSec(crowdSec *services.CrowdSecService) {
+	h.crowdSec = crowdSec
 }
 
 // GetSettings returns all settings.
@@ -29,6 +53,9 @@ func (h *SettingsHandler) GetSettings(c *gin.Context) {
 	settingsMap := make(map[string]string)
 	for _, s := range settings {
 		settingsMap[s.Key] = s.Value
+		if caddy.IsSecretSetting(s.Key) {
+			settingsMap[s.Key] = secretSettingMask
+		}
 	}
 
 	c.JSON(http.StatusOK, settingsMap)
@@ -49,15 +76,35 @@ func (h *SettingsHandler) UpdateSetting(c *gin.Context) {
 		return
 	}
 
+	// Reject settings that would make config generation fail for every host
+	if err := validateCaddySetting(req.Key, req.Value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	setting := models.Setting{
 		Key:   req.Key,
 		Value: req.Value,
 	}
 
+	if caddy.IsSecretSetting(req.Key) {
+		if h.cipher == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "secret settings require an encryption key"})
+			return
+		}
+		encrypted, err := h.cipher.Encrypt(req.Value)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt setting"})
+			return
+		}
+		setting.Value = encrypted
+		setting.Type = "secret"
+	}
+
 	if req.Category != "" {
 		setting.Category = req.Category
 	}
-	if req.Type != "" {
+	if req.Type != "" && setting.Type == "" {
 		setting.Type = req.Type
 	}
 
@@ -67,5 +114,83 @@ func (h *SettingsHandler) UpdateSetting(c *gin.Context) {
 		return
 	}
 
+	h.settingChanged(req.Key, "setting updated: ")
+
+	if caddy.IsSecretSetting(setting.Key) {
+		setting.Value = secretSettingMask
+	}
+
 	c.JSON(http.StatusOK, setting)
 }
+
+// DeleteSetting removes a setting, so its default applies again.
+func (h *SettingsHandler) DeleteSetting(c *gin.Context) {
+	key := c.Param("key")
+	result := h.DB.Where("key = ?", key).Delete(&models.Setting{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete setting"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Setting not found"})
+		return
+	}
+
+	h.settingChanged(key, "setting deleted: ")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Setting deleted"})
+}
+
+// settingChanged tells the reconciler and the CrowdSec poller about a changed setting.
+func (h *SettingsHandler) settingChanged(key, reason string) {
+	if h.notifier != nil && affectsCaddyConfig(key) {
+		h.notifier.Notify(reason + key)
+	}
+
+	if h.crowdSec != nil && (key == caddy.CrowdSecLAPIURLSettingKey || key == caddy.CrowdSecBouncerKeySettingKey) {
+		h.crowdSec.SyncPoller()
+	}
+}
+
+func affectsCaddyConfig(key string) bool {
+	for _, prefix := range caddySettingPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCaddySetting parses settings that feed config generation or CPM's own
+// integrations such as single sign-on, so a bad value is rejected on save instead
+// of failing every later use.
+func validateCaddySetting(key, value string) error {
+	var err error
+	switch key {
+	case caddy.ExploitRulesSettingKey:
+		_, err = caddy.ParseExploitRules(value)
+	case caddy.ProtocolsSettingKey:
+		_, err = caddy.ParseProtocols(value)
+	case caddy.ZeroSSLEABSettingKey:
+		_, err = caddy.ParseExternalAccount(value)
+	case caddy.DefaultSiteSettingKey:
+		_, err = caddy.ParseDefaultSite(value)
+	case caddy.TrustedProxiesSettingKey:
+		_, err = caddy.ParseTrustedProxies(value)
+	case caddy.CrowdSecLAPIURLSettingKey:
+		_, err = caddy.ParseCrowdSecLAPIURL(value)
+	case caddy.CrowdSecMachineSettingKey:
+		_, err = caddy.ParseCrowdSecMachine(value)
+	case services.SSOCookieDomainSettingKey:
+		_, err = services.ParseSSOCookieDomain(value)
+	case services.SSOLoginURLSettingKey:
+		_, err = services.ParseSSOLoginURL(value)
+	case services.GeoIPDatabaseSettingKey:
+		err = services.ValidateGeoIPDatabase(value)
+	default:
+		if caddy.IsDNSProviderSetting(key) {
+			_, err = caddy.ParseDNSCredentials(strings.TrimPrefix(key, caddy.DNSProviderSettingPrefix), value)
+		}
+	}
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -13,7 +14,10 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/handlers"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
 )
 
 func setupSettingsTestDB(t *testing.T) *gorm.DB {
@@ -33,7 +37,7 @@ func TestSettingsHandler_GetSettings(t *testing.T) {
 	// Seed data
 	db.Create(&models.Setting{Key: "test_key", Value: "test_value", Category: "general", Type: "string"})
 
-	handler := handlers.NewSettingsHandler(db)
+	handler := handlers.NewSettingsHandler(db, nil)
 	router := gin.New()
 	router.GET("/settings", handler.GetSettings)
 
@@ -53,7 +57,7 @@ func TestSettingsHandler_UpdateSettings(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupSettingsTestDB(t)
 
-	handler := handlers.NewSettingsHandler(db)
+	handler := handlers.NewSettingsHandler(db, nil)
 	router := gin.New()
 	router.POST("/settings", handler.UpdateSetting)
 
@@ -91,3 +95,208 @@ func TestSettingsHandler_UpdateSettings(t *testing.T) {
 	db.Where("key = ?", "new_key").First(&setting)
 	assert.Equal(t, "updated_value", setting.Value)
 }
+
+type recordingNotifier struct {
+	reasons []string
+}
+
+func (n *recordingNotifier) Notify(reason string) {
+	n.reasons = append(n.reasons, reason)
+}
+
+func TestSettingsHandler_UpdateSetting_NotifiesForCaddyKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	notifier := &recordingNotifier{}
+	handler := handlers.NewSettingsHandler(db, notifier)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+
+	for _, key := range []string{"caddy.acme_email", "ui.theme"} {
+		body, _ := json.Marshal(map[string]string{"key": key, "value": "v"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, []string{"setting updated: caddy.acme_email"}, notifier.reasons)
+}
+
+func TestSettingsHandler_UpdateSetting_ValidatesExploitRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	handler := handlers.NewSettingsHandler(db, nil)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+
+	body, _ := json.Marshal(map[string]string{"key": "caddy.exploit_rules", "value": `{"paths": ["(unclosed"]}`})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body, _ = json.Marshal(map[string]string{"key": "caddy.exploit_rules", "value": `{"paths": ["/secret-admin"]}`})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSettingsHandler_UpdateSetting_ValidatesProtocols(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	handler := handlers.NewSettingsHandler(db, nil)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+
+	for value, want := range map[string]int{
+		"h1,h2":   http.StatusOK,
+		"h1,quic": http.StatusBadRequest,
+	} {
+		body, _ := json.Marshal(map[string]string{"key": "caddy.protocols", "value": value})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, value)
+	}
+}
+
+func TestSettingsHandler_UpdateSetting_ValidatesTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	handler := handlers.NewSettingsHandler(db, nil)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+
+	for value, want := range map[string]int{
+		"173.245.48.0/20, 2400:cb00::/32": http.StatusOK,
+		"cloudflare":                      http.StatusBadRequest,
+	} {
+		body, _ := json.Marshal(map[string]string{"key": "caddy.trusted_proxies", "value": value})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, value)
+	}
+}
+
+func TestSettingsHandler_UpdateSetting_ValidatesDefaultSite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	handler := handlers.NewSettingsHandler(db, nil)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+
+	for value, want := range map[string]int{
+		`{"mode": "close"}`:    http.StatusOK,
+		`{"mode": "redirect"}`: http.StatusBadRequest,
+		"congratulations":      http.StatusBadRequest,
+	} {
+		body, _ := json.Marshal(map[string]string{"key": "caddy.default_site", "value": value})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, value)
+	}
+}
+
+func TestSettingsHandler_DNSProviderCredentialsAreSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	assert.NoError(t, err)
+
+	handler := handlers.NewSettingsHandler(db, nil)
+	handler.SetCipher(cipher)
+	router := gin.New()
+	router.GET("/settings", handler.GetSettings)
+	router.POST("/settings", handler.UpdateSetting)
+
+	for value, want := range map[string]int{
+		`{"api_token":""}`:         http.StatusBadRequest,
+		`{"api_token":"cf-token"}`: http.StatusOK,
+	} {
+		body, _ := json.Marshal(map[string]string{"key": "caddy.dns_provider.cloudflare", "value": value})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, value)
+		assert.NotContains(t, w.Body.String(), "cf-token")
+	}
+
+	var setting models.Setting
+	db.Where("key = ?", "caddy.dns_provider.cloudflare").First(&setting)
+	assert.True(t, secrets.IsEncrypted(setting.Value))
+	decrypted, err := cipher.Decrypt(setting.Value)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"api_token":"cf-token"}`, decrypted)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/settings", nil)
+	router.ServeHTTP(w, req)
+
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "********", response["caddy.dns_provider.cloudflare"])
+}
+
+func TestSettingsHandler_DeleteSetting_StopsCrowdSecPoller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSettingsTestDB(t)
+	lapi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"type":"ban","scope":"Ip","value":"203.0.113.9"}]`))
+	}))
+	defer lapi.Close()
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	assert.NoError(t, err)
+	crowdSec := services.NewCrowdSecService(db, cipher)
+
+	notifier := &recordingNotifier{}
+	handler := handlers.NewSettingsHandler(db, notifier)
+	handler.SetCipher(cipher)
+	handler.SetCrowdSec(crowdSec)
+	router := gin.New()
+	router.POST("/settings", handler.UpdateSetting)
+	router.DELETE("/settings/:key", handler.DeleteSetting)
+
+	for key, value := range map[string]string{
+		caddy.CrowdSecLAPIURLSettingKey:    lapi.URL,
+		caddy.CrowdSecBouncerKeySettingKey: "bouncer-key",
+	} {
+		body, _ := json.Marshal(map[string]string{"key": key, "value": value})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Eventually(t, crowdSec.Polled, time.Second, 5*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/settings/"+caddy.CrowdSecBouncerKeySettingKey, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, crowdSec.Polled())
+	assert.False(t, crowdSec.Status().Configured)
+	assert.Contains(t, notifier.reasons, "setting deleted: "+caddy.CrowdSecBouncerKeySettingKey)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/settings/"+caddy.CrowdSecBouncerKeySettingKey, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
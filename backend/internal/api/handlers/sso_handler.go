@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// SSOHandler answers Caddy's forward auth requests for sso access lists.
+type SSOHandler struct {
+	service *services.SSOService
+}
+
+// NewSSOHandler creates a new single sign-on handler.
+func NewSSOHandler(service *services.SSOService) *SSOHandler {
+	return &SSOHandler{service: service}
+}
+
+// RegisterRoutes registers single sign-on routes. They must stay outside the
+// auth middleware: Caddy calls them on behalf of unauthenticated clients.
+func (h *SSOHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/forward-auth/verify", h.Verify)
+}
+
+// Verify admits requests carrying a valid single sign-on session by answering
+// 200 with the user's identity headers. Clients without a session are sent to
+// the CPM login page; signed-in users the access list excludes get a 403.
+func (h *SSOHandler) Verify(c *gin.Context) {
+	token, _ := c.Cookie(services.SSOCookieName)
+	user, err := h.service.Verify(token, c.Query("list"))
+	if errors.Is(err, services.ErrSSOForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		target := c.GetHeader("X-Forwarded-Proto") + "://" + c.GetHeader("X-Forwarded-Host") + c.GetHeader("X-Forwarded-Uri")
+		if login := h.service.LoginRedirect(target); login != "" {
+			c.Redirect(http.StatusFound, login)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Remote-User", user.Email)
+	c.Header("Remote-Email", user.Email)
+	c.Header("Remote-Name", user.Name)
+	c.Header("Remote-Role", user.Role)
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+func TestSSOLoginAndVerify(t *testing.T) {
+	handler, db := setupAuthHandler(t)
+	require.NoError(t, db.AutoMigrate(&models.AccessList{}))
+	require.NoError(t, db.Create(&models.Setting{Key: services.SSOCookieDomainSettingKey, Value: "example.com"}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: services.SSOLoginURLSettingKey, Value: "https://cpm.example.com/login"}).Error)
+
+	sso := services.NewSSOService(db, handler.authService)
+	handler.SetSSO(sso)
+	_, err := handler.authService.Register("admin@example.com", "password123", "Admin")
+	require.NoError(t, err)
+	viewer, err := handler.authService.Register("viewer@example.com", "password123", "Viewer")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.AccessList{UUID: "admins", Name: "Admins", Type: models.AccessListSSO, AllowedRoles: []string{"admin"}}).Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", handler.Login)
+	NewSSOHandler(sso).RegisterRoutes(r.Group("/api/v1"))
+
+	login := func(email, redirect string) (*httptest.ResponseRecorder, *http.Cookie) {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": "password123", "redirect": redirect})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == services.SSOCookieName {
+				return w, cookie
+			}
+		}
+		return w, nil
+	}
+	verify := func(cookie *http.Cookie, list string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/forward-auth/verify?list="+list, nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "app.example.com")
+		req.Header.Set("X-Forwarded-Uri", "/dashboard")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a session the client is sent to the CPM login page
+	w := verify(nil, "admins")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://cpm.example.com/login?rd=https%3A%2F%2Fapp.example.com%2Fdashboard", w.Header().Get("Location"))
+
+	w, adminCookie := login("admin@example.com", "https://app.example.com/dashboard")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, adminCookie)
+	assert.Equal(t, "example.com", adminCookie.Domain)
+	assert.True(t, adminCookie.HttpOnly)
+	assert.True(t, adminCookie.Secure)
+	assert.Contains(t, w.Body.String(), `"redirect":"https://app.example.com/dashboard"`)
+
+	w = verify(adminCookie, "admins")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin@example.com", w.Header().Get("Remote-User"))
+	assert.Equal(t, "admin@example.com", w.Header().Get("Remote-Email"))
+	assert.Equal(t, "Admin", w.Header().Get("Remote-Name"))
+	assert.Equal(t, "admin", w.Header().Get("Remote-Role"))
+
+	// Redirects outside the cookie domain are dropped; the viewer is not an admin
+	w, viewerCookie := login(viewer.Email, "https://evil.net/")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "redirect")
+	assert.Equal(t, http.StatusForbidden, verify(viewerCookie, "admins").Code)
+
+	// Invalid sessions are sent to the login page too
+	w = verify(&http.Cookie{Name: services.SSOCookieName, Value: "garbage"}, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+}
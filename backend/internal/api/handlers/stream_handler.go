@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
+)
+
+// StreamHandler handles CRUD operations for streams.
+type StreamHandler struct {
+	service *services.StreamService
+}
+
+// NewStreamHandler creates a new stream handler.
+// notifier may be nil when changes should not be pushed to Caddy.
+func NewStreamHandler(db *gorm.DB, notifier services.ConfigNotifier) *StreamHandler {
+	service := services.NewStreamService(db)
+	service.SetNotifier(notifier)
+
+	return &StreamHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers stream routes.
+func (h *StreamHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/streams", h.List)
+	router.POST("/streams", h.Create)
+	router.GET("/streams/:uuid", h.Get)
+	router.PUT("/streams/:uuid", h.Update)
+	router.DELETE("/streams/:uuid", h.Delete)
+}
+
+// List retrieves all streams.
+func (h *StreamHandler) List(c *gin.Context) {
+	streams, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, streams)
+}
+
+// Create creates a new stream.
+func (h *StreamHandler) Create(c *gin.Context) {
+	// Defaults for fields the request leaves out; an explicit false still wins
+	stream := models.Stream{Enabled: true}
+	if err := c.ShouldBindJSON(&stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream.UUID = uuid.NewString()
+
+	if err := h.service.Create(&stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, stream)
+}
+
+// Get retrieves a stream by UUID.
+func (h *StreamHandler) Get(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	stream, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stream not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stream)
+}
+
+// Update updates an existing stream.
+func (h *StreamHandler) Update(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	stream, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stream not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stream)
+}
+
+// Delete removes a stream.
+func (h *StreamHandler) Delete(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	stream, err := h.service.GetByUUID(uuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stream not found"})
+		return
+	}
+
+	if err := h.service.Delete(stream.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "stream deleted"})
+}
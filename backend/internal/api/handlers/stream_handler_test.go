@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func setupStreamRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Stream{}))
+
+	r := gin.New()
+	NewStreamHandler(db, nil).RegisterRoutes(r.Group("/api/v1"))
+	return r, db
+}
+
+func TestStreamLifecycle(t *testing.T) {
+	router, _ := setupStreamRouter(t)
+
+	body := `{"name":"Postgres","listen_port":5432,"protocol":"tcp","upstreams":[{"host":"db","port":5432}],"enabled":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	var created models.Stream
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.NotEmpty(t, created.UUID)
+
+	updateReq := httptest.NewRequest(http.MethodPut, "/api/v1/streams/"+created.UUID, strings.NewReader(`{"listen_port":15432}`))
+	updateReq.Header.Set("Content-Type", "application/json")
+	updateResp := httptest.NewRecorder()
+	router.ServeHTTP(updateResp, updateReq)
+	require.Equal(t, http.StatusOK, updateResp.Code)
+
+	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/streams", nil)
+	listResp := httptest.NewRecorder()
+	router.ServeHTTP(listResp, listReq)
+	require.Equal(t, http.StatusOK, listResp.Code)
+
+	var streams []models.Stream
+	require.NoError(t, json.Unmarshal(listResp.Body.Bytes(), &streams))
+	require.Len(t, streams, 1)
+	require.Equal(t, 15432, streams[0].ListenPort)
+
+	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/streams/"+created.UUID, nil)
+	deleteResp := httptest.NewRecorder()
+	router.ServeHTTP(deleteResp, deleteReq)
+	require.Equal(t, http.StatusOK, deleteResp.Code)
+
+	getReq := httptest.NewRequest(http.MethodGet, "/api/v1/streams/"+created.UUID, nil)
+	getResp := httptest.NewRecorder()
+	router.ServeHTTP(getResp, getReq)
+	require.Equal(t, http.StatusNotFound, getResp.Code)
+}
+
+func TestStreamCreate_RejectsHTTPPort(t *testing.T) {
+	router, _ := setupStreamRouter(t)
+
+	body := `{"listen_port":443,"protocol":"tcp","upstreams":[{"host":"db","port":5432}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "used by the HTTP server")
+}
+
+func TestStreamCreate_Disabled(t *testing.T) {
+	router, db := setupStreamRouter(t)
+
+	for _, body := range []string{
+		`{"listen_port":5432,"upstreams":[{"host":"db","port":5432}]}`,
+		`{"listen_port":1883,"upstreams":[{"host":"mqtt","port":1883}],"enabled":false}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	var streams []models.Stream
+	require.NoError(t, db.Find(&streams).Error)
+	app, _ := caddy.GenerateStreams(streams)
+	require.NotNil(t, app)
+	require.Contains(t, app.Servers, "cpm_stream_tcp_5432")
+	require.NotContains(t, app.Servers, "cpm_stream_tcp_1883")
+}
@@ -26,7 +26,7 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := authService.ValidateToken(tokenString)
-		if err != nil {
+		if err != nil || claims.Scope != "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
@@ -146,6 +146,28 @@ func TestAuthMiddleware_InvalidToken(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Invalid token")
 }
 
+func TestAuthMiddleware_RejectsSSOToken(t *testing.T) {
+	authService := setupAuthService(t)
+	user, err := authService.Register("test@example.com", "password", "Test User")
+	require.NoError(t, err)
+	token, err := authService.GenerateSSOToken(user)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AuthMiddleware(authService))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 func TestRequireRole_MissingRoleInContext(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
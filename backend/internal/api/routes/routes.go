@@ -9,48 +9,87 @@ import (
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/handlers"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/api/middleware"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/config"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/services"
 )
 
-// Register wires up API routes and performs automatic migrations.
-func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
+// Register wires up API routes and performs automatic migrations. It returns
+// the reconciler that pushes configuration changes to Caddy.
+func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) (*caddy.Reconciler, error) {
 	// AutoMigrate all models for Issue #5 persistence layer
 	if err := db.AutoMigrate(
 		&models.ProxyHost{},
 		&models.Location{},
+		&models.RedirectionHost{},
+		&models.Stream{},
 		&models.CaddyConfig{},
 		&models.RemoteServer{},
 		&models.SSLCertificate{},
+		&models.SecurityHeaderProfile{},
 		&models.AccessList{},
+		&models.AccessListUser{},
+		&models.PageTemplate{},
 		&models.User{},
 		&models.Setting{},
 		&models.ImportSession{},
 		&models.Notification{},
 	); err != nil {
-		return fmt.Errorf("auto migrate: %w", err)
+		return nil, fmt.Errorf("auto migrate: %w", err)
 	}
 
 	router.GET("/api/v1/health", handlers.HealthHandler)
 
 	api := router.Group("/api/v1")
 
+	// Secrets such as custom certificate keys are encrypted at rest
+	cipher, err := secrets.LoadCipher(cfg.EncryptionKey, cfg.EncryptionKeyFile)
+	if err != nil {
+		fmt.Printf("Warning: encryption key unavailable, custom certificates are disabled: %v\n", err)
+	}
+
+	// Caddy reconciler pushes every config-relevant change to the running Caddy
+	caddyClient := caddy.NewClient(cfg.CaddyAdminAPI)
+	caddyManager := caddy.NewManager(caddyClient, db, cfg.CaddyConfigDir)
+	caddyManager.SetCipher(cipher)
+	caddyManager.SetInternalURL(cfg.InternalURL)
+	// Optional handlers such as the CrowdSec bouncer depend on the Caddy build
+	caddyModules, err := caddy.ListModules(&caddy.DefaultExecutor{}, cfg.CaddyBinary)
+	if err != nil {
+		fmt.Printf("Warning: cannot list Caddy modules, optional handlers are disabled: %v\n", err)
+	}
+	caddyManager.SetModules(caddyModules)
+	reconciler := caddy.NewReconciler(caddyManager, 2*time.Second)
+
 	// Auth routes
 	authService := services.NewAuthService(db, cfg)
 	authHandler := handlers.NewAuthHandler(authService)
 	authMiddleware := middleware.AuthMiddleware(authService)
 
+	// Single sign-on for proxied apps through sso access lists
+	ssoService := services.NewSSOService(db, authService)
+	authHandler.SetSSO(ssoService)
+	ssoHandler := handlers.NewSSOHandler(ssoService)
+
 	// Backup routes
 	backupService := services.NewBackupService(&cfg)
 	backupHandler := handlers.NewBackupHandler(backupService)
 
+	// GeoIP lookups for country access lists and log entries
+	geoIPService := services.NewGeoIPService(db)
+	geoIPHandler := handlers.NewGeoIPHandler(geoIPService)
+
 	// Log routes
 	logService := services.NewLogService(&cfg)
+	logService.SetGeoIP(geoIPService)
 	logsHandler := handlers.NewLogsHandler(logService)
 
 	api.POST("/auth/login", authHandler.Login)
 	api.POST("/auth/register", authHandler.Register)
+	ssoHandler.RegisterRoutes(api)
+	geoIPHandler.RegisterRoutes(api)
 
 	protected := api.Group("/")
 	protected.Use(authMiddleware)
@@ -71,10 +110,26 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 		protected.GET("/logs/:filename", logsHandler.Read)
 		protected.GET("/logs/:filename/download", logsHandler.Download)
 
+		// CrowdSec decisions, polled from the LAPI while it is configured
+		crowdSecService := services.NewCrowdSecService(db, cipher)
+		crowdSecService.SetModules(caddyModules)
+		crowdSecService.SyncPoller()
+		crowdSecHandler := handlers.NewCrowdSecHandler(crowdSecService)
+		crowdSecHandler.RegisterRoutes(protected)
+
 		// Settings
-		settingsHandler := handlers.NewSettingsHandler(db)
+		settingsHandler := handlers.NewSettingsHandler(db, reconciler)
+		settingsHandler.SetCipher(cipher)
+		settingsHandler.SetCrowdSec(crowdSecService)
 		protected.GET("/settings", settingsHandler.GetSettings)
 		protected.POST("/settings", settingsHandler.UpdateSetting)
+		protected.DELETE("/settings/:key", settingsHandler.DeleteSetting)
+
+		// Caddy
+		caddyHandler := handlers.NewCaddyHandler(reconciler)
+		caddyHandler.SetClient(caddyClient)
+		caddyHandler.SetExporter(caddyManager)
+		caddyHandler.RegisterRoutes(protected)
 
 		// User Profile & API Key
 		userHandler := handlers.NewUserHandler(db)
@@ -121,8 +176,32 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 		})
 	}
 
-	proxyHostHandler := handlers.NewProxyHostHandler(db)
-	proxyHostHandler.RegisterRoutes(api)
+	proxyHostHandler := handlers.NewProxyHostHandler(db, reconciler)
+	proxyHostHandler.SetCipher(cipher)
+	if caddyModules != nil {
+		// Advanced configs are checked with the same Caddy binary
+		proxyHostHandler.SetAdapter(caddy.NewImporter(cfg.CaddyBinary), caddyModules)
+	}
+	proxyHostHandler.RegisterRoutes(protected)
+
+	locationHandler := handlers.NewLocationHandler(db, reconciler)
+	locationHandler.SetModules(caddyModules)
+	locationHandler.RegisterRoutes(protected)
+
+	redirectionHostHandler := handlers.NewRedirectionHostHandler(db, reconciler)
+	redirectionHostHandler.RegisterRoutes(protected)
+
+	streamHandler := handlers.NewStreamHandler(db, reconciler)
+	streamHandler.RegisterRoutes(protected)
+
+	securityHeaderHandler := handlers.NewSecurityHeaderHandler(db, reconciler)
+	securityHeaderHandler.RegisterRoutes(protected)
+
+	pageTemplateHandler := handlers.NewPageTemplateHandler(db, reconciler)
+	pageTemplateHandler.RegisterRoutes(protected)
+
+	accessListHandler := handlers.NewAccessListHandler(db, reconciler)
+	accessListHandler.RegisterRoutes(protected)
 
 	remoteServerHandler := handlers.NewRemoteServerHandler(db)
 	remoteServerHandler.RegisterRoutes(api)
@@ -137,12 +216,12 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	certHandler := handlers.NewCertificateHandler(certService)
 	api.GET("/certificates", certHandler.List)
 
-	return nil
-}
+	customCertHandler := handlers.NewCustomCertificateHandler(db, cipher, reconciler)
+	customCertHandler.RegisterRoutes(protected)
 
-// RegisterImportHandler wires up import routes with config dependencies.
-func RegisterImportHandler(router *gin.Engine, db *gorm.DB, caddyBinary, importDir string) {
-	importHandler := handlers.NewImportHandler(db, caddyBinary, importDir)
-	api := router.Group("/api/v1")
-	importHandler.RegisterRoutes(api)
+	// Import routes share the reconciler so committed hosts reach Caddy
+	importHandler := handlers.NewImportHandler(db, cfg.CaddyBinary, cfg.ImportDir, reconciler)
+	importHandler.RegisterRoutes(protected)
+
+	return reconciler, nil
 }
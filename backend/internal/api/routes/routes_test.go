@@ -1,6 +1,8 @@
 package routes
 
 import (
+"net/http"
+"net/http/httptest"
 "testing"
 
 "github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/config"
@@ -23,7 +25,7 @@ cfg := config.Config{
 JWTSecret: "test-secret",
 }
 
-err = Register(router, db, cfg)
+_, err = Register(router, db, cfg)
 assert.NoError(t, err)
 
 // Verify some routes are registered
@@ -39,3 +41,45 @@ break
 }
 assert.True(t, foundHealth, "Health route should be registered")
 }
+
+func TestRegister_RequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	_, err = Register(router, db, config.Config{JWTSecret: "test-secret"})
+	require.NoError(t, err)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/proxy-hosts"},
+		{http.MethodPost, "/api/v1/proxy-hosts"},
+		{http.MethodPut, "/api/v1/proxy-hosts/some-uuid"},
+		{http.MethodDelete, "/api/v1/proxy-hosts/some-uuid"},
+		{http.MethodPost, "/api/v1/import/upload"},
+		{http.MethodPost, "/api/v1/import/commit"},
+		{http.MethodDelete, "/api/v1/settings/caddy.crowdsec_lapi_url"},
+		{http.MethodGet, "/api/v1/streams"},
+		{http.MethodPost, "/api/v1/streams"},
+		{http.MethodDelete, "/api/v1/streams/some-uuid"},
+		{http.MethodPost, "/api/v1/redirection-hosts"},
+		{http.MethodPut, "/api/v1/redirection-hosts/some-uuid"},
+		{http.MethodGet, "/api/v1/certificates/custom"},
+		{http.MethodPost, "/api/v1/certificates/custom"},
+		{http.MethodDelete, "/api/v1/certificates/custom/some-uuid"},
+		{http.MethodPost, "/api/v1/proxy-hosts/some-uuid/locations"},
+		{http.MethodPut, "/api/v1/proxy-hosts/some-uuid/locations/loc-uuid"},
+		{http.MethodPost, "/api/v1/security-header-profiles"},
+		{http.MethodGet, "/api/v1/security-headers/report"},
+		{http.MethodGet, "/api/v1/access-lists"},
+		{http.MethodPut, "/api/v1/access-lists/some-uuid"},
+		{http.MethodPost, "/api/v1/access-lists/some-uuid/users"},
+		{http.MethodPost, "/api/v1/access-lists/some-uuid/check"},
+		{http.MethodPost, "/api/v1/page-templates"},
+		{http.MethodDelete, "/api/v1/page-templates/some-uuid"},
+	} {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusUnauthorized, resp.Code, route.method+" "+route.path)
+	}
+}
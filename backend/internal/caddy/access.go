@@ -0,0 +1,228 @@
+package caddy
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// TrustedProxiesSettingKey holds the comma-separated networks of proxies in front
+// of Caddy, e.g. a CDN or load balancer. When set, access lists match the client
+// address they report in X-Forwarded-For instead of the connecting address.
+const TrustedProxiesSettingKey = "caddy.trusted_proxies"
+
+// ParseNetwork checks a CIDR range or single IP address, IPv4 or IPv6, and
+// returns it in canonical form.
+func ParseNetwork(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if prefix, err := netip.ParsePrefix(raw); err == nil {
+		return prefix.Masked().String(), nil
+	}
+	addr, err := netip.ParseAddr(raw)
+	if err != nil || addr.Zone() != "" {
+		return "", fmt.Errorf("invalid network %q (expected an IP address or CIDR range)", raw)
+	}
+	return addr.String(), nil
+}
+
+// ParseTrustedProxies parses the trusted proxies setting. An empty value returns nil.
+func ParseTrustedProxies(raw string) ([]string, error) {
+	var ranges []string
+	for _, r := range strings.Split(raw, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		network, err := ParseNetwork(r)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, network)
+	}
+	return ranges, nil
+}
+
+// trustedProxiesConfig builds a server's trusted_proxies block.
+func trustedProxiesConfig(ranges []string) map[string]interface{} {
+	if len(ranges) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"source": "static",
+		"ranges": ranges,
+	}
+}
+
+// accessControl resolves the access lists referenced by hosts and locations.
+type accessControl struct {
+	lists       map[uint]*models.AccessList
+	clientIP    bool   // Match client_ip, resolved through trusted proxies, instead of remote_ip
+	internalURL string // Base URL of CPM for sso and country lists
+}
+
+func newAccessControl(lists []models.AccessList, clientIP bool, internalURL string) *accessControl {
+	a := &accessControl{lists: make(map[uint]*models.AccessList, len(lists)), clientIP: clientIP, internalURL: internalURL}
+	for i := range lists {
+		a.lists[lists[i].ID] = &lists[i]
+	}
+	return a
+}
+
+// handler returns the handler enforcing the access lists ids, or nil when no
+// enabled list applies.
+func (a *accessControl) handler(ids []uint, satisfy string) (Handler, error) {
+	var allow, deny, countryAllow, countryDeny []string
+	var auth []Handler
+	var basic []*models.AccessList
+	for _, id := range ids {
+		list, ok := a.lists[id]
+		if !ok {
+			return nil, fmt.Errorf("access list %d not found", id)
+		}
+		if !list.Enabled {
+			continue
+		}
+
+		switch list.Type {
+		case models.AccessListAllow:
+			allow = append(allow, list.Rules...)
+		case models.AccessListDeny:
+			deny = append(deny, list.Rules...)
+		case models.AccessListBasicAuth:
+			basic = append(basic, list)
+		case models.AccessListForwardAuth:
+			h, err := ForwardAuthHandler(list)
+			if err != nil {
+				return nil, err
+			}
+			auth = append(auth, h)
+		case models.AccessListCountryAllow:
+			countryAllow = append(countryAllow, list.Rules...)
+		case models.AccessListCountryDeny:
+			countryDeny = append(countryDeny, list.Rules...)
+		case models.AccessListSSO:
+			h, err := SSOHandler(list, a.internalURL)
+			if err != nil {
+				return nil, err
+			}
+			auth = append(auth, h)
+		default:
+			return nil, fmt.Errorf("access list %s: unsupported type %s", list.Name, list.Type)
+		}
+	}
+
+	if len(basic) > 0 {
+		auth = append([]Handler{BasicAuthHandler(basic)}, auth...)
+	}
+
+	gate := AccessControlHandler(a.deniedMatchers(allow, deny), auth, satisfy)
+	if len(countryAllow) == 0 && len(countryDeny) == 0 {
+		return gate, nil
+	}
+
+	// Country lists apply to every client, whatever access_satisfy says
+	country, err := CountryHandler(a.internalURL, countryAllow, countryDeny)
+	if err != nil {
+		return nil, err
+	}
+	routes := []*Route{{Handle: []Handler{country}}}
+	if gate != nil {
+		routes = append(routes, gate["routes"].([]*Route)...)
+	}
+	return Handler{
+		"handler": "subroute",
+		"routes":  routes,
+	}, nil
+}
+
+// BasicAuthHandler builds one authentication handler accepting the accounts of
+// every given basic_auth list; the first list names the realm. A username found
+// in several lists keeps its first password. Lists without accounts admit
+// nobody, so they render as a 401 instead.
+func BasicAuthHandler(lists []*models.AccessList) Handler {
+	realm := lists[0].Realm
+	if realm == "" {
+		realm = models.DefaultRealm
+	}
+
+	var accounts []map[string]string
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, user := range list.Users {
+			if seen[user.Username] {
+				continue
+			}
+			seen[user.Username] = true
+			accounts = append(accounts, map[string]string{
+				"username": user.Username,
+				"password": user.PasswordHash,
+			})
+		}
+	}
+
+	if len(accounts) == 0 {
+		return StaticResponseHandler(401, "Unauthorized", map[string][]string{
+			"WWW-Authenticate": {fmt.Sprintf("Basic realm=%q", realm)},
+		})
+	}
+	return Handler{
+		"handler": "authentication",
+		"providers": map[string]interface{}{
+			"http_basic": map[string]interface{}{
+				"accounts": accounts,
+				"hash":     map[string]string{"algorithm": "bcrypt"},
+				"realm":    realm,
+			},
+		},
+	}
+}
+
+// deniedMatchers returns matcher sets selecting clients the IP lists refuse:
+// any denied network, or anything outside the allowed networks.
+func (a *accessControl) deniedMatchers(allow, deny []string) []Match {
+	var sets []Match
+	if len(deny) > 0 {
+		sets = append(sets, a.ipMatch(deny))
+	}
+	if len(allow) > 0 {
+		sets = append(sets, Match{Not: []Match{a.ipMatch(allow)}})
+	}
+	return sets
+}
+
+func (a *accessControl) ipMatch(ranges []string) Match {
+	if a.clientIP {
+		return Match{ClientIP: &IPMatch{Ranges: ranges}}
+	}
+	return Match{RemoteIP: &IPMatch{Ranges: ranges}}
+}
+
+// AccessControlHandler combines IP restrictions and authentication handlers into
+// a subroute, or returns nil when there is nothing to enforce. With satisfy
+// "all" refused networks get a 403 and everyone else must authenticate; with
+// "any" only refused networks must authenticate.
+func AccessControlHandler(denied []Match, auth []Handler, satisfy string) Handler {
+	var routes []*Route
+	if len(denied) > 0 && len(auth) > 0 && satisfy == models.AccessSatisfyAny {
+		routes = append(routes, &Route{Match: denied, Handle: auth})
+	} else {
+		if len(denied) > 0 {
+			routes = append(routes, &Route{
+				Match:  denied,
+				Handle: []Handler{StaticResponseHandler(403, "Forbidden", nil)},
+			})
+		}
+		if len(auth) > 0 {
+			routes = append(routes, &Route{Handle: auth})
+		}
+	}
+
+	if len(routes) == 0 {
+		return nil
+	}
+	return Handler{
+		"handler": "subroute",
+		"routes":  routes,
+	}
+}
@@ -0,0 +1,197 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "192.168.1.10", want: "192.168.1.10"},
+		{raw: " 10.1.2.3/8 ", want: "10.0.0.0/8"},
+		{raw: "2001:db8::1", want: "2001:db8::1"},
+		{raw: "2001:DB8::/32", want: "2001:db8::/32"},
+		{raw: "fe80::1%eth0", wantErr: true},
+		{raw: "10.0.0.0/33", wantErr: true},
+		{raw: "example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseNetwork(tt.raw)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerateConfig_AccessLists(t *testing.T) {
+	lists := []models.AccessList{
+		{ID: 1, Name: "Office", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8", "2001:db8::/32"}, Enabled: true},
+		{ID: 2, Name: "Blocked", Type: models.AccessListDeny, Rules: []string{"10.6.6.6"}, Enabled: true},
+		{ID: 3, Name: "Admins", Type: models.AccessListAllow, Rules: []string{"10.0.0.5"}, Enabled: true},
+		{ID: 4, Name: "Disabled", Type: models.AccessListDeny, Rules: []string{"0.0.0.0/0"}, Enabled: false},
+	}
+	hosts := []models.ProxyHost{
+		{
+			UUID:          "acl-uuid",
+			DomainNames:   "internal.example.com",
+			ForwardHost:   "app",
+			ForwardPort:   8080,
+			Enabled:       true,
+			AccessListIDs: []uint{1, 2, 4},
+			Locations: []models.Location{
+				{Path: "/admin", ForwardHost: "admin", ForwardPort: 9000, AccessListIDs: []uint{3}},
+				{Path: "/public", ForwardHost: "public", ForwardPort: 9001},
+			},
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	server := config.Apps.HTTP.Servers["cpm_server"]
+	require.Nil(t, server.TrustedProxies)
+	routes := server.Routes
+	require.Len(t, routes, 3)
+
+	hostGate := Handler{
+		"handler": "subroute",
+		"routes": []*Route{
+			{
+				Match: []Match{
+					{RemoteIP: &IPMatch{Ranges: []string{"10.6.6.6"}}},
+					{Not: []Match{{RemoteIP: &IPMatch{Ranges: []string{"10.0.0.0/8", "2001:db8::/32"}}}}},
+				},
+				Handle: []Handler{StaticResponseHandler(403, "Forbidden", nil)},
+			},
+		},
+	}
+
+	// The location's own list replaces the host's
+	admin := routes[0]
+	require.Len(t, admin.Handle, 2)
+	adminRoutes := admin.Handle[0]["routes"].([]*Route)
+	require.Equal(t, []Match{{Not: []Match{{RemoteIP: &IPMatch{Ranges: []string{"10.0.0.5"}}}}}}, adminRoutes[0].Match)
+
+	// Other locations and the main route inherit the host's lists
+	require.Equal(t, hostGate, routes[1].Handle[0])
+	require.Equal(t, hostGate, routes[2].Handle[0])
+
+	// Behind trusted proxies the forwarded client address is matched
+	config, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists, TrustedProxies: []string{"172.16.0.0/12"}})
+	require.NoError(t, err)
+	server = config.Apps.HTTP.Servers["cpm_server"]
+	require.Equal(t, map[string]interface{}{"source": "static", "ranges": []string{"172.16.0.0/12"}}, server.TrustedProxies)
+	gateRoutes := server.Routes[2].Handle[0]["routes"].([]*Route)
+	require.Nil(t, gateRoutes[0].Match[0].RemoteIP)
+	require.Equal(t, []string{"10.6.6.6"}, gateRoutes[0].Match[0].ClientIP.Ranges)
+
+	_, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.ErrorContains(t, err, "access list 1 not found")
+}
+
+func TestAccessControlHandler_Satisfy(t *testing.T) {
+	denied := []Match{{Not: []Match{{RemoteIP: &IPMatch{Ranges: []string{"10.0.0.0/8"}}}}}}
+	auth := []Handler{{"handler": "authentication"}}
+
+	require.Nil(t, AccessControlHandler(nil, nil, models.AccessSatisfyAll))
+
+	// all: refused networks get a 403, everyone else must still authenticate
+	all := AccessControlHandler(denied, auth, models.AccessSatisfyAll)["routes"].([]*Route)
+	require.Len(t, all, 2)
+	require.Equal(t, denied, all[0].Match)
+	require.Equal(t, 403, all[0].Handle[0]["status_code"])
+	require.Nil(t, all[1].Match)
+	require.Equal(t, auth, all[1].Handle)
+
+	// any: allowed networks skip authentication
+	anyRoutes := AccessControlHandler(denied, auth, models.AccessSatisfyAny)["routes"].([]*Route)
+	require.Len(t, anyRoutes, 1)
+	require.Equal(t, denied, anyRoutes[0].Match)
+	require.Equal(t, auth, anyRoutes[0].Handle)
+
+	// any without auth lists still refuses
+	ipOnly := AccessControlHandler(denied, nil, models.AccessSatisfyAny)["routes"].([]*Route)
+	require.Equal(t, 403, ipOnly[0].Handle[0]["status_code"])
+}
+
+func TestGenerateConfig_BasicAuth(t *testing.T) {
+	staff := models.AccessListUser{Username: "alice"}
+	require.NoError(t, staff.SetPassword("s3cret"))
+	lists := []models.AccessList{
+		{ID: 1, Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}, Enabled: true},
+		{ID: 2, Name: "Staff", Type: models.AccessListBasicAuth, Realm: "Staff only", Enabled: true, Users: []models.AccessListUser{staff}},
+		{ID: 3, Name: "Contractors", Type: models.AccessListBasicAuth, Realm: "Other", Enabled: true, Users: []models.AccessListUser{{Username: "alice", PasswordHash: "ignored"}, {Username: "bob", PasswordHash: "$2a$10$x"}}},
+		{ID: 4, Name: "Empty", Type: models.AccessListBasicAuth, Realm: "Nobody", Enabled: true},
+	}
+	hosts := []models.ProxyHost{{
+		UUID: "wiki", DomainNames: "wiki.example.com", ForwardHost: "wiki", ForwardPort: 80, Enabled: true,
+		AccessListIDs: []uint{1, 2, 3}, AccessSatisfy: models.AccessSatisfyAny,
+		Locations: []models.Location{
+			{Path: "/private", ForwardHost: "wiki", ForwardPort: 80, AccessListIDs: []uint{4}},
+		},
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists})
+	require.NoError(t, err)
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 2)
+
+	// location: an empty basic_auth list admits nobody
+	locGate := routes[0].Handle[0]["routes"].([]*Route)
+	require.Len(t, locGate, 1)
+	require.Equal(t, 401, locGate[0].Handle[0]["status_code"])
+	require.Equal(t, []string{`Basic realm="Nobody"`}, locGate[0].Handle[0]["headers"].(map[string][]string)["WWW-Authenticate"])
+
+	// host: outside the LAN, clients log in with any account of the two lists
+	gate := routes[1].Handle[0]["routes"].([]*Route)
+	require.Len(t, gate, 1)
+	require.Equal(t, []string{"10.0.0.0/8"}, gate[0].Match[0].Not[0].RemoteIP.Ranges)
+	auth := gate[0].Handle[0]
+	require.Equal(t, "authentication", auth["handler"])
+	basic := auth["providers"].(map[string]interface{})["http_basic"].(map[string]interface{})
+	require.Equal(t, "Staff only", basic["realm"])
+	require.Equal(t, map[string]string{"algorithm": "bcrypt"}, basic["hash"])
+	require.Equal(t, []map[string]string{
+		{"username": "alice", "password": staff.PasswordHash},
+		{"username": "bob", "password": "$2a$10$x"},
+	}, basic["accounts"])
+}
+
+func TestValidate_AccessListRanges(t *testing.T) {
+	gate := AccessControlHandler([]Match{{RemoteIP: &IPMatch{Ranges: []string{"10.0.0.0/40"}}}}, nil, models.AccessSatisfyAll)
+	config := &Config{
+		Apps: Apps{
+			HTTP: &HTTPApp{
+				Servers: map[string]*Server{
+					"srv": {
+						Listen: []string{":80"},
+						Routes: []*Route{
+							{
+								Match:  []Match{{Host: []string{"test.com"}}},
+								Handle: []Handler{gate, ReverseProxyHandler([]string{"app:80"}, false)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := Validate(config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid network")
+}
@@ -0,0 +1,238 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// SnippetAdapter checks advanced configs with the Caddy binary. *Importer
+// implements it.
+type SnippetAdapter interface {
+	// AdaptSnippet turns Caddyfile directives into validated Caddy JSON routes.
+	AdaptSnippet(snippet string) (json.RawMessage, error)
+	// ValidateRoutes loads and provisions routes without running them.
+	ValidateRoutes(routes json.RawMessage) error
+}
+
+// advancedRoute is a route of an advanced config. Matchers stay undecoded so
+// matchers CPM does not model survive.
+type advancedRoute struct {
+	Match    []map[string]json.RawMessage `json:"match,omitempty"`
+	Handle   []Handler                    `json:"handle"`
+	Terminal bool                         `json:"terminal,omitempty"`
+}
+
+// CompileAdvanced turns the content of a host's advanced config into Caddy JSON
+// routes. adapter may be nil, in which case Caddyfile snippets are refused and
+// JSON is only checked structurally; when modules is known, every handler and
+// matcher must be among them.
+func CompileAdvanced(format, content string, adapter SnippetAdapter, modules []string) (json.RawMessage, error) {
+	var routes json.RawMessage
+	var err error
+	switch format {
+	case models.AdvancedFormatCaddyfile:
+		if adapter == nil {
+			return nil, errors.New("caddyfile snippets need the caddy binary")
+		}
+		routes, err = adapter.AdaptSnippet(content)
+	default:
+		routes, err = ParseAdvancedJSON(content)
+		if err == nil && adapter != nil {
+			err = adapter.ValidateRoutes(routes)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAdvancedRoutes(routes, modules); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// ParseAdvancedJSON reads a JSON advanced config: a route, a handler, or an
+// array of them. Handlers become routes of their own.
+func ParseAdvancedJSON(content string) (json.RawMessage, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := strings.Count(content[:min(int(syntaxErr.Offset), len(content))], "\n") + 1
+			return nil, fmt.Errorf("json: line %d: %w", line, err)
+		}
+		return nil, fmt.Errorf("json: %w", err)
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	routes := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("json: item %d is not a route or handler object", i)
+		}
+		if _, isHandler := object["handler"]; isHandler {
+			object = map[string]interface{}{"handle": []interface{}{object}}
+		}
+		routes = append(routes, object)
+	}
+	return json.Marshal(routes)
+}
+
+// checkAdvancedRoutes makes sure routes decode, every route handles requests
+// and, when modules is known, that the build has every handler and matcher.
+func checkAdvancedRoutes(raw json.RawMessage, modules []string) error {
+	var routes []advancedRoute
+	if err := json.Unmarshal(raw, &routes); err != nil {
+		return fmt.Errorf("invalid routes: %w", err)
+	}
+	if len(routes) == 0 {
+		return errors.New("no routes")
+	}
+
+	for i, route := range routes {
+		if len(route.Handle) == 0 {
+			return fmt.Errorf("route %d has no handlers", i)
+		}
+		for _, set := range route.Match {
+			for name := range set {
+				if len(modules) > 0 && !hasModule(modules, "http.matchers."+name) {
+					return fmt.Errorf("route %d: the Caddy build has no %s matcher", i, name)
+				}
+			}
+		}
+		for j, handler := range route.Handle {
+			name, _ := handler["handler"].(string)
+			if name == "" {
+				return fmt.Errorf("route %d handler %d: missing 'handler' field", i, j)
+			}
+			if len(modules) > 0 && !hasModule(modules, "http.handlers."+name) {
+				return fmt.Errorf("route %d: the Caddy build has no %s handler", i, name)
+			}
+			if nested, ok := handler["routes"]; ok && name == "subroute" {
+				nestedJSON, _ := json.Marshal(nested)
+				if err := checkAdvancedRoutes(nestedJSON, modules); err != nil {
+					return fmt.Errorf("route %d subroute: %w", i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// AdvancedHandler runs the routes of a host's advanced config inside its main
+// route, so they only see requests the host's access lists let through.
+func AdvancedHandler(routes json.RawMessage) Handler {
+	return Handler{
+		"handler": "subroute",
+		"routes":  routes,
+	}
+}
+
+// caddyfilePosition matches the positions in errors of `caddy adapt`, such as
+// "/tmp/cpm-snippet/Caddyfile:3".
+var caddyfilePosition = regexp.MustCompile(`\S*Caddyfile:(\d+)`)
+
+// AdaptSnippet wraps Caddyfile directives in a site block and adapts it with
+// `caddy adapt --validate`. Error positions refer to lines of the snippet.
+func (i *Importer) AdaptSnippet(snippet string) (json.RawMessage, error) {
+	dir, err := os.MkdirTemp("", "cpm-snippet-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Caddyfile")
+	if err := os.WriteFile(path, []byte(":80 {\n"+snippet+"\n}\n"), 0o600); err != nil {
+		return nil, err
+	}
+
+	output, err := i.executor.Execute(i.caddyBinaryPath, "adapt", "--config", path, "--adapter", "caddyfile", "--validate")
+	if err != nil {
+		// The snippet starts on the second line of the file
+		message := caddyfilePosition.ReplaceAllStringFunc(commandError(output, err), func(position string) string {
+			line, _ := strconv.Atoi(caddyfilePosition.FindStringSubmatch(position)[1])
+			return fmt.Sprintf("line %d", line-1)
+		})
+		return nil, fmt.Errorf("caddyfile: %s", message)
+	}
+
+	var adapted struct {
+		Apps struct {
+			HTTP struct {
+				Servers map[string]struct {
+					Routes json.RawMessage `json:"routes"`
+				} `json:"servers"`
+			} `json:"http"`
+		} `json:"apps"`
+	}
+	if err := json.Unmarshal(output, &adapted); err != nil {
+		return nil, fmt.Errorf("parsing adapted caddyfile: %w", err)
+	}
+	for _, server := range adapted.Apps.HTTP.Servers {
+		if len(server.Routes) > 0 {
+			return server.Routes, nil
+		}
+	}
+	return nil, errors.New("caddyfile: the snippet has no directives")
+}
+
+// ValidateRoutes loads and provisions routes in a throwaway config with
+// `caddy validate`, which catches unknown modules and invalid fields.
+func (i *Importer) ValidateRoutes(routes json.RawMessage) error {
+	dir, err := os.MkdirTemp("", "cpm-snippet-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Only the http app; Config would add an empty storage module
+	config, err := json.Marshal(map[string]interface{}{
+		"apps": Apps{HTTP: &HTTPApp{Servers: map[string]*Server{
+			"advanced": {
+				Listen:    []string{":80"},
+				Routes:    []*Route{{Handle: []Handler{AdvancedHandler(routes)}}},
+				AutoHTTPS: &AutoHTTPSConfig{Disable: true},
+			},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "advanced.json")
+	if err := os.WriteFile(path, config, 0o600); err != nil {
+		return err
+	}
+
+	output, err := i.executor.Execute(i.caddyBinaryPath, "validate", "--config", path)
+	if err != nil {
+		return fmt.Errorf("json: %s", commandError(output, err))
+	}
+	return nil
+}
+
+// commandError returns what a failed Caddy command printed, preferring stderr.
+func commandError(output []byte, err error) string {
+	message := string(output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		message = string(exitErr.Stderr)
+	}
+	message = strings.TrimPrefix(strings.TrimSpace(message), "Error: ")
+	if message == "" {
+		return err.Error()
+	}
+	return message
+}
@@ -0,0 +1,112 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestParseAdvancedJSON(t *testing.T) {
+	// A bare handler becomes a route of its own
+	routes, err := ParseAdvancedJSON(`{"handler": "vars", "tenant": "acme"}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"handle": [{"handler": "vars", "tenant": "acme"}]}]`, string(routes))
+
+	routes, err = ParseAdvancedJSON(`[{"match": [{"path": ["/old/*"]}], "handle": [{"handler": "static_response", "status_code": 410}]}]`)
+	require.NoError(t, err)
+	require.NoError(t, checkAdvancedRoutes(routes, nil))
+
+	_, err = ParseAdvancedJSON("{\n  \"handler\": \"vars\",\n  \"tenant\" \"acme\"\n}")
+	require.ErrorContains(t, err, "json: line 3")
+	_, err = ParseAdvancedJSON(`["vars"]`)
+	require.ErrorContains(t, err, "item 0 is not a route or handler object")
+}
+
+func TestCompileAdvanced(t *testing.T) {
+	modules := []string{"http.handlers.static_response", "http.handlers.subroute", "http.matchers.path"}
+
+	routes, err := CompileAdvanced(models.AdvancedFormatJSON, `{"match": [{"path": ["/ping"]}], "handle": [{"handler": "static_response", "body": "pong"}]}`, nil, modules)
+	require.NoError(t, err)
+	require.Contains(t, string(routes), "pong")
+
+	for content, wantErr := range map[string]string{
+		`[]`:                          "no routes",
+		`{"handle": []}`:              "route 0 has no handlers",
+		`{"handle": [{"body": "x"}]}`: "missing 'handler' field",
+		`{"handler": "teapot"}`:       "no teapot handler",
+		`{"match": [{"header": {"X": ["1"]}}], "handle": [{"handler": "static_response"}]}`: "no header matcher",
+		`{"handler": "subroute", "routes": [{"handle": [{"handler": "teapot"}]}]}`:          "subroute: route 0: the Caddy build has no teapot handler",
+	} {
+		_, err := CompileAdvanced(models.AdvancedFormatJSON, content, nil, modules)
+		require.ErrorContains(t, err, wantErr, content)
+	}
+
+	_, err = CompileAdvanced(models.AdvancedFormatCaddyfile, "respond 204", nil, nil)
+	require.ErrorContains(t, err, "need the caddy binary")
+}
+
+func TestImporter_AdaptSnippet(t *testing.T) {
+	importer := NewImporter("caddy")
+	importer.executor = &MockExecutor{
+		Output: []byte(`{"apps": {"http": {"servers": {"srv0": {"listen": [":80"], "routes": [{"handle": [{"handler": "static_response", "status_code": 204}]}]}}}}}`),
+	}
+	routes, err := CompileAdvanced(models.AdvancedFormatCaddyfile, "respond 204", importer, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"handle": [{"handler": "static_response", "status_code": 204}]}]`, string(routes))
+
+	// Errors point at lines of the snippet, not of the wrapping site block
+	importer.executor = &MockExecutor{
+		Output: []byte("Error: /tmp/cpm-snippet-1/Caddyfile:3: unrecognized directive: foo"),
+		Err:    assert.AnError,
+	}
+	_, err = importer.AdaptSnippet("respond 204\nfoo")
+	require.EqualError(t, err, "caddyfile: line 2: unrecognized directive: foo")
+
+	importer.executor = &MockExecutor{Output: []byte("Error: loading http app module: unknown module"), Err: assert.AnError}
+	err = importer.ValidateRoutes(json.RawMessage(`[{"handle": [{"handler": "teapot"}]}]`))
+	require.EqualError(t, err, "json: loading http app module: unknown module")
+}
+
+func TestGenerateConfig_AdvancedSnippets(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{
+			UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+			HTTPSMode: models.HTTPSModeHTTPS,
+			Advanced: models.AdvancedConfig{
+				Format: models.AdvancedFormatJSON,
+				Routes: `[{"match": [{"path": ["/ping"]}], "handle": [{"handler": "static_response", "body": "pong"}]}]`,
+			},
+		},
+		{
+			UUID: "blog", DomainNames: "blog.example.com", ForwardHost: "blog", ForwardPort: 80, Enabled: true,
+			HTTPSMode: models.HTTPSModeHTTPS,
+			Advanced:  models.AdvancedConfig{Format: models.AdvancedFormatJSON, Routes: `[{"handle": [{"handler": "teapot"}]}]`},
+		},
+	}
+	opts := ConfigOptions{Modules: []string{"http.handlers.static_response", "http.handlers.reverse_proxy", "http.matchers.path"}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", opts)
+	require.NoError(t, err)
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 2)
+
+	// The snippet runs just before the proxy
+	handlers := routes[0].Handle
+	require.Equal(t, AdvancedHandler(json.RawMessage(hosts[0].Advanced.Routes)), handlers[len(handlers)-2])
+	require.Equal(t, "reverse_proxy", handlers[len(handlers)-1]["handler"])
+
+	// A snippet the build cannot run is left out instead of failing every host
+	for _, handler := range routes[1].Handle {
+		require.NotEqual(t, "subroute", handler["handler"])
+	}
+	require.Equal(t, []string{"proxy host blog: advanced config skipped: route 0: the Caddy build has no teapot handler"}, config.Warnings)
+	require.NoError(t, Validate(config))
+
+	// Validate covers the raw routes too
+	routes[0].Handle[len(handlers)-2] = AdvancedHandler(json.RawMessage(`[{"handle": []}]`))
+	require.ErrorContains(t, Validate(config), "route 0 has no handlers")
+}
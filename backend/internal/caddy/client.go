@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -99,3 +100,35 @@ func (c *Client) Ping(ctx context.Context) error {
 
 	return nil
 }
+
+// RootCertificate returns the PEM root certificate of one of Caddy's local CAs,
+// "local" being the CA behind the internal issuer.
+func (c *Client) RootCertificate(ctx context.Context, caID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/pki/ca/"+url.PathEscape(caID), nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("caddy returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var ca struct {
+		RootCertificate string `json:"root_certificate"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ca); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if ca.RootCertificate == "" {
+		return "", fmt.Errorf("ca %s has no root certificate", caID)
+	}
+
+	return ca.RootCertificate, nil
+}
@@ -1,15 +1,60 @@
 package caddy
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
 )
 
+// ConfigOptions carries global, settings-driven inputs to GenerateConfig.
+type ConfigOptions struct {
+	// ExploitRules applies to hosts with BlockExploits; zero value means DefaultExploitRules.
+	ExploitRules ExploitRules
+	// RedirectionHosts are served from the same server as proxy hosts.
+	RedirectionHosts []models.RedirectionHost
+	// Streams are generated into the layer4 app.
+	Streams []models.Stream
+	// Protocols is the HTTPS server's protocols list (h1, h2, h2c, h3); empty keeps Caddy's defaults.
+	Protocols []string
+	// Certificates are the custom certificates referenced by hosts, with decrypted keys.
+	Certificates []models.SSLCertificate
+	// DNSCredentials maps DNS provider names to their decrypted credentials.
+	DNSCredentials map[string]map[string]string
+	// ZeroSSLAccount is the EAB used by hosts selecting the zerossl issuer.
+	ZeroSSLAccount *ExternalAccount
+	// SecurityHeaderProfiles are the profiles hosts may reference.
+	SecurityHeaderProfiles []models.SecurityHeaderProfile
+	// AccessLists are the access lists hosts and locations may reference.
+	AccessLists []models.AccessList
+	// PageTemplates are the maintenance and error pages hosts may reference.
+	PageTemplates []models.PageTemplate
+	// TrustedProxies are networks whose X-Forwarded-For is believed; access lists then match client_ip.
+	TrustedProxies []string
+	// InternalURL is the base URL Caddy reaches CPM at, for access lists CPM checks itself.
+	InternalURL string
+	// Modules are the IDs of the modules in the running Caddy build; optional
+	// handlers whose module is missing are left out.
+	Modules []string
+	// CrowdSec connects hosts with CrowdSec enabled to the LAPI; nil leaves them unprotected.
+	CrowdSec *CrowdSecApp
+	// DefaultSite answers requests no host matches; nil leaves them unanswered.
+	DefaultSite *DefaultSite
+}
+
 // GenerateConfig creates a Caddy JSON configuration from proxy hosts.
 // This is the core transformation layer from our database model to Caddy config.
-func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail string) (*Config, error) {
+func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail string, opts ConfigOptions) (*Config, error) {
+	exploitRules := opts.ExploitRules
+	if exploitRules.IsZero() {
+		exploitRules = DefaultExploitRules()
+	}
+
 	// Define log file paths
 	// We assume storageDir is like ".../data/caddy/data", so we go up to ".../data/logs"
 	// Or we can just use a relative path if Caddy's working directory is set correctly.
@@ -69,12 +114,40 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 		}
 	}
 
-	if len(hosts) == 0 {
+	if layer4, tlsNames := GenerateStreams(opts.Streams); layer4 != nil {
+		config.Apps.Layer4 = layer4
+		if len(tlsNames) > 0 {
+			if config.Apps.TLS == nil {
+				config.Apps.TLS = &TLSApp{}
+			}
+			config.Apps.TLS.Certificates = &CertificatesConfig{Automate: tlsNames}
+		}
+	}
+
+	if len(hosts) == 0 && len(opts.RedirectionHosts) == 0 && opts.DefaultSite == nil {
 		return config, nil
 	}
 
-	// We already initialized srv0 above, so we just append routes to it
-	routes := make([]*Route, 0)
+	// HTTPS hosts are served from cpm_server on :443. cpm_http on :80 carries the
+	// explicit per-host redirects, hosts that also answer plain HTTP and HTTP-only
+	// hosts, which never reach a TLS listener and so never request certificates.
+	httpsRoutes := make([]*Route, 0)
+	httpRoutes := make([]*Route, 0)
+	var httpsErrorRoutes, httpErrorRoutes []*Route
+	var httpOnlyDomains, http1Domains, skipCertDomains []string
+	customCerts := customCertificateLoader(opts.Certificates)
+	trustedProxies := forwardAuthTrustedProxies(opts.TrustedProxies, opts.AccessLists)
+	access := newAccessControl(opts.AccessLists, len(trustedProxies) > 0, opts.InternalURL)
+	profiles := make(map[uint]*models.SecurityHeaderProfile, len(opts.SecurityHeaderProfiles))
+	for i := range opts.SecurityHeaderProfiles {
+		profiles[opts.SecurityHeaderProfiles[i].ID] = &opts.SecurityHeaderProfiles[i]
+	}
+	templates := newPageTemplates(opts.PageTemplates)
+	var automation automationPolicies
+	crowdSec := opts.CrowdSec != nil && hasModule(opts.Modules, CrowdSecModule)
+	usesCrowdSec := false
+	// Like Validate, trust an unknown module list
+	rateLimit := len(opts.Modules) == 0 || hasModule(opts.Modules, RateLimitModule)
 
 	for _, host := range hosts {
 		if !host.Enabled {
@@ -91,69 +164,575 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 			domains[i] = strings.TrimSpace(domains[i])
 		}
 
-		// Build handlers for this host
-		handlers := make([]Handler, 0)
+		var profile *models.SecurityHeaderProfile
+		if host.SecurityHeaderProfileID != nil {
+			if profile = profiles[*host.SecurityHeaderProfileID]; profile == nil {
+				return nil, fmt.Errorf("proxy host %s: security header profile %d not found", host.UUID, *host.SecurityHeaderProfileID)
+			}
+		}
 
-		// Add HSTS header if enabled
-		if host.HSTSEnabled {
-			hstsValue := "max-age=31536000"
-			if host.HSTSSubdomains {
-				hstsValue += "; includeSubDomains"
+		// A broken advanced config only costs its host the extra routes
+		var advanced Handler
+		if host.Advanced.Routes != "" {
+			raw := json.RawMessage(host.Advanced.Routes)
+			if err := checkAdvancedRoutes(raw, opts.Modules); err != nil {
+				config.Warnings = append(config.Warnings, fmt.Sprintf("proxy host %s: advanced config skipped: %v", host.UUID, err))
+			} else {
+				advanced = AdvancedHandler(raw)
 			}
-			handlers = append(handlers, HeaderHandler(map[string][]string{
-				"Strict-Transport-Security": {hstsValue},
-			}))
 		}
 
-		// Add exploit blocking if enabled
-		if host.BlockExploits {
-			handlers = append(handlers, BlockExploitsHandler())
+		// Without the rate_limit module Caddy would refuse the config of every host
+		if !rateLimit && hasRateLimits(&host) {
+			config.Warnings = append(config.Warnings, fmt.Sprintf("proxy host %s: rate limits skipped: the Caddy build lacks %s", host.UUID, RateLimitModule))
+			host.RateLimits = nil
+			host.Locations = slices.Clone(host.Locations)
+			for i := range host.Locations {
+				host.Locations[i].RateLimits = nil
+			}
 		}
 
-		// Handle custom locations first (more specific routes)
-		for _, loc := range host.Locations {
-			dial := fmt.Sprintf("%s:%d", loc.ForwardHost, loc.ForwardPort)
-			locRoute := &Route{
-				Match: []Match{
-					{
-						Host: domains,
-						Path: []string{loc.Path, loc.Path + "/*"},
-					},
-				},
-				Handle: []Handler{
-					ReverseProxyHandler(dial, host.WebsocketSupport),
-				},
-				Terminal: true,
+		mode := host.EffectiveHTTPSMode()
+		routes, err := hostRoutes(&host, profile, access, domains, mode, storageDir, exploitRules, advanced)
+		if err != nil {
+			return nil, err
+		}
+		if host.Maintenance.Enabled {
+			page := DefaultMaintenancePage
+			if host.Maintenance.TemplateID != nil {
+				if page, err = templates.content(*host.Maintenance.TemplateID); err != nil {
+					return nil, fmt.Errorf("proxy host %s: maintenance: %w", host.UUID, err)
+				}
+			}
+			maintenance := maintenanceRoutes(domains, host.Maintenance, page, access)
+			if len(host.Maintenance.BypassIPs) == 0 && host.Maintenance.BypassCookie == "" {
+				// Nobody gets past the maintenance page
+				routes = nil
+			}
+			routes = append(maintenance, routes...)
+		}
+		if host.CrowdSec && crowdSec {
+			routes = append([]*Route{CrowdSecRoute(domains)}, routes...)
+			usesCrowdSec = true
+		}
+
+		switch mode {
+		case models.HTTPSModeRedirect:
+			httpsRoutes = append(httpsRoutes, routes...)
+			httpRoutes = append(httpRoutes, httpsRedirectRoute(domains))
+		case models.HTTPSModeHTTPS:
+			httpsRoutes = append(httpsRoutes, routes...)
+			httpRoutes = append(httpRoutes, routes...)
+		case models.HTTPSModeHTTPOnly:
+			httpRoutes = append(httpRoutes, routes...)
+			httpOnlyDomains = append(httpOnlyDomains, domains...)
+		}
+
+		errorRoutes, err := errorPageRoutes(domains, host.ErrorPages, templates)
+		if err != nil {
+			return nil, fmt.Errorf("proxy host %s: %w", host.UUID, err)
+		}
+		if limitRoute := LimitErrorRoute(domains, host.LimitResponse); limitRoute != nil {
+			errorRoutes = append([]*Route{limitRoute}, errorRoutes...)
+		}
+		if mode != models.HTTPSModeHTTPOnly {
+			httpsErrorRoutes = append(httpsErrorRoutes, errorRoutes...)
+		}
+		if mode != models.HTTPSModeRedirect {
+			httpErrorRoutes = append(httpErrorRoutes, errorRoutes...)
+		}
+
+		switch {
+		case mode == models.HTTPSModeHTTPOnly:
+		case host.CertificateID != nil:
+			if err := customCerts.load(*host.CertificateID); err != nil {
+				return nil, fmt.Errorf("proxy host %s: %w", host.UUID, err)
+			}
+			skipCertDomains = append(skipCertDomains, domains...)
+		case host.WildcardCert:
+			if host.DNSProvider == "" && host.CertIssuer != models.CertIssuerInternal {
+				return nil, fmt.Errorf("proxy host %s: wildcard certificates require a dns provider", host.UUID)
+			}
+			// Subdomains are served from the shared wildcard; apexes still get their own certificate
+			for _, domain := range domains {
+				if wildcard := WildcardFor(domain); wildcard != "" {
+					automation.add(host.CertIssuer, host.DNSProvider, wildcard)
+					automation.addWildcard(wildcard)
+					skipCertDomains = append(skipCertDomains, domain)
+				} else {
+					automation.add(host.CertIssuer, host.DNSProvider, domain)
+				}
 			}
-			routes = append(routes, locRoute)
+		default:
+			automation.add(host.CertIssuer, host.DNSProvider, domains...)
 		}
 
-		// Main proxy handler
-		dial := fmt.Sprintf("%s:%d", host.ForwardHost, host.ForwardPort)
-		mainHandlers := append(handlers, ReverseProxyHandler(dial, host.WebsocketSupport))
+		if !host.HTTP2Support && mode != models.HTTPSModeHTTPOnly {
+			http1Domains = append(http1Domains, domains...)
+		}
+	}
 
-		route := &Route{
-			Match: []Match{
-				{Host: domains},
+	for _, redirect := range opts.RedirectionHosts {
+		if !redirect.Enabled {
+			continue
+		}
+
+		route, err := redirectRoute(&redirect)
+		if err != nil {
+			return nil, err
+		}
+		httpsRoutes = append(httpsRoutes, route)
+		httpRoutes = append(httpRoutes, route)
+	}
+
+	// The default site goes last and matches no host, so automatic HTTPS never
+	// manages a certificate for it. No policy enables on-demand TLS either:
+	// handshakes for unknown SNI fail instead of requesting certificates for
+	// whatever name a client sends, and only plain HTTP reaches the default site.
+	if fallback := DefaultSiteRoute(opts.DefaultSite); fallback != nil {
+		httpsRoutes = append(httpsRoutes, fallback)
+		httpRoutes = append(httpRoutes, fallback)
+	}
+
+	if len(httpsRoutes) > 0 {
+		server := &Server{
+			Listen: []string{":443"},
+			Routes: httpsRoutes,
+			AutoHTTPS: &AutoHTTPSConfig{
+				// cpm_http carries explicit redirects for hosts that want them
+				DisableRedir: true,
+				SkipCerts:    skipCertDomains,
+			},
+			Protocols: opts.Protocols,
+			Logs: &ServerLogs{
+				DefaultLoggerName: "access_log",
 			},
-			Handle:   mainHandlers,
-			Terminal: true,
+			TrustedProxies: trustedProxiesConfig(trustedProxies),
+			Errors:         serverErrors(httpsErrorRoutes),
 		}
+		if len(http1Domains) > 0 && offersHTTP2(opts.Protocols) {
+			// Hosts without HTTP/2 negotiate HTTP/1.1 only; everyone else uses the defaults
+			server.TLSConnPolicies = []*TLSConnectionPolicy{
+				{Match: &TLSConnectionMatch{SNI: http1Domains}, ALPN: []string{"http/1.1"}},
+				{},
+			}
+		}
+		config.Apps.HTTP.Servers["cpm_server"] = server
+	}
 
-		routes = append(routes, route)
+	if len(httpRoutes) > 0 {
+		server := &Server{
+			Listen: []string{":80"},
+			Routes: httpRoutes,
+			AutoHTTPS: &AutoHTTPSConfig{
+				Skip: httpOnlyDomains,
+			},
+			Logs: &ServerLogs{
+				DefaultLoggerName: "access_log",
+			},
+			TrustedProxies: trustedProxiesConfig(trustedProxies),
+			Errors:         serverErrors(httpErrorRoutes),
+		}
+		if len(opts.Protocols) > 0 {
+			server.Protocols = []string{"h1"}
+			if slices.Contains(opts.Protocols, "h2c") {
+				server.Protocols = append(server.Protocols, "h2c")
+			}
+		}
+		config.Apps.HTTP.Servers["cpm_http"] = server
+	}
+
+	if usesCrowdSec {
+		config.Apps.CrowdSec = opts.CrowdSec
+	}
+
+	if len(customCerts.loaded) > 0 || len(automation.wildcards) > 0 {
+		if config.Apps.TLS == nil {
+			config.Apps.TLS = &TLSApp{}
+		}
+		if config.Apps.TLS.Certificates == nil {
+			config.Apps.TLS.Certificates = &CertificatesConfig{}
+		}
+		config.Apps.TLS.Certificates.LoadPEM = customCerts.loaded
+		// Wildcards are not a route host, so Caddy only manages them when asked to
+		config.Apps.TLS.Certificates.Automate = append(config.Apps.TLS.Certificates.Automate, automation.wildcards...)
 	}
 
-	config.Apps.HTTP.Servers["cpm_server"] = &Server{
-		Listen: []string{":80", ":443"},
-		Routes: routes,
-		AutoHTTPS: &AutoHTTPSConfig{
-			Disable:      false,
-			DisableRedir: false,
+	// Per-host issuer and DNS-01 policies name their subjects and must come before the catch-all policy
+	policies, err := automation.policies(acmeEmail, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) > 0 {
+		if config.Apps.TLS == nil {
+			config.Apps.TLS = &TLSApp{}
+		}
+		if config.Apps.TLS.Automation == nil {
+			config.Apps.TLS.Automation = &AutomationConfig{}
+		}
+		config.Apps.TLS.Automation.Policies = append(policies, config.Apps.TLS.Automation.Policies...)
+	}
+
+	return config, nil
+}
+
+// hasRateLimits reports whether the host or one of its locations has rate limits.
+func hasRateLimits(host *models.ProxyHost) bool {
+	if len(host.RateLimits) > 0 {
+		return true
+	}
+	for _, loc := range host.Locations {
+		if len(loc.RateLimits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// serverErrors wraps error routes, or returns nil when there are none.
+func serverErrors(routes []*Route) *ServerErrors {
+	if len(routes) == 0 {
+		return nil
+	}
+	return &ServerErrors{Routes: routes}
+}
+
+// locationRoute builds the route for a custom location: response headers and
+// access lists, optional rewrites, then a reverse proxy to the location's own upstream.
+func locationRoute(host *models.ProxyHost, loc *models.Location, profile *models.SecurityHeaderProfile, access *accessControl, domains []string, storageDir string) (*Route, error) {
+	match := Match{Host: domains}
+	if loc.PathRegexp != "" {
+		match.PathRegexp = &RegexpMatch{Name: "location", Pattern: loc.PathRegexp}
+	} else {
+		match.Path = []string{loc.Path, loc.Path + "/*"}
+	}
+
+	// Host response headers apply to locations too; their own request headers win
+	handlers := responseHeaderHandlers(host, profile)
+
+	// Location rate limits run before access lists so failed logins count too
+	if len(loc.RateLimits) > 0 {
+		handlers = append(handlers, RateLimitHandler("cpm_"+host.UUID+"_"+loc.UUID, loc.RateLimits))
+	}
+
+	// A location's own access lists replace the host's
+	accessIDs, satisfy := host.AccessListIDs, host.AccessSatisfy
+	if len(loc.AccessListIDs) > 0 {
+		accessIDs, satisfy = loc.AccessListIDs, loc.AccessSatisfy
+	}
+	gate, err := access.handler(accessIDs, satisfy)
+	if err != nil {
+		return nil, err
+	}
+	if gate != nil {
+		handlers = append(handlers, gate)
+	}
+
+	if maxBodySize := cmp.Or(loc.MaxBodySize, host.MaxBodySize); maxBodySize > 0 {
+		handlers = append(handlers, RequestBodyHandler(maxBodySize))
+	}
+
+	if loc.StripPathPrefix && loc.Path != "" {
+		handlers = append(handlers, Handler{"handler": "rewrite", "strip_path_prefix": loc.Path})
+	}
+	if loc.RewriteURI != "" {
+		// A separate handler: within one rewrite Caddy applies uri before strip_path_prefix
+		handlers = append(handlers, Handler{"handler": "rewrite", "uri": loc.RewriteURI})
+	}
+
+	dial := net.JoinHostPort(loc.ForwardHost, strconv.Itoa(loc.ForwardPort))
+	proxy := ReverseProxyHandler([]string{dial}, loc.Websocket(host))
+	ApplyRequestHeaderRules(proxy, host.HeaderRules)
+	SetRequestHeaders(proxy, loc.RequestHeaders)
+	if host.InterceptErrors {
+		InterceptErrors(proxy, host.ErrorPages)
+	}
+
+	// The host's upstream TLS settings belong to a different upstream
+	transport, err := HTTPTransport(loc.ForwardScheme, models.UpstreamTLSConfig{}, storageDir, host.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if transport != nil {
+		proxy["transport"] = transport
+	}
+
+	return &Route{
+		Match:    []Match{match},
+		Handle:   append(handlers, proxy),
+		Terminal: true,
+	}, nil
+}
+
+// certificateLoader collects each referenced custom certificate into load_pem once.
+type certificateLoader struct {
+	byID   map[uint]*models.SSLCertificate
+	seen   map[uint]bool
+	loaded []LoadPEMCertificate
+}
+
+func customCertificateLoader(certs []models.SSLCertificate) *certificateLoader {
+	l := &certificateLoader{byID: map[uint]*models.SSLCertificate{}, seen: map[uint]bool{}}
+	for i := range certs {
+		l.byID[certs[i].ID] = &certs[i]
+	}
+	return l
+}
+
+func (l *certificateLoader) load(id uint) error {
+	if l.seen[id] {
+		return nil
+	}
+
+	cert, ok := l.byID[id]
+	if !ok {
+		return fmt.Errorf("certificate %d not found", id)
+	}
+
+	pemChain := strings.TrimRight(cert.Certificate, "\n") + "\n"
+	if strings.TrimSpace(cert.Chain) != "" {
+		pemChain += strings.TrimRight(cert.Chain, "\n") + "\n"
+	}
+
+	l.seen[id] = true
+	l.loaded = append(l.loaded, LoadPEMCertificate{
+		Certificate: pemChain,
+		Key:         cert.PrivateKey,
+		Tags:        []string{"cpm_cert_" + cert.UUID},
+	})
+	return nil
+}
+
+// hostRoutes builds the routes serving one proxy host: rate limits and exploit
+// blocking first, then custom locations, then the catch-all proxy route. The
+// advanced handler, if any, runs in the catch-all route just before the proxy.
+func hostRoutes(host *models.ProxyHost, profile *models.SecurityHeaderProfile, access *accessControl, domains []string, mode, storageDir string, exploitRules ExploitRules, advanced Handler) ([]*Route, error) {
+	routes := make([]*Route, 0)
+
+	// Build handlers for this host
+	handlers := make([]Handler, 0)
+
+	// Add HSTS header if enabled; browsers ignore it on plain HTTP
+	if host.HSTSEnabled && mode != models.HTTPSModeHTTPOnly {
+		handlers = append(handlers, HeaderHandler(map[string][]string{
+			"Strict-Transport-Security": {hstsValue(host)},
+		}))
+	}
+
+	handlers = append(handlers, responseHeaderHandlers(host, profile)...)
+
+	// Access lists run after the header handlers so refusals carry them too
+	gate, err := access.handler(host.AccessListIDs, host.AccessSatisfy)
+	if err != nil {
+		return nil, fmt.Errorf("proxy host %s: %w", host.UUID, err)
+	}
+	if gate != nil {
+		handlers = append(handlers, gate)
+	}
+	if host.MaxBodySize > 0 {
+		handlers = append(handlers, RequestBodyHandler(host.MaxBodySize))
+	}
+	if advanced != nil {
+		handlers = append(handlers, advanced)
+	}
+
+	// Host rate limits count every request, whichever location serves it
+	if len(host.RateLimits) > 0 {
+		routes = append(routes, &Route{
+			Match:  []Match{{Host: domains}},
+			Handle: []Handler{RateLimitHandler("cpm_"+host.UUID, host.RateLimits)},
+		})
+	}
+
+	// Reject exploit probes before any location or proxy route sees them
+	if host.BlockExploits {
+		blockRoute, err := BlockExploitsRoute(domains, exploitRules)
+		if err != nil {
+			return nil, fmt.Errorf("proxy host %s: %w", host.UUID, err)
+		}
+		if blockRoute != nil {
+			routes = append(routes, blockRoute)
+		}
+	}
+
+	// Handle custom locations first (more specific routes)
+	for i := range host.Locations {
+		locRoute, err := locationRoute(host, &host.Locations[i], profile, access, domains, storageDir)
+		if err != nil {
+			return nil, fmt.Errorf("proxy host %s location %s: %w", host.UUID, host.Locations[i].Path, err)
+		}
+		routes = append(routes, locRoute)
+	}
+
+	// Main proxy handler
+	proxyHandler, err := hostReverseProxy(host, storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("proxy host %s: %w", host.UUID, err)
+	}
+	ApplyRequestHeaderRules(proxyHandler, host.HeaderRules)
+	if host.InterceptErrors {
+		InterceptErrors(proxyHandler, host.ErrorPages)
+	}
+
+	routes = append(routes, &Route{
+		Match: []Match{
+			{Host: domains},
+		},
+		Handle:   append(handlers, proxyHandler),
+		Terminal: true,
+	})
+
+	return routes, nil
+}
+
+// hstsValue returns the Strict-Transport-Security header of a host.
+func hstsValue(host *models.ProxyHost) string {
+	value := "max-age=31536000"
+	if host.HSTSSubdomains {
+		value += "; includeSubDomains"
+	}
+	return value
+}
+
+// responseHeaderHandlers returns the deferred response header handlers of a host.
+// Deferred operations run in reverse order, so the host's own rules come first
+// to override its security profile.
+func responseHeaderHandlers(host *models.ProxyHost, profile *models.SecurityHeaderProfile) []Handler {
+	var handlers []Handler
+	if h := ResponseHeaderRulesHandler(host.HeaderRules); h != nil {
+		handlers = append(handlers, h)
+	}
+	if h := SecurityHeadersHandler(profile, host.CSPOverride); h != nil {
+		handlers = append(handlers, h)
+	}
+	return handlers
+}
+
+// httpsRedirectRoute sends plain HTTP requests for domains to the same URL over HTTPS.
+func httpsRedirectRoute(domains []string) *Route {
+	return &Route{
+		Match: []Match{
+			{Host: domains},
 		},
-		Logs: &ServerLogs{
-			DefaultLoggerName: "access_log",
+		Handle: []Handler{
+			StaticResponseHandler(308, "", map[string][]string{
+				"Location": {"https://{http.request.host}{http.request.uri}"},
+			}),
 		},
+		Terminal: true,
+	}
+}
+
+// offersHTTP2 reports whether a server with the given protocols list negotiates HTTP/2 over TLS.
+func offersHTTP2(protocols []string) bool {
+	return len(protocols) == 0 || slices.Contains(protocols, "h2")
+}
+
+// redirectRoute answers every request to a redirection host with a redirect
+// to its forward domain, optionally keeping the original path and query.
+func redirectRoute(host *models.RedirectionHost) (*Route, error) {
+	if host.DomainNames == "" {
+		return nil, fmt.Errorf("redirection host %s has empty domain names", host.UUID)
 	}
 
-	return config, nil
+	domains := strings.Split(host.DomainNames, ",")
+	for i := range domains {
+		domains[i] = strings.TrimSpace(domains[i])
+	}
+
+	scheme := host.ForwardScheme
+	if scheme == "" || scheme == "auto" {
+		scheme = "{http.request.scheme}"
+	}
+	target := scheme + "://" + host.ForwardDomain
+	if host.PreservePath {
+		target += "{http.request.uri}"
+	}
+
+	status := host.StatusCode
+	if status == 0 {
+		status = 301
+	}
+
+	return &Route{
+		Match: []Match{
+			{Host: domains},
+		},
+		Handle: []Handler{
+			StaticResponseHandler(status, "", map[string][]string{"Location": {target}}),
+		},
+		Terminal: true,
+	}, nil
+}
+
+// hostReverseProxy builds the reverse_proxy handler for a host's upstream set,
+// adding a load balancing policy when there is more than one upstream and a TLS
+// transport for https upstreams.
+func hostReverseProxy(host *models.ProxyHost, storageDir string) (Handler, error) {
+	upstreams := host.EffectiveUpstreams()
+	dials := make([]string, 0, len(upstreams))
+	weights := make([]int, 0, len(upstreams))
+	for _, u := range upstreams {
+		dials = append(dials, net.JoinHostPort(u.Host, strconv.Itoa(u.Port)))
+		weights = append(weights, u.Weight)
+	}
+
+	handler := ReverseProxyHandler(dials, host.WebsocketSupport)
+	if len(dials) > 1 {
+		handler["load_balancing"] = LoadBalancingConfig(host.LoadBalancing, weights)
+	}
+	if checks := healthChecks(host.HealthCheck); checks != nil {
+		handler["health_checks"] = checks
+	}
+
+	transport, err := HTTPTransport(host.ForwardScheme, host.UpstreamTLS, storageDir, host.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if transport != nil {
+		handler["transport"] = transport
+	}
+
+	return handler, nil
+}
+
+// healthChecks renders the reverse_proxy health_checks block, or nil when no checks are enabled.
+func healthChecks(hc models.HealthCheckConfig) map[string]interface{} {
+	checks := map[string]interface{}{}
+
+	if hc.ActiveEnabled {
+		active := map[string]interface{}{
+			"uri":      hc.ActivePath,
+			"interval": seconds(hc.ActiveInterval, 30),
+			"timeout":  seconds(hc.ActiveTimeout, 5),
+		}
+		if hc.ExpectStatus > 0 {
+			active["expect_status"] = hc.ExpectStatus
+		}
+		checks["active"] = active
+	}
+
+	if hc.PassiveEnabled {
+		passive := map[string]interface{}{
+			"fail_duration": seconds(hc.FailDuration, 30),
+			"max_fails":     max(hc.MaxFails, 1),
+		}
+		if len(hc.UnhealthyStatus) > 0 {
+			passive["unhealthy_status"] = hc.UnhealthyStatus
+		}
+		checks["passive"] = passive
+	}
+
+	if len(checks) == 0 {
+		return nil
+	}
+	return checks
+}
+
+// seconds formats a duration for Caddy JSON, substituting fallback for non-positive values.
+func seconds(value, fallback int) string {
+	if value <= 0 {
+		value = fallback
+	}
+	return fmt.Sprintf("%ds", value)
 }
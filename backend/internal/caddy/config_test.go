@@ -9,7 +9,7 @@ import (
 )
 
 func TestGenerateConfig_Empty(t *testing.T) {
-	config, err := GenerateConfig([]models.ProxyHost{}, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig([]models.ProxyHost{}, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 	require.NotNil(t, config)
 	require.NotNil(t, config.Apps.HTTP)
@@ -31,18 +31,24 @@ func TestGenerateConfig_SingleHost(t *testing.T) {
 		},
 	}
 
-	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 	require.NotNil(t, config)
 	require.NotNil(t, config.Apps.HTTP)
-	require.Len(t, config.Apps.HTTP.Servers, 1)
+	require.Len(t, config.Apps.HTTP.Servers, 2)
 
 	server := config.Apps.HTTP.Servers["cpm_server"]
 	require.NotNil(t, server)
-	require.Contains(t, server.Listen, ":80")
-	require.Contains(t, server.Listen, ":443")
+	require.Equal(t, []string{":443"}, server.Listen)
 	require.Len(t, server.Routes, 1)
 
+	// SSLForced redirects plain HTTP
+	httpServer := config.Apps.HTTP.Servers["cpm_http"]
+	require.NotNil(t, httpServer)
+	require.Equal(t, []string{":80"}, httpServer.Listen)
+	require.Len(t, httpServer.Routes, 1)
+	require.Equal(t, 308, httpServer.Routes[0].Handle[0]["status_code"])
+
 	route := server.Routes[0]
 	require.Len(t, route.Match, 1)
 	require.Equal(t, []string{"media.example.com"}, route.Match[0].Host)
@@ -71,7 +77,7 @@ func TestGenerateConfig_MultipleHosts(t *testing.T) {
 		},
 	}
 
-	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 	require.Len(t, config.Apps.HTTP.Servers["cpm_server"].Routes, 2)
 }
@@ -88,7 +94,7 @@ func TestGenerateConfig_WebSocketEnabled(t *testing.T) {
 		},
 	}
 
-	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 
 	route := config.Apps.HTTP.Servers["cpm_server"].Routes[0]
@@ -109,14 +115,14 @@ func TestGenerateConfig_EmptyDomain(t *testing.T) {
 		},
 	}
 
-	_, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	_, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "empty domain")
 }
 
 func TestGenerateConfig_Logging(t *testing.T) {
 	hosts := []models.ProxyHost{}
-	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 
 	// Verify logging config
@@ -157,38 +163,492 @@ func TestGenerateConfig_Advanced(t *testing.T) {
 		},
 	}
 
-	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	require.NoError(t, err)
 	require.NotNil(t, config)
 
 	server := config.Apps.HTTP.Servers["cpm_server"]
 	require.NotNil(t, server)
-	// Should have 2 routes: 1 for location /api, 1 for main domain
-	require.Len(t, server.Routes, 2)
+	// Should have 3 routes: exploit block, location /api, main domain
+	require.Len(t, server.Routes, 3)
+
+	// Exploit blocking comes first so it also protects custom locations
+	blockRoute := server.Routes[0]
+	require.Equal(t, "static_response", blockRoute.Handle[0]["handler"])
+	require.Equal(t, 403, blockRoute.Handle[0]["status_code"])
 
-	// Check Location Route (should be first as it is more specific)
-	locRoute := server.Routes[0]
+	// Check Location Route (more specific than the main route)
+	locRoute := server.Routes[1]
 	require.Equal(t, []string{"/api", "/api/*"}, locRoute.Match[0].Path)
 	require.Equal(t, []string{"advanced.example.com"}, locRoute.Match[0].Host)
 
 	// Check Main Route
-	mainRoute := server.Routes[1]
+	mainRoute := server.Routes[2]
 	require.Nil(t, mainRoute.Match[0].Path) // No path means all paths
 	require.Equal(t, []string{"advanced.example.com"}, mainRoute.Match[0].Host)
 
-	// Check HSTS and BlockExploits handlers in main route
-	// Handlers are: [HSTS, BlockExploits, ReverseProxy]
-	// But wait, BlockExploitsHandler implementation details?
-	// Let's just check count for now or inspect types if possible.
-	// Based on code:
-	// handlers = append(handlers, HeaderHandler(...)) // HSTS
-	// handlers = append(handlers, BlockExploitsHandler()) // BlockExploits
-	// mainHandlers = append(handlers, ReverseProxyHandler(...))
-
-	require.Len(t, mainRoute.Handle, 3)
+	// Handlers are: [HSTS, ReverseProxy]
+	require.Len(t, mainRoute.Handle, 2)
 
 	// Check HSTS
 	hstsHandler := mainRoute.Handle[0]
 	require.Equal(t, "headers", hstsHandler["handler"])
-	// We can't easily check the map content without casting, but we know it's there.
+}
+
+func TestGenerateConfig_Locations(t *testing.T) {
+	disabled := false
+	hosts := []models.ProxyHost{
+		{
+			UUID:             "locations-uuid",
+			DomainNames:      "app.example.com",
+			ForwardScheme:    "http",
+			ForwardHost:      "app",
+			ForwardPort:      8080,
+			WebsocketSupport: true,
+			Enabled:          true,
+			Locations: []models.Location{
+				{
+					Path:            "/api",
+					ForwardScheme:   "https",
+					ForwardHost:     "api",
+					ForwardPort:     8443,
+					StripPathPrefix: true,
+					RewriteURI:      "/v2{http.request.uri}",
+					RequestHeaders:  map[string]string{"X-Api-Gateway": "cpm"},
+				},
+				{
+					PathRegexp:       `^/static/.*\.(css|js)$`,
+					ForwardHost:      "cdn",
+					ForwardPort:      80,
+					WebsocketSupport: &disabled,
+				},
+			},
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 3)
+
+	// Prefix location: strip, then rewrite, then an https upstream with its own headers
+	api := routes[0]
+	require.Equal(t, []string{"/api", "/api/*"}, api.Match[0].Path)
+	require.Len(t, api.Handle, 3)
+	require.Equal(t, Handler{"handler": "rewrite", "strip_path_prefix": "/api"}, api.Handle[0])
+	require.Equal(t, Handler{"handler": "rewrite", "uri": "/v2{http.request.uri}"}, api.Handle[1])
+
+	proxy := api.Handle[2]
+	require.Equal(t, "reverse_proxy", proxy["handler"])
+	require.NotNil(t, proxy["transport"])
+	set := proxy["headers"].(map[string]interface{})["request"].(map[string]interface{})["set"].(map[string][]string)
+	require.Equal(t, []string{"cpm"}, set["X-Api-Gateway"])
+	require.Contains(t, set, "Upgrade") // inherited from the host
+
+	// Regexp location overrides the host's websocket setting
+	static := routes[1]
+	require.Nil(t, static.Match[0].Path)
+	require.Equal(t, &RegexpMatch{Name: "location", Pattern: `^/static/.*\.(css|js)$`}, static.Match[0].PathRegexp)
+	require.Len(t, static.Handle, 1)
+	require.Nil(t, static.Handle[0]["headers"])
+	require.Nil(t, static.Handle[0]["transport"])
+}
+
+func TestGenerateConfig_HeaderRules(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{
+			UUID:             "headers-uuid",
+			DomainNames:      "headers.example.com",
+			ForwardHost:      "app",
+			ForwardPort:      8080,
+			WebsocketSupport: true,
+			Enabled:          true,
+			HeaderRules: []models.HeaderRule{
+				{Direction: models.HeaderRuleRequest, Operation: models.HeaderOpSet, Name: "X-Real-IP", Value: "{http.request.remote.host}"},
+				{Direction: models.HeaderRuleRequest, Operation: models.HeaderOpAdd, Name: "X-Tag", Value: "a"},
+				{Direction: models.HeaderRuleRequest, Operation: models.HeaderOpAdd, Name: "X-Tag", Value: "b"},
+				{Direction: models.HeaderRuleRequest, Operation: models.HeaderOpDelete, Name: "Cookie"},
+				{Direction: models.HeaderRuleResponse, Operation: models.HeaderOpDelete, Name: "Server"},
+				{Direction: models.HeaderRuleResponse, Operation: models.HeaderOpSet, Name: "X-Frame-Options", Value: "DENY"},
+			},
+			Locations: []models.Location{
+				{Path: "/api", ForwardHost: "api", ForwardPort: 9000, RequestHeaders: map[string]string{"X-Real-IP": "location"}},
+			},
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 2)
+
+	wantResponse := Handler{
+		"handler": "headers",
+		"response": map[string]interface{}{
+			"set":      map[string][]string{"X-Frame-Options": {"DENY"}},
+			"delete":   []string{"Server"},
+			"deferred": true,
+		},
+	}
+
+	// Main route: response rules in a headers handler, request rules on the proxy
+	main := routes[1]
+	require.Len(t, main.Handle, 2)
+	require.Equal(t, wantResponse, main.Handle[0])
+
+	request := main.Handle[1]["headers"].(map[string]interface{})["request"].(map[string]interface{})
+	set := request["set"].(map[string][]string)
+	require.Equal(t, []string{"{http.request.remote.host}"}, set["X-Real-IP"])
+	require.Contains(t, set, "Upgrade") // websocket headers are kept
+	require.Equal(t, map[string][]string{"X-Tag": {"a", "b"}}, request["add"])
+	require.Equal(t, []string{"Cookie"}, request["delete"])
+
+	// Locations inherit the rules; their own request headers win
+	loc := routes[0]
+	require.Len(t, loc.Handle, 2)
+	require.Equal(t, wantResponse, loc.Handle[0])
+	locSet := loc.Handle[1]["headers"].(map[string]interface{})["request"].(map[string]interface{})["set"].(map[string][]string)
+	require.Equal(t, []string{"location"}, locSet["X-Real-IP"])
+}
+
+func TestGenerateConfig_SecurityHeaders(t *testing.T) {
+	profileID := uint(7)
+	profile := models.SecurityHeaderProfile{ID: profileID, Name: "Strict", Preset: models.SecurityPresetStrict}
+	profile.ApplyPreset()
+
+	hosts := []models.ProxyHost{
+		{
+			UUID:                    "secure-uuid",
+			DomainNames:             "secure.example.com",
+			ForwardHost:             "app",
+			ForwardPort:             8080,
+			Enabled:                 true,
+			SecurityHeaderProfileID: &profileID,
+			CSPOverride:             "default-src 'self' cdn.example.com",
+			HeaderRules: []models.HeaderRule{
+				{Direction: models.HeaderRuleResponse, Operation: models.HeaderOpSet, Name: "X-Frame-Options", Value: "SAMEORIGIN"},
+			},
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{SecurityHeaderProfiles: []models.SecurityHeaderProfile{profile}})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	route := config.Apps.HTTP.Servers["cpm_server"].Routes[0]
+	require.Len(t, route.Handle, 3)
+
+	// Host rules come first: deferred handlers apply in reverse, so they win
+	require.Equal(t, "headers", route.Handle[0]["handler"])
+	require.Equal(t, map[string][]string{"X-Frame-Options": {"SAMEORIGIN"}}, route.Handle[0]["response"].(map[string]interface{})["set"])
+
+	security := route.Handle[1]["response"].(map[string]interface{})
+	require.Equal(t, true, security["deferred"])
+	require.Equal(t, []string{"Server"}, security["delete"])
+	set := security["set"].(map[string][]string)
+	require.Equal(t, []string{"default-src 'self' cdn.example.com"}, set["Content-Security-Policy"])
+	require.Equal(t, []string{"DENY"}, set["X-Frame-Options"])
+	require.Equal(t, []string{"nosniff"}, set["X-Content-Type-Options"])
+	require.Equal(t, []string{"no-referrer"}, set["Referrer-Policy"])
+
+	effective := EffectiveResponseHeaders(&hosts[0], &profile)
+	require.Equal(t, "SAMEORIGIN", effective["X-Frame-Options"])
+	require.Equal(t, "default-src 'self' cdn.example.com", effective["Content-Security-Policy"])
+	require.Contains(t, effective, "Server")
+
+	// A profile that no longer exists is an error rather than a silently unprotected host
+	_, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.ErrorContains(t, err, "security header profile 7 not found")
+}
+
+func TestGenerateConfig_LoadBalancing(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		upstreams []models.Upstream
+		want      map[string]interface{}
+	}{
+		{
+			name:      "round robin",
+			policy:    "round_robin",
+			upstreams: []models.Upstream{{Host: "a", Port: 80}, {Host: "b", Port: 80}},
+			want:      map[string]interface{}{"policy": "round_robin"},
+		},
+		{
+			name:      "weighted",
+			policy:    "round_robin",
+			upstreams: []models.Upstream{{Host: "a", Port: 80, Weight: 3}, {Host: "b", Port: 80}},
+			want:      map[string]interface{}{"policy": "weighted_round_robin", "weights": []int{3, 1}},
+		},
+		{
+			name:      "cookie sticky sessions",
+			policy:    "cookie",
+			upstreams: []models.Upstream{{Host: "a", Port: 80}, {Host: "b", Port: 80}},
+			want:      map[string]interface{}{"policy": "cookie", "name": "cpm_lb"},
+		},
+		{
+			name:      "least conn",
+			policy:    "least_conn",
+			upstreams: []models.Upstream{{Host: "a", Port: 80}, {Host: "b", Port: 80}},
+			want:      map[string]interface{}{"policy": "least_conn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts := []models.ProxyHost{{
+				UUID:          "lb",
+				DomainNames:   "lb.example.com",
+				ForwardHost:   "a",
+				ForwardPort:   80,
+				Upstreams:     tt.upstreams,
+				LoadBalancing: tt.policy,
+				Enabled:       true,
+			}}
+
+			config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+			require.NoError(t, err)
+			require.NoError(t, Validate(config))
+
+			handler := config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0]
+			require.Len(t, handler["upstreams"], len(tt.upstreams))
+			lb := handler["load_balancing"].(map[string]interface{})
+			require.Equal(t, tt.want, lb["selection_policy"])
+		})
+	}
+}
+
+func TestGenerateConfig_SingleUpstreamHasNoLoadBalancing(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "single",
+		DomainNames: "single.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+
+	handler := config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0]
+	require.Equal(t, []map[string]interface{}{{"dial": "app:8080"}}, handler["upstreams"])
+	require.NotContains(t, handler, "load_balancing")
+}
+
+func TestGenerateConfig_HealthChecks(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "hc",
+		DomainNames: "hc.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+		HealthCheck: models.HealthCheckConfig{
+			ActiveEnabled:   true,
+			ActivePath:      "/healthz",
+			ActiveInterval:  10,
+			ExpectStatus:    200,
+			PassiveEnabled:  true,
+			MaxFails:        3,
+			UnhealthyStatus: []int{502, 503},
+		},
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	handler := config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0]
+	require.Equal(t, map[string]interface{}{
+		"active": map[string]interface{}{
+			"uri":           "/healthz",
+			"interval":      "10s",
+			"timeout":       "5s",
+			"expect_status": 200,
+		},
+		"passive": map[string]interface{}{
+			"fail_duration":    "30s",
+			"max_fails":        3,
+			"unhealthy_status": []int{502, 503},
+		},
+	}, handler["health_checks"])
+}
+
+func TestGenerateConfig_NoHealthChecksByDefault(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "plain",
+		DomainNames: "plain.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0], "health_checks")
+}
+
+func TestGenerateConfig_RedirectionHosts(t *testing.T) {
+	opts := ConfigOptions{
+		RedirectionHosts: []models.RedirectionHost{
+			{
+				UUID:          "keep-path",
+				DomainNames:   "old.example.com, www.old.example.com",
+				ForwardScheme: "https",
+				ForwardDomain: "new.example.com",
+				StatusCode:    308,
+				PreservePath:  true,
+				Enabled:       true,
+			},
+			{
+				UUID:          "auto-scheme",
+				DomainNames:   "legacy.example.com",
+				ForwardScheme: "auto",
+				ForwardDomain: "example.com",
+				StatusCode:    302,
+				Enabled:       true,
+			},
+			{
+				UUID:          "disabled",
+				DomainNames:   "off.example.com",
+				ForwardDomain: "example.com",
+				Enabled:       false,
+			},
+		},
+	}
+
+	config, err := GenerateConfig(nil, "/tmp/caddy-data", "", opts)
+	require.NoError(t, err)
+
+	server := config.Apps.HTTP.Servers["cpm_server"]
+	require.NotNil(t, server)
+	require.Len(t, server.Routes, 2)
+
+	first := server.Routes[0]
+	require.Equal(t, []string{"old.example.com", "www.old.example.com"}, first.Match[0].Host)
+	require.True(t, first.Terminal)
+	require.Equal(t, Handler{
+		"handler":     "static_response",
+		"status_code": 308,
+		"headers":     map[string][]string{"Location": {"https://new.example.com{http.request.uri}"}},
+	}, first.Handle[0])
+
+	second := server.Routes[1]
+	require.Equal(t, map[string][]string{"Location": {"{http.request.scheme}://example.com"}}, second.Handle[0]["headers"])
+	require.Equal(t, 302, second.Handle[0]["status_code"])
+
+	require.NoError(t, Validate(config))
+}
+
+func TestGenerateConfig_HTTPSModes(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "redirect", DomainNames: "secure.example.com", HTTPSMode: models.HTTPSModeRedirect, HSTSEnabled: true, ForwardHost: "a", ForwardPort: 80, HTTP2Support: true, Enabled: true},
+		{UUID: "both", DomainNames: "both.example.com", HTTPSMode: models.HTTPSModeHTTPS, ForwardHost: "b", ForwardPort: 80, HTTP2Support: true, Enabled: true},
+		{UUID: "lan", DomainNames: "nas.lan", HTTPSMode: models.HTTPSModeHTTPOnly, HSTSEnabled: true, ForwardHost: "c", ForwardPort: 80, HTTP2Support: true, Enabled: true},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	hostsIn := func(server *Server) []string {
+		var names []string
+		for _, r := range server.Routes {
+			names = append(names, r.Match[0].Host...)
+		}
+		return names
+	}
+
+	https := config.Apps.HTTP.Servers["cpm_server"]
+	require.Equal(t, []string{":443"}, https.Listen)
+	require.True(t, https.AutoHTTPS.DisableRedir)
+	require.Equal(t, []string{"secure.example.com", "both.example.com"}, hostsIn(https))
+	require.Empty(t, https.TLSConnPolicies)
+
+	plain := config.Apps.HTTP.Servers["cpm_http"]
+	require.Equal(t, []string{":80"}, plain.Listen)
+	require.Equal(t, []string{"secure.example.com", "both.example.com", "nas.lan"}, hostsIn(plain))
+	require.Equal(t, []string{"nas.lan"}, plain.AutoHTTPS.Skip)
+
+	// Redirect host: plain HTTP only redirects
+	require.Equal(t, Handler{
+		"handler":     "static_response",
+		"status_code": 308,
+		"headers":     map[string][]string{"Location": {"https://{http.request.host}{http.request.uri}"}},
+	}, plain.Routes[0].Handle[0])
+
+	// Both-mode host proxies on plain HTTP too
+	require.Equal(t, "reverse_proxy", plain.Routes[1].Handle[0]["handler"])
+
+	// HTTP-only hosts never send HSTS
+	require.Len(t, plain.Routes[2].Handle, 1)
+	require.Equal(t, "reverse_proxy", plain.Routes[2].Handle[0]["handler"])
+}
+
+func TestGenerateConfig_HTTP2AndProtocols(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "legacy", DomainNames: "legacy.example.com", ForwardHost: "a", ForwardPort: 80, HTTP2Support: false, Enabled: true},
+		{UUID: "modern", DomainNames: "modern.example.com", ForwardHost: "b", ForwardPort: 80, HTTP2Support: true, Enabled: true},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{Protocols: []string{"h1", "h2", "h2c"}})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	https := config.Apps.HTTP.Servers["cpm_server"]
+	require.Equal(t, []string{"h1", "h2", "h2c"}, https.Protocols)
+	require.Equal(t, []*TLSConnectionPolicy{
+		{Match: &TLSConnectionMatch{SNI: []string{"legacy.example.com"}}, ALPN: []string{"http/1.1"}},
+		{},
+	}, https.TLSConnPolicies)
+	require.Equal(t, []string{"h1", "h2c"}, config.Apps.HTTP.Servers["cpm_http"].Protocols)
+
+	// Without h2 on offer there is nothing to opt out of
+	config, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{Protocols: []string{"h1"}})
+	require.NoError(t, err)
+	require.Empty(t, config.Apps.HTTP.Servers["cpm_server"].TLSConnPolicies)
+}
+
+func TestGenerateConfig_CustomCertificates(t *testing.T) {
+	certID := uint(7)
+	hosts := []models.ProxyHost{
+		{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, CertificateID: &certID, Enabled: true},
+		{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, CertificateID: &certID, Enabled: true},
+		{UUID: "c", DomainNames: "c.example.com", ForwardHost: "c", ForwardPort: 80, Enabled: true},
+	}
+	certs := []models.SSLCertificate{
+		{ID: certID, UUID: "wild", Certificate: "LEAF\n", Chain: "INTERMEDIATE\n", PrivateKey: "KEY"},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{Certificates: certs})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	// Shared certificates are loaded once
+	require.Equal(t, []LoadPEMCertificate{
+		{Certificate: "LEAF\nINTERMEDIATE\n", Key: "KEY", Tags: []string{"cpm_cert_wild"}},
+	}, config.Apps.TLS.Certificates.LoadPEM)
+	require.Equal(t, []string{"a.example.com", "b.example.com"}, config.Apps.HTTP.Servers["cpm_server"].AutoHTTPS.SkipCerts)
+
+	_, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.ErrorContains(t, err, "certificate 7 not found")
+}
+
+func TestParseProtocols(t *testing.T) {
+	protocols, err := ParseProtocols("")
+	require.NoError(t, err)
+	require.Nil(t, protocols)
+
+	protocols, err = ParseProtocols(" H1, h2 ,h3,h2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"h1", "h2", "h3"}, protocols)
+
+	_, err = ParseProtocols("h1,spdy")
+	require.ErrorContains(t, err, "unknown protocol")
+
+	_, err = ParseProtocols("h3")
+	require.ErrorContains(t, err, "h1 or h2")
 }
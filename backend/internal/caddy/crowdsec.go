@@ -0,0 +1,74 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CrowdSec settings. The bouncer key lets Caddy and CPM read decisions from the
+// Local API (LAPI); the machine credentials let CPM add and remove decisions.
+// The key and the credentials are secret settings.
+const (
+	CrowdSecLAPIURLSettingKey    = "caddy.crowdsec_lapi_url"    // e.g. http://crowdsec:8080
+	CrowdSecBouncerKeySettingKey = "caddy.crowdsec_bouncer_key" // From `cscli bouncers add cpm`
+	CrowdSecMachineSettingKey    = "crowdsec.machine"           // JSON {"machine_id", "password"} from `cscli machines add cpm`
+)
+
+// CrowdSecApp configures the crowdsec app of the bouncer module, which keeps the
+// decisions the http.handlers.crowdsec handler enforces.
+type CrowdSecApp struct {
+	APIURL         string `json:"api_url"`
+	APIKey         string `json:"api_key"`
+	TickerInterval string `json:"ticker_interval,omitempty"`
+}
+
+// CrowdSecMachine holds the credentials of a CrowdSec machine (watcher).
+type CrowdSecMachine struct {
+	MachineID string `json:"machine_id"`
+	Password  string `json:"password"`
+}
+
+// ParseCrowdSecLAPIURL checks the LAPI URL setting and returns it without a
+// trailing slash.
+func ParseCrowdSecLAPIURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid crowdsec lapi url %q (expected e.g. http://crowdsec:8080)", raw)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("crowdsec lapi url must not contain credentials, a query or a fragment")
+	}
+	return strings.TrimSuffix(raw, "/"), nil
+}
+
+// ParseCrowdSecMachine decodes the machine credentials setting.
+func ParseCrowdSecMachine(raw string) (*CrowdSecMachine, error) {
+	var machine CrowdSecMachine
+	if err := json.Unmarshal([]byte(raw), &machine); err != nil {
+		return nil, fmt.Errorf("parse crowdsec machine: %w", err)
+	}
+	if strings.TrimSpace(machine.MachineID) == "" || machine.Password == "" {
+		return nil, errors.New("crowdsec machine requires machine_id and password")
+	}
+	return &machine, nil
+}
+
+// CrowdSecRoute checks clients of domains against the current decisions before
+// any other route of the host; banned clients get 403, everyone else continues.
+func CrowdSecRoute(domains []string) *Route {
+	return &Route{
+		Match: []Match{
+			{Host: domains},
+		},
+		Handle: []Handler{
+			{"handler": "crowdsec"},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestListModules(t *testing.T) {
+	output := "admin.api.load\nhttp.handlers.reverse_proxy\n\n  Standard modules: 2\n\nhttp.handlers.crowdsec\nlayer4\n\n  Non-standard modules: 2\n\n  Unknown modules: 0\n"
+	modules, err := ListModules(&MockExecutor{Output: []byte(output)}, "caddy")
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin.api.load", "http.handlers.reverse_proxy", "http.handlers.crowdsec", "layer4"}, modules)
+
+	_, err = ListModules(&MockExecutor{Err: assert.AnError}, "caddy")
+	require.Error(t, err)
+}
+
+func TestParseCrowdSecSettings(t *testing.T) {
+	apiURL, err := ParseCrowdSecLAPIURL(" http://crowdsec:8080/ ")
+	require.NoError(t, err)
+	require.Equal(t, "http://crowdsec:8080", apiURL)
+
+	for _, raw := range []string{"crowdsec:8080", "ftp://crowdsec", "http://user:pw@crowdsec:8080"} {
+		_, err := ParseCrowdSecLAPIURL(raw)
+		require.Error(t, err, raw)
+	}
+
+	machine, err := ParseCrowdSecMachine(`{"machine_id": "cpm", "password": "secret"}`)
+	require.NoError(t, err)
+	require.Equal(t, "cpm", machine.MachineID)
+	_, err = ParseCrowdSecMachine(`{"machine_id": "cpm"}`)
+	require.ErrorContains(t, err, "requires machine_id and password")
+}
+
+func TestGenerateConfig_CrowdSec(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "shop", DomainNames: "shop.example.com", ForwardHost: "shop", ForwardPort: 80, Enabled: true, CrowdSec: true},
+		{UUID: "wiki", DomainNames: "wiki.example.com", ForwardHost: "wiki", ForwardPort: 80, Enabled: true},
+	}
+	crowdSec := &CrowdSecApp{APIURL: "http://crowdsec:8080/", APIKey: "bouncer-key", TickerInterval: "15s"}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{CrowdSec: crowdSec, Modules: []string{CrowdSecModule}})
+	require.NoError(t, err)
+	require.Equal(t, crowdSec, config.Apps.CrowdSec)
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 3)
+	require.Equal(t, []string{"shop.example.com"}, routes[0].Match[0].Host)
+	require.Equal(t, Handler{"handler": "crowdsec"}, routes[0].Handle[0])
+	require.False(t, routes[0].Terminal)
+	for _, route := range routes[1:] {
+		require.NotEqual(t, "crowdsec", route.Handle[0]["handler"])
+	}
+	require.NoError(t, Validate(config, CrowdSecModule))
+
+	// Without the bouncer in the Caddy build the hosts are served unprotected
+	config, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{CrowdSec: crowdSec})
+	require.NoError(t, err)
+	require.Nil(t, config.Apps.CrowdSec)
+	require.Len(t, config.Apps.HTTP.Servers["cpm_server"].Routes, 2)
+
+	// Hosts with CrowdSec disabled leave the app out
+	hosts[0].CrowdSec = false
+	config, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{CrowdSec: crowdSec, Modules: []string{CrowdSecModule}})
+	require.NoError(t, err)
+	require.Nil(t, config.Apps.CrowdSec)
+}
@@ -0,0 +1,111 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultSiteSettingKey holds the JSON DefaultSite answering requests for
+// hostnames no host serves, and requests for bare IP addresses.
+const DefaultSiteSettingKey = "caddy.default_site"
+
+// Modes of the DefaultSite.
+const (
+	DefaultSiteCongratulations = "congratulations" // Built-in page saying CPM is running
+	DefaultSiteNotFound        = "404"
+	DefaultSiteClose           = "close"    // Drop the connection without a response, like nginx's 444
+	DefaultSiteRedirect        = "redirect" // Redirect to RedirectURL
+	DefaultSiteHTML            = "html"     // Serve HTML
+)
+
+// DefaultSiteModes lists every supported default site mode.
+var DefaultSiteModes = []string{DefaultSiteCongratulations, DefaultSiteNotFound, DefaultSiteClose, DefaultSiteRedirect, DefaultSiteHTML}
+
+// DefaultSite is the fallback for requests no host matches.
+type DefaultSite struct {
+	Mode        string `json:"mode"`
+	RedirectURL string `json:"redirect_url,omitempty"` // May use placeholders, e.g. https://example.com{http.request.uri}
+	HTML        string `json:"html,omitempty"`
+}
+
+// CongratulationsPage is served by the congratulations default site.
+const CongratulationsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Congratulations!</title>
+<style>
+body { font-family: system-ui, sans-serif; color: #333; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { max-width: 32rem; padding: 2rem; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+<h1>Congratulations!</h1>
+<p>Caddy Proxy Manager Plus is running, but no proxy host is set up for this address yet.</p>
+</main>
+</body>
+</html>
+`
+
+// ParseDefaultSite decodes the default site setting. An empty value returns nil,
+// leaving unmatched requests with Caddy's empty response.
+func ParseDefaultSite(raw string) (*DefaultSite, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var site DefaultSite
+	if err := json.Unmarshal([]byte(raw), &site); err != nil {
+		return nil, fmt.Errorf("parse default site: %w", err)
+	}
+	if !slices.Contains(DefaultSiteModes, site.Mode) {
+		return nil, fmt.Errorf("unsupported default site mode %q", site.Mode)
+	}
+
+	switch site.Mode {
+	case DefaultSiteRedirect:
+		// Placeholders may follow the host, which is checked on its own
+		base, _, _ := strings.Cut(site.RedirectURL, "{")
+		u, err := url.Parse(base)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") || strings.ContainsAny(site.RedirectURL, "\r\n") {
+			return nil, fmt.Errorf("invalid default site redirect_url %q (expected e.g. https://example.com)", site.RedirectURL)
+		}
+	case DefaultSiteHTML:
+		if strings.TrimSpace(site.HTML) == "" {
+			return nil, errors.New("default site html is required")
+		}
+	}
+	return &site, nil
+}
+
+// DefaultSiteRoute builds the last route of a server, which has no matcher and
+// so catches every request no host route took. It returns nil without a site.
+func DefaultSiteRoute(site *DefaultSite) *Route {
+	if site == nil {
+		return nil
+	}
+
+	var handler Handler
+	switch site.Mode {
+	case DefaultSiteCongratulations:
+		handler = StaticResponseHandler(http.StatusOK, CongratulationsPage, htmlHeaders())
+	case DefaultSiteNotFound:
+		handler = StaticResponseHandler(http.StatusNotFound, "", nil)
+	case DefaultSiteClose:
+		handler = Handler{"handler": "static_response", "abort": true}
+	case DefaultSiteRedirect:
+		handler = StaticResponseHandler(http.StatusFound, "", map[string][]string{"Location": {site.RedirectURL}})
+	case DefaultSiteHTML:
+		handler = StaticResponseHandler(http.StatusOK, site.HTML, htmlHeaders())
+	default:
+		return nil
+	}
+	return &Route{Handle: []Handler{handler}, Terminal: true}
+}
@@ -0,0 +1,77 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestParseDefaultSite(t *testing.T) {
+	site, err := ParseDefaultSite("")
+	require.NoError(t, err)
+	require.Nil(t, site)
+
+	site, err = ParseDefaultSite(`{"mode": "redirect", "redirect_url": "https://example.com{http.request.uri}"}`)
+	require.NoError(t, err)
+	require.Equal(t, &DefaultSite{Mode: DefaultSiteRedirect, RedirectURL: "https://example.com{http.request.uri}"}, site)
+
+	for raw, wantErr := range map[string]string{
+		`404`:                  "parse default site",
+		`{"mode": "teapot"}`:   "unsupported default site mode",
+		`{"mode": "redirect"}`: "invalid default site redirect_url",
+		`{"mode": "redirect", "redirect_url": "/welcome"}`: "invalid default site redirect_url",
+		`{"mode": "html", "html": " "}`:                    "html is required",
+	} {
+		_, err := ParseDefaultSite(raw)
+		require.ErrorContains(t, err, wantErr, raw)
+	}
+}
+
+func TestGenerateConfig_DefaultSite(t *testing.T) {
+	// Without hosts the default site is all there is
+	config, err := GenerateConfig(nil, "/tmp/caddy-data", "", ConfigOptions{DefaultSite: &DefaultSite{Mode: DefaultSiteCongratulations}})
+	require.NoError(t, err)
+	for _, name := range []string{"cpm_server", "cpm_http"} {
+		routes := config.Apps.HTTP.Servers[name].Routes
+		require.Len(t, routes, 1, name)
+		require.Empty(t, routes[0].Match)
+		require.Equal(t, CongratulationsPage, routes[0].Handle[0]["body"])
+	}
+	require.Nil(t, config.Apps.TLS)
+	require.NoError(t, Validate(config))
+
+	hosts := []models.ProxyHost{{
+		UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		HTTPSMode: models.HTTPSModeRedirect,
+	}}
+	tests := []struct {
+		site    DefaultSite
+		handler Handler
+	}{
+		{DefaultSite{Mode: DefaultSiteNotFound}, Handler{"handler": "static_response", "status_code": 404}},
+		{DefaultSite{Mode: DefaultSiteClose}, Handler{"handler": "static_response", "abort": true}},
+		{DefaultSite{Mode: DefaultSiteRedirect, RedirectURL: "https://example.com"}, StaticResponseHandler(302, "", map[string][]string{"Location": {"https://example.com"}})},
+		{DefaultSite{Mode: DefaultSiteHTML, HTML: "<h1>Nothing here</h1>"}, StaticResponseHandler(200, "<h1>Nothing here</h1>", htmlHeaders())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.site.Mode, func(t *testing.T) {
+			config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{DefaultSite: &tt.site})
+			require.NoError(t, err)
+			for _, name := range []string{"cpm_server", "cpm_http"} {
+				routes := config.Apps.HTTP.Servers[name].Routes
+				require.Len(t, routes, 2, name)
+				require.Equal(t, []string{"app.example.com"}, routes[0].Match[0].Host)
+				require.Equal(t, &Route{Handle: []Handler{tt.handler}, Terminal: true}, routes[1], name)
+			}
+			require.NoError(t, Validate(config))
+
+			// Unknown SNI must never lead to certificate issuance
+			raw, err := json.Marshal(config.Apps.TLS)
+			require.NoError(t, err)
+			require.NotContains(t, string(raw), "on_demand")
+		})
+	}
+}
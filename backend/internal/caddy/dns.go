@@ -0,0 +1,91 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DNSProviderSettingPrefix prefixes the settings holding DNS provider credentials,
+// e.g. "caddy.dns_provider.cloudflare". Values are JSON objects stored encrypted.
+const DNSProviderSettingPrefix = "caddy.dns_provider."
+
+// DNSProviderSpec lists the credential fields a caddy-dns module accepts.
+type DNSProviderSpec struct {
+	Required []string
+	Optional []string
+}
+
+// DNSProviders are the caddy-dns modules compiled into the bundled Caddy build.
+var DNSProviders = map[string]DNSProviderSpec{
+	"cloudflare":   {Required: []string{"api_token"}, Optional: []string{"zone_token"}},
+	"route53":      {Optional: []string{"access_key_id", "secret_access_key", "session_token", "region", "profile"}},
+	"digitalocean": {Required: []string{"auth_token"}},
+	"duckdns":      {Required: []string{"api_token"}},
+	"hetzner":      {Required: []string{"api_token"}},
+	"porkbun":      {Required: []string{"api_key", "api_secret_key"}},
+}
+
+// DNSProviderSettingKey returns the setting key holding credentials for provider.
+func DNSProviderSettingKey(provider string) string {
+	return DNSProviderSettingPrefix + provider
+}
+
+// IsDNSProviderSetting reports whether key holds DNS provider credentials.
+func IsDNSProviderSetting(key string) bool {
+	return strings.HasPrefix(key, DNSProviderSettingPrefix)
+}
+
+// ParseDNSCredentials decodes and checks the credentials stored for provider.
+func ParseDNSCredentials(provider, raw string) (map[string]string, error) {
+	spec, ok := DNSProviders[provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dns provider %q", provider)
+	}
+
+	var creds map[string]string
+	if err := json.Unmarshal([]byte(raw), &creds); err != nil {
+		return nil, fmt.Errorf("parse %s credentials: %w", provider, err)
+	}
+
+	for _, field := range spec.Required {
+		if strings.TrimSpace(creds[field]) == "" {
+			return nil, fmt.Errorf("%s credentials require %s", provider, field)
+		}
+	}
+	for field := range creds {
+		if field == "name" || (!slices.Contains(spec.Required, field) && !slices.Contains(spec.Optional, field)) {
+			return nil, fmt.Errorf("%s credentials do not accept %s", provider, field)
+		}
+	}
+
+	return creds, nil
+}
+
+// DNSChallenge builds the ACME challenges block solving DNS-01 through provider.
+func DNSChallenge(provider string, creds map[string]string) map[string]interface{} {
+	module := map[string]interface{}{"name": provider}
+	for field, value := range creds {
+		module[field] = value
+	}
+
+	return map[string]interface{}{
+		"dns": map[string]interface{}{
+			"provider": module,
+		},
+	}
+}
+
+// WildcardFor returns the wildcard name covering domain, or "" when domain is an
+// apex or already a wildcard.
+func WildcardFor(domain string) string {
+	if strings.HasPrefix(domain, "*.") {
+		return ""
+	}
+	_, parent, ok := strings.Cut(domain, ".")
+	if !ok || !strings.Contains(parent, ".") {
+		return ""
+	}
+	return "*." + parent
+}
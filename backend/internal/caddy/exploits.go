@@ -0,0 +1,178 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ExploitRulesVersion identifies the built-in exploit rule set. Bump it whenever
+// DefaultExploitRules changes so applied configs can be traced to a rule revision.
+const ExploitRulesVersion = "1"
+
+// ExploitRulesSettingKey holds user-supplied rules that extend the built-in set.
+const ExploitRulesSettingKey = "caddy.exploit_rules"
+
+// ExploitRules describes requests that are rejected with 403 for hosts with BlockExploits enabled.
+// Patterns are RE2 regular expressions and are matched case-insensitively.
+type ExploitRules struct {
+	Version    string   `json:"version,omitempty"`
+	Paths      []string `json:"paths,omitempty"`       // matched against the request path
+	Query      []string `json:"query,omitempty"`       // matched against the raw (encoded) query string
+	UserAgents []string `json:"user_agents,omitempty"` // matched against the User-Agent header
+	Methods    []string `json:"methods,omitempty"`     // request methods that are always rejected
+}
+
+// DefaultExploitRules returns the built-in rule set covering common scanner probes,
+// SQL injection, XSS and path traversal attempts.
+func DefaultExploitRules() ExploitRules {
+	return ExploitRules{
+		Version: ExploitRulesVersion,
+		Paths: []string{
+			`\.\.[/\\]`,
+			`/\.(env|git|svn|hg|htaccess|htpasswd|DS_Store)(/|$)`,
+			`/(wp-admin|wp-login\.php|wp-config\.php|xmlrpc\.php)`,
+			`/(phpmyadmin|pma|myadmin)(/|$)`,
+			`/vendor/phpunit/`,
+			`/cgi-bin/.*\.(sh|pl|cgi)$`,
+			`/(etc/passwd|proc/self/environ)`,
+		},
+		Query: []string{
+			`union(\+|%20|\s)+(all(\+|%20|\s)+)?select`,
+			`information_schema`,
+			`(sleep|benchmark|concat)(\(|%28)`,
+			`(<|%3C)(script|iframe)`,
+			`javascript(:|%3A)`,
+			`document\.cookie`,
+			`(\.\.|%2e%2e)(/|%2f)`,
+			`etc(/|%2f)passwd`,
+			`base64_(en|de)code(\(|%28)`,
+			`(GLOBALS|_REQUEST)(=|\[|%5B)`,
+		},
+		UserAgents: []string{
+			`sqlmap`, `nikto`, `nmap`, `masscan`, `zgrab`, `acunetix`, `nessus`,
+			`w3af`, `dirbuster`, `gobuster`, `wpscan`, `havij`, `netsparker`, `nuclei`,
+		},
+		Methods: []string{"TRACE", "TRACK", "DEBUG"},
+	}
+}
+
+// ParseExploitRules decodes and validates custom rules stored in settings.
+func ParseExploitRules(raw string) (ExploitRules, error) {
+	var rules ExploitRules
+	if strings.TrimSpace(raw) == "" {
+		return rules, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return rules, fmt.Errorf("parse exploit rules: %w", err)
+	}
+
+	for _, patterns := range [][]string{rules.Paths, rules.Query, rules.UserAgents} {
+		if _, err := combinePatterns(patterns); err != nil {
+			return rules, err
+		}
+	}
+
+	return rules, nil
+}
+
+// Merge returns the rules extended with extra patterns. The version records
+// that custom rules are in effect.
+func (r ExploitRules) Merge(extra ExploitRules) ExploitRules {
+	merged := ExploitRules{
+		Version:    r.Version,
+		Paths:      append(append([]string{}, r.Paths...), extra.Paths...),
+		Query:      append(append([]string{}, r.Query...), extra.Query...),
+		UserAgents: append(append([]string{}, r.UserAgents...), extra.UserAgents...),
+		Methods:    append(append([]string{}, r.Methods...), extra.Methods...),
+	}
+
+	if !extra.IsZero() {
+		merged.Version += "+custom"
+	}
+
+	return merged
+}
+
+// IsZero reports whether the rule set contains no rules.
+func (r ExploitRules) IsZero() bool {
+	return len(r.Paths) == 0 && len(r.Query) == 0 && len(r.UserAgents) == 0 && len(r.Methods) == 0
+}
+
+// BlockExploitsRoute builds a terminal route that answers 403 for any request to
+// domains matching the rules. It must be placed ahead of the host's proxy routes.
+func BlockExploitsRoute(domains []string, rules ExploitRules) (*Route, error) {
+	var matchers []Match
+
+	paths, err := combinePatterns(rules.Paths)
+	if err != nil {
+		return nil, err
+	}
+	if paths != "" {
+		matchers = append(matchers, Match{
+			Host:       domains,
+			PathRegexp: &RegexpMatch{Name: "exploit_path", Pattern: paths},
+		})
+	}
+
+	query, err := combinePatterns(rules.Query)
+	if err != nil {
+		return nil, err
+	}
+	if query != "" {
+		matchers = append(matchers, Match{
+			Host: domains,
+			VarsRegexp: map[string]*RegexpMatch{
+				"{http.request.uri.query}": {Name: "exploit_query", Pattern: query},
+			},
+		})
+	}
+
+	agents, err := combinePatterns(rules.UserAgents)
+	if err != nil {
+		return nil, err
+	}
+	if agents != "" {
+		matchers = append(matchers, Match{
+			Host: domains,
+			HeaderRegexp: map[string]*RegexpMatch{
+				"User-Agent": {Name: "exploit_agent", Pattern: agents},
+			},
+		})
+	}
+
+	if len(rules.Methods) > 0 {
+		methods := make([]string, 0, len(rules.Methods))
+		for _, m := range rules.Methods {
+			methods = append(methods, strings.ToUpper(m))
+		}
+		matchers = append(matchers, Match{Host: domains, Method: methods})
+	}
+
+	if len(matchers) == 0 {
+		return nil, nil
+	}
+
+	return &Route{
+		Match:    matchers,
+		Handle:   []Handler{StaticResponseHandler(403, "Forbidden", nil)},
+		Terminal: true,
+	}, nil
+}
+
+// combinePatterns joins patterns into one case-insensitive alternation, verifying each compiles.
+func combinePatterns(patterns []string) (string, error) {
+	if len(patterns) == 0 {
+		return "", nil
+	}
+
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return "", fmt.Errorf("invalid exploit pattern %q: %w", p, err)
+		}
+	}
+
+	return "(?i)(" + strings.Join(patterns, "|") + ")", nil
+}
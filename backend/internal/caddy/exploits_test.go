@@ -0,0 +1,80 @@
+package caddy
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultExploitRules_Match(t *testing.T) {
+	route, err := BlockExploitsRoute([]string{"app.example.com"}, DefaultExploitRules())
+	require.NoError(t, err)
+	require.NotNil(t, route)
+	require.Len(t, route.Match, 4)
+
+	path := regexp.MustCompile(route.Match[0].PathRegexp.Pattern)
+	query := regexp.MustCompile(route.Match[1].VarsRegexp["{http.request.uri.query}"].Pattern)
+	agent := regexp.MustCompile(route.Match[2].HeaderRegexp["User-Agent"].Pattern)
+
+	blockedPaths := []string{"/.env", "/app/.git/config", "/wp-admin/install.php", "/xmlrpc.php", "/static/../../etc/passwd", "/phpMyAdmin/"}
+	for _, p := range blockedPaths {
+		assert.True(t, path.MatchString(p), "path %s should be blocked", p)
+	}
+	allowedPaths := []string{"/", "/api/v1/users", "/environment", "/static/app.js", "/.well-known/acme-challenge/token"}
+	for _, p := range allowedPaths {
+		assert.False(t, path.MatchString(p), "path %s should be allowed", p)
+	}
+
+	blockedQueries := []string{"id=1+UNION+SELECT+password", "q=%3Cscript%3Ealert(1)%3C/script%3E", "file=..%2F..%2Fetc%2Fpasswd", "x=sleep(5)"}
+	for _, q := range blockedQueries {
+		assert.True(t, query.MatchString(q), "query %s should be blocked", q)
+	}
+	allowedQueries := []string{"page=2&sort=desc", "q=select+a+plan", "redirect=%2Fdashboard"}
+	for _, q := range allowedQueries {
+		assert.False(t, query.MatchString(q), "query %s should be allowed", q)
+	}
+
+	assert.True(t, agent.MatchString("sqlmap/1.7.2#stable (https://sqlmap.org)"))
+	assert.False(t, agent.MatchString("Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0"))
+
+	assert.Equal(t, []string{"TRACE", "TRACK", "DEBUG"}, route.Match[3].Method)
+	for _, m := range route.Match {
+		assert.Equal(t, []string{"app.example.com"}, m.Host)
+	}
+}
+
+func TestParseExploitRules(t *testing.T) {
+	rules, err := ParseExploitRules("")
+	require.NoError(t, err)
+	assert.True(t, rules.IsZero())
+
+	rules, err = ParseExploitRules(`{"paths": ["/secret-admin"], "methods": ["propfind"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/secret-admin"}, rules.Paths)
+
+	_, err = ParseExploitRules(`{"paths": ["(unclosed"]}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid exploit pattern")
+
+	_, err = ParseExploitRules(`not json`)
+	require.Error(t, err)
+}
+
+func TestExploitRules_Merge(t *testing.T) {
+	base := DefaultExploitRules()
+
+	unchanged := base.Merge(ExploitRules{})
+	assert.Equal(t, ExploitRulesVersion, unchanged.Version)
+	assert.Equal(t, base.Paths, unchanged.Paths)
+
+	merged := base.Merge(ExploitRules{Paths: []string{"/secret-admin"}, Methods: []string{"propfind"}})
+	assert.Equal(t, ExploitRulesVersion+"+custom", merged.Version)
+	assert.Len(t, merged.Paths, len(base.Paths)+1)
+	assert.Len(t, base.Paths, len(DefaultExploitRules().Paths), "merge must not mutate the base rules")
+
+	route, err := BlockExploitsRoute([]string{"a.com"}, merged)
+	require.NoError(t, err)
+	assert.Contains(t, route.Match[3].Method, "PROPFIND")
+}
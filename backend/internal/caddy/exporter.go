@@ -0,0 +1,431 @@
+package caddy
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// Formats of Manager.Export.
+const (
+	ExportFormatCaddyfile = "caddyfile" // Readable Caddyfile of the proxy hosts
+	ExportFormatJSON      = "json"      // The complete config CPM loads into Caddy, secrets excepted
+)
+
+// ConfigExporter renders the managed configuration for use without CPM.
+// *Manager implements it.
+type ConfigExporter interface {
+	Export(format string) ([]byte, error)
+}
+
+// caddyfileWriter writes Caddyfile lines indented with tabs, like `caddy fmt`.
+type caddyfileWriter struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (w *caddyfileWriter) line(tokens ...string) {
+	w.buf.WriteString(strings.Repeat("\t", w.indent))
+	w.buf.WriteString(strings.Join(tokens, " "))
+	w.buf.WriteByte('\n')
+}
+
+func (w *caddyfileWriter) open(tokens ...string) {
+	w.line(append(tokens, "{")...)
+	w.indent++
+}
+
+func (w *caddyfileWriter) close() {
+	w.indent--
+	w.line("}")
+}
+
+func (w *caddyfileWriter) blank() {
+	w.buf.WriteByte('\n')
+}
+
+// caddyfileToken quotes value when the Caddyfile lexer would otherwise split
+// it or read it as a comment or block.
+func caddyfileToken(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"") && !strings.HasPrefix(value, "#") && value != "{" && value != "}" {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// envPlaceholder names the environment variable standing in for a secret,
+// e.g. {env.CLOUDFLARE_API_TOKEN}. Characters a shell variable name cannot
+// hold become underscores.
+func envPlaceholder(parts ...string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(strings.Join(parts, "_")))
+	return "{env." + name + "}"
+}
+
+// ExportCaddyfile renders the enabled proxy hosts as a Caddyfile: addresses
+// follow the HTTPS mode, and TLS settings, response headers, locations and
+// upstreams become directives. Secrets are replaced with {env.*} placeholders,
+// and features a Caddyfile cannot express are listed in a comment per site.
+func ExportCaddyfile(hosts []models.ProxyHost, acmeEmail string, opts ConfigOptions) []byte {
+	w := &caddyfileWriter{}
+	w.line("# Exported from Caddy Proxy Manager Plus. Features without a Caddyfile")
+	w.line("# equivalent are listed per site; the JSON export is complete.")
+
+	if acmeEmail != "" {
+		w.blank()
+		w.open()
+		w.line("email", caddyfileToken(acmeEmail))
+		w.close()
+	}
+
+	profiles := make(map[uint]*models.SecurityHeaderProfile, len(opts.SecurityHeaderProfiles))
+	for i := range opts.SecurityHeaderProfiles {
+		profiles[opts.SecurityHeaderProfiles[i].ID] = &opts.SecurityHeaderProfiles[i]
+	}
+
+	for i := range hosts {
+		host := &hosts[i]
+		if !host.Enabled || host.DomainNames == "" {
+			continue
+		}
+		var profile *models.SecurityHeaderProfile
+		if host.SecurityHeaderProfileID != nil {
+			profile = profiles[*host.SecurityHeaderProfileID]
+		}
+		w.blank()
+		exportHost(w, host, profile, opts)
+	}
+
+	return w.buf.Bytes()
+}
+
+// ExportJSON renders the config GenerateConfig builds as indented JSON, with
+// every secret replaced by an {env.*} placeholder.
+func ExportJSON(hosts []models.ProxyHost, storageDir, acmeEmail string, opts ConfigOptions) ([]byte, error) {
+	config, err := GenerateConfig(hosts, storageDir, acmeEmail, opts)
+	if err != nil {
+		return nil, fmt.Errorf("generate config: %w", err)
+	}
+	return marshalReplacingSecrets(config, func(name, _ string) (string, error) {
+		return envPlaceholder(name), nil
+	})
+}
+
+// exportHost writes the site block of one proxy host.
+func exportHost(w *caddyfileWriter, host *models.ProxyHost, profile *models.SecurityHeaderProfile, opts ConfigOptions) {
+	mode := host.EffectiveHTTPSMode()
+	var addresses []string
+	for _, domain := range strings.Split(host.DomainNames, ",") {
+		domain = strings.TrimSpace(domain)
+		switch mode {
+		case models.HTTPSModeRedirect:
+			addresses = append(addresses, domain)
+		case models.HTTPSModeHTTPS:
+			addresses = append(addresses, domain, "http://"+domain)
+		default:
+			addresses = append(addresses, "http://"+domain)
+		}
+	}
+
+	if host.Name != "" && host.Name != host.DomainNames {
+		w.line("#", host.Name)
+	}
+	w.open(strings.Join(addresses, ", "))
+
+	if skipped := unexportedFeatures(host); len(skipped) > 0 {
+		w.line("# Not exported:", strings.Join(skipped, ", "))
+	}
+	if mode != models.HTTPSModeHTTPOnly {
+		exportTLS(w, host, opts)
+	}
+
+	headers := EffectiveResponseHeaders(host, profile)
+	if len(headers) > 0 {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.open("header")
+		for _, name := range names {
+			if headers[name] == "" {
+				w.line("-" + name)
+			} else {
+				w.line(name, caddyfileToken(headers[name]))
+			}
+		}
+		// Like CPM, apply the headers to responses from the upstream too
+		w.line("defer")
+		w.close()
+	}
+
+	for i := range host.Locations {
+		exportLocation(w, host, &host.Locations[i], i+1)
+	}
+
+	w.open("handle")
+	if host.MaxBodySize > 0 {
+		exportBodyLimit(w, host.MaxBodySize)
+	}
+	if host.Advanced.Format == models.AdvancedFormatCaddyfile && strings.TrimSpace(host.Advanced.Content) != "" {
+		for _, line := range strings.Split(strings.TrimSpace(host.Advanced.Content), "\n") {
+			if line = strings.TrimRight(line, " \t\r"); line == "" {
+				w.blank()
+			} else {
+				w.line(line)
+			}
+		}
+	}
+
+	upstreams := host.EffectiveUpstreams()
+	dials := make([]string, 0, len(upstreams))
+	weights := make([]int, 0, len(upstreams))
+	for _, u := range upstreams {
+		dials = append(dials, net.JoinHostPort(u.Host, strconv.Itoa(u.Port)))
+		weights = append(weights, u.Weight)
+	}
+	proxy := &caddyfileWriter{indent: w.indent + 1}
+	if len(dials) > 1 {
+		exportLoadBalancing(proxy, host.LoadBalancing, weights)
+	}
+	exportHealthChecks(proxy, host.HealthCheck)
+	exportProxyHeaders(proxy, host.HeaderRules, nil, host.WebsocketSupport)
+	exportTransport(proxy, host.ForwardScheme, host.UpstreamTLS)
+	exportReverseProxy(w, dials, proxy)
+	w.close()
+
+	w.close()
+}
+
+// unexportedFeatures names the settings of a host the Caddyfile leaves out.
+func unexportedFeatures(host *models.ProxyHost) []string {
+	var skipped []string
+	add := func(enabled bool, feature string) {
+		if enabled {
+			skipped = append(skipped, feature)
+		}
+	}
+
+	add(len(host.AccessListIDs) > 0, "access lists")
+	add(len(host.RateLimits) > 0, "rate limits")
+	add(host.BlockExploits, "exploit blocking")
+	add(host.CrowdSec, "CrowdSec")
+	add(host.Maintenance.Enabled, "maintenance mode")
+	add(len(host.ErrorPages) > 0, "error pages")
+	add(host.WildcardCert, "shared wildcard certificate")
+	add(host.UpstreamTLS.TrustedCAPEM != "", "upstream trusted CA")
+	add(host.UpstreamTLS.HasClientCert(), "upstream client certificate")
+	add(host.Advanced.Format != models.AdvancedFormatCaddyfile && strings.TrimSpace(host.Advanced.Content) != "", "advanced JSON config")
+	for _, loc := range host.Locations {
+		add(len(loc.AccessListIDs) > 0, "location "+loc.Path+" access lists")
+		add(len(loc.RateLimits) > 0, "location "+loc.Path+" rate limits")
+	}
+	return skipped
+}
+
+// exportTLS writes the tls directive selecting the issuer and challenge of a host.
+func exportTLS(w *caddyfileWriter, host *models.ProxyHost, opts ConfigOptions) {
+	if host.CertificateID != nil {
+		name := strconv.FormatUint(uint64(*host.CertificateID), 10)
+		for _, cert := range opts.Certificates {
+			if cert.ID == *host.CertificateID {
+				name = strconv.Quote(cert.Name)
+			}
+		}
+		w.line("# Custom certificate", name, "is not exported; install it and use: tls <cert_file> <key_file>")
+		return
+	}
+	if host.CertIssuer == models.CertIssuerInternal {
+		w.line("tls internal")
+		return
+	}
+
+	var ca string
+	switch host.CertIssuer {
+	case models.CertIssuerLetsEncrypt:
+		ca = LetsEncryptDirectory
+	case models.CertIssuerLetsEncryptStaging:
+		ca = LetsEncryptStagingDirectory
+	case models.CertIssuerZeroSSL:
+		ca = ZeroSSLDirectory
+	}
+	if ca == "" && host.DNSProvider == "" {
+		return
+	}
+
+	w.open("tls")
+	if ca != "" {
+		w.line("ca", ca)
+	}
+	if host.CertIssuer == models.CertIssuerZeroSSL {
+		w.line("eab", envPlaceholder("zerossl_eab_key_id"), envPlaceholder("zerossl_eab_mac_key"))
+	}
+	if host.DNSProvider != "" {
+		fields := make([]string, 0, len(opts.DNSCredentials[host.DNSProvider]))
+		for field := range opts.DNSCredentials[host.DNSProvider] {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		if len(fields) == 0 {
+			w.line("dns", host.DNSProvider)
+		} else {
+			w.open("dns", host.DNSProvider)
+			for _, field := range fields {
+				w.line(field, envPlaceholder(host.DNSProvider, field))
+			}
+			w.close()
+		}
+	}
+	w.close()
+}
+
+// exportLocation writes a named path matcher and the handle block of a location.
+func exportLocation(w *caddyfileWriter, host *models.ProxyHost, loc *models.Location, n int) {
+	matcher := "@location_" + strconv.Itoa(n)
+	if loc.PathRegexp != "" {
+		w.line(matcher, "path_regexp", "location", caddyfileToken(loc.PathRegexp))
+	} else {
+		w.line(matcher, "path", caddyfileToken(loc.Path), caddyfileToken(loc.Path+"/*"))
+	}
+
+	w.open("handle", matcher)
+	if maxBodySize := cmp.Or(loc.MaxBodySize, host.MaxBodySize); maxBodySize > 0 {
+		exportBodyLimit(w, maxBodySize)
+	}
+
+	// route keeps the order; the Caddyfile would rewrite before stripping
+	strip := loc.StripPathPrefix && loc.Path != ""
+	ordered := strip && loc.RewriteURI != ""
+	if ordered {
+		w.open("route")
+	}
+	if strip {
+		w.line("uri strip_prefix", caddyfileToken(loc.Path))
+	}
+	if loc.RewriteURI != "" {
+		w.line("rewrite *", caddyfileToken(loc.RewriteURI))
+	}
+
+	proxy := &caddyfileWriter{indent: w.indent + 1}
+	exportProxyHeaders(proxy, host.HeaderRules, loc.RequestHeaders, loc.Websocket(host))
+	exportTransport(proxy, loc.ForwardScheme, models.UpstreamTLSConfig{})
+	exportReverseProxy(w, []string{net.JoinHostPort(loc.ForwardHost, strconv.Itoa(loc.ForwardPort))}, proxy)
+
+	if ordered {
+		w.close()
+	}
+	w.close()
+}
+
+func exportBodyLimit(w *caddyfileWriter, maxSize int64) {
+	w.open("request_body")
+	w.line("max_size", strconv.FormatInt(maxSize, 10))
+	w.close()
+}
+
+// exportReverseProxy writes a reverse_proxy directive with the subdirectives
+// already written to proxy, if any.
+func exportReverseProxy(w *caddyfileWriter, dials []string, proxy *caddyfileWriter) {
+	if proxy.buf.Len() == 0 {
+		w.line(append([]string{"reverse_proxy"}, dials...)...)
+		return
+	}
+	w.open(append([]string{"reverse_proxy"}, dials...)...)
+	w.buf.Write(proxy.buf.Bytes())
+	w.close()
+}
+
+func exportLoadBalancing(w *caddyfileWriter, policy string, weights []int) {
+	selection, _ := LoadBalancingConfig(policy, weights)["selection_policy"].(map[string]interface{})
+	tokens := []string{"lb_policy", selection["policy"].(string)}
+	if normalized, ok := selection["weights"].([]int); ok {
+		for _, weight := range normalized {
+			tokens = append(tokens, strconv.Itoa(weight))
+		}
+	}
+	if name, ok := selection["name"].(string); ok {
+		tokens = append(tokens, name)
+	}
+	w.line(tokens...)
+}
+
+func exportHealthChecks(w *caddyfileWriter, hc models.HealthCheckConfig) {
+	if hc.ActiveEnabled {
+		w.line("health_uri", caddyfileToken(hc.ActivePath))
+		w.line("health_interval", seconds(hc.ActiveInterval, 30))
+		w.line("health_timeout", seconds(hc.ActiveTimeout, 5))
+		if hc.ExpectStatus > 0 {
+			w.line("health_status", strconv.Itoa(hc.ExpectStatus))
+		}
+	}
+	if hc.PassiveEnabled {
+		w.line("fail_duration", seconds(hc.FailDuration, 30))
+		w.line("max_fails", strconv.Itoa(max(hc.MaxFails, 1)))
+		if len(hc.UnhealthyStatus) > 0 {
+			tokens := []string{"unhealthy_status"}
+			for _, code := range hc.UnhealthyStatus {
+				tokens = append(tokens, strconv.Itoa(code))
+			}
+			w.line(tokens...)
+		}
+	}
+}
+
+// exportProxyHeaders writes the header_up subdirectives of a reverse_proxy:
+// websocket upgrades, the host's request rules, then a location's own headers.
+func exportProxyHeaders(w *caddyfileWriter, rules []models.HeaderRule, set map[string]string, websocket bool) {
+	if websocket {
+		w.line("header_up Upgrade {http.request.header.Upgrade}")
+		w.line("header_up Connection {http.request.header.Connection}")
+	}
+	for _, rule := range rules {
+		if rule.Direction != models.HeaderRuleRequest {
+			continue
+		}
+		switch rule.Operation {
+		case models.HeaderOpSet:
+			w.line("header_up", rule.Name, caddyfileToken(rule.Value))
+		case models.HeaderOpAdd:
+			w.line("header_up", "+"+rule.Name, caddyfileToken(rule.Value))
+		case models.HeaderOpDelete:
+			w.line("header_up", "-"+rule.Name)
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.line("header_up", name, caddyfileToken(set[name]))
+	}
+}
+
+// exportTransport writes the TLS transport of an https upstream.
+func exportTransport(w *caddyfileWriter, scheme string, cfg models.UpstreamTLSConfig) {
+	if scheme != "https" {
+		return
+	}
+	w.open("transport http")
+	w.line("tls")
+	if cfg.InsecureSkipVerify {
+		w.line("tls_insecure_skip_verify")
+	}
+	if cfg.ServerName != "" {
+		w.line("tls_server_name", caddyfileToken(cfg.ServerName))
+	}
+	w.close()
+}
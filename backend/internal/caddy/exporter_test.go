@@ -0,0 +1,191 @@
+package caddy
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// exportHosts covers every HTTPS mode, locations, headers, load balancing and
+// the TLS settings of upstreams and certificates.
+func exportHosts() ([]models.ProxyHost, ConfigOptions) {
+	profileID := uint(1)
+	websocket := false
+	hosts := []models.ProxyHost{
+		{
+			UUID: "app", Name: "App", DomainNames: "app.example.com,www.example.com",
+			ForwardScheme: "http", ForwardHost: "app", ForwardPort: 8080, Enabled: true,
+			HTTPSMode: models.HTTPSModeRedirect, SSLForced: true, WebsocketSupport: true,
+			HSTSEnabled: true, BlockExploits: true, MaxBodySize: 10 << 20,
+			SecurityHeaderProfileID: &profileID,
+			HeaderRules: []models.HeaderRule{
+				{Direction: models.HeaderRuleRequest, Operation: models.HeaderOpSet, Name: "X-Real-IP", Value: "{http.request.remote.host}"},
+				{Direction: models.HeaderRuleResponse, Operation: models.HeaderOpSet, Name: "X-Served-By", Value: "cpm edge"},
+			},
+			HealthCheck: models.HealthCheckConfig{ActiveEnabled: true, ActivePath: "/healthz", ExpectStatus: 200},
+			Locations: []models.Location{
+				{Path: "/api", ForwardScheme: "http", ForwardHost: "api", ForwardPort: 9000, StripPathPrefix: true, RewriteURI: "/v2{http.request.uri}", WebsocketSupport: &websocket},
+				{PathRegexp: `^/files/\d+$`, ForwardScheme: "http", ForwardHost: "files", ForwardPort: 80, RequestHeaders: map[string]string{"X-Tenant": "acme"}},
+			},
+			AccessListIDs: []uint{3},
+			Advanced:      models.AdvancedConfig{Format: models.AdvancedFormatCaddyfile, Content: "encode gzip"},
+		},
+		{
+			UUID: "lb", DomainNames: "lb.example.com",
+			ForwardScheme: "http", ForwardHost: "app1", ForwardPort: 8080, Enabled: true,
+			HTTPSMode: models.HTTPSModeHTTPS,
+			Upstreams: []models.Upstream{
+				{Host: "app1", Port: 8080, Weight: 3}, {Host: "app2", Port: 8080, Weight: 1}, {Host: "::1", Port: 9000, Weight: 1},
+			},
+			LoadBalancing: models.LoadBalancingRoundRobin,
+			CertIssuer:    models.CertIssuerLetsEncryptStaging, DNSProvider: "cloudflare",
+		},
+		{
+			UUID: "pve", DomainNames: "pve.example.com",
+			ForwardScheme: "https", ForwardHost: "10.0.0.5", ForwardPort: 8006, Enabled: true,
+			HTTPSMode: models.HTTPSModeRedirect, SSLForced: true, CertIssuer: models.CertIssuerInternal,
+			UpstreamTLS: models.UpstreamTLSConfig{InsecureSkipVerify: true, ServerName: "pve.internal"},
+		},
+		{
+			UUID: "nas", DomainNames: "nas.lan",
+			ForwardScheme: "http", ForwardHost: "10.0.0.2", ForwardPort: 5000, Enabled: true,
+			HTTPSMode: models.HTTPSModeHTTPOnly,
+		},
+		{
+			UUID: "old", DomainNames: "old.example.com",
+			ForwardScheme: "http", ForwardHost: "old", ForwardPort: 80, Enabled: false,
+		},
+	}
+	opts := ConfigOptions{
+		SecurityHeaderProfiles: []models.SecurityHeaderProfile{{ID: 1, Name: "Strict", XFrameOptions: "DENY", RemoveServerHeader: true}},
+		DNSCredentials:         map[string]map[string]string{"cloudflare": {"api_token": "cf-secret"}},
+	}
+	return hosts, opts
+}
+
+func TestExportCaddyfile(t *testing.T) {
+	hosts, opts := exportHosts()
+	got := ExportCaddyfile(hosts, "admin@example.com", opts)
+	require.NotContains(t, string(got), "cf-secret")
+
+	path := filepath.Join("testdata", "export.golden.Caddyfile")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
+
+func TestExportCaddyfile_HTTPSLocation(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID: "pve", DomainNames: "pve.example.com", Enabled: true,
+		ForwardScheme: "https", ForwardHost: "10.0.0.5", ForwardPort: 8006,
+		UpstreamTLS: models.UpstreamTLSConfig{InsecureSkipVerify: true, ServerName: "pve.internal"},
+		Locations:   []models.Location{{Path: "/backup", ForwardScheme: "https", ForwardHost: "10.0.0.9", ForwardPort: 8007}},
+	}}
+	got := string(ExportCaddyfile(hosts, "", ConfigOptions{}))
+
+	// Only the host's own upstream gets its TLS settings
+	require.Equal(t, 1, strings.Count(got, "tls_server_name pve.internal"))
+	require.Equal(t, 1, strings.Count(got, "tls_insecure_skip_verify"))
+	require.Equal(t, 2, strings.Count(got, "transport http {"))
+}
+
+func TestExportJSON(t *testing.T) {
+	hosts, opts := exportHosts()
+	certPEM, keyPEM, _ := selfSignedPEM(t, "pve.example.com")
+	certID := uint(7)
+	hosts[0].CrowdSec = true
+	hosts[1].CertIssuer = models.CertIssuerZeroSSL
+	hosts[2].CertificateID = &certID
+	opts.Certificates = []models.SSLCertificate{{ID: certID, UUID: "pve-cert", Certificate: certPEM, PrivateKey: keyPEM}}
+	opts.ZeroSSLAccount = &ExternalAccount{KeyID: "eab-id", MACKey: "eab-mac-secret"}
+	opts.CrowdSec = &CrowdSecApp{APIURL: "http://crowdsec:8080/", APIKey: "bouncer-secret"}
+	opts.Modules = []string{CrowdSecModule}
+	opts.AccessLists = []models.AccessList{{ID: 3, Type: models.AccessListBasicAuth, Realm: "Staff", Enabled: true,
+		Users: []models.AccessListUser{{Username: "ops", PasswordHash: "bcrypt-hash-secret"}}}}
+
+	got, err := ExportJSON(hosts, "/data", "admin@example.com", opts)
+	require.NoError(t, err)
+
+	// Every secret becomes a placeholder, the rest of the config is kept
+	for _, secret := range []string{"cf-secret", "BEGIN EC PRIVATE KEY", "eab-mac-secret", "bouncer-secret", "bcrypt-hash-secret"} {
+		require.NotContains(t, string(got), secret)
+	}
+	for _, placeholder := range []string{"{env.CLOUDFLARE_API_TOKEN}", "{env.CPM_CERT_PVE_CERT_KEY}", "{env.ZEROSSL_EAB_MAC_KEY}", "{env.CROWDSEC_API_KEY}", "{env.BASIC_AUTH_STAFF_OPS}"} {
+		require.Contains(t, string(got), placeholder)
+	}
+	require.Contains(t, string(got), `"key_id": "eab-id"`)
+	require.Contains(t, string(got), "app.example.com")
+	require.True(t, json.Valid(got))
+}
+
+// adaptExecutor adapts with the caddy binary when one is on PATH. Otherwise it
+// answers with testdata/export.adapted.json, the `caddy adapt` output of
+// testdata/export.golden.Caddyfile, after checking that file is what it got.
+type adaptExecutor struct {
+	t *testing.T
+}
+
+func (e *adaptExecutor) Execute(name string, args ...string) ([]byte, error) {
+	if binary, err := exec.LookPath("caddy"); err == nil {
+		return (&DefaultExecutor{}).Execute(binary, args...)
+	}
+
+	require.Equal(e.t, []string{"adapt", "--config"}, args[:2])
+	got, err := os.ReadFile(args[2])
+	require.NoError(e.t, err)
+	want, err := os.ReadFile(filepath.Join("testdata", "export.golden.Caddyfile"))
+	require.NoError(e.t, err)
+	require.Equal(e.t, string(want), string(got), "the recorded adapt output is stale")
+	return os.ReadFile(filepath.Join("testdata", "export.adapted.json"))
+}
+
+func TestExportCaddyfile_RoundTrip(t *testing.T) {
+	hosts, opts := exportHosts()
+	path := filepath.Join(t.TempDir(), "Caddyfile")
+	require.NoError(t, os.WriteFile(path, ExportCaddyfile(hosts, "admin@example.com", opts), 0644))
+
+	importer := NewImporter("caddy")
+	importer.executor = &adaptExecutor{t: t}
+	result, err := importer.ImportFile(path)
+	require.NoError(t, err)
+	require.Empty(t, result.Conflicts)
+
+	// Importing keeps what the importer models of every enabled host and
+	// gives the rest the defaults of a new host
+	var want []models.ProxyHost
+	for _, host := range hosts {
+		if !host.Enabled {
+			continue
+		}
+		imported := models.ProxyHost{
+			Name:             host.DomainNames,
+			DomainNames:      host.DomainNames,
+			ForwardScheme:    host.ForwardScheme,
+			ForwardHost:      host.ForwardHost,
+			ForwardPort:      host.ForwardPort,
+			SSLForced:        host.SSLForced,
+			HTTPSMode:        host.HTTPSMode,
+			WebsocketSupport: host.WebsocketSupport,
+			UpstreamTLS:      models.UpstreamTLSConfig{InsecureSkipVerify: host.UpstreamTLS.InsecureSkipVerify, ServerName: host.UpstreamTLS.ServerName},
+			HTTP2Support:     true,
+			BlockExploits:    true,
+			Enabled:          true,
+		}
+		if len(host.Upstreams) > 1 {
+			imported.Upstreams = host.Upstreams
+			imported.LoadBalancing = host.LoadBalancing
+		}
+		want = append(want, imported)
+	}
+	require.ElementsMatch(t, want, ConvertToProxyHosts(result.Hosts))
+}
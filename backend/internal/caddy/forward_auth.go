@@ -0,0 +1,195 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// DefaultForwardAuthHeaders are copied from the auth service when a forward_auth
+// list names none. Authelia and Authentik's proxy outpost both send them.
+var DefaultForwardAuthHeaders = []string{"Remote-User", "Remote-Groups", "Remote-Name", "Remote-Email"}
+
+// ForwardAuthEndpoint is a parsed forward_auth URL.
+type ForwardAuthEndpoint struct {
+	Scheme     string // http or https
+	Dial       string // host:port of the auth service
+	ServerName string // TLS server name for https endpoints
+	URI        string // Path and query the request is rewritten to
+}
+
+// ParseForwardAuthURL checks the endpoint of a forward_auth list.
+func ParseForwardAuthURL(raw string) (*ForwardAuthEndpoint, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid forward auth url %q", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("forward auth url must use http or https, got %q", u.Scheme)
+	}
+	if u.User != nil || u.Fragment != "" {
+		return nil, fmt.Errorf("forward auth url must not contain credentials or a fragment")
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return &ForwardAuthEndpoint{
+		Scheme:     u.Scheme,
+		Dial:       net.JoinHostPort(u.Hostname(), port),
+		ServerName: u.Hostname(),
+		URI:        u.RequestURI(),
+	}, nil
+}
+
+// ForwardAuthHandler builds the equivalent of Caddy's forward_auth directive: the
+// request is sent to the auth service as a GET with X-Forwarded-Method and
+// X-Forwarded-Uri. A 2xx answer copies the configured headers onto the request
+// and lets it continue; any other answer, such as a redirect to the login
+// portal, is returned to the client.
+func ForwardAuthHandler(list *models.AccessList) (Handler, error) {
+	copyHeaders := list.CopyHeaders
+	if len(copyHeaders) == 0 {
+		copyHeaders = DefaultForwardAuthHeaders
+	}
+	h, err := forwardAuthHandler(list.ForwardAuthURL, copyHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("access list %s: %w", list.Name, err)
+	}
+	return h, nil
+}
+
+// Paths of CPM's own forward auth endpoints.
+const (
+	SSOVerifyPath   = "/api/v1/forward-auth/verify"
+	GeoIPVerifyPath = "/api/v1/geoip/verify"
+)
+
+// internalEndpoint builds the URL of a CPM endpoint as Caddy reaches it.
+func internalEndpoint(internalURL, path string, query url.Values) (string, error) {
+	if internalURL == "" {
+		return "", errors.New("CPM internal url is not configured")
+	}
+	u, err := url.Parse(strings.TrimSuffix(internalURL, "/") + path)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// SSOHandler asks CPM's own verify endpoint about the request, restricting it
+// to the users and roles of the sso list.
+func SSOHandler(list *models.AccessList, internalURL string) (Handler, error) {
+	endpoint, err := internalEndpoint(internalURL, SSOVerifyPath, url.Values{"list": {list.UUID}})
+	if err != nil {
+		return nil, fmt.Errorf("access list %s: %w", list.Name, err)
+	}
+
+	h, err := forwardAuthHandler(endpoint, SSOHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("access list %s: %w", list.Name, err)
+	}
+	return h, nil
+}
+
+// CountryHandler asks CPM which country the client is located in and refuses
+// clients outside allow or inside deny. Caddy has no GeoIP module, so CPM looks
+// the address up in its GeoLite2 database. The client address is passed as
+// X-Client-IP, resolved through the trusted proxies.
+func CountryHandler(internalURL string, allow, deny []string) (Handler, error) {
+	query := url.Values{}
+	if len(allow) > 0 {
+		query.Set("allow", strings.Join(allow, ","))
+	}
+	if len(deny) > 0 {
+		query.Set("deny", strings.Join(deny, ","))
+	}
+	endpoint, err := internalEndpoint(internalURL, GeoIPVerifyPath, query)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := forwardAuthHandler(endpoint, []string{"Remote-Country"})
+	if err != nil {
+		return nil, err
+	}
+	SetRequestHeaders(h, map[string]string{"X-Client-IP": "{http.vars.client_ip}"})
+	return h, nil
+}
+
+// SSOHeaders are the identity headers CPM's verify endpoint returns.
+var SSOHeaders = []string{"Remote-User", "Remote-Email", "Remote-Name", "Remote-Role"}
+
+func forwardAuthHandler(rawURL string, copyHeaders []string) (Handler, error) {
+	endpoint, err := ParseForwardAuthURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients must not be able to supply the identity headers themselves, so
+	// they are always removed and only set when the auth service returned them.
+	routes := []*Route{{
+		Handle: []Handler{{
+			"handler": "headers",
+			"request": map[string]interface{}{"delete": copyHeaders},
+		}},
+	}}
+	for _, name := range copyHeaders {
+		placeholder := "{http.reverse_proxy.header." + name + "}"
+		routes = append(routes, &Route{
+			Match: []Match{{Not: []Match{{Vars: map[string][]string{placeholder: {""}}}}}},
+			Handle: []Handler{{
+				"handler": "headers",
+				"request": map[string]interface{}{
+					"set": map[string][]string{name: {placeholder}},
+				},
+			}},
+		})
+	}
+
+	h := ReverseProxyHandler([]string{endpoint.Dial}, false)
+	h["rewrite"] = map[string]string{"method": "GET", "uri": endpoint.URI}
+	SetRequestHeaders(h, map[string]string{
+		"X-Forwarded-Method": "{http.request.method}",
+		"X-Forwarded-Uri":    "{http.request.uri}",
+	})
+	h["handle_response"] = []map[string]interface{}{{
+		"match":  map[string][]int{"status_code": {2}},
+		"routes": routes,
+	}}
+	if endpoint.Scheme == "https" {
+		h["transport"] = map[string]interface{}{
+			"protocol": "http",
+			"tls":      map[string]interface{}{"server_name": endpoint.ServerName},
+		}
+	}
+	return h, nil
+}
+
+// forwardAuthTrustedProxies adds the trusted proxies of enabled forward_auth
+// lists to the server-wide ones. Caddy only trusts proxies per server, so a
+// proxy trusted by one list is trusted for every host.
+func forwardAuthTrustedProxies(global []string, lists []models.AccessList) []string {
+	ranges := slices.Clone(global)
+	for _, list := range lists {
+		if !list.Enabled || list.Type != models.AccessListForwardAuth {
+			continue
+		}
+		for _, network := range list.TrustedProxies {
+			if !slices.Contains(ranges, network) {
+				ranges = append(ranges, network)
+			}
+		}
+	}
+	return ranges
+}
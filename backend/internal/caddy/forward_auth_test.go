@@ -0,0 +1,134 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestParseForwardAuthURL(t *testing.T) {
+	endpoint, err := ParseForwardAuthURL("http://authelia:9091/api/authz/forward-auth")
+	require.NoError(t, err)
+	require.Equal(t, ForwardAuthEndpoint{Scheme: "http", Dial: "authelia:9091", ServerName: "authelia", URI: "/api/authz/forward-auth"}, *endpoint)
+
+	endpoint, err = ParseForwardAuthURL("https://auth.example.com/outpost.goauthentik.io/auth/caddy?x=1")
+	require.NoError(t, err)
+	require.Equal(t, "auth.example.com:443", endpoint.Dial)
+	require.Equal(t, "/outpost.goauthentik.io/auth/caddy?x=1", endpoint.URI)
+
+	for _, raw := range []string{"authelia:9091", "ftp://auth/", "http://user:pw@auth/", "http://auth/#frag", "http:///verify"} {
+		_, err := ParseForwardAuthURL(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestGenerateConfig_ForwardAuth(t *testing.T) {
+	lists := []models.AccessList{
+		{
+			ID: 1, Name: "Authelia", Type: models.AccessListForwardAuth, Enabled: true,
+			ForwardAuthURL: "https://auth.example.com/api/authz/forward-auth",
+			CopyHeaders:    []string{"Remote-User", "Remote-Groups"},
+			TrustedProxies: []string{"172.16.0.0/12"},
+		},
+		{ID: 2, Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}, Enabled: true},
+	}
+	hosts := []models.ProxyHost{{
+		UUID: "wiki", DomainNames: "wiki.example.com", ForwardHost: "wiki", ForwardPort: 80, Enabled: true,
+		AccessListIDs: []uint{2, 1}, AccessSatisfy: models.AccessSatisfyAny,
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists, TrustedProxies: []string{"192.0.2.1"}})
+	require.NoError(t, err)
+	server := config.Apps.HTTP.Servers["cpm_server"]
+	require.Equal(t, []string{"192.0.2.1", "172.16.0.0/12"}, server.TrustedProxies["ranges"])
+
+	// Clients outside the LAN, judged by client_ip behind the trusted proxies, must pass the portal
+	gate := server.Routes[0].Handle[0]["routes"].([]*Route)
+	require.Len(t, gate, 1)
+	require.Equal(t, []string{"10.0.0.0/8"}, gate[0].Match[0].Not[0].ClientIP.Ranges)
+
+	raw, err := json.Marshal(gate[0].Handle[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"handler": "reverse_proxy",
+		"upstreams": [{"dial": "auth.example.com:443"}],
+		"rewrite": {"method": "GET", "uri": "/api/authz/forward-auth"},
+		"headers": {"request": {"set": {
+			"X-Forwarded-Method": ["{http.request.method}"],
+			"X-Forwarded-Uri": ["{http.request.uri}"]
+		}}},
+		"transport": {"protocol": "http", "tls": {"server_name": "auth.example.com"}},
+		"handle_response": [{
+			"match": {"status_code": [2]},
+			"routes": [
+				{"handle": [{"handler": "headers", "request": {"delete": ["Remote-User", "Remote-Groups"]}}]},
+				{
+					"match": [{"not": [{"vars": {"{http.reverse_proxy.header.Remote-User}": [""]}}]}],
+					"handle": [{"handler": "headers", "request": {"set": {"Remote-User": ["{http.reverse_proxy.header.Remote-User}"]}}}]
+				},
+				{
+					"match": [{"not": [{"vars": {"{http.reverse_proxy.header.Remote-Groups}": [""]}}]}],
+					"handle": [{"handler": "headers", "request": {"set": {"Remote-Groups": ["{http.reverse_proxy.header.Remote-Groups}"]}}}]
+				}
+			]
+		}]
+	}`, string(raw))
+
+	// Disabled lists neither gate the host nor add trusted proxies
+	lists[0].Enabled = false
+	config, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists})
+	require.NoError(t, err)
+	require.Nil(t, config.Apps.HTTP.Servers["cpm_server"].TrustedProxies)
+}
+
+func TestGenerateConfig_SSO(t *testing.T) {
+	lists := []models.AccessList{{ID: 1, UUID: "admins", Name: "Admins", Type: models.AccessListSSO, Enabled: true}}
+	hosts := []models.ProxyHost{{
+		UUID: "grafana", DomainNames: "grafana.example.com", ForwardHost: "grafana", ForwardPort: 3000, Enabled: true,
+		AccessListIDs: []uint{1},
+	}}
+
+	_, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists})
+	require.ErrorContains(t, err, "CPM internal url is not configured")
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{
+		AccessLists: lists,
+		InternalURL: "http://localhost:8080",
+	})
+	require.NoError(t, err)
+	gate := config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0]["routes"].([]*Route)
+	require.Len(t, gate, 1)
+	auth := gate[0].Handle[0]
+	require.Equal(t, []map[string]interface{}{{"dial": "localhost:8080"}}, auth["upstreams"])
+	require.Equal(t, map[string]string{"method": "GET", "uri": "/api/v1/forward-auth/verify?list=admins"}, auth["rewrite"])
+	routes := auth["handle_response"].([]map[string]interface{})[0]["routes"].([]*Route)
+	require.Equal(t, SSOHeaders, routes[0].Handle[0]["request"].(map[string]interface{})["delete"])
+}
+
+func TestGenerateConfig_CountryLists(t *testing.T) {
+	lists := []models.AccessList{
+		{ID: 1, Name: "Home", Type: models.AccessListCountryAllow, Rules: []string{"GB", "IE"}, Enabled: true},
+		{ID: 2, Name: "Blocked", Type: models.AccessListCountryDeny, Rules: []string{"RU"}, Enabled: true},
+		{ID: 3, Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}, Enabled: true},
+	}
+	hosts := []models.ProxyHost{{
+		UUID: "shop", DomainNames: "shop.example.com", ForwardHost: "shop", ForwardPort: 80, Enabled: true,
+		AccessListIDs: []uint{1, 2, 3},
+	}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{AccessLists: lists, InternalURL: "http://localhost:8080/"})
+	require.NoError(t, err)
+	gate := config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0]["routes"].([]*Route)
+	require.Len(t, gate, 2)
+
+	// The country check runs first for everyone, then the IP lists
+	country := gate[0].Handle[0]
+	require.Nil(t, gate[0].Match)
+	require.Equal(t, map[string]string{"method": "GET", "uri": "/api/v1/geoip/verify?allow=GB%2CIE&deny=RU"}, country["rewrite"])
+	set := country["headers"].(map[string]interface{})["request"].(map[string]interface{})["set"].(map[string][]string)
+	require.Equal(t, []string{"{http.vars.client_ip}"}, set["X-Client-IP"])
+	require.Equal(t, 403, gate[1].Handle[0]["status_code"])
+}
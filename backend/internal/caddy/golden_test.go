@@ -0,0 +1,136 @@
+package caddy
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// assertGolden compares the generated config with testdata/<name>.golden.json.
+// Run `go test ./internal/caddy -run Golden -update` after intended changes.
+func assertGolden(t *testing.T, name string, config *Config) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(config, "", "  ")
+	require.NoError(t, err)
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, got, 0644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, string(want), string(got))
+}
+
+var goldenDNSCredentials = map[string]map[string]string{
+	"cloudflare": {"api_token": "cf-token"},
+	"route53":    {"access_key_id": "AKIDEXAMPLE", "secret_access_key": "secret", "region": "us-east-1"},
+}
+
+func TestGolden_DNSChallenge(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "internal", DomainNames: "nas.home.example.org", ForwardHost: "10.0.0.2", ForwardPort: 5000, DNSProvider: "route53", SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "public", DomainNames: "www.example.net", ForwardHost: "10.0.0.3", ForwardPort: 80, SSLForced: true, HTTP2Support: true, Enabled: true},
+	}
+
+	config, err := GenerateConfig(hosts, "/app/data/caddy/data", "admin@example.com", ConfigOptions{DNSCredentials: goldenDNSCredentials})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+	assertGolden(t, "dns_challenge", config)
+}
+
+func TestGolden_SharedWildcard(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "app", DomainNames: "app.example.com, example.com", ForwardHost: "10.0.0.4", ForwardPort: 3000, DNSProvider: "cloudflare", WildcardCert: true, SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "api", DomainNames: "api.example.com", ForwardHost: "10.0.0.5", ForwardPort: 8080, DNSProvider: "cloudflare", WildcardCert: true, SSLForced: true, HTTP2Support: true, Enabled: true},
+	}
+
+	config, err := GenerateConfig(hosts, "/app/data/caddy/data", "admin@example.com", ConfigOptions{DNSCredentials: goldenDNSCredentials})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+	assertGolden(t, "shared_wildcard", config)
+}
+
+func TestGolden_WildcardHost(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "catchall", DomainNames: "*.apps.example.com", ForwardHost: "10.0.0.6", ForwardPort: 80, DNSProvider: "cloudflare", SSLForced: true, HTTP2Support: true, Enabled: true},
+	}
+
+	config, err := GenerateConfig(hosts, "/app/data/caddy/data", "", ConfigOptions{DNSCredentials: goldenDNSCredentials})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+	assertGolden(t, "wildcard_host", config)
+}
+
+func TestGolden_PerHostIssuers(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "nas", DomainNames: "nas.home.lan", ForwardHost: "10.0.0.2", ForwardPort: 5000, CertIssuer: models.CertIssuerInternal, SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "lan", DomainNames: "*.svc.home.lan", ForwardHost: "10.0.0.7", ForwardPort: 80, CertIssuer: models.CertIssuerInternal, SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "test", DomainNames: "test.example.com", ForwardHost: "10.0.0.3", ForwardPort: 80, CertIssuer: models.CertIssuerLetsEncryptStaging, SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "shop", DomainNames: "shop.example.com", ForwardHost: "10.0.0.4", ForwardPort: 80, CertIssuer: models.CertIssuerZeroSSL, SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "vpn", DomainNames: "vpn.example.com", ForwardHost: "10.0.0.5", ForwardPort: 80, CertIssuer: models.CertIssuerLetsEncrypt, DNSProvider: "cloudflare", SSLForced: true, HTTP2Support: true, Enabled: true},
+		{UUID: "www", DomainNames: "www.example.com", ForwardHost: "10.0.0.6", ForwardPort: 80, SSLForced: true, HTTP2Support: true, Enabled: true},
+	}
+
+	opts := ConfigOptions{
+		DNSCredentials: goldenDNSCredentials,
+		ZeroSSLAccount: &ExternalAccount{KeyID: "kid-123", MACKey: "mac-456"},
+	}
+	config, err := GenerateConfig(hosts, "/app/data/caddy/data", "admin@example.com", opts)
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+	assertGolden(t, "per_host_issuers", config)
+
+	_, err = GenerateConfig(hosts, "/app/data/caddy/data", "admin@example.com", ConfigOptions{DNSCredentials: goldenDNSCredentials})
+	require.ErrorContains(t, err, "zerossl issuer requires eab credentials")
+}
+
+func TestGenerateConfig_DNSProviderWithoutCredentials(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "x", DomainNames: "x.example.com", ForwardHost: "x", ForwardPort: 80, DNSProvider: "hetzner", Enabled: true},
+	}
+
+	_, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{DNSCredentials: goldenDNSCredentials})
+	require.ErrorContains(t, err, "dns provider hetzner has no credentials configured")
+}
+
+func TestValidate_WildcardNeedsDNSChallenge(t *testing.T) {
+	config := &Config{Apps: Apps{TLS: &TLSApp{Automation: &AutomationConfig{Policies: []*AutomationPolicy{
+		{Subjects: []string{"*.example.com"}, IssuersRaw: []interface{}{map[string]interface{}{"module": "acme"}}},
+	}}}}}
+
+	require.ErrorContains(t, Validate(config), "wildcard *.example.com requires a dns challenge")
+}
+
+func TestParseDNSCredentials(t *testing.T) {
+	creds, err := ParseDNSCredentials("cloudflare", `{"api_token":"abc"}`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api_token": "abc"}, creds)
+
+	_, err = ParseDNSCredentials("cloudflare", `{}`)
+	require.ErrorContains(t, err, "require api_token")
+
+	_, err = ParseDNSCredentials("cloudflare", `{"api_token":"abc","password":"x"}`)
+	require.ErrorContains(t, err, "do not accept password")
+
+	_, err = ParseDNSCredentials("namecheap", `{}`)
+	require.ErrorContains(t, err, "unsupported dns provider")
+}
+
+func TestWildcardFor(t *testing.T) {
+	require.Equal(t, "*.example.com", WildcardFor("app.example.com"))
+	require.Equal(t, "*.b.example.com", WildcardFor("a.b.example.com"))
+	require.Equal(t, "", WildcardFor("example.com"))
+	require.Equal(t, "", WildcardFor("*.example.com"))
+}
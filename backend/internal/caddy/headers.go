@@ -0,0 +1,111 @@
+package caddy
+
+import (
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// hopByHopHeaders only describe a single connection; reverse_proxy manages them
+// itself, so header rules may not touch them.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// IsHopByHopHeader reports whether name is a hop-by-hop header.
+func IsHopByHopHeader(name string) bool {
+	for _, h := range hopByHopHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerOps renders the rules for one direction as a Caddy header operations
+// block, or returns nil when there are none.
+func headerOps(rules []models.HeaderRule, direction string) map[string]interface{} {
+	set := map[string][]string{}
+	add := map[string][]string{}
+	var del []string
+
+	for _, rule := range rules {
+		if rule.Direction != direction {
+			continue
+		}
+		switch rule.Operation {
+		case models.HeaderOpSet:
+			set[rule.Name] = []string{rule.Value}
+		case models.HeaderOpAdd:
+			add[rule.Name] = append(add[rule.Name], rule.Value)
+		case models.HeaderOpDelete:
+			del = append(del, rule.Name)
+		}
+	}
+
+	ops := map[string]interface{}{}
+	if len(set) > 0 {
+		ops["set"] = set
+	}
+	if len(add) > 0 {
+		ops["add"] = add
+	}
+	if len(del) > 0 {
+		ops["delete"] = del
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	return ops
+}
+
+// ResponseHeaderRulesHandler renders the response rules of a host as a headers
+// handler, or returns nil when there are none. The operations are deferred so
+// they also apply to headers written by the upstream, e.g. deleting Server.
+func ResponseHeaderRulesHandler(rules []models.HeaderRule) Handler {
+	ops := headerOps(rules, models.HeaderRuleResponse)
+	if ops == nil {
+		return nil
+	}
+	ops["deferred"] = true
+
+	return Handler{
+		"handler":  "headers",
+		"response": ops,
+	}
+}
+
+// ApplyRequestHeaderRules adds the request rules of a host to the upstream
+// request of a reverse_proxy handler, keeping headers already configured.
+func ApplyRequestHeaderRules(h Handler, rules []models.HeaderRule) {
+	ops := headerOps(rules, models.HeaderRuleRequest)
+	if ops == nil {
+		return
+	}
+
+	request := proxyRequestHeaders(h)
+	for op, value := range ops {
+		switch v := value.(type) {
+		case map[string][]string:
+			existing, _ := request[op].(map[string][]string)
+			if existing == nil {
+				existing = map[string][]string{}
+				request[op] = existing
+			}
+			for name, values := range v {
+				existing[name] = values
+			}
+		case []string:
+			existing, _ := request[op].([]string)
+			request[op] = append(existing, v...)
+		}
+	}
+}
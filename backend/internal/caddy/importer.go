@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
@@ -41,10 +44,25 @@ type CaddyHTTP struct {
 
 // CaddyServer represents a single server configuration.
 type CaddyServer struct {
+	Listen                []string      `json:"listen,omitempty"`
 	Routes                []*CaddyRoute `json:"routes,omitempty"`
 	TLSConnectionPolicies interface{}   `json:"tls_connection_policies,omitempty"`
 }
 
+// plainHTTP reports whether the server only listens on port 80, like the
+// server `caddy adapt` creates for http:// site addresses.
+func (s *CaddyServer) plainHTTP() bool {
+	if len(s.Listen) == 0 || s.TLSConnectionPolicies != nil {
+		return false
+	}
+	for _, addr := range s.Listen {
+		if !strings.HasSuffix(addr, ":80") {
+			return false
+		}
+	}
+	return true
+}
+
 // CaddyRoute represents a single route with matchers and handlers.
 type CaddyRoute struct {
 	Match  []*CaddyMatcher `json:"match,omitempty"`
@@ -53,26 +71,36 @@ type CaddyRoute struct {
 
 // CaddyMatcher represents route matching criteria.
 type CaddyMatcher struct {
-	Host []string `json:"host,omitempty"`
+	Host       []string    `json:"host,omitempty"`
+	Path       []string    `json:"path,omitempty"`
+	PathRegexp interface{} `json:"path_regexp,omitempty"`
 }
 
 // CaddyHandler represents a handler in the route.
 type CaddyHandler struct {
-	Handler   string      `json:"handler"`
-	Upstreams interface{} `json:"upstreams,omitempty"`
-	Headers   interface{} `json:"headers,omitempty"`
+	Handler       string        `json:"handler"`
+	Upstreams     interface{}   `json:"upstreams,omitempty"`
+	Headers       interface{}   `json:"headers,omitempty"`
+	LoadBalancing interface{}   `json:"load_balancing,omitempty"`
+	Transport     interface{}   `json:"transport,omitempty"`
+	Routes        []*CaddyRoute `json:"routes,omitempty"` // Of subroute handlers
 }
 
 // ParsedHost represents a single host detected during Caddyfile import.
 type ParsedHost struct {
-	DomainNames      string   `json:"domain_names"`
-	ForwardScheme    string   `json:"forward_scheme"`
-	ForwardHost      string   `json:"forward_host"`
-	ForwardPort      int      `json:"forward_port"`
-	SSLForced        bool     `json:"ssl_forced"`
-	WebsocketSupport bool     `json:"websocket_support"`
-	RawJSON          string   `json:"raw_json"` // Original Caddy JSON for this route
-	Warnings         []string `json:"warnings"` // Unsupported features
+	DomainNames      string `json:"domain_names"`
+	ForwardScheme    string `json:"forward_scheme"`
+	ForwardHost      string `json:"forward_host"`
+	ForwardPort      int    `json:"forward_port"`
+	SSLForced        bool   `json:"ssl_forced"`
+	HTTPSMode        string `json:"https_mode"`
+	WebsocketSupport bool   `json:"websocket_support"`
+	// Upstreams is only set when the route balances across more than one backend.
+	Upstreams     []models.Upstream        `json:"upstreams,omitempty"`
+	LoadBalancing string                   `json:"load_balancing,omitempty"`
+	UpstreamTLS   models.UpstreamTLSConfig `json:"upstream_tls"`
+	RawJSON       string                   `json:"raw_json"` // Original Caddy JSON for this route
+	Warnings      []string                 `json:"warnings"` // Unsupported features
 }
 
 // ImportResult contains parsed hosts and detected conflicts.
@@ -130,94 +158,205 @@ func (i *Importer) ExtractHosts(caddyJSON []byte) (*ImportResult, error) {
 		return result, nil // Empty config
 	}
 
-	seenDomains := make(map[string]bool)
+	// Sites with an https address come first, so http:// addresses of the
+	// same site only lift its redirect instead of conflicting
+	names := make([]string, 0, len(config.Apps.HTTP.Servers))
+	for name := range config.Apps.HTTP.Servers {
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(a, b int) bool {
+		plainA, plainB := config.Apps.HTTP.Servers[names[a]].plainHTTP(), config.Apps.HTTP.Servers[names[b]].plainHTTP()
+		if plainA != plainB {
+			return plainB
+		}
+		return names[a] < names[b]
+	})
+
+	seenDomains := make(map[string]int) // Domain -> index of its host in result.Hosts
+	for _, serverName := range names {
+		server := config.Apps.HTTP.Servers[serverName]
+		plain := server.plainHTTP()
 
-	for serverName, server := range config.Apps.HTTP.Servers {
 		for routeIdx, route := range server.Routes {
+			// One route, usually one site block, becomes one host
+			var domains []string
+			merged := -1
 			for _, match := range route.Match {
-				for _, hostMatcher := range match.Host {
-					domain := hostMatcher
-
-					// Check for duplicate domains
-					if seenDomains[domain] {
+				for _, domain := range match.Host {
+					idx, seen := seenDomains[domain]
+					switch {
+					case !seen:
+						domains = append(domains, domain)
+					case plain && result.Hosts[idx].HTTPSMode == models.HTTPSModeRedirect && (merged == -1 || merged == idx):
+						// The http:// address of a site also served over https
+						merged = idx
+					default:
 						result.Conflicts = append(result.Conflicts,
 							fmt.Sprintf("Duplicate domain detected: %s", domain))
-						continue
-					}
-					seenDomains[domain] = true
-
-					// Extract reverse proxy handler
-					host := ParsedHost{
-						DomainNames: domain,
-						SSLForced:   strings.HasPrefix(domain, "https") || server.TLSConnectionPolicies != nil,
 					}
+				}
+			}
+			if merged >= 0 {
+				result.Hosts[merged].SSLForced = false
+				result.Hosts[merged].HTTPSMode = models.HTTPSModeHTTPS
+				if len(domains) > 0 {
+					result.Conflicts = append(result.Conflicts,
+						fmt.Sprintf("Domains %s share a route with https domains", strings.Join(domains, ", ")))
+				}
+				continue
+			}
+			if len(domains) == 0 {
+				continue
+			}
 
-					// Find reverse_proxy handler
-					for _, handler := range route.Handle {
-						if handler.Handler == "reverse_proxy" {
-							upstreams, _ := handler.Upstreams.([]interface{})
-							if len(upstreams) > 0 {
-								if upstream, ok := upstreams[0].(map[string]interface{}); ok {
-									dial, _ := upstream["dial"].(string)
-									if dial != "" {
-										parts := strings.Split(dial, ":")
-										if len(parts) == 2 {
-											host.ForwardHost = parts[0]
-											if _, err := fmt.Sscanf(parts[1], "%d", &host.ForwardPort); err != nil {
-												// Default to 80 if parsing fails, or handle error appropriately
-												// For now, just log or ignore, but at least we checked err
-												host.ForwardPort = 80
-											}
-										}
-									}
-								}
-							}
-
-							// Check for websocket support
-							if headers, ok := handler.Headers.(map[string]interface{}); ok {
-								if upgrade, ok := headers["Upgrade"].([]interface{}); ok {
-									for _, v := range upgrade {
-										if v == "websocket" {
-											host.WebsocketSupport = true
-											break
-										}
-									}
-								}
-							}
+			host := ParsedHost{
+				DomainNames: strings.Join(domains, ","),
+				SSLForced:   !plain,
+				HTTPSMode:   models.HTTPSModeRedirect,
+			}
+			if plain {
+				host.HTTPSMode = models.HTTPSModeHTTPOnly
+			}
 
-							// Default scheme
-							host.ForwardScheme = "http"
-							if host.SSLForced {
-								host.ForwardScheme = "https"
+			for _, handler := range siteHandlers(route.Handle) {
+				if handler.Handler == "reverse_proxy" && host.ForwardHost == "" {
+					upstreams := parseUpstreams(handler.Upstreams)
+					if len(upstreams) > 0 {
+						host.ForwardHost = upstreams[0].Host
+						host.ForwardPort = upstreams[0].Port
+					}
+					if len(upstreams) > 1 {
+						policy, weights := parseLoadBalancing(handler.LoadBalancing)
+						for i := range upstreams {
+							if i < len(weights) {
+								upstreams[i].Weight = weights[i]
 							}
 						}
+						host.Upstreams = upstreams
+						host.LoadBalancing = policy
+					}
 
-						// Detect unsupported features
-						if handler.Handler == "rewrite" {
-							host.Warnings = append(host.Warnings, "Rewrite rules not supported - manual configuration required")
-						}
-						if handler.Handler == "file_server" {
-							host.Warnings = append(host.Warnings, "File server directives not supported")
+					// Websockets need the upgrade headers passed upstream
+					if headers, ok := handler.Headers.(map[string]interface{}); ok {
+						request, _ := headers["request"].(map[string]interface{})
+						set, _ := request["set"].(map[string]interface{})
+						if _, ok := set["Upgrade"]; ok {
+							host.WebsocketSupport = true
 						}
 					}
 
-					// Store raw JSON for this route
-					routeJSON, _ := json.Marshal(map[string]interface{}{
-						"server": serverName,
-						"route":  routeIdx,
-						"data":   route,
-					})
-					host.RawJSON = string(routeJSON)
+					// The upstream scheme follows the transport, not the site address
+					host.ForwardScheme = "http"
+					if tlsConfig, ok := parseTransportTLS(handler.Transport); ok {
+						host.ForwardScheme = "https"
+						host.UpstreamTLS = tlsConfig
+					}
+				}
 
-					result.Hosts = append(result.Hosts, host)
+				// Detect unsupported features
+				if handler.Handler == "rewrite" {
+					host.Warnings = append(host.Warnings, "Rewrite rules not supported - manual configuration required")
 				}
+				if handler.Handler == "file_server" {
+					host.Warnings = append(host.Warnings, "File server directives not supported")
+				}
+			}
+
+			// Store raw JSON for this route
+			routeJSON, _ := json.Marshal(map[string]interface{}{
+				"server": serverName,
+				"route":  routeIdx,
+				"data":   route,
+			})
+			host.RawJSON = string(routeJSON)
+
+			for _, domain := range domains {
+				seenDomains[domain] = len(result.Hosts)
 			}
+			result.Hosts = append(result.Hosts, host)
 		}
 	}
 
 	return result, nil
 }
 
+// siteHandlers flattens the handlers of a site route, including the subroutes
+// `caddy adapt` wraps site blocks and handle blocks in. Routes matching a path
+// belong to a location and are left out, so the site's own proxy is found.
+func siteHandlers(handlers []*CaddyHandler) []*CaddyHandler {
+	var flat []*CaddyHandler
+	for _, handler := range handlers {
+		if handler.Handler != "subroute" {
+			flat = append(flat, handler)
+			continue
+		}
+		for _, route := range handler.Routes {
+			if !matchesPath(route.Match) {
+				flat = append(flat, siteHandlers(route.Handle)...)
+			}
+		}
+	}
+	return flat
+}
+
+func matchesPath(matchers []*CaddyMatcher) bool {
+	for _, match := range matchers {
+		if len(match.Path) > 0 || match.PathRegexp != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUpstreams converts reverse_proxy upstreams into models, skipping dynamic or malformed dials.
+func parseUpstreams(raw interface{}) []models.Upstream {
+	list, _ := raw.([]interface{})
+	upstreams := make([]models.Upstream, 0, len(list))
+
+	for _, item := range list {
+		upstream, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		dial, _ := upstream["dial"].(string)
+		host, portStr, err := net.SplitHostPort(dial)
+		if err != nil || host == "" {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			// Placeholders such as {env.PORT} can't be resolved at import time
+			port = 80
+		}
+		upstreams = append(upstreams, models.Upstream{Host: host, Port: port})
+	}
+
+	return upstreams
+}
+
+// parseLoadBalancing maps a reverse_proxy selection policy onto a CPM+ policy and weights.
+func parseLoadBalancing(raw interface{}) (string, []int) {
+	lb, _ := raw.(map[string]interface{})
+	selection, _ := lb["selection_policy"].(map[string]interface{})
+	policy, _ := selection["policy"].(string)
+
+	switch policy {
+	case "weighted_round_robin":
+		var weights []int
+		list, _ := selection["weights"].([]interface{})
+		for _, w := range list {
+			n, _ := w.(float64)
+			weights = append(weights, int(n))
+		}
+		return models.LoadBalancingRoundRobin, weights
+	case models.LoadBalancingLeastConn, models.LoadBalancingIPHash, models.LoadBalancingCookie, models.LoadBalancingFirst:
+		return policy, nil
+	default:
+		// Caddy's default is random; round_robin is the closest supported policy
+		return models.LoadBalancingRoundRobin, nil
+	}
+}
+
 // ImportFile performs complete import: parse Caddyfile and extract hosts.
 func (i *Importer) ImportFile(caddyfilePath string) (*ImportResult, error) {
 	caddyJSON, err := i.ParseCaddyfile(caddyfilePath)
@@ -228,6 +367,26 @@ func (i *Importer) ImportFile(caddyfilePath string) (*ImportResult, error) {
 	return i.ExtractHosts(caddyJSON)
 }
 
+// parseTransportTLS reports whether a reverse_proxy transport dials upstreams over TLS
+// and extracts the options CPM+ can represent. Client certificates and CA pools refer
+// to files on the source machine and are left for the user to upload.
+func parseTransportTLS(raw interface{}) (models.UpstreamTLSConfig, bool) {
+	var cfg models.UpstreamTLSConfig
+
+	transport, ok := raw.(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	tlsConfig, ok := transport["tls"].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+
+	cfg.InsecureSkipVerify, _ = tlsConfig["insecure_skip_verify"].(bool)
+	cfg.ServerName, _ = tlsConfig["server_name"].(string)
+	return cfg, true
+}
+
 // ConvertToProxyHosts converts parsed hosts to ProxyHost models.
 func ConvertToProxyHosts(parsedHosts []ParsedHost) []models.ProxyHost {
 	hosts := make([]models.ProxyHost, 0, len(parsedHosts))
@@ -244,7 +403,14 @@ func ConvertToProxyHosts(parsedHosts []ParsedHost) []models.ProxyHost {
 			ForwardHost:      parsed.ForwardHost,
 			ForwardPort:      parsed.ForwardPort,
 			SSLForced:        parsed.SSLForced,
+			HTTPSMode:        parsed.HTTPSMode,
 			WebsocketSupport: parsed.WebsocketSupport,
+			Upstreams:        parsed.Upstreams,
+			LoadBalancing:    parsed.LoadBalancing,
+			UpstreamTLS:      parsed.UpstreamTLS,
+			HTTP2Support:     true,
+			BlockExploits:    true,
+			Enabled:          true,
 		})
 	}
 
@@ -6,6 +6,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
 )
 
 func TestNewImporter(t *testing.T) {
@@ -268,3 +271,97 @@ func TestBackupCaddyfile(t *testing.T) {
 	_, err = BackupCaddyfile("non-existent", backupDir)
 	assert.Error(t, err)
 }
+
+func TestImporter_ExtractHosts_MultipleUpstreams(t *testing.T) {
+	importer := NewImporter("caddy")
+
+	caddyJSON := []byte(`{
+		"apps": {
+			"http": {
+				"servers": {
+					"srv0": {
+						"routes": [
+							{
+								"match": [{"host": ["lb.example.com"]}],
+								"handle": [
+									{
+										"handler": "reverse_proxy",
+										"upstreams": [{"dial": "app1:8080"}, {"dial": "app2:8080"}, {"dial": "[::1]:9000"}],
+										"load_balancing": {"selection_policy": {"policy": "weighted_round_robin", "weights": [3, 1, 1]}}
+									}
+								]
+							}
+						]
+					}
+				}
+			}
+		}
+	}`)
+
+	result, err := importer.ExtractHosts(caddyJSON)
+	require.NoError(t, err)
+	require.Len(t, result.Hosts, 1)
+
+	host := result.Hosts[0]
+	assert.Equal(t, "app1", host.ForwardHost)
+	assert.Equal(t, 8080, host.ForwardPort)
+	assert.Equal(t, "round_robin", host.LoadBalancing)
+	assert.Equal(t, []models.Upstream{
+		{Host: "app1", Port: 8080, Weight: 3},
+		{Host: "app2", Port: 8080, Weight: 1},
+		{Host: "::1", Port: 9000, Weight: 1},
+	}, host.Upstreams)
+
+	converted := ConvertToProxyHosts(result.Hosts)
+	require.Len(t, converted, 1)
+	assert.Len(t, converted[0].Upstreams, 3)
+}
+
+func TestImporter_ExtractHosts_HTTPSUpstream(t *testing.T) {
+	importer := NewImporter("caddy")
+
+	caddyJSON := []byte(`{
+		"apps": {
+			"http": {
+				"servers": {
+					"srv0": {
+						"tls_connection_policies": [{}],
+						"routes": [
+							{
+								"match": [{"host": ["pve.example.com"]}],
+								"handle": [{
+									"handler": "reverse_proxy",
+									"upstreams": [{"dial": "10.0.0.5:8006"}],
+									"transport": {"protocol": "http", "tls": {"insecure_skip_verify": true, "server_name": "pve.internal"}}
+								}]
+							},
+							{
+								"match": [{"host": ["app.example.com"]}],
+								"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "app:8080"}]}]
+							}
+						]
+					}
+				}
+			}
+		}
+	}`)
+
+	result, err := importer.ExtractHosts(caddyJSON)
+	require.NoError(t, err)
+	require.Len(t, result.Hosts, 2)
+
+	byDomain := map[string]ParsedHost{}
+	for _, h := range result.Hosts {
+		byDomain[h.DomainNames] = h
+	}
+
+	pve := byDomain["pve.example.com"]
+	assert.Equal(t, "https", pve.ForwardScheme)
+	assert.True(t, pve.UpstreamTLS.InsecureSkipVerify)
+	assert.Equal(t, "pve.internal", pve.UpstreamTLS.ServerName)
+
+	// A TLS site in front of a plain upstream still proxies over http
+	app := byDomain["app.example.com"]
+	assert.True(t, app.SSLForced)
+	assert.Equal(t, "http", app.ForwardScheme)
+}
@@ -0,0 +1,160 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// ZeroSSLEABSettingKey holds the ZeroSSL external account binding as JSON
+// {"key_id": "...", "mac_key": "..."}. It is stored encrypted.
+const ZeroSSLEABSettingKey = "caddy.zerossl_eab"
+
+// ACME directories of the selectable public issuers.
+const (
+	LetsEncryptDirectory        = "https://acme-v02.api.letsencrypt.org/directory"
+	LetsEncryptStagingDirectory = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	ZeroSSLDirectory            = "https://acme.zerossl.com/v2/DV90"
+)
+
+// ExternalAccount is an ACME external account binding.
+type ExternalAccount struct {
+	KeyID  string `json:"key_id"`
+	MACKey string `json:"mac_key"`
+}
+
+// IsSecretSetting reports whether key holds credentials that are stored encrypted
+// and never returned by the API.
+func IsSecretSetting(key string) bool {
+	return IsDNSProviderSetting(key) || key == ZeroSSLEABSettingKey ||
+		key == CrowdSecBouncerKeySettingKey || key == CrowdSecMachineSettingKey
+}
+
+// ParseExternalAccount decodes the ZeroSSL EAB setting.
+func ParseExternalAccount(raw string) (*ExternalAccount, error) {
+	var eab ExternalAccount
+	if err := json.Unmarshal([]byte(raw), &eab); err != nil {
+		return nil, fmt.Errorf("parse zerossl eab: %w", err)
+	}
+	if strings.TrimSpace(eab.KeyID) == "" || strings.TrimSpace(eab.MACKey) == "" {
+		return nil, errors.New("zerossl eab requires key_id and mac_key")
+	}
+	return &eab, nil
+}
+
+// policyKey identifies an automation policy: who issues and how ACME is solved.
+type policyKey struct {
+	issuer      string
+	dnsProvider string
+}
+
+// automationPolicies collects certificate subjects for every non-default
+// issuer and DNS provider combination used by hosts.
+type automationPolicies struct {
+	subjects  map[policyKey][]string
+	wildcards []string
+}
+
+func (p *automationPolicies) add(issuer, dnsProvider string, names ...string) {
+	if issuer == models.CertIssuerInternal {
+		// The internal CA signs locally; there is no challenge to solve
+		dnsProvider = ""
+	}
+	key := policyKey{issuer: issuer, dnsProvider: dnsProvider}
+	if key == (policyKey{}) {
+		return
+	}
+
+	if p.subjects == nil {
+		p.subjects = map[policyKey][]string{}
+	}
+	for _, name := range names {
+		if !slices.Contains(p.subjects[key], name) {
+			p.subjects[key] = append(p.subjects[key], name)
+		}
+	}
+}
+
+func (p *automationPolicies) addWildcard(name string) {
+	if !slices.Contains(p.wildcards, name) {
+		p.wildcards = append(p.wildcards, name)
+	}
+}
+
+// policies builds one automation policy per key, sorted by issuer then DNS provider.
+// They name their subjects and so must precede the catch-all policy.
+func (p *automationPolicies) policies(acmeEmail string, opts ConfigOptions) ([]*AutomationPolicy, error) {
+	keys := make([]policyKey, 0, len(p.subjects))
+	for key := range p.subjects {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].issuer != keys[j].issuer {
+			return keys[i].issuer < keys[j].issuer
+		}
+		return keys[i].dnsProvider < keys[j].dnsProvider
+	})
+
+	policies := make([]*AutomationPolicy, 0, len(keys))
+	for _, key := range keys {
+		issuer, err := issuerModule(key, acmeEmail, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		subjects := append([]string{}, p.subjects[key]...)
+		sort.Strings(subjects)
+		policies = append(policies, &AutomationPolicy{
+			Subjects:   subjects,
+			IssuersRaw: []interface{}{issuer},
+		})
+	}
+
+	return policies, nil
+}
+
+// issuerModule builds the issuer for a policy key.
+func issuerModule(key policyKey, acmeEmail string, opts ConfigOptions) (map[string]interface{}, error) {
+	if key.issuer == models.CertIssuerInternal {
+		return map[string]interface{}{"module": "internal"}, nil
+	}
+
+	issuer := map[string]interface{}{"module": "acme"}
+	if acmeEmail != "" {
+		issuer["email"] = acmeEmail
+	}
+
+	switch key.issuer {
+	case models.CertIssuerDefault:
+	case models.CertIssuerLetsEncrypt:
+		issuer["ca"] = LetsEncryptDirectory
+	case models.CertIssuerLetsEncryptStaging:
+		issuer["ca"] = LetsEncryptStagingDirectory
+	case models.CertIssuerZeroSSL:
+		if opts.ZeroSSLAccount == nil {
+			return nil, fmt.Errorf("zerossl issuer requires eab credentials (%s)", ZeroSSLEABSettingKey)
+		}
+		issuer["ca"] = ZeroSSLDirectory
+		issuer["external_account"] = map[string]interface{}{
+			"key_id":  opts.ZeroSSLAccount.KeyID,
+			"mac_key": opts.ZeroSSLAccount.MACKey,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported certificate issuer %q", key.issuer)
+	}
+
+	if key.dnsProvider != "" {
+		creds, ok := opts.DNSCredentials[key.dnsProvider]
+		if !ok {
+			return nil, fmt.Errorf("dns provider %s has no credentials configured", key.dnsProvider)
+		}
+		issuer["challenges"] = DNSChallenge(key.dnsProvider, creds)
+	}
+
+	return issuer, nil
+}
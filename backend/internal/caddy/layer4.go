@@ -0,0 +1,118 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// Layer4App configures the layer4 app (github.com/mholt/caddy-l4) used for TCP/UDP streams.
+type Layer4App struct {
+	Servers map[string]*Layer4Server `json:"servers"`
+}
+
+// Layer4Server listens on one or more addresses and routes raw connections.
+type Layer4Server struct {
+	Listen []string       `json:"listen"`
+	Routes []*Layer4Route `json:"routes"`
+}
+
+// Layer4Route pairs connection matchers with handlers. Routes without matchers match everything.
+type Layer4Route struct {
+	Match  []Layer4Match `json:"match,omitempty"`
+	Handle []Handler     `json:"handle"`
+}
+
+// Layer4Match matches connections by protocol properties.
+type Layer4Match struct {
+	TLS *Layer4TLSMatch `json:"tls,omitempty"`
+}
+
+// Layer4TLSMatch matches TLS connections by their ClientHello.
+type Layer4TLSMatch struct {
+	SNI []string `json:"sni,omitempty"`
+}
+
+// GenerateStreams builds the layer4 app for enabled streams. Streams sharing a protocol
+// and port become routes of one server, TLS-terminating streams routed by SNI ahead of
+// any plain stream. It also returns the server names that need managed certificates.
+func GenerateStreams(streams []models.Stream) (*Layer4App, []string) {
+	servers := map[string]*Layer4Server{}
+	var tlsNames []string
+
+	// Visit TLS streams first so their SNI routes precede catch-all routes.
+	sorted := make([]models.Stream, 0, len(streams))
+	for _, s := range streams {
+		if s.Enabled {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TLSTermination && !sorted[j].TLSTermination
+	})
+
+	for _, stream := range sorted {
+		protocol := stream.Protocol
+		if protocol == "" {
+			protocol = models.StreamProtocolTCP
+		}
+
+		name := fmt.Sprintf("cpm_stream_%s_%d", protocol, stream.ListenPort)
+		server, ok := servers[name]
+		if !ok {
+			server = &Layer4Server{
+				Listen: []string{fmt.Sprintf("%s/:%d", protocol, stream.ListenPort)},
+				Routes: []*Layer4Route{},
+			}
+			servers[name] = server
+		}
+
+		route := &Layer4Route{}
+		if stream.TLSTermination {
+			route.Match = []Layer4Match{{TLS: &Layer4TLSMatch{SNI: stream.TLSServerNames}}}
+			route.Handle = append(route.Handle, Handler{"handler": "tls"})
+			tlsNames = append(tlsNames, stream.TLSServerNames...)
+		}
+		route.Handle = append(route.Handle, Layer4ProxyHandler(protocol, stream.Upstreams, stream.LoadBalancing))
+
+		server.Routes = append(server.Routes, route)
+	}
+
+	if len(servers) == 0 {
+		return nil, nil
+	}
+	return &Layer4App{Servers: servers}, tlsNames
+}
+
+// Layer4ProxyHandler creates a layer4 proxy handler for the given upstreams.
+func Layer4ProxyHandler(protocol string, upstreams []models.Upstream, policy string) Handler {
+	prefix := ""
+	if protocol == models.StreamProtocolUDP {
+		prefix = "udp/"
+	}
+
+	ups := make([]map[string]interface{}, 0, len(upstreams))
+	for _, u := range upstreams {
+		ups = append(ups, map[string]interface{}{
+			"dial": []string{prefix + net.JoinHostPort(u.Host, strconv.Itoa(u.Port))},
+		})
+	}
+
+	h := Handler{
+		"handler":   "proxy",
+		"upstreams": ups,
+	}
+	if len(upstreams) > 1 {
+		if policy == "" {
+			policy = models.LoadBalancingRoundRobin
+		}
+		h["load_balancing"] = map[string]interface{}{
+			"selection": map[string]interface{}{"policy": policy},
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,109 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestGenerateStreams(t *testing.T) {
+	streams := []models.Stream{
+		{
+			UUID:       "postgres",
+			ListenPort: 5432,
+			Protocol:   models.StreamProtocolTCP,
+			Upstreams:  []models.Upstream{{Host: "db", Port: 5432}},
+			Enabled:    true,
+		},
+		{
+			UUID:       "dns",
+			ListenPort: 53,
+			Protocol:   models.StreamProtocolUDP,
+			Upstreams: []models.Upstream{
+				{Host: "10.0.0.2", Port: 53},
+				{Host: "10.0.0.3", Port: 53},
+			},
+			LoadBalancing: models.LoadBalancingFirst,
+			Enabled:       true,
+		},
+		{
+			UUID:       "disabled",
+			ListenPort: 1883,
+			Upstreams:  []models.Upstream{{Host: "mqtt", Port: 1883}},
+			Enabled:    false,
+		},
+	}
+
+	app, tlsNames := GenerateStreams(streams)
+	require.NotNil(t, app)
+	require.Empty(t, tlsNames)
+	require.Len(t, app.Servers, 2)
+
+	tcp := app.Servers["cpm_stream_tcp_5432"]
+	require.NotNil(t, tcp)
+	require.Equal(t, []string{"tcp/:5432"}, tcp.Listen)
+	require.Len(t, tcp.Routes, 1)
+	require.Empty(t, tcp.Routes[0].Match)
+	require.Equal(t, Handler{
+		"handler":   "proxy",
+		"upstreams": []map[string]interface{}{{"dial": []string{"db:5432"}}},
+	}, tcp.Routes[0].Handle[0])
+
+	udp := app.Servers["cpm_stream_udp_53"]
+	require.NotNil(t, udp)
+	require.Equal(t, []string{"udp/:53"}, udp.Listen)
+	proxy := udp.Routes[0].Handle[0]
+	require.Equal(t, []map[string]interface{}{
+		{"dial": []string{"udp/10.0.0.2:53"}},
+		{"dial": []string{"udp/10.0.0.3:53"}},
+	}, proxy["upstreams"])
+	require.Equal(t, map[string]interface{}{
+		"selection": map[string]interface{}{"policy": "first"},
+	}, proxy["load_balancing"])
+}
+
+func TestGenerateStreams_TLSBySNI(t *testing.T) {
+	streams := []models.Stream{
+		{
+			UUID:       "fallback",
+			ListenPort: 8883,
+			Upstreams:  []models.Upstream{{Host: "mqtt", Port: 1883}},
+			Enabled:    true,
+		},
+		{
+			UUID:           "mqtt-tls",
+			ListenPort:     8883,
+			Upstreams:      []models.Upstream{{Host: "broker", Port: 1883}},
+			TLSTermination: true,
+			TLSServerNames: []string{"mqtt.example.com"},
+			Enabled:        true,
+		},
+	}
+
+	config, err := GenerateConfig(nil, "/tmp/caddy-data", "", ConfigOptions{Streams: streams})
+	require.NoError(t, err)
+	require.Empty(t, config.Apps.HTTP.Servers)
+	require.NotNil(t, config.Apps.TLS)
+	require.Equal(t, []string{"mqtt.example.com"}, config.Apps.TLS.Certificates.Automate)
+
+	server := config.Apps.Layer4.Servers["cpm_stream_tcp_8883"]
+	require.Len(t, server.Routes, 2)
+
+	// The SNI route must come before the catch-all route
+	require.Equal(t, []Layer4Match{{TLS: &Layer4TLSMatch{SNI: []string{"mqtt.example.com"}}}}, server.Routes[0].Match)
+	require.Equal(t, "tls", server.Routes[0].Handle[0]["handler"])
+	require.Equal(t, "proxy", server.Routes[0].Handle[1]["handler"])
+	require.Empty(t, server.Routes[1].Match)
+
+	raw, err := json.Marshal(config.Apps.Layer4)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"servers":{"cpm_stream_tcp_8883":{"listen":["tcp/:8883"],"routes":[
+		{"match":[{"tls":{"sni":["mqtt.example.com"]}}],"handle":[{"handler":"tls"},{"handler":"proxy","upstreams":[{"dial":["broker:1883"]}]}]},
+		{"handle":[{"handler":"proxy","upstreams":[{"dial":["mqtt:1883"]}]}]}
+	]}}}`, string(raw))
+
+	require.NoError(t, Validate(config))
+}
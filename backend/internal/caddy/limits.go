@@ -0,0 +1,81 @@
+package caddy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// RateLimitHandler builds a rate_limit handler with one zone per rule. The module
+// keeps a zone's counters across config reloads by name, so zone names are
+// derived from the owning host or location.
+func RateLimitHandler(zonePrefix string, rules []models.RateLimitRule) Handler {
+	zones := make(map[string]interface{}, len(rules))
+	for i, rule := range rules {
+		zones[fmt.Sprintf("%s_%d", zonePrefix, i)] = map[string]interface{}{
+			"key":        rateLimitKey(rule),
+			"window":     seconds(rule.Window, 1),
+			"max_events": rule.Requests,
+		}
+	}
+	return Handler{
+		"handler":     "rate_limit",
+		"rate_limits": zones,
+	}
+}
+
+// rateLimitKey returns the placeholder whose value a rule counts requests by.
+func rateLimitKey(rule models.RateLimitRule) string {
+	switch rule.Key {
+	case models.RateLimitKeyHeader:
+		return "{http.request.header." + rule.Header + "}"
+	case models.RateLimitKeyPath:
+		return "{http.request.uri.path}"
+	default:
+		// client_ip honours trusted proxies; it is the remote address otherwise
+		return "{http.vars.client_ip}"
+	}
+}
+
+// RequestBodyHandler limits request bodies to maxSize bytes. Larger bodies fail
+// with 413 once the upstream starts reading them.
+func RequestBodyHandler(maxSize int64) Handler {
+	return Handler{
+		"handler":  "request_body",
+		"max_size": maxSize,
+	}
+}
+
+// LimitErrorRoute answers requests of domains that hit a rate limit with resp, or
+// returns nil to keep Caddy's empty 429 when resp is empty. It belongs to the
+// server's error routes, where the rate_limit handler's error ends up.
+func LimitErrorRoute(domains []string, resp models.LimitResponse) *Route {
+	if resp.StatusCode == 0 && resp.Body == "" {
+		return nil
+	}
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	return &Route{
+		Match: []Match{{
+			Host: domains,
+			Vars: map[string][]string{"{http.error.status_code}": {strconv.Itoa(http.StatusTooManyRequests)}},
+		}},
+		Handle: []Handler{{
+			"handler":     "static_response",
+			"status_code": status,
+			"body":        resp.Body,
+			// Retry-After is already set by the rate_limit handler
+			"headers": map[string][]string{"Content-Type": {contentType}},
+		}},
+		Terminal: true,
+	}
+}
@@ -0,0 +1,114 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestGenerateConfig_Limits(t *testing.T) {
+	host := models.ProxyHost{
+		UUID: "api", DomainNames: "api.example.com", ForwardHost: "api", ForwardPort: 80, Enabled: true,
+		HTTPSMode: models.HTTPSModeRedirect,
+		RateLimits: []models.RateLimitRule{
+			{Key: models.RateLimitKeyIP, Requests: 600, Window: 60},
+			{Key: models.RateLimitKeyHeader, Header: "X-Api-Key", Requests: 10, Window: 1},
+		},
+		MaxBodySize:   1 << 20,
+		LimitResponse: models.LimitResponse{Body: `{"error":"slow down"}`, ContentType: "application/json"},
+		Locations: []models.Location{
+			{UUID: "login", Path: "/login", ForwardHost: "api", ForwardPort: 80, RateLimits: []models.RateLimitRule{{Key: models.RateLimitKeyPath, Requests: 5, Window: 60}}},
+			{UUID: "upload", Path: "/upload", ForwardHost: "api", ForwardPort: 80, MaxBodySize: 100 << 20},
+		},
+	}
+
+	config, err := GenerateConfig([]models.ProxyHost{host}, "/tmp/caddy-data", "", ConfigOptions{})
+	require.NoError(t, err)
+	server := config.Apps.HTTP.Servers["cpm_server"]
+	routes := server.Routes
+
+	// Host-wide rate limits come first and fall through to the other routes
+	require.False(t, routes[0].Terminal)
+	require.Equal(t, Handler{
+		"handler": "rate_limit",
+		"rate_limits": map[string]interface{}{
+			"cpm_api_0": map[string]interface{}{"key": "{http.vars.client_ip}", "window": "60s", "max_events": 600},
+			"cpm_api_1": map[string]interface{}{"key": "{http.request.header.X-Api-Key}", "window": "1s", "max_events": 10},
+		},
+	}, routes[0].Handle[0])
+
+	require.Len(t, routes, 4)
+	login, upload, main := routes[1], routes[2], routes[3]
+	require.Equal(t, Handler{
+		"handler": "rate_limit",
+		"rate_limits": map[string]interface{}{
+			"cpm_api_login_0": map[string]interface{}{"key": "{http.request.uri.path}", "window": "60s", "max_events": 5},
+		},
+	}, login.Handle[0])
+	require.Equal(t, RequestBodyHandler(1<<20), login.Handle[1])
+	require.Equal(t, RequestBodyHandler(100<<20), upload.Handle[0])
+	require.Equal(t, RequestBodyHandler(1<<20), main.Handle[0])
+
+	// The limit response only exists on the HTTPS server; plain HTTP redirects
+	require.NotNil(t, server.Errors)
+	require.Equal(t, []*Route{{
+		Match: []Match{{Host: []string{"api.example.com"}, Vars: map[string][]string{"{http.error.status_code}": {"429"}}}},
+		Handle: []Handler{{
+			"handler":     "static_response",
+			"status_code": 429,
+			"body":        `{"error":"slow down"}`,
+			"headers":     map[string][]string{"Content-Type": {"application/json"}},
+		}},
+		Terminal: true,
+	}}, server.Errors.Routes)
+	require.Nil(t, config.Apps.HTTP.Servers["cpm_http"].Errors)
+
+	require.NoError(t, Validate(config))
+	require.NoError(t, Validate(config, "http.handlers.reverse_proxy", RateLimitModule))
+	require.ErrorContains(t, Validate(config, "http.handlers.reverse_proxy"), "the Caddy build lacks http.handlers.rate_limit")
+}
+
+func TestValidate_RateLimit(t *testing.T) {
+	route := func(zone map[string]interface{}) *Config {
+		return &Config{Apps: Apps{HTTP: &HTTPApp{Servers: map[string]*Server{
+			"srv": {Listen: []string{":80"}, Routes: []*Route{{
+				Match:  []Match{{Host: []string{"example.com"}}},
+				Handle: []Handler{{"handler": "rate_limit", "rate_limits": map[string]interface{}{"zone": zone}}},
+			}}},
+		}}}}
+	}
+
+	require.NoError(t, Validate(route(map[string]interface{}{"key": "{http.vars.client_ip}", "window": "1m", "max_events": 5})))
+	require.ErrorContains(t, Validate(route(map[string]interface{}{"key": "{http.vars.client_ip}", "window": "1m", "max_events": 0})), "at least one event")
+	require.ErrorContains(t, Validate(route(map[string]interface{}{"key": "{http.vars.client_ip}", "window": "soon", "max_events": 5})), "invalid window")
+	require.ErrorContains(t, Validate(route(map[string]interface{}{"window": "1m", "max_events": 5})), "has no key")
+}
+
+func TestGenerateConfig_RateLimitsWithoutModule(t *testing.T) {
+	limited := models.ProxyHost{
+		UUID: "api", DomainNames: "api.example.com", ForwardHost: "api", ForwardPort: 80, Enabled: true,
+		HTTPSMode:  models.HTTPSModeHTTPOnly,
+		RateLimits: []models.RateLimitRule{{Key: models.RateLimitKeyIP, Requests: 600, Window: 60}},
+		Locations: []models.Location{
+			{UUID: "login", Path: "/login", ForwardHost: "api", ForwardPort: 80, RateLimits: []models.RateLimitRule{{Key: models.RateLimitKeyPath, Requests: 5, Window: 60}}},
+		},
+	}
+	other := models.ProxyHost{UUID: "web", DomainNames: "web.example.com", ForwardHost: "web", ForwardPort: 80, Enabled: true, HTTPSMode: models.HTTPSModeHTTPOnly}
+	modules := []string{"http.handlers.reverse_proxy"}
+
+	config, err := GenerateConfig([]models.ProxyHost{limited, other}, "/tmp/caddy-data", "", ConfigOptions{Modules: modules})
+	require.NoError(t, err)
+	require.Equal(t, []string{"proxy host api: rate limits skipped: the Caddy build lacks http.handlers.rate_limit"}, config.Warnings)
+	routes := config.Apps.HTTP.Servers["cpm_http"].Routes
+	require.NotEmpty(t, routes)
+	require.NoError(t, walkHandlers(routes, func(handler Handler) error {
+		require.NotEqual(t, "rate_limit", handler["handler"])
+		return nil
+	}))
+	require.NoError(t, Validate(config, modules...))
+
+	// The stored host keeps its rules for when the module shows up
+	require.Len(t, limited.Locations[0].RateLimits, 1)
+}
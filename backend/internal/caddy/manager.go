@@ -4,22 +4,31 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 )
 
+// ErrRolledBack is wrapped by ApplyConfig when Caddy rejected a config and the previous snapshot was restored.
+var ErrRolledBack = errors.New("rolled back")
+
 // Manager orchestrates Caddy configuration lifecycle: generate, validate, apply, rollback.
 type Manager struct {
-	client    *Client
-	db        *gorm.DB
-	configDir string
+	client      *Client
+	db          *gorm.DB
+	configDir   string
+	cipher      *secrets.Cipher
+	internalURL string
+	modules     []string
 }
 
 // NewManager creates a configuration manager.
@@ -31,32 +40,49 @@ func NewManager(client *Client, db *gorm.DB, configDir string) *Manager {
 	}
 }
 
+// SetCipher sets the cipher used to decrypt custom certificate keys.
+func (m *Manager) SetCipher(cipher *secrets.Cipher) {
+	m.cipher = cipher
+}
+
+// SetInternalURL sets the base URL Caddy reaches CPM at, for access lists CPM checks itself.
+func (m *Manager) SetInternalURL(internalURL string) {
+	m.internalURL = internalURL
+}
+
+// SetModules sets the IDs of the modules in the running Caddy build, which
+// decide whether optional handlers such as the CrowdSec bouncer are generated.
+func (m *Manager) SetModules(modules []string) {
+	m.modules = modules
+}
+
 // ApplyConfig generates configuration from database, validates it, applies to Caddy with rollback on failure.
 func (m *Manager) ApplyConfig(ctx context.Context) error {
-	// Fetch all proxy hosts from database
-	var hosts []models.ProxyHost
-	if err := m.db.Find(&hosts).Error; err != nil {
-		return fmt.Errorf("fetch proxy hosts: %w", err)
-	}
-
-	// Fetch ACME email setting
-	var acmeEmailSetting models.Setting
-	var acmeEmail string
-	if err := m.db.Where("key = ?", "caddy.acme_email").First(&acmeEmailSetting).Error; err == nil {
-		acmeEmail = acmeEmailSetting.Value
+	hosts, acmeEmail, opts, err := m.configSources()
+	if err != nil {
+		return err
 	}
 
 	// Generate Caddy config
-	config, err := GenerateConfig(hosts, filepath.Join(m.configDir, "data"), acmeEmail)
+	storageDir := filepath.Join(m.configDir, "data")
+	config, err := GenerateConfig(hosts, storageDir, acmeEmail, opts)
 	if err != nil {
 		return fmt.Errorf("generate config: %w", err)
 	}
+	for _, warning := range config.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
 
 	// Validate before applying
-	if err := Validate(config); err != nil {
+	if err := Validate(config, m.modules...); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	// Caddy reads upstream client certificates from disk
+	if err := WriteUpstreamClientCerts(storageDir, hosts); err != nil {
+		return err
+	}
+
 	// Save snapshot for rollback
 	snapshotPath, err := m.saveSnapshot(config)
 	if err != nil {
@@ -81,7 +107,7 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 
 		// Record failed attempt
 		m.recordConfigChange(configHash, false, err.Error())
-		return fmt.Errorf("apply failed (rolled back): %w", err)
+		return fmt.Errorf("apply failed (%w): %w", ErrRolledBack, err)
 	}
 
 	// Record successful application
@@ -96,18 +122,279 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 	return nil
 }
 
-// saveSnapshot stores the config to disk with timestamp.
+// configSources loads the proxy hosts and the options GenerateConfig needs
+// from the database and settings.
+func (m *Manager) configSources() ([]models.ProxyHost, string, ConfigOptions, error) {
+	// Fetch all proxy hosts from database
+	var hosts []models.ProxyHost
+	if err := m.db.Preload("Locations").Find(&hosts).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch proxy hosts: %w", err)
+	}
+
+	var redirects []models.RedirectionHost
+	if err := m.db.Find(&redirects).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch redirection hosts: %w", err)
+	}
+
+	var streams []models.Stream
+	if err := m.db.Find(&streams).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch streams: %w", err)
+	}
+
+	var profiles []models.SecurityHeaderProfile
+	if err := m.db.Find(&profiles).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch security header profiles: %w", err)
+	}
+
+	var accessLists []models.AccessList
+	if err := m.db.Preload("Users").Find(&accessLists).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch access lists: %w", err)
+	}
+
+	var templates []models.PageTemplate
+	if err := m.db.Find(&templates).Error; err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("fetch page templates: %w", err)
+	}
+
+	if err := m.decryptClientKeys(hosts); err != nil {
+		return nil, "", ConfigOptions{}, err
+	}
+	certs, err := m.loadCertificates(hosts)
+	if err != nil {
+		return nil, "", ConfigOptions{}, err
+	}
+
+	dnsCredentials, err := m.loadDNSCredentials()
+	if err != nil {
+		return nil, "", ConfigOptions{}, err
+	}
+	zeroSSLAccount, err := m.loadZeroSSLAccount()
+	if err != nil {
+		return nil, "", ConfigOptions{}, err
+	}
+	crowdSec, err := m.loadCrowdSec()
+	if err != nil {
+		return nil, "", ConfigOptions{}, err
+	}
+
+	// Fetch ACME email setting
+	acmeEmail := m.getSetting("caddy.acme_email")
+
+	// Custom exploit rules extend the built-in set
+	customRules, err := ParseExploitRules(m.getSetting(ExploitRulesSettingKey))
+	if err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("load exploit rules: %w", err)
+	}
+	protocols, err := ParseProtocols(m.getSetting(ProtocolsSettingKey))
+	if err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("load protocols: %w", err)
+	}
+	trustedProxies, err := ParseTrustedProxies(m.getSetting(TrustedProxiesSettingKey))
+	if err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("load trusted proxies: %w", err)
+	}
+	defaultSite, err := ParseDefaultSite(m.getSetting(DefaultSiteSettingKey))
+	if err != nil {
+		return nil, "", ConfigOptions{}, fmt.Errorf("load default site: %w", err)
+	}
+	opts := ConfigOptions{
+		ExploitRules:     DefaultExploitRules().Merge(customRules),
+		RedirectionHosts: redirects,
+		Streams:          streams,
+		Protocols:        protocols,
+		Certificates:     certs,
+		DNSCredentials:   dnsCredentials,
+		ZeroSSLAccount:   zeroSSLAccount,
+
+		SecurityHeaderProfiles: profiles,
+		AccessLists:            accessLists,
+		PageTemplates:          templates,
+		TrustedProxies:         trustedProxies,
+		InternalURL:            m.internalURL,
+		Modules:                m.modules,
+		CrowdSec:               crowdSec,
+		DefaultSite:            defaultSite,
+	}
+
+	return hosts, acmeEmail, opts, nil
+}
+
+// Export renders the managed configuration as the Caddy JSON ApplyConfig would
+// load (ExportFormatJSON) or as a Caddyfile of the proxy hosts. Both replace
+// secrets with {env.*} placeholders.
+func (m *Manager) Export(format string) ([]byte, error) {
+	hosts, acmeEmail, opts, err := m.configSources()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ExportFormatCaddyfile:
+		return ExportCaddyfile(hosts, acmeEmail, opts), nil
+	case ExportFormatJSON:
+		return ExportJSON(hosts, filepath.Join(m.configDir, "data"), acmeEmail, opts)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// loadCertificates fetches the custom certificates referenced by hosts and decrypts their keys.
+func (m *Manager) loadCertificates(hosts []models.ProxyHost) ([]models.SSLCertificate, error) {
+	var ids []uint
+	for _, host := range hosts {
+		if host.CertificateID != nil {
+			ids = append(ids, *host.CertificateID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if m.cipher == nil {
+		return nil, errors.New("custom certificates require an encryption key")
+	}
+
+	var certs []models.SSLCertificate
+	if err := m.db.Where("id IN ?", ids).Find(&certs).Error; err != nil {
+		return nil, fmt.Errorf("fetch certificates: %w", err)
+	}
+
+	for i := range certs {
+		key, err := m.cipher.Decrypt(certs[i].PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt certificate %s: %w", certs[i].UUID, err)
+		}
+		certs[i].PrivateKey = key
+	}
+
+	return certs, nil
+}
+
+// decryptClientKeys decrypts the upstream client keys of hosts in place. Keys
+// stored before they were encrypted are used as they are.
+func (m *Manager) decryptClientKeys(hosts []models.ProxyHost) error {
+	for i := range hosts {
+		key := hosts[i].UpstreamTLS.ClientKeyPEM
+		if !secrets.IsEncrypted(key) {
+			continue
+		}
+		if m.cipher == nil {
+			return errors.New("upstream client certificates require an encryption key")
+		}
+		plain, err := m.cipher.Decrypt(key)
+		if err != nil {
+			return fmt.Errorf("decrypt client key of proxy host %s: %w", hosts[i].UUID, err)
+		}
+		hosts[i].UpstreamTLS.ClientKeyPEM = plain
+	}
+	return nil
+}
+
+// loadDNSCredentials decrypts and parses every configured DNS provider.
+func (m *Manager) loadDNSCredentials() (map[string]map[string]string, error) {
+	var settings []models.Setting
+	if err := m.db.Where("key LIKE ?", DNSProviderSettingPrefix+"%").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("fetch dns providers: %w", err)
+	}
+
+	credentials := make(map[string]map[string]string, len(settings))
+	for _, setting := range settings {
+		provider := strings.TrimPrefix(setting.Key, DNSProviderSettingPrefix)
+		raw, err := m.decryptSetting(setting)
+		if err != nil {
+			return nil, err
+		}
+
+		creds, err := ParseDNSCredentials(provider, raw)
+		if err != nil {
+			return nil, fmt.Errorf("load dns provider: %w", err)
+		}
+		credentials[provider] = creds
+	}
+
+	return credentials, nil
+}
+
+// loadZeroSSLAccount returns the ZeroSSL EAB, or nil when none is configured.
+func (m *Manager) loadZeroSSLAccount() (*ExternalAccount, error) {
+	var setting models.Setting
+	if err := m.db.Where("key = ?", ZeroSSLEABSettingKey).Limit(1).Find(&setting).Error; err != nil {
+		return nil, fmt.Errorf("fetch zerossl eab: %w", err)
+	}
+	if setting.Value == "" {
+		return nil, nil
+	}
+
+	raw, err := m.decryptSetting(setting)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExternalAccount(raw)
+}
+
+// loadCrowdSec returns the LAPI connection, or nil when CrowdSec is not configured.
+func (m *Manager) loadCrowdSec() (*CrowdSecApp, error) {
+	apiURL, err := ParseCrowdSecLAPIURL(m.getSetting(CrowdSecLAPIURLSettingKey))
+	if err != nil {
+		return nil, fmt.Errorf("load crowdsec: %w", err)
+	}
+	var setting models.Setting
+	if err := m.db.Where("key = ?", CrowdSecBouncerKeySettingKey).Limit(1).Find(&setting).Error; err != nil {
+		return nil, fmt.Errorf("fetch crowdsec bouncer key: %w", err)
+	}
+	if apiURL == "" || setting.Value == "" {
+		return nil, nil
+	}
+
+	apiKey, err := m.decryptSetting(setting)
+	if err != nil {
+		return nil, err
+	}
+	return &CrowdSecApp{APIURL: apiURL + "/", APIKey: apiKey, TickerInterval: "15s"}, nil
+}
+
+// decryptSetting returns the plaintext of a secret setting.
+func (m *Manager) decryptSetting(setting models.Setting) (string, error) {
+	if !secrets.IsEncrypted(setting.Value) {
+		return setting.Value, nil
+	}
+	if m.cipher == nil {
+		return "", fmt.Errorf("%s: secret settings require an encryption key", setting.Key)
+	}
+	raw, err := m.cipher.Decrypt(setting.Value)
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", setting.Key, err)
+	}
+	return raw, nil
+}
+
+// getSetting returns a setting value, or "" when it is unset.
+func (m *Manager) getSetting(key string) string {
+	var setting models.Setting
+	// Find instead of First: a missing setting is normal and shouldn't be logged as an error
+	if err := m.db.Where("key = ?", key).Limit(1).Find(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+// saveSnapshot stores the config to disk with timestamp. Secrets are encrypted
+// so rollback can restore them, or left out when there is no encryption key.
 func (m *Manager) saveSnapshot(config *Config) (string, error) {
 	timestamp := time.Now().Unix()
 	filename := fmt.Sprintf("config-%d.json", timestamp)
 	path := filepath.Join(m.configDir, filename)
 
-	configJSON, err := json.MarshalIndent(config, "", "  ")
+	configJSON, err := marshalReplacingSecrets(config, func(name, value string) (string, error) {
+		if m.cipher == nil {
+			return envPlaceholder(name), nil
+		}
+		return m.cipher.Encrypt(value)
+	})
 	if err != nil {
 		return "", fmt.Errorf("marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, configJSON, 0644); err != nil {
+	if err := os.WriteFile(path, configJSON, 0600); err != nil {
 		return "", fmt.Errorf("write snapshot: %w", err)
 	}
 
@@ -128,13 +415,18 @@ func (m *Manager) rollback(ctx context.Context) error {
 		return fmt.Errorf("read snapshot: %w", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(configJSON, &config); err != nil {
+	config, err := unmarshalReplacingSecrets(configJSON, func(_, value string) (string, error) {
+		if m.cipher == nil || !secrets.IsEncrypted(value) {
+			return value, nil
+		}
+		return m.cipher.Decrypt(value)
+	})
+	if err != nil {
 		return fmt.Errorf("unmarshal snapshot: %w", err)
 	}
 
 	// Apply the snapshot
-	if err := m.client.Load(ctx, &config); err != nil {
+	if err := m.client.Load(ctx, config); err != nil {
 		return fmt.Errorf("load snapshot: %w", err)
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -40,7 +42,7 @@ func TestManager_ApplyConfig(t *testing.T) {
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Setting{}, &models.CaddyConfig{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
 
 	// Setup Manager
 	tmpDir := t.TempDir()
@@ -77,7 +79,7 @@ func TestManager_ApplyConfig_Failure(t *testing.T) {
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Setting{}, &models.CaddyConfig{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
 
 	// Setup Manager
 	tmpDir := t.TempDir()
@@ -158,7 +160,7 @@ func TestManager_RotateSnapshots(t *testing.T) {
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Setting{}, &models.CaddyConfig{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
 
 	client := NewClient(caddyServer.URL)
 	manager := NewManager(client, db, tmpDir)
@@ -189,3 +191,156 @@ func TestManager_RotateSnapshots(t *testing.T) {
 	// Should be 10 (kept)
 	assert.Equal(t, 10, count)
 }
+
+func TestManager_ApplyConfig_DecryptsDNSCredentials(t *testing.T) {
+	var loaded Config
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" {
+			_ = json.NewDecoder(r.Body).Decode(&loaded)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer caddyServer.Close()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(`{"api_token":"cf-token"}`)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.Setting{Key: DNSProviderSettingKey("cloudflare"), Value: encrypted}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "h", DomainNames: "app.example.com", ForwardHost: "127.0.0.1", ForwardPort: 8080, DNSProvider: "cloudflare", Enabled: true}).Error)
+
+	manager := NewManager(NewClient(caddyServer.URL), db, t.TempDir())
+
+	// Without the key the credentials cannot be read
+	require.ErrorContains(t, manager.ApplyConfig(context.Background()), "secret settings require an encryption key")
+
+	manager.SetCipher(cipher)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	require.NotNil(t, loaded.Apps.TLS)
+	policy := loaded.Apps.TLS.Automation.Policies[0]
+	require.Equal(t, []string{"app.example.com"}, policy.Subjects)
+	issuer := policy.IssuersRaw[0].(map[string]interface{})
+	provider := issuer["challenges"].(map[string]interface{})["dns"].(map[string]interface{})["provider"].(map[string]interface{})
+	require.Equal(t, "cf-token", provider["api_token"])
+}
+
+func TestManager_SnapshotsEncryptSecrets(t *testing.T) {
+	loads := 0
+	var restored []byte
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loads++
+		switch loads {
+		case 2:
+			w.WriteHeader(http.StatusBadRequest)
+		case 3:
+			restored, _ = io.ReadAll(r.Body)
+		}
+	}))
+	defer caddyServer.Close()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(`{"api_token":"cf-token"}`)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.Setting{Key: DNSProviderSettingKey("cloudflare"), Value: encrypted}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "h", DomainNames: "app.example.com", ForwardHost: "127.0.0.1", ForwardPort: 8080, DNSProvider: "cloudflare", Enabled: true}).Error)
+
+	configDir := t.TempDir()
+	manager := NewManager(NewClient(caddyServer.URL), db, configDir)
+	manager.SetCipher(cipher)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	snapshots, err := manager.listSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	snapshot, err := os.ReadFile(snapshots[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(snapshot), "cf-token")
+	// Snapshots are named by the second; keep the next apply from overwriting this one
+	require.NoError(t, os.Rename(snapshots[0], filepath.Join(configDir, "config-1.json")))
+
+	// Rolling back restores the decrypted credentials
+	require.ErrorIs(t, manager.ApplyConfig(context.Background()), ErrRolledBack)
+	require.Contains(t, string(restored), `"api_token":"cf-token"`)
+}
+
+func TestManager_ApplyConfig_DecryptsUpstreamClientKey(t *testing.T) {
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer caddyServer.Close()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	certPEM, keyPEM, _ := selfSignedPEM(t, "cpm-client")
+	encrypted, err := cipher.Encrypt(keyPEM)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.ProxyHost{
+		UUID: "mtls", DomainNames: "app.example.com", ForwardScheme: "https", ForwardHost: "10.0.0.5", ForwardPort: 443, Enabled: true,
+		UpstreamTLS: models.UpstreamTLSConfig{ClientCertPEM: certPEM, ClientKeyPEM: encrypted},
+	}).Error)
+
+	configDir := t.TempDir()
+	manager := NewManager(NewClient(caddyServer.URL), db, configDir)
+	require.ErrorContains(t, manager.ApplyConfig(context.Background()), "require an encryption key")
+
+	// Caddy reads the decrypted key from the file written for it
+	manager.SetCipher(cipher)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	_, keyPath := upstreamClientCertPaths(filepath.Join(configDir, "data"), "mtls")
+	key, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, keyPEM, string(key))
+}
+
+func TestManager_Export(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.Stream{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}, &models.CaddyConfig{}))
+
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(`{"api_token":"cf-token"}`)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.Setting{Key: DNSProviderSettingKey("cloudflare"), Value: encrypted}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: "caddy.acme_email", Value: "admin@example.com"}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "h", DomainNames: "app.example.com", ForwardHost: "127.0.0.1", ForwardPort: 8080, DNSProvider: "cloudflare", Enabled: true}).Error)
+
+	manager := NewManager(nil, db, t.TempDir())
+	manager.SetCipher(cipher)
+
+	caddyfile, err := manager.Export(ExportFormatCaddyfile)
+	require.NoError(t, err)
+	require.Contains(t, string(caddyfile), "email admin@example.com")
+	require.Contains(t, string(caddyfile), "app.example.com {")
+	require.Contains(t, string(caddyfile), "api_token {env.CLOUDFLARE_API_TOKEN}")
+	require.NotContains(t, string(caddyfile), "cf-token")
+
+	// The JSON export is the config ApplyConfig loads, without the credentials
+	raw, err := manager.Export(ExportFormatJSON)
+	require.NoError(t, err)
+	var config Config
+	require.NoError(t, json.Unmarshal(raw, &config))
+	require.Equal(t, []string{"app.example.com"}, config.Apps.HTTP.Servers["cpm_server"].Routes[len(config.Apps.HTTP.Servers["cpm_server"].Routes)-1].Match[0].Host)
+	require.NotContains(t, string(raw), "cf-token")
+	require.Contains(t, string(raw), `"api_token": "{env.CLOUDFLARE_API_TOKEN}"`)
+
+	_, err = manager.Export("nginx")
+	require.ErrorContains(t, err, `unsupported export format "nginx"`)
+}
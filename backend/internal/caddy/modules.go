@@ -0,0 +1,106 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Optional modules CPM generates config for. They are not part of standard
+// Caddy, so hosts only get them when the running build has them.
+const (
+	CrowdSecModule  = "http.handlers.crowdsec"   // github.com/hslatman/caddy-crowdsec-bouncer
+	RateLimitModule = "http.handlers.rate_limit" // github.com/mholt/caddy-ratelimit
+)
+
+// optionalHandlers maps the handler names of optional modules to their module IDs.
+var optionalHandlers = map[string]string{
+	"crowdsec":   CrowdSecModule,
+	"rate_limit": RateLimitModule,
+}
+
+// ListModules returns the IDs of the modules compiled into the Caddy binary, as
+// printed by `caddy list-modules`.
+func ListModules(executor Executor, caddyBinary string) ([]string, error) {
+	output, err := executor.Execute(caddyBinary, "list-modules")
+	if err != nil {
+		return nil, fmt.Errorf("list caddy modules: %w", err)
+	}
+
+	var modules []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		// Skip blank lines and the "Standard modules: 106" style summaries
+		if line == "" || strings.Contains(line, " ") {
+			continue
+		}
+		modules = append(modules, line)
+	}
+	return modules, nil
+}
+
+// hasModule reports whether module is among the available modules.
+func hasModule(modules []string, module string) bool {
+	return slices.Contains(modules, module)
+}
+
+// validateModules rejects handlers of optional modules missing from modules.
+func validateModules(cfg *Config, modules []string) error {
+	if cfg.Apps.HTTP == nil {
+		return nil
+	}
+	for serverName, server := range cfg.Apps.HTTP.Servers {
+		routes := server.Routes
+		if server.Errors != nil {
+			routes = append(slices.Clone(routes), server.Errors.Routes...)
+		}
+		if err := walkHandlers(routes, func(handler Handler) error {
+			name, _ := handler["handler"].(string)
+			if module, optional := optionalHandlers[name]; optional && !hasModule(modules, module) {
+				return fmt.Errorf("server %s uses the %s handler, but the Caddy build lacks %s", serverName, name, module)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkHandlers calls fn for every handler of routes, including handlers nested
+// in subroutes, advanced configs and reverse_proxy response routes.
+func walkHandlers(routes []*Route, fn func(Handler) error) error {
+	for _, route := range routes {
+		for _, handler := range route.Handle {
+			if err := fn(handler); err != nil {
+				return err
+			}
+			if nested, ok := handler["routes"].([]*Route); ok {
+				if err := walkHandlers(nested, fn); err != nil {
+					return err
+				}
+			}
+			if raw, ok := handler["routes"].(json.RawMessage); ok {
+				var nested []advancedRoute
+				if err := json.Unmarshal(raw, &nested); err != nil {
+					return err
+				}
+				for _, route := range nested {
+					if err := walkHandlers([]*Route{{Handle: route.Handle}}, fn); err != nil {
+						return err
+					}
+				}
+			}
+			responses, _ := handler["handle_response"].([]map[string]interface{})
+			for _, response := range responses {
+				if nested, ok := response["routes"].([]*Route); ok {
+					if err := walkHandlers(nested, fn); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
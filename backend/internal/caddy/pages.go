@@ -0,0 +1,155 @@
+package caddy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// DefaultMaintenancePage is served by hosts in maintenance mode without a template.
+const DefaultMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Down for maintenance</title>
+<style>
+body { font-family: system-ui, sans-serif; color: #333; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { max-width: 32rem; padding: 2rem; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+<h1>Down for maintenance</h1>
+<p>This site is being upgraded and will be back shortly.</p>
+</main>
+</body>
+</html>
+`
+
+// errorStatusPlaceholder holds the status of the error being handled in error routes.
+const errorStatusPlaceholder = "{http.error.status_code}"
+
+// htmlHeaders are the headers of pages Caddy serves itself. They must not be
+// cached, or clients keep seeing them after the host is back.
+func htmlHeaders() map[string][]string {
+	return map[string][]string{
+		"Content-Type":  {"text/html; charset=utf-8"},
+		"Cache-Control": {"no-store"},
+	}
+}
+
+// pageTemplates resolves the page templates referenced by hosts.
+type pageTemplates map[uint]*models.PageTemplate
+
+func newPageTemplates(templates []models.PageTemplate) pageTemplates {
+	byID := make(pageTemplates, len(templates))
+	for i := range templates {
+		byID[templates[i].ID] = &templates[i]
+	}
+	return byID
+}
+
+func (p pageTemplates) content(id uint) (string, error) {
+	template, ok := p[id]
+	if !ok {
+		return "", fmt.Errorf("page template %d not found", id)
+	}
+	return template.Content, nil
+}
+
+// maintenanceRoutes answer requests for domains with the maintenance page and
+// 503, except for clients in the bypass networks or holding the bypass cookie.
+// Visiting any URL with ?cpm_maintenance=<secret> sets the cookie.
+func maintenanceRoutes(domains []string, m models.MaintenanceConfig, page string, access *accessControl) []*Route {
+	var routes []*Route
+	var bypass []Match
+	if len(m.BypassIPs) > 0 {
+		bypass = append(bypass, access.ipMatch(m.BypassIPs))
+	}
+	if m.BypassCookie != "" {
+		bypass = append(bypass, Match{Vars: map[string][]string{
+			"{http.request.cookie." + models.MaintenanceCookie + "}": {m.BypassCookie},
+		}})
+		routes = append(routes, &Route{
+			Match: []Match{{Host: domains, Query: map[string][]string{models.MaintenanceCookie: {m.BypassCookie}}}},
+			Handle: []Handler{StaticResponseHandler(http.StatusFound, "", map[string][]string{
+				"Location":   {"{http.request.uri.path}"},
+				"Set-Cookie": {models.MaintenanceCookie + "=" + m.BypassCookie + "; Path=/; HttpOnly; SameSite=Lax"},
+			})},
+			Terminal: true,
+		})
+	}
+
+	return append(routes, &Route{
+		Match:    []Match{{Host: domains, Not: bypass}},
+		Handle:   []Handler{StaticResponseHandler(http.StatusServiceUnavailable, page, htmlHeaders())},
+		Terminal: true,
+	})
+}
+
+// errorPageRoutes serve the error pages of a host from the server's error
+// routes. Exact statuses are matched before classes such as 5xx.
+func errorPageRoutes(domains []string, pages []models.ErrorPage, templates pageTemplates) ([]*Route, error) {
+	var exact, classes []*Route
+	for _, page := range pages {
+		content, err := templates.content(page.TemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("error page %s: %w", page.Status, err)
+		}
+
+		match := Match{Host: domains}
+		if code, err := strconv.Atoi(page.Status); err == nil {
+			match.Vars = map[string][]string{errorStatusPlaceholder: {strconv.Itoa(code)}}
+		} else {
+			match.VarsRegexp = map[string]*RegexpMatch{errorStatusPlaceholder: {Pattern: "^" + page.Status[:1] + `\d\d$`}}
+		}
+		route := &Route{
+			Match: []Match{match},
+			Handle: []Handler{{
+				"handler":     "static_response",
+				"status_code": errorStatusPlaceholder,
+				"body":        content,
+				"headers":     htmlHeaders(),
+			}},
+			Terminal: true,
+		}
+		if match.Vars != nil {
+			exact = append(exact, route)
+		} else {
+			classes = append(classes, route)
+		}
+	}
+	return append(exact, classes...), nil
+}
+
+// InterceptErrors turns upstream responses whose status has an error page into
+// errors, so the page replaces them like errors Caddy raises itself.
+func InterceptErrors(proxy Handler, pages []models.ErrorPage) {
+	var codes []int
+	for _, page := range pages {
+		code, err := strconv.Atoi(page.Status)
+		if err != nil {
+			// Classes such as 5xx match by their first digit
+			code = int(page.Status[0] - '0')
+		}
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return
+	}
+
+	responses, _ := proxy["handle_response"].([]map[string]interface{})
+	proxy["handle_response"] = append(responses, map[string]interface{}{
+		"match": map[string][]int{"status_code": codes},
+		"routes": []*Route{{Handle: []Handler{{
+			"handler":     "error",
+			"status_code": "{http.reverse_proxy.status_code}",
+		}}}},
+	})
+}
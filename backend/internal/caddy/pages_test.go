@@ -0,0 +1,108 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestGenerateConfig_Maintenance(t *testing.T) {
+	templateID := uint(1)
+	hosts := []models.ProxyHost{
+		{
+			UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+			HTTPSMode: models.HTTPSModeRedirect,
+			Maintenance: models.MaintenanceConfig{
+				Enabled: true, TemplateID: &templateID,
+				BypassIPs: []string{"10.0.0.0/8"}, BypassCookie: "s3cret-s3cret-s3cret",
+			},
+		},
+		{
+			UUID: "blog", DomainNames: "blog.example.com", ForwardHost: "blog", ForwardPort: 80, Enabled: true,
+			HTTPSMode:   models.HTTPSModeRedirect,
+			Maintenance: models.MaintenanceConfig{Enabled: true},
+		},
+	}
+	opts := ConfigOptions{PageTemplates: []models.PageTemplate{{ID: 1, Name: "Upgrade", Content: "<h1>Back soon</h1>"}}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", opts)
+	require.NoError(t, err)
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 4)
+
+	// The bypass link sets the cookie
+	require.Equal(t, map[string][]string{"cpm_maintenance": {"s3cret-s3cret-s3cret"}}, routes[0].Match[0].Query)
+	require.Equal(t, []string{"cpm_maintenance=s3cret-s3cret-s3cret; Path=/; HttpOnly; SameSite=Lax"}, routes[0].Handle[0]["headers"].(map[string][]string)["Set-Cookie"])
+
+	// Everyone else gets the page, bypassing clients fall through to the proxy
+	require.Equal(t, []Match{{
+		Host: []string{"app.example.com"},
+		Not: []Match{
+			{RemoteIP: &IPMatch{Ranges: []string{"10.0.0.0/8"}}},
+			{Vars: map[string][]string{"{http.request.cookie.cpm_maintenance}": {"s3cret-s3cret-s3cret"}}},
+		},
+	}}, routes[1].Match)
+	require.Equal(t, StaticResponseHandler(503, "<h1>Back soon</h1>", htmlHeaders()), routes[1].Handle[0])
+	require.Equal(t, "reverse_proxy", routes[2].Handle[len(routes[2].Handle)-1]["handler"])
+
+	// Without a bypass the host is not proxied at all
+	require.Equal(t, []Match{{Host: []string{"blog.example.com"}}}, routes[3].Match)
+	require.Equal(t, DefaultMaintenancePage, routes[3].Handle[0]["body"])
+	require.NoError(t, Validate(config))
+
+	// Templates must exist
+	_, err = GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{})
+	require.ErrorContains(t, err, "page template 1 not found")
+}
+
+func TestGenerateConfig_ErrorPages(t *testing.T) {
+	host := models.ProxyHost{
+		UUID: "api", DomainNames: "api.example.com", ForwardHost: "api", ForwardPort: 80, Enabled: true,
+		HTTPSMode:       models.HTTPSModeHTTPS,
+		ErrorPages:      []models.ErrorPage{{Status: "5xx", TemplateID: 1}, {Status: "404", TemplateID: 2}},
+		InterceptErrors: true,
+		LimitResponse:   models.LimitResponse{Body: "slow down"},
+		Locations:       []models.Location{{UUID: "v2", Path: "/v2", ForwardHost: "api2", ForwardPort: 80}},
+	}
+	opts := ConfigOptions{PageTemplates: []models.PageTemplate{
+		{ID: 1, Name: "Broken", Content: "<h1>{http.error.status_code}</h1>"},
+		{ID: 2, Name: "Missing", Content: "<h1>Not here</h1>"},
+	}}
+
+	config, err := GenerateConfig([]models.ProxyHost{host}, "/tmp/caddy-data", "", opts)
+	require.NoError(t, err)
+
+	for _, name := range []string{"cpm_server", "cpm_http"} {
+		server := config.Apps.HTTP.Servers[name]
+		require.NotNil(t, server.Errors, name)
+		errorRoutes := server.Errors.Routes
+		require.Len(t, errorRoutes, 3, name)
+
+		// The limit response wins, then exact statuses before classes
+		require.Equal(t, map[string][]string{"{http.error.status_code}": {"429"}}, errorRoutes[0].Match[0].Vars)
+		require.Equal(t, map[string][]string{"{http.error.status_code}": {"404"}}, errorRoutes[1].Match[0].Vars)
+		require.Equal(t, map[string]*RegexpMatch{"{http.error.status_code}": {Pattern: `^5\d\d$`}}, errorRoutes[2].Match[0].VarsRegexp)
+		require.Equal(t, Handler{
+			"handler":     "static_response",
+			"status_code": "{http.error.status_code}",
+			"body":        "<h1>{http.error.status_code}</h1>",
+			"headers":     htmlHeaders(),
+		}, errorRoutes[2].Handle[0])
+	}
+
+	// Upstream responses with a page become errors, for locations too
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	for _, route := range routes {
+		proxy := route.Handle[len(route.Handle)-1]
+		require.Equal(t, []map[string]interface{}{{
+			"match": map[string][]int{"status_code": {5, 404}},
+			"routes": []*Route{{Handle: []Handler{{
+				"handler":     "error",
+				"status_code": "{http.reverse_proxy.status_code}",
+			}}}},
+		}}, proxy["handle_response"])
+	}
+	require.NoError(t, Validate(config))
+}
@@ -0,0 +1,34 @@
+package caddy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ProtocolsSettingKey holds the comma-separated protocols served on the HTTPS listener.
+const ProtocolsSettingKey = "caddy.protocols"
+
+// knownProtocols are the values Caddy accepts in a server's protocols list.
+var knownProtocols = []string{"h1", "h2", "h2c", "h3"}
+
+// ParseProtocols parses a protocols setting such as "h1,h2,h3". An empty value
+// returns nil, keeping Caddy's defaults (h1, h2 and h3).
+func ParseProtocols(raw string) ([]string, error) {
+	var protocols []string
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || slices.Contains(protocols, p) {
+			continue
+		}
+		if !slices.Contains(knownProtocols, p) {
+			return nil, fmt.Errorf("unknown protocol %q (expected h1, h2, h2c or h3)", p)
+		}
+		protocols = append(protocols, p)
+	}
+
+	if len(protocols) > 0 && !slices.Contains(protocols, "h1") && !slices.Contains(protocols, "h2") {
+		return nil, fmt.Errorf("protocols must include h1 or h2")
+	}
+	return protocols, nil
+}
@@ -0,0 +1,149 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ApplyState describes the outcome of a reconciliation run.
+type ApplyState string
+
+const (
+	ApplyStateApplied    ApplyState = "applied"
+	ApplyStateRolledBack ApplyState = "rolled_back"
+	ApplyStateFailed     ApplyState = "failed"
+)
+
+// ConfigApplier is implemented by anything that can push the current database state to Caddy.
+// *Manager is the production implementation.
+type ConfigApplier interface {
+	ApplyConfig(ctx context.Context) error
+}
+
+// ApplyResult records the outcome of a single ApplyConfig call.
+type ApplyResult struct {
+	State      ApplyState `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	Reasons    []string   `json:"reasons"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
+
+// ReconcilerStatus is a snapshot of the reconciler for the status endpoint.
+type ReconcilerStatus struct {
+	Pending bool         `json:"pending"`
+	Last    *ApplyResult `json:"last,omitempty"`
+}
+
+// Reconciler coalesces change notifications into debounced, serialized ApplyConfig calls.
+// Every change inside the debounce window is satisfied by one apply that runs after it.
+type Reconciler struct {
+	applier  ConfigApplier
+	debounce time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	reasons  []string
+	waiters  []chan ApplyResult
+	inflight int
+	last     *ApplyResult
+
+	// applyMu serializes apply runs so a burst that arrives mid-apply waits its turn.
+	applyMu sync.Mutex
+}
+
+// NewReconciler creates a reconciler that waits debounce after the first change before applying.
+func NewReconciler(applier ConfigApplier, debounce time.Duration) *Reconciler {
+	return &Reconciler{
+		applier:  applier,
+		debounce: debounce,
+		timeout:  60 * time.Second,
+	}
+}
+
+// Notify schedules an apply without waiting for its result.
+func (r *Reconciler) Notify(reason string) {
+	r.Request(reason)
+}
+
+// Request schedules an apply and returns a channel that receives the result of the run
+// that includes this change. The channel is buffered so callers may ignore it.
+func (r *Reconciler) Request(reason string) <-chan ApplyResult {
+	ch := make(chan ApplyResult, 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reasons = append(r.reasons, reason)
+	r.waiters = append(r.waiters, ch)
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.debounce, r.flush)
+	}
+
+	return ch
+}
+
+// ApplyNow schedules an apply and blocks until it finishes or ctx is done.
+func (r *Reconciler) ApplyNow(ctx context.Context, reason string) (ApplyResult, error) {
+	select {
+	case result := <-r.Request(reason):
+		return result, nil
+	case <-ctx.Done():
+		return ApplyResult{}, ctx.Err()
+	}
+}
+
+// Status reports whether an apply is pending and the outcome of the last run.
+func (r *Reconciler) Status() ReconcilerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := ReconcilerStatus{Pending: r.timer != nil || r.inflight > 0}
+	if r.last != nil {
+		last := *r.last
+		status.Last = &last
+	}
+	return status
+}
+
+// flush takes the pending batch and applies it once.
+func (r *Reconciler) flush() {
+	r.mu.Lock()
+	reasons := r.reasons
+	waiters := r.waiters
+	r.reasons = nil
+	r.waiters = nil
+	r.timer = nil
+	r.inflight++
+	r.mu.Unlock()
+
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result := ApplyResult{
+		State:   ApplyStateApplied,
+		Reasons: reasons,
+	}
+	if err := r.applier.ApplyConfig(ctx); err != nil {
+		result.State = ApplyStateFailed
+		if errors.Is(err, ErrRolledBack) {
+			result.State = ApplyStateRolledBack
+		}
+		result.Error = err.Error()
+	}
+	result.FinishedAt = time.Now()
+
+	r.mu.Lock()
+	r.last = &result
+	r.inflight--
+	r.mu.Unlock()
+
+	for _, ch := range waiters {
+		ch <- result
+	}
+}
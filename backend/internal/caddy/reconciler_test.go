@@ -0,0 +1,115 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeApplier struct {
+	calls   atomic.Int32
+	err     error
+	delay   time.Duration
+	mu      sync.Mutex
+	running int
+	maxRun  int
+}
+
+func (f *fakeApplier) ApplyConfig(ctx context.Context) error {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maxRun {
+		f.maxRun = f.running
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+	f.calls.Add(1)
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+	return f.err
+}
+
+func TestReconciler_CoalescesBurst(t *testing.T) {
+	applier := &fakeApplier{}
+	r := NewReconciler(applier, 50*time.Millisecond)
+
+	var results []<-chan ApplyResult
+	for i := 0; i < 5; i++ {
+		results = append(results, r.Request(fmt.Sprintf("change %d", i)))
+	}
+	assert.True(t, r.Status().Pending)
+
+	for _, ch := range results {
+		select {
+		case res := <-ch:
+			assert.Equal(t, ApplyStateApplied, res.State)
+			assert.Len(t, res.Reasons, 5)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for apply result")
+		}
+	}
+
+	assert.Equal(t, int32(1), applier.calls.Load())
+
+	status := r.Status()
+	assert.False(t, status.Pending)
+	require.NotNil(t, status.Last)
+	assert.Equal(t, ApplyStateApplied, status.Last.State)
+}
+
+func TestReconciler_SerializesApplies(t *testing.T) {
+	applier := &fakeApplier{delay: 100 * time.Millisecond}
+	r := NewReconciler(applier, 10*time.Millisecond)
+
+	first := r.Request("first")
+	time.Sleep(50 * time.Millisecond) // first apply is now running
+	second := r.Request("second")
+
+	<-first
+	res := <-second
+	assert.Equal(t, []string{"second"}, res.Reasons)
+	assert.Equal(t, int32(2), applier.calls.Load())
+	assert.Equal(t, 1, applier.maxRun)
+}
+
+func TestReconciler_ReportsFailureStates(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		state ApplyState
+	}{
+		{"rolled back", fmt.Errorf("apply failed (%w): %w", ErrRolledBack, errors.New("bad config")), ApplyStateRolledBack},
+		{"failed", errors.New("apply failed: rollback also failed"), ApplyStateFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReconciler(&fakeApplier{err: tt.err}, time.Millisecond)
+
+			res, err := r.ApplyNow(context.Background(), "manual")
+			require.NoError(t, err)
+			assert.Equal(t, tt.state, res.State)
+			assert.Equal(t, tt.err.Error(), res.Error)
+		})
+	}
+}
+
+func TestReconciler_ApplyNowContextCancelled(t *testing.T) {
+	r := NewReconciler(&fakeApplier{}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.ApplyNow(ctx, "manual")
+	assert.ErrorIs(t, err, context.Canceled)
+}
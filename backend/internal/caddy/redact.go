@@ -0,0 +1,143 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// secretReplacer returns what a secret of the generated config is replaced
+// with. name identifies the secret, e.g. "cloudflare_api_token".
+type secretReplacer func(name, value string) (string, error)
+
+// replaceSecrets rewrites the secrets of a config decoded from JSON: keys of
+// load_pem certificates, DNS provider credentials, the ACME EAB MAC key, the
+// CrowdSec bouncer key and basic auth password hashes.
+func replaceSecrets(node interface{}, replace secretReplacer) error {
+	switch node := node.(type) {
+	case []interface{}:
+		for _, item := range node {
+			if err := replaceSecrets(item, replace); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if err := replaceSecretFields(node, replace); err != nil {
+			return err
+		}
+		for _, value := range node {
+			if err := replaceSecrets(value, replace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceSecretFields replaces the secrets held directly below object.
+func replaceSecretFields(object map[string]interface{}, replace secretReplacer) error {
+	if certs, ok := object["load_pem"].([]interface{}); ok {
+		for i, item := range certs {
+			cert, _ := item.(map[string]interface{})
+			name := fmt.Sprintf("load_pem_%d", i)
+			if tags, _ := cert["tags"].([]interface{}); len(tags) > 0 {
+				name, _ = tags[0].(string)
+			}
+			if err := replaceField(cert, "key", name+"_key", replace); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dns, ok := object["dns"].(map[string]interface{}); ok {
+		if provider, ok := dns["provider"].(map[string]interface{}); ok {
+			name, _ := provider["name"].(string)
+			for field := range provider {
+				if field == "name" {
+					continue
+				}
+				if err := replaceField(provider, field, name+"_"+field, replace); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if account, ok := object["external_account"].(map[string]interface{}); ok {
+		if err := replaceField(account, "mac_key", "zerossl_eab_mac_key", replace); err != nil {
+			return err
+		}
+	}
+
+	if crowdSec, ok := object["crowdsec"].(map[string]interface{}); ok {
+		if err := replaceField(crowdSec, "api_key", "crowdsec_api_key", replace); err != nil {
+			return err
+		}
+	}
+
+	if basic, ok := object["http_basic"].(map[string]interface{}); ok {
+		realm, _ := basic["realm"].(string)
+		accounts, _ := basic["accounts"].([]interface{})
+		for _, item := range accounts {
+			account, _ := item.(map[string]interface{})
+			username, _ := account["username"].(string)
+			if err := replaceField(account, "password", "basic_auth_"+realm+"_"+username, replace); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// replaceField replaces object[field] when it holds a string.
+func replaceField(object map[string]interface{}, field, name string, replace secretReplacer) error {
+	value, ok := object[field].(string)
+	if !ok || value == "" {
+		return nil
+	}
+	replaced, err := replace(name, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	object[field] = replaced
+	return nil
+}
+
+// marshalReplacingSecrets renders config as indented JSON with every secret
+// replaced.
+func marshalReplacingSecrets(config *Config, replace secretReplacer) ([]byte, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, err
+	}
+	if err := replaceSecrets(tree, replace); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+// unmarshalReplacingSecrets decodes raw into a Config after replacing every
+// secret, reversing marshalReplacingSecrets.
+func unmarshalReplacingSecrets(raw []byte, replace secretReplacer) (*Config, error) {
+	var tree interface{}
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, err
+	}
+	if err := replaceSecrets(tree, replace); err != nil {
+		return nil, err
+	}
+	restored, err := json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(restored, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -0,0 +1,86 @@
+package caddy
+
+import (
+	"net/http"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// securityHeaderValues returns the headers a host's security profile sets, with
+// the host's CSP override replacing the profile's policy.
+func securityHeaderValues(profile *models.SecurityHeaderProfile, cspOverride string) map[string]string {
+	headers := map[string]string{}
+	if profile != nil {
+		headers = profile.Headers()
+	}
+	if cspOverride != "" {
+		headers["Content-Security-Policy"] = cspOverride
+	}
+	return headers
+}
+
+// SecurityHeadersHandler renders a host's security header profile as a deferred
+// response headers handler, or returns nil when the host has neither a profile
+// nor a CSP override.
+func SecurityHeadersHandler(profile *models.SecurityHeaderProfile, cspOverride string) Handler {
+	headers := securityHeaderValues(profile, cspOverride)
+	removeServer := profile != nil && profile.RemoveServerHeader
+	if len(headers) == 0 && !removeServer {
+		return nil
+	}
+
+	ops := map[string]interface{}{"deferred": true}
+	if len(headers) > 0 {
+		set := make(map[string][]string, len(headers))
+		for name, value := range headers {
+			set[name] = []string{value}
+		}
+		ops["set"] = set
+	}
+	if removeServer {
+		ops["delete"] = []string{"Server"}
+	}
+
+	return Handler{
+		"handler":  "headers",
+		"response": ops,
+	}
+}
+
+// EffectiveResponseHeaders returns the response headers CPM sets for a host, in
+// the order the generated handlers apply them: HSTS, the security profile, then
+// the host's response header rules. Deleted headers map to "".
+func EffectiveResponseHeaders(host *models.ProxyHost, profile *models.SecurityHeaderProfile) map[string]string {
+	headers := map[string]string{}
+	if host.HSTSEnabled && host.EffectiveHTTPSMode() != models.HTTPSModeHTTPOnly {
+		headers["Strict-Transport-Security"] = hstsValue(host)
+	}
+
+	for name, value := range securityHeaderValues(profile, host.CSPOverride) {
+		headers[name] = value
+	}
+	if profile != nil && profile.RemoveServerHeader {
+		headers["Server"] = ""
+	}
+
+	for _, rule := range host.HeaderRules {
+		if rule.Direction != models.HeaderRuleResponse {
+			continue
+		}
+		name := http.CanonicalHeaderKey(rule.Name)
+		switch rule.Operation {
+		case models.HeaderOpSet:
+			headers[name] = rule.Value
+		case models.HeaderOpAdd:
+			if headers[name] == "" {
+				headers[name] = rule.Value
+			} else {
+				headers[name] += ", " + rule.Value
+			}
+		case models.HeaderOpDelete:
+			headers[name] = ""
+		}
+	}
+
+	return headers
+}
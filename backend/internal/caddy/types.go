@@ -6,6 +6,8 @@ type Config struct {
 	Apps    Apps           `json:"apps"`
 	Logging *LoggingConfig `json:"logging,omitempty"`
 	Storage Storage        `json:"storage,omitempty"`
+	// Warnings lists parts of the CPM config left out of the Caddy config.
+	Warnings []string `json:"-"`
 }
 
 // LoggingConfig configures Caddy's logging facility.
@@ -51,8 +53,10 @@ type Storage struct {
 
 // Apps contains all Caddy app modules.
 type Apps struct {
-	HTTP *HTTPApp `json:"http,omitempty"`
-	TLS  *TLSApp  `json:"tls,omitempty"`
+	HTTP     *HTTPApp     `json:"http,omitempty"`
+	TLS      *TLSApp      `json:"tls,omitempty"`
+	Layer4   *Layer4App   `json:"layer4,omitempty"`
+	CrowdSec *CrowdSecApp `json:"crowdsec,omitempty"`
 }
 
 // HTTPApp configures the HTTP app.
@@ -62,10 +66,32 @@ type HTTPApp struct {
 
 // Server represents an HTTP server instance.
 type Server struct {
-	Listen    []string         `json:"listen"`
-	Routes    []*Route         `json:"routes"`
-	AutoHTTPS *AutoHTTPSConfig `json:"automatic_https,omitempty"`
-	Logs      *ServerLogs      `json:"logs,omitempty"`
+	Listen          []string               `json:"listen"`
+	Routes          []*Route               `json:"routes"`
+	AutoHTTPS       *AutoHTTPSConfig       `json:"automatic_https,omitempty"`
+	TLSConnPolicies []*TLSConnectionPolicy `json:"tls_connection_policies,omitempty"`
+	Protocols       []string               `json:"protocols,omitempty"`
+	Logs            *ServerLogs            `json:"logs,omitempty"`
+	TrustedProxies  map[string]interface{} `json:"trusted_proxies,omitempty"`
+	Errors          *ServerErrors          `json:"errors,omitempty"`
+}
+
+// ServerErrors holds the routes that handle errors returned by the server's
+// handlers, such as 429 from rate_limit. Unmatched errors get Caddy's empty answer.
+type ServerErrors struct {
+	Routes []*Route `json:"routes"`
+}
+
+// TLSConnectionPolicy customizes TLS handshakes for matching connections.
+// A policy without Match applies to every connection not matched earlier.
+type TLSConnectionPolicy struct {
+	Match *TLSConnectionMatch `json:"match,omitempty"`
+	ALPN  []string            `json:"alpn,omitempty"`
+}
+
+// TLSConnectionMatch selects connections by their ClientHello.
+type TLSConnectionMatch struct {
+	SNI []string `json:"sni,omitempty"`
 }
 
 // AutoHTTPSConfig controls automatic HTTPS behavior.
@@ -73,6 +99,8 @@ type AutoHTTPSConfig struct {
 	Disable      bool     `json:"disable,omitempty"`
 	DisableRedir bool     `json:"disable_redirects,omitempty"`
 	Skip         []string `json:"skip,omitempty"`
+	// SkipCerts names keep their redirects but are not managed, e.g. because a custom certificate is loaded.
+	SkipCerts []string `json:"skip_certificates,omitempty"`
 }
 
 // ServerLogs configures access logging.
@@ -87,23 +115,47 @@ type Route struct {
 	Terminal bool      `json:"terminal,omitempty"`
 }
 
-// Match represents a request matcher.
+// Match represents a request matcher. All set fields must match (AND);
+// multiple Match entries in a route are alternatives (OR).
 type Match struct {
-	Host []string `json:"host,omitempty"`
-	Path []string `json:"path,omitempty"`
+	Host         []string                `json:"host,omitempty"`
+	Path         []string                `json:"path,omitempty"`
+	Method       []string                `json:"method,omitempty"`
+	Query        map[string][]string     `json:"query,omitempty"`
+	PathRegexp   *RegexpMatch            `json:"path_regexp,omitempty"`
+	HeaderRegexp map[string]*RegexpMatch `json:"header_regexp,omitempty"`
+	Vars         map[string][]string     `json:"vars,omitempty"`
+	VarsRegexp   map[string]*RegexpMatch `json:"vars_regexp,omitempty"`
+	RemoteIP     *IPMatch                `json:"remote_ip,omitempty"`
+	ClientIP     *IPMatch                `json:"client_ip,omitempty"`
+	Not          []Match                 `json:"not,omitempty"`
+}
+
+// IPMatch is the body of Caddy's remote_ip and client_ip matchers.
+type IPMatch struct {
+	Ranges []string `json:"ranges"`
+}
+
+// RegexpMatch is the body of Caddy's *_regexp matchers.
+type RegexpMatch struct {
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern"`
 }
 
 // Handler is the interface for all handler types.
 // Actual types will implement handler-specific fields.
 type Handler map[string]interface{}
 
-// ReverseProxyHandler creates a reverse_proxy handler.
-func ReverseProxyHandler(dial string, enableWS bool) Handler {
+// ReverseProxyHandler creates a reverse_proxy handler balancing across the given dial addresses.
+func ReverseProxyHandler(dials []string, enableWS bool) Handler {
+	upstreams := make([]map[string]interface{}, 0, len(dials))
+	for _, dial := range dials {
+		upstreams = append(upstreams, map[string]interface{}{"dial": dial})
+	}
+
 	h := Handler{
-		"handler": "reverse_proxy",
-		"upstreams": []map[string]interface{}{
-			{"dial": dial},
-		},
+		"handler":   "reverse_proxy",
+		"upstreams": upstreams,
 	}
 
 	if enableWS {
@@ -121,6 +173,72 @@ func ReverseProxyHandler(dial string, enableWS bool) Handler {
 	return h
 }
 
+// SetRequestHeaders sets headers on the upstream request of a reverse_proxy handler,
+// keeping headers already configured (e.g. for websockets).
+func SetRequestHeaders(h Handler, headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+
+	request := proxyRequestHeaders(h)
+	set, _ := request["set"].(map[string][]string)
+	if set == nil {
+		set = map[string][]string{}
+		request["set"] = set
+	}
+
+	for name, value := range headers {
+		set[name] = []string{value}
+	}
+}
+
+// proxyRequestHeaders returns the headers.request block of a reverse_proxy
+// handler, creating it when missing.
+func proxyRequestHeaders(h Handler) map[string]interface{} {
+	hdrs, _ := h["headers"].(map[string]interface{})
+	if hdrs == nil {
+		hdrs = map[string]interface{}{}
+		h["headers"] = hdrs
+	}
+	request, _ := hdrs["request"].(map[string]interface{})
+	if request == nil {
+		request = map[string]interface{}{}
+		hdrs["request"] = request
+	}
+	return request
+}
+
+// LoadBalancingConfig builds the reverse_proxy load_balancing block for a policy.
+// Weights are only honoured by round_robin, which then becomes weighted_round_robin.
+func LoadBalancingConfig(policy string, weights []int) map[string]interface{} {
+	selection := map[string]interface{}{}
+
+	switch policy {
+	case "", "round_robin":
+		selection["policy"] = "round_robin"
+		for _, w := range weights {
+			if w > 0 {
+				normalized := make([]int, len(weights))
+				for i, w := range weights {
+					normalized[i] = max(w, 1)
+				}
+				selection["policy"] = "weighted_round_robin"
+				selection["weights"] = normalized
+				break
+			}
+		}
+	case "cookie":
+		selection["policy"] = "cookie"
+		selection["name"] = "cpm_lb"
+	default:
+		selection["policy"] = policy
+	}
+
+	return map[string]interface{}{
+		"selection_policy": selection,
+	}
+}
+
 // HeaderHandler creates a handler that sets HTTP response headers.
 func HeaderHandler(headers map[string][]string) Handler {
 	return Handler{
@@ -131,19 +249,40 @@ func HeaderHandler(headers map[string][]string) Handler {
 	}
 }
 
-// BlockExploitsHandler creates a handler that blocks common exploits.
-// This uses Caddy's request matchers to block malicious patterns.
-func BlockExploitsHandler() Handler {
-	return Handler{
-		"handler": "vars",
-		// Placeholder for future exploit blocking logic
-		// Can be extended with specific matchers for SQL injection, XSS, etc.
+// StaticResponseHandler creates a handler that answers directly without proxying.
+func StaticResponseHandler(statusCode int, body string, headers map[string][]string) Handler {
+	h := Handler{
+		"handler":     "static_response",
+		"status_code": statusCode,
+	}
+	if body != "" {
+		h["body"] = body
+	}
+	if len(headers) > 0 {
+		h["headers"] = headers
 	}
+	return h
 }
 
 // TLSApp configures the TLS app for certificate management.
 type TLSApp struct {
-	Automation *AutomationConfig `json:"automation,omitempty"`
+	Certificates *CertificatesConfig `json:"certificates,omitempty"`
+	Automation   *AutomationConfig   `json:"automation,omitempty"`
+}
+
+// CertificatesConfig lists certificates the TLS app loads or manages outside the HTTP app.
+type CertificatesConfig struct {
+	// Automate names are managed even though no HTTP route references them (e.g. layer4 TLS).
+	Automate []string `json:"automate,omitempty"`
+	// LoadPEM holds uploaded certificates; Certificate includes any intermediates.
+	LoadPEM []LoadPEMCertificate `json:"load_pem,omitempty"`
+}
+
+// LoadPEMCertificate is a certificate and key loaded verbatim into the TLS app.
+type LoadPEMCertificate struct {
+	Certificate string   `json:"certificate"`
+	Key         string   `json:"key"`
+	Tags        []string `json:"tags,omitempty"`
 }
 
 // AutomationConfig controls certificate automation.
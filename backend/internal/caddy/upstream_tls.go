@@ -0,0 +1,100 @@
+package caddy
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// upstreamTLSDir is the directory below Caddy's storage root that holds client
+// certificates for upstream mTLS. Caddy's HTTP transport only reads them from files.
+const upstreamTLSDir = "cpm_upstream_tls"
+
+// upstreamClientCertPaths returns where the client certificate and key of a host are stored.
+func upstreamClientCertPaths(storageDir, hostUUID string) (string, string) {
+	dir := filepath.Join(storageDir, upstreamTLSDir)
+	return filepath.Join(dir, hostUUID+".crt"), filepath.Join(dir, hostUUID+".key")
+}
+
+// WriteUpstreamClientCerts writes the client certificate and key of every host using
+// upstream mTLS to the paths GenerateConfig references.
+func WriteUpstreamClientCerts(storageDir string, hosts []models.ProxyHost) error {
+	for _, host := range hosts {
+		if !host.UpstreamTLS.HasClientCert() {
+			continue
+		}
+
+		certPath, keyPath := upstreamClientCertPaths(storageDir, host.UUID)
+		if err := os.MkdirAll(filepath.Dir(certPath), 0o700); err != nil {
+			return fmt.Errorf("create upstream tls dir: %w", err)
+		}
+		if err := os.WriteFile(certPath, []byte(host.UpstreamTLS.ClientCertPEM), 0o600); err != nil {
+			return fmt.Errorf("write client certificate for %s: %w", host.UUID, err)
+		}
+		if err := os.WriteFile(keyPath, []byte(host.UpstreamTLS.ClientKeyPEM), 0o600); err != nil {
+			return fmt.Errorf("write client key for %s: %w", host.UUID, err)
+		}
+	}
+	return nil
+}
+
+// HTTPTransport builds the reverse_proxy transport for an upstream scheme. Plain
+// HTTP needs no transport and returns nil.
+func HTTPTransport(scheme string, cfg models.UpstreamTLSConfig, storageDir, hostUUID string) (map[string]interface{}, error) {
+	if scheme != "https" {
+		return nil, nil
+	}
+
+	tlsConfig := map[string]interface{}{}
+	if cfg.InsecureSkipVerify {
+		tlsConfig["insecure_skip_verify"] = true
+	}
+	if cfg.ServerName != "" {
+		tlsConfig["server_name"] = cfg.ServerName
+	}
+	if cfg.TrustedCAPEM != "" {
+		certs, err := pemCertificatesBase64(cfg.TrustedCAPEM)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig["ca"] = map[string]interface{}{
+			"provider":         "inline",
+			"trusted_ca_certs": certs,
+		}
+	}
+	if cfg.HasClientCert() {
+		certPath, keyPath := upstreamClientCertPaths(storageDir, hostUUID)
+		tlsConfig["client_certificate_file"] = certPath
+		tlsConfig["client_certificate_key_file"] = keyPath
+	}
+
+	return map[string]interface{}{
+		"protocol": "http",
+		"tls":      tlsConfig,
+	}, nil
+}
+
+// pemCertificatesBase64 converts a PEM bundle into the base64 DER form Caddy's inline CA pool expects.
+func pemCertificatesBase64(bundle string) ([]string, error) {
+	var certs []string
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, base64.StdEncoding.EncodeToString(block.Bytes))
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("trusted CA PEM contains no certificates")
+	}
+	return certs, nil
+}
@@ -0,0 +1,147 @@
+package caddy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// selfSignedPEM returns a throwaway certificate and key for commonName.
+func selfSignedPEM(t *testing.T, commonName string) (string, string, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM), der
+}
+
+func TestGenerateConfig_HTTPSUpstream(t *testing.T) {
+	caPEM, _, caDER := selfSignedPEM(t, "Internal CA")
+	certPEM, keyPEM, _ := selfSignedPEM(t, "cpm-client")
+
+	hosts := []models.ProxyHost{{
+		UUID:          "pve",
+		DomainNames:   "pve.example.com",
+		ForwardScheme: "https",
+		ForwardHost:   "10.0.0.5",
+		ForwardPort:   8006,
+		UpstreamTLS: models.UpstreamTLSConfig{
+			InsecureSkipVerify: true,
+			ServerName:         "pve.internal",
+			TrustedCAPEM:       caPEM,
+			ClientCertPEM:      certPEM,
+			ClientKeyPEM:       keyPEM,
+		},
+		Locations: []models.Location{
+			{Path: "/plain", ForwardScheme: "http", ForwardHost: "10.0.0.6", ForwardPort: 80},
+			{Path: "/secure", ForwardScheme: "https", ForwardHost: "10.0.0.7", ForwardPort: 443},
+		},
+		Enabled: true,
+	}}
+
+	config, err := GenerateConfig(hosts, "/data/caddy", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	routes := config.Apps.HTTP.Servers["cpm_server"].Routes
+	require.Len(t, routes, 3)
+
+	expected := map[string]interface{}{
+		"protocol": "http",
+		"tls": map[string]interface{}{
+			"insecure_skip_verify": true,
+			"server_name":          "pve.internal",
+			"ca": map[string]interface{}{
+				"provider":         "inline",
+				"trusted_ca_certs": []string{base64.StdEncoding.EncodeToString(caDER)},
+			},
+			"client_certificate_file":     "/data/caddy/cpm_upstream_tls/pve.crt",
+			"client_certificate_key_file": "/data/caddy/cpm_upstream_tls/pve.key",
+		},
+	}
+
+	require.NotContains(t, routes[0].Handle[0], "transport")
+	require.Equal(t, expected, routes[2].Handle[0]["transport"])
+
+	// The https location is a different upstream and keeps Caddy's TLS defaults
+	require.Equal(t, map[string]interface{}{"protocol": "http", "tls": map[string]interface{}{}}, routes[1].Handle[0]["transport"])
+}
+
+func TestGenerateConfig_HTTPUpstreamHasNoTransport(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:          "plain",
+		DomainNames:   "plain.example.com",
+		ForwardScheme: "http",
+		ForwardHost:   "app",
+		ForwardPort:   8080,
+		UpstreamTLS:   models.UpstreamTLSConfig{InsecureSkipVerify: true},
+		Enabled:       true,
+	}}
+
+	config, err := GenerateConfig(hosts, "/data/caddy", "", ConfigOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, config.Apps.HTTP.Servers["cpm_server"].Routes[0].Handle[0], "transport")
+}
+
+func TestHTTPTransport_MinimalHTTPS(t *testing.T) {
+	transport, err := HTTPTransport("https", models.UpstreamTLSConfig{}, "/data", "uuid")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"protocol": "http",
+		"tls":      map[string]interface{}{},
+	}, transport)
+
+	_, err = HTTPTransport("https", models.UpstreamTLSConfig{TrustedCAPEM: "not a pem"}, "/data", "uuid")
+	require.Error(t, err)
+}
+
+func TestWriteUpstreamClientCerts(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM, _ := selfSignedPEM(t, "cpm-client")
+
+	hosts := []models.ProxyHost{
+		{UUID: "mtls", UpstreamTLS: models.UpstreamTLSConfig{ClientCertPEM: certPEM, ClientKeyPEM: keyPEM}},
+		{UUID: "none"},
+	}
+	require.NoError(t, WriteUpstreamClientCerts(dir, hosts))
+
+	cert, err := os.ReadFile(filepath.Join(dir, "cpm_upstream_tls", "mtls.crt"))
+	require.NoError(t, err)
+	require.Equal(t, certPEM, string(cert))
+
+	info, err := os.Stat(filepath.Join(dir, "cpm_upstream_tls", "mtls.key"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = os.Stat(filepath.Join(dir, "cpm_upstream_tls", "none.crt"))
+	require.True(t, os.IsNotExist(err))
+}
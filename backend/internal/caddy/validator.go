@@ -4,39 +4,85 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Validate performs pre-flight validation on a Caddy config before applying it.
-func Validate(cfg *Config) error {
+// modules are the IDs of the modules in the Caddy build, see ListModules; when
+// given, handlers of optional modules the build lacks are rejected.
+func Validate(cfg *Config, modules ...string) error {
 	if cfg == nil {
 		return fmt.Errorf("config cannot be nil")
 	}
 
-	if cfg.Apps.HTTP == nil {
-		return nil // Empty config is valid
+	if len(modules) > 0 {
+		if err := validateModules(cfg, modules); err != nil {
+			return err
+		}
 	}
 
-	// Track seen hosts to detect duplicates
-	seenHosts := make(map[string]bool)
+	if cfg.Apps.HTTP != nil {
+		for serverName, server := range cfg.Apps.HTTP.Servers {
+			// Track seen hosts to detect duplicates. Servers own separate listeners,
+			// so the same host may appear once in each.
+			seenHosts := make(map[string]bool)
 
-	for serverName, server := range cfg.Apps.HTTP.Servers {
-		if len(server.Listen) == 0 {
-			return fmt.Errorf("server %s has no listen addresses", serverName)
+			if len(server.Listen) == 0 {
+				return fmt.Errorf("server %s has no listen addresses", serverName)
+			}
+
+			// Validate listen addresses
+			for _, addr := range server.Listen {
+				if err := validateListenAddr(addr); err != nil {
+					return fmt.Errorf("invalid listen address %s in server %s: %w", addr, serverName, err)
+				}
+			}
+
+			for _, protocol := range server.Protocols {
+				if !slices.Contains(knownProtocols, protocol) {
+					return fmt.Errorf("unknown protocol %s in server %s", protocol, serverName)
+				}
+			}
+
+			// Validate routes
+			for i, route := range server.Routes {
+				if err := validateRoute(route, seenHosts); err != nil {
+					return fmt.Errorf("invalid route %d in server %s: %w", i, serverName, err)
+				}
+			}
+			if server.Errors != nil {
+				seenErrorHosts := make(map[string]bool)
+				for i, route := range server.Errors.Routes {
+					if err := validateRoute(route, seenErrorHosts); err != nil {
+						return fmt.Errorf("invalid error route %d in server %s: %w", i, serverName, err)
+					}
+				}
+			}
 		}
+	}
 
-		// Validate listen addresses
-		for _, addr := range server.Listen {
-			if err := validateListenAddr(addr); err != nil {
-				return fmt.Errorf("invalid listen address %s in server %s: %w", addr, serverName, err)
+	if cfg.Apps.Layer4 != nil {
+		if err := validateLayer4(cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Apps.TLS != nil && cfg.Apps.TLS.Automation != nil {
+		for i, policy := range cfg.Apps.TLS.Automation.Policies {
+			if err := validateAutomationPolicy(policy); err != nil {
+				return fmt.Errorf("invalid automation policy %d: %w", i, err)
 			}
 		}
+	}
 
-		// Validate routes
-		for i, route := range server.Routes {
-			if err := validateRoute(route, seenHosts); err != nil {
-				return fmt.Errorf("invalid route %d in server %s: %w", i, serverName, err)
+	if cfg.Apps.TLS != nil && cfg.Apps.TLS.Certificates != nil {
+		for i, cert := range cfg.Apps.TLS.Certificates.LoadPEM {
+			if strings.TrimSpace(cert.Certificate) == "" || strings.TrimSpace(cert.Key) == "" {
+				return fmt.Errorf("load_pem certificate %d needs both certificate and key", i)
 			}
 		}
 	}
@@ -49,6 +95,37 @@ func Validate(cfg *Config) error {
 	return nil
 }
 
+// validateAutomationPolicy checks that wildcard subjects can be issued: ACME
+// only allows them through the DNS-01 challenge, the internal CA always can.
+func validateAutomationPolicy(policy *AutomationPolicy) error {
+	for _, subject := range policy.Subjects {
+		if strings.HasPrefix(subject, "*.") && !issuesWildcards(policy) {
+			return fmt.Errorf("wildcard %s requires a dns challenge", subject)
+		}
+	}
+	return nil
+}
+
+// issuesWildcards reports whether every issuer of the policy can sign wildcard names.
+func issuesWildcards(policy *AutomationPolicy) bool {
+	if len(policy.IssuersRaw) == 0 {
+		return false
+	}
+	for _, issuer := range policy.IssuersRaw {
+		module, _ := issuer.(map[string]interface{})
+		if module["module"] == "internal" {
+			continue
+		}
+		challenges, _ := module["challenges"].(map[string]interface{})
+		dns, _ := challenges["dns"].(map[string]interface{})
+		provider, _ := dns["provider"].(map[string]interface{})
+		if name, _ := provider["name"].(string); name == "" {
+			return false
+		}
+	}
+	return true
+}
+
 func validateListenAddr(addr string) error {
 	// Strip network type prefix if present (tcp/, udp/)
 	if idx := strings.Index(addr, "/"); idx != -1 {
@@ -78,18 +155,140 @@ func validateListenAddr(addr string) error {
 	return nil
 }
 
+// listenSocket returns the network ("tcp" or "udp") and port of a listen address.
+func listenSocket(addr string) (string, int, error) {
+	network := "tcp"
+	if idx := strings.Index(addr, "/"); idx != -1 {
+		network = addr[:idx]
+	}
+
+	if err := validateListenAddr(addr); err != nil {
+		return "", 0, err
+	}
+
+	if idx := strings.Index(addr, "/"); idx != -1 {
+		addr = addr[idx+1:]
+	}
+	_, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	return network, port, nil
+}
+
+// validateLayer4 checks layer4 servers and makes sure no two servers, HTTP or
+// layer4, bind the same network and port.
+func validateLayer4(cfg *Config) error {
+	owners := make(map[string]string)
+
+	if cfg.Apps.HTTP != nil {
+		for serverName, server := range cfg.Apps.HTTP.Servers {
+			for _, addr := range server.Listen {
+				network, port, err := listenSocket(addr)
+				if err != nil {
+					return fmt.Errorf("invalid listen address %s in server %s: %w", addr, serverName, err)
+				}
+				owners[fmt.Sprintf("%s/%d", network, port)] = "http server " + serverName
+				// HTTP/3 is served over UDP on the HTTPS port
+				if network == "tcp" && port == 443 && (len(server.Protocols) == 0 || slices.Contains(server.Protocols, "h3")) {
+					owners["udp/443"] = "http server " + serverName
+				}
+			}
+		}
+	}
+
+	for serverName, server := range cfg.Apps.Layer4.Servers {
+		if len(server.Listen) == 0 {
+			return fmt.Errorf("layer4 server %s has no listen addresses", serverName)
+		}
+
+		for _, addr := range server.Listen {
+			network, port, err := listenSocket(addr)
+			if err != nil {
+				return fmt.Errorf("invalid listen address %s in layer4 server %s: %w", addr, serverName, err)
+			}
+			if network != "tcp" && network != "udp" {
+				return fmt.Errorf("unsupported network %s in layer4 server %s", network, serverName)
+			}
+
+			key := fmt.Sprintf("%s/%d", network, port)
+			if owner, taken := owners[key]; taken {
+				return fmt.Errorf("listen port clash on %s between layer4 server %s and %s", key, serverName, owner)
+			}
+			owners[key] = "layer4 server " + serverName
+		}
+
+		if len(server.Routes) == 0 {
+			return fmt.Errorf("layer4 server %s has no routes", serverName)
+		}
+		for i, route := range server.Routes {
+			if len(route.Handle) == 0 {
+				return fmt.Errorf("invalid route %d in layer4 server %s: route has no handlers", i, serverName)
+			}
+			for j, handler := range route.Handle {
+				if err := validateLayer4Handler(handler); err != nil {
+					return fmt.Errorf("invalid handler %d of route %d in layer4 server %s: %w", j, i, serverName, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateLayer4Handler(handler Handler) error {
+	if handler["handler"] != "proxy" {
+		return nil
+	}
+
+	upstreams, ok := handler["upstreams"].([]map[string]interface{})
+	if !ok || len(upstreams) == 0 {
+		return fmt.Errorf("proxy has no upstreams")
+	}
+	for i, upstream := range upstreams {
+		dials, ok := upstream["dial"].([]string)
+		if !ok || len(dials) == 0 {
+			return fmt.Errorf("upstream %d has no dial address", i)
+		}
+		for _, dial := range dials {
+			if idx := strings.Index(dial, "/"); idx != -1 {
+				dial = dial[idx+1:]
+			}
+			if _, _, err := net.SplitHostPort(dial); err != nil {
+				return fmt.Errorf("upstream %d: invalid dial address %s: %w", i, dial, err)
+			}
+		}
+	}
+	return nil
+}
+
 func validateRoute(route *Route, seenHosts map[string]bool) error {
 	if len(route.Handle) == 0 {
 		return fmt.Errorf("route has no handlers")
 	}
 
-	// Check for duplicate host matchers
+	// Check for duplicate host matchers. Routes scoped by further matchers (custom
+	// locations, exploit blocking) legitimately share a host with the catch-all
+	// route, so key on the host plus the rest of the matcher. Filter routes such
+	// as rate limits fall through to the route serving the host.
 	for _, match := range route.Match {
+		if isFilterRoute(route) {
+			break
+		}
+		rest := match
+		rest.Host = nil
+		restJSON, _ := json.Marshal(rest)
 		for _, host := range match.Host {
-			if seenHosts[host] {
+			key := host + "|" + string(restJSON)
+			if seenHosts[key] {
 				return fmt.Errorf("duplicate host matcher: %s", host)
 			}
-			seenHosts[host] = true
+			seenHosts[key] = true
+		}
+	}
+
+	for _, match := range route.Match {
+		if err := validateMatch(match); err != nil {
+			return err
 		}
 	}
 
@@ -103,6 +302,69 @@ func validateRoute(route *Route, seenHosts map[string]bool) error {
 	return nil
 }
 
+// filterHandlers only refuse requests and otherwise pass them on.
+var filterHandlers = []string{"crowdsec", "rate_limit"}
+
+// isFilterRoute reports whether route falls through after filtering requests.
+func isFilterRoute(route *Route) bool {
+	if route.Terminal {
+		return false
+	}
+	for _, handler := range route.Handle {
+		if name, _ := handler["handler"].(string); !slices.Contains(filterHandlers, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateMatch checks the patterns and IP ranges of a matcher set, including negated sets.
+func validateMatch(match Match) error {
+	if match.PathRegexp != nil {
+		if _, err := regexp.Compile(match.PathRegexp.Pattern); err != nil {
+			return fmt.Errorf("invalid path_regexp: %w", err)
+		}
+	}
+	for _, ips := range []*IPMatch{match.RemoteIP, match.ClientIP} {
+		if ips == nil {
+			continue
+		}
+		if len(ips.Ranges) == 0 {
+			return fmt.Errorf("ip matcher has no ranges")
+		}
+		for _, r := range ips.Ranges {
+			if _, err := ParseNetwork(r); err != nil {
+				return err
+			}
+		}
+	}
+	for _, not := range match.Not {
+		if err := validateMatch(not); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSubroute validates the routes nested in a subroute handler. They share
+// the outer route's host, so duplicate hosts are not checked.
+func validateSubroute(handler Handler) error {
+	if raw, ok := handler["routes"].(json.RawMessage); ok {
+		// Routes of an advanced config
+		return checkAdvancedRoutes(raw, nil)
+	}
+	routes, ok := handler["routes"].([]*Route)
+	if !ok {
+		return nil
+	}
+	for i, route := range routes {
+		if err := validateRoute(route, map[string]bool{}); err != nil {
+			return fmt.Errorf("subroute %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
 func validateHandler(handler Handler) error {
 	handlerType, ok := handler["handler"].(string)
 	if !ok {
@@ -112,7 +374,15 @@ func validateHandler(handler Handler) error {
 	switch handlerType {
 	case "reverse_proxy":
 		return validateReverseProxy(handler)
-	case "file_server", "static_response":
+	case "static_response":
+		return validateStaticResponse(handler)
+	case "rewrite":
+		return validateRewrite(handler)
+	case "subroute":
+		return validateSubroute(handler)
+	case "rate_limit":
+		return validateRateLimit(handler)
+	case "file_server":
 		return nil // Accept other common handlers
 	default:
 		// Unknown handlers are allowed (Caddy is extensible)
@@ -120,6 +390,41 @@ func validateHandler(handler Handler) error {
 	}
 }
 
+// validateRateLimit checks that every zone of a rate_limit handler allows some events.
+func validateRateLimit(handler Handler) error {
+	zones, _ := handler["rate_limits"].(map[string]interface{})
+	if len(zones) == 0 {
+		return fmt.Errorf("rate_limit has no zones")
+	}
+	for name, zone := range zones {
+		fields, _ := zone.(map[string]interface{})
+		if key, _ := fields["key"].(string); key == "" {
+			return fmt.Errorf("rate limit zone %s has no key", name)
+		}
+		if events, _ := fields["max_events"].(int); events < 1 {
+			return fmt.Errorf("rate limit zone %s must allow at least one event", name)
+		}
+		window, _ := fields["window"].(string)
+		if d, err := time.ParseDuration(window); err != nil || d <= 0 {
+			return fmt.Errorf("rate limit zone %s has an invalid window %q", name, window)
+		}
+	}
+	return nil
+}
+
+// validateRewrite rejects rewrite handlers that would not change the request.
+func validateRewrite(handler Handler) error {
+	uri, _ := handler["uri"].(string)
+	prefix, _ := handler["strip_path_prefix"].(string)
+	if uri == "" && prefix == "" {
+		return fmt.Errorf("rewrite has nothing to rewrite")
+	}
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("strip_path_prefix must start with /")
+	}
+	return nil
+}
+
 func validateReverseProxy(handler Handler) error {
 	upstreams, ok := handler["upstreams"].([]map[string]interface{})
 	if !ok {
@@ -142,5 +447,132 @@ func validateReverseProxy(handler Handler) error {
 		}
 	}
 
+	if lb, ok := handler["load_balancing"].(map[string]interface{}); ok {
+		if err := validateLoadBalancing(lb, len(upstreams)); err != nil {
+			return fmt.Errorf("reverse_proxy load_balancing: %w", err)
+		}
+	}
+
+	if checks, ok := handler["health_checks"].(map[string]interface{}); ok {
+		if err := validateHealthChecks(checks); err != nil {
+			return fmt.Errorf("reverse_proxy health_checks: %w", err)
+		}
+	}
+
+	if transport, ok := handler["transport"].(map[string]interface{}); ok {
+		if err := validateTransport(transport); err != nil {
+			return fmt.Errorf("reverse_proxy transport: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validateTransport(transport map[string]interface{}) error {
+	if protocol, _ := transport["protocol"].(string); protocol == "" {
+		return fmt.Errorf("missing protocol")
+	}
+
+	tlsConfig, ok := transport["tls"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	_, hasCert := tlsConfig["client_certificate_file"]
+	_, hasKey := tlsConfig["client_certificate_key_file"]
+	if hasCert != hasKey {
+		return fmt.Errorf("client certificate and key must be set together")
+	}
+	if ca, ok := tlsConfig["ca"].(map[string]interface{}); ok {
+		if certs, _ := ca["trusted_ca_certs"].([]string); len(certs) == 0 {
+			return fmt.Errorf("inline CA pool has no certificates")
+		}
+	}
+	return nil
+}
+
+func validateHealthChecks(checks map[string]interface{}) error {
+	if active, ok := checks["active"].(map[string]interface{}); ok {
+		uri, _ := active["uri"].(string)
+		if !strings.HasPrefix(uri, "/") {
+			return fmt.Errorf("active uri %q must start with /", uri)
+		}
+		for _, key := range []string{"interval", "timeout"} {
+			if err := validateDuration(active[key]); err != nil {
+				return fmt.Errorf("active %s: %w", key, err)
+			}
+		}
+	}
+
+	if passive, ok := checks["passive"].(map[string]interface{}); ok {
+		if err := validateDuration(passive["fail_duration"]); err != nil {
+			return fmt.Errorf("passive fail_duration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validateDuration(value interface{}) error {
+	s, _ := value.(string)
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("duration %s must be positive", s)
+	}
+	return nil
+}
+
+// knownSelectionPolicies are the reverse_proxy selection policies CPM+ generates.
+var knownSelectionPolicies = map[string]bool{
+	"round_robin":          true,
+	"weighted_round_robin": true,
+	"least_conn":           true,
+	"ip_hash":              true,
+	"cookie":               true,
+	"first":                true,
+}
+
+func validateLoadBalancing(lb map[string]interface{}, upstreamCount int) error {
+	selection, ok := lb["selection_policy"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("missing selection_policy")
+	}
+
+	policy, _ := selection["policy"].(string)
+	if !knownSelectionPolicies[policy] {
+		return fmt.Errorf("unknown selection policy %q", policy)
+	}
+
+	if policy == "weighted_round_robin" {
+		weights, ok := selection["weights"].([]int)
+		if !ok || len(weights) != upstreamCount {
+			return fmt.Errorf("weighted_round_robin needs one weight per upstream")
+		}
+		for i, w := range weights {
+			if w < 1 {
+				return fmt.Errorf("weight %d must be positive", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateStaticResponse(handler Handler) error {
+	status, ok := handler["status_code"].(int)
+	if !ok {
+		return nil // Caddy defaults to 200
+	}
+	if status < 100 || status > 599 {
+		return fmt.Errorf("static_response status %d out of range", status)
+	}
+	if status >= 300 && status < 400 && status != 304 {
+		headers, _ := handler["headers"].(map[string][]string)
+		if len(headers["Location"]) == 0 || headers["Location"][0] == "" {
+			return fmt.Errorf("static_response redirect %d missing Location header", status)
+		}
+	}
 	return nil
 }
@@ -25,7 +25,7 @@ func TestValidate_ValidConfig(t *testing.T) {
 		},
 	}
 
-	config, _ := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com")
+	config, _ := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
 	err := Validate(config)
 	require.NoError(t, err)
 }
@@ -41,13 +41,13 @@ func TestValidate_DuplicateHosts(t *testing.T) {
 							{
 								Match: []Match{{Host: []string{"test.com"}}},
 								Handle: []Handler{
-									ReverseProxyHandler("app:8080", false),
+									ReverseProxyHandler([]string{"app:8080"}, false),
 								},
 							},
 							{
 								Match: []Match{{Host: []string{"test.com"}}},
 								Handle: []Handler{
-									ReverseProxyHandler("app2:8080", false),
+									ReverseProxyHandler([]string{"app2:8080"}, false),
 								},
 							},
 						},
@@ -123,3 +123,230 @@ func TestValidate_NoHandlers(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "no handlers")
 }
+
+func TestValidate_HostWithLocations(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{
+			UUID:        "loc",
+			DomainNames: "loc.example.com",
+			ForwardHost: "app",
+			ForwardPort: 8080,
+			Enabled:     true,
+			Locations: []models.Location{
+				{Path: "/api", ForwardHost: "api", ForwardPort: 9000},
+			},
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "admin@example.com", ConfigOptions{})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+}
+
+func TestValidate_LocationRewrites(t *testing.T) {
+	config := &Config{
+		Apps: Apps{
+			HTTP: &HTTPApp{
+				Servers: map[string]*Server{
+					"srv": {
+						Listen: []string{":80"},
+						Routes: []*Route{
+							{
+								Match:  []Match{{Host: []string{"test.com"}, PathRegexp: &RegexpMatch{Pattern: "^/api/("}}},
+								Handle: []Handler{ReverseProxyHandler([]string{"app:80"}, false)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := Validate(config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "path_regexp")
+
+	route := config.Apps.HTTP.Servers["srv"].Routes[0]
+	route.Match[0].PathRegexp = &RegexpMatch{Pattern: "^/api/"}
+	route.Handle = []Handler{{"handler": "rewrite"}, route.Handle[0]}
+	err = Validate(config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rewrite")
+
+	route.Handle[0] = Handler{"handler": "rewrite", "strip_path_prefix": "/api"}
+	require.NoError(t, Validate(config))
+}
+
+func TestValidate_LoadBalancing(t *testing.T) {
+	newConfig := func(lb map[string]interface{}) *Config {
+		handler := ReverseProxyHandler([]string{"a:80", "b:80"}, false)
+		handler["load_balancing"] = lb
+		return &Config{Apps: Apps{HTTP: &HTTPApp{Servers: map[string]*Server{
+			"srv": {
+				Listen: []string{":80"},
+				Routes: []*Route{{Match: []Match{{Host: []string{"lb.com"}}}, Handle: []Handler{handler}}},
+			},
+		}}}}
+	}
+
+	require.NoError(t, Validate(newConfig(LoadBalancingConfig("ip_hash", nil))))
+
+	err := Validate(newConfig(LoadBalancingConfig("random_choose", nil)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown selection policy")
+
+	err = Validate(newConfig(map[string]interface{}{
+		"selection_policy": map[string]interface{}{"policy": "weighted_round_robin", "weights": []int{1}},
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "one weight per upstream")
+}
+
+func TestValidate_HealthChecks(t *testing.T) {
+	handler := ReverseProxyHandler([]string{"a:80"}, false)
+	handler["health_checks"] = map[string]interface{}{
+		"active": map[string]interface{}{"uri": "healthz", "interval": "10s", "timeout": "5s"},
+	}
+	config := &Config{Apps: Apps{HTTP: &HTTPApp{Servers: map[string]*Server{
+		"srv": {
+			Listen: []string{":80"},
+			Routes: []*Route{{Match: []Match{{Host: []string{"hc.com"}}}, Handle: []Handler{handler}}},
+		},
+	}}}}
+
+	err := Validate(config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must start with /")
+
+	handler["health_checks"] = map[string]interface{}{
+		"passive": map[string]interface{}{"fail_duration": "soon"},
+	}
+	err = Validate(config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fail_duration")
+}
+
+func TestValidate_RedirectionHostConflicts(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "proxy",
+		DomainNames: "app.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+	}}
+	opts := ConfigOptions{
+		RedirectionHosts: []models.RedirectionHost{{
+			UUID:          "redirect",
+			DomainNames:   "app.example.com",
+			ForwardDomain: "example.com",
+			StatusCode:    301,
+			Enabled:       true,
+		}},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", opts)
+	require.NoError(t, err)
+	require.ErrorContains(t, Validate(config), "duplicate host")
+}
+
+func TestValidate_RedirectWithoutLocation(t *testing.T) {
+	config := &Config{
+		Apps: Apps{
+			HTTP: &HTTPApp{
+				Servers: map[string]*Server{
+					"srv": {
+						Listen: []string{":80"},
+						Routes: []*Route{{
+							Match:  []Match{{Host: []string{"test.com"}}},
+							Handle: []Handler{StaticResponseHandler(301, "", nil)},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	require.ErrorContains(t, Validate(config), "missing Location header")
+}
+
+func TestValidate_StreamPortClash(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "proxy",
+		DomainNames: "app.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+	}}
+
+	tests := []struct {
+		name    string
+		stream  models.Stream
+		wantErr string
+	}{
+		{
+			name:   "free tcp port",
+			stream: models.Stream{ListenPort: 5432, Protocol: "tcp", Upstreams: []models.Upstream{{Host: "db", Port: 5432}}, Enabled: true},
+		},
+		{
+			name:    "tcp port used by cpm_server",
+			stream:  models.Stream{ListenPort: 443, Protocol: "tcp", Upstreams: []models.Upstream{{Host: "db", Port: 443}}, Enabled: true},
+			wantErr: "listen port clash on tcp/443",
+		},
+		{
+			name:    "udp port used by HTTP/3",
+			stream:  models.Stream{ListenPort: 443, Protocol: "udp", Upstreams: []models.Upstream{{Host: "vpn", Port: 443}}, Enabled: true},
+			wantErr: "listen port clash on udp/443",
+		},
+		{
+			name:   "udp port 80 is free",
+			stream: models.Stream{ListenPort: 80, Protocol: "udp", Upstreams: []models.Upstream{{Host: "game", Port: 80}}, Enabled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{Streams: []models.Stream{tt.stream}})
+			require.NoError(t, err)
+
+			err = Validate(config)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidate_Layer4ServersClash(t *testing.T) {
+	config := &Config{
+		Apps: Apps{
+			Layer4: &Layer4App{
+				Servers: map[string]*Layer4Server{
+					"a": {Listen: []string{"tcp/:5432"}, Routes: []*Layer4Route{{Handle: []Handler{{"handler": "proxy", "upstreams": []map[string]interface{}{{"dial": []string{"db:5432"}}}}}}}},
+					"b": {Listen: []string{":5432"}, Routes: []*Layer4Route{{Handle: []Handler{{"handler": "proxy", "upstreams": []map[string]interface{}{{"dial": []string{"db2:5432"}}}}}}}},
+				},
+			},
+		},
+	}
+
+	require.ErrorContains(t, Validate(config), "listen port clash on tcp/5432")
+}
+
+func TestValidate_UDP443FreeWithoutHTTP3(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID:        "proxy",
+		DomainNames: "app.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Enabled:     true,
+	}}
+	streams := []models.Stream{{ListenPort: 443, Protocol: "udp", Upstreams: []models.Upstream{{Host: "vpn", Port: 443}}, Enabled: true}}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy-data", "", ConfigOptions{Streams: streams, Protocols: []string{"h1", "h2"}})
+	require.NoError(t, err)
+	require.NoError(t, Validate(config))
+
+	config.Apps.HTTP.Servers["cpm_server"].Protocols = []string{"h1", "h4"}
+	require.ErrorContains(t, Validate(config), "unknown protocol h4")
+}
@@ -8,16 +8,19 @@ import (
 
 // Config captures runtime configuration sourced from environment variables.
 type Config struct {
-	Environment     string
-	HTTPPort        string
-	DatabasePath    string
-	FrontendDir     string
-	CaddyAdminAPI   string
-	CaddyConfigDir  string
-	CaddyBinary     string
-	ImportCaddyfile string
-	ImportDir       string
-	JWTSecret       string
+	Environment       string
+	HTTPPort          string
+	DatabasePath      string
+	FrontendDir       string
+	CaddyAdminAPI     string
+	CaddyConfigDir    string
+	CaddyBinary       string
+	ImportCaddyfile   string
+	ImportDir         string
+	JWTSecret         string
+	EncryptionKey     string // base64 AES-256 key for secrets at rest
+	EncryptionKeyFile string // holds the key when EncryptionKey is unset; created on first start
+	InternalURL       string // base URL Caddy reaches CPM at, e.g. for forward auth checks
 }
 
 // Load reads env vars and falls back to defaults so the server can boot with zero configuration.
@@ -33,7 +36,10 @@ func Load() (Config, error) {
 		ImportCaddyfile: getEnv("CPM_IMPORT_CADDYFILE", "/import/Caddyfile"),
 		ImportDir:       getEnv("CPM_IMPORT_DIR", filepath.Join("data", "imports")),
 		JWTSecret:       getEnv("CPM_JWT_SECRET", "change-me-in-production"),
+		EncryptionKey:   os.Getenv("CPM_ENCRYPTION_KEY"),
 	}
+	cfg.InternalURL = getEnv("CPM_INTERNAL_URL", "http://localhost:"+cfg.HTTPPort)
+	cfg.EncryptionKeyFile = getEnv("CPM_ENCRYPTION_KEY_FILE", filepath.Join(filepath.Dir(cfg.DatabasePath), "encryption.key"))
 
 	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0o755); err != nil {
 		return Config{}, fmt.Errorf("ensure data directory: %w", err)
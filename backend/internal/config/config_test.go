@@ -46,4 +46,5 @@ func TestLoad_Defaults(t *testing.T) {
 
 	assert.Equal(t, "development", cfg.Environment)
 	assert.Equal(t, "8080", cfg.HTTPPort)
+	assert.Equal(t, "http://localhost:8080", cfg.InternalURL)
 }
@@ -0,0 +1,153 @@
+// Package geoiptest writes small MaxMind DB files for tests.
+package geoiptest
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type node struct {
+	children [2]*node
+	data     int // Index into the data records, -1 for internal nodes
+}
+
+// Database encodes a GeoLite2-Country style database (IPv6 tree, 24-bit records)
+// mapping each network to an ISO country code. IPv4 networks are stored below
+// ::/96 as MaxMind does. Networks must not overlap.
+func Database(t testing.TB, countries map[string]string) []byte {
+	t.Helper()
+
+	networks := make([]string, 0, len(countries))
+	for network := range countries {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	var data []byte
+	offsets := map[string]int{}
+	root := &node{data: -1}
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			t.Fatalf("geoiptest: %v", err)
+		}
+		bits := prefix.Bits()
+		addr := prefix.Addr().As16()
+		if prefix.Addr().Is4() {
+			// Map a.b.c.d/n to ::a.b.c.d/96+n
+			v4 := prefix.Addr().As4()
+			addr = [16]byte{12: v4[0], 13: v4[1], 14: v4[2], 15: v4[3]}
+			bits += 96
+		}
+
+		code := countries[network]
+		if _, ok := offsets[code]; !ok {
+			offsets[code] = len(data)
+			data = append(data, encodeMap(2)...)
+			data = append(data, encodeString("country")...)
+			data = append(data, encodeMap(1)...)
+			data = append(data, encodeString("iso_code")...)
+			data = append(data, encodeString(code)...)
+			data = append(data, encodeString("continent")...)
+			data = append(data, encodeMap(1)...)
+			data = append(data, encodeString("code")...)
+			data = append(data, encodeString("XX")...)
+		}
+
+		n := root
+		for i := 0; i < bits; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{data: -1}
+			}
+			n = n.children[bit]
+		}
+		n.data = offsets[code]
+	}
+
+	// Number the internal nodes breadth first; leaves become data pointers
+	var order []*node
+	numbers := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		numbers[n] = len(order)
+		order = append(order, n)
+		for _, child := range n.children {
+			if child != nil && child.data < 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	nodeCount := len(order)
+	var tree []byte
+	for _, n := range order {
+		for _, child := range n.children {
+			record := nodeCount // Empty
+			switch {
+			case child == nil:
+			case child.data >= 0:
+				record = nodeCount + 16 + child.data
+			default:
+				record = numbers[child]
+			}
+			tree = append(tree, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+
+	out := append(tree, make([]byte, 16)...)
+	out = append(out, data...)
+	out = append(out, "\xab\xcd\xefMaxMind.com"...)
+	out = append(out, encodeMap(6)...)
+	out = append(out, encodeString("node_count")...)
+	out = append(out, encodeUint(6, uint64(nodeCount))...)
+	out = append(out, encodeString("record_size")...)
+	out = append(out, encodeUint(5, 24)...)
+	out = append(out, encodeString("ip_version")...)
+	out = append(out, encodeUint(5, 6)...)
+	out = append(out, encodeString("database_type")...)
+	out = append(out, encodeString("GeoLite2-Country")...)
+	out = append(out, encodeString("binary_format_major_version")...)
+	out = append(out, encodeUint(5, 2)...)
+	out = append(out, encodeString("build_epoch")...)
+	out = append(out, encodeUint(9, 1700000000)...)
+	return out
+}
+
+// WriteDatabase writes Database(t, countries) to a temporary file and returns its path.
+func WriteDatabase(t testing.TB, countries map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	if err := os.WriteFile(path, Database(t, countries), 0o600); err != nil {
+		t.Fatalf("geoiptest: %v", err)
+	}
+	return path
+}
+
+func encodeString(s string) []byte {
+	if len(s) >= 29 {
+		panic("geoiptest: string too long")
+	}
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func encodeMap(pairs int) []byte {
+	return []byte{7<<5 | byte(pairs)}
+}
+
+// encodeUint encodes v as uint16 (5), uint32 (6) or uint64 (9).
+func encodeUint(typeNum byte, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if typeNum < 8 {
+		return append([]byte{typeNum<<5 | byte(len(b))}, b...)
+	}
+	return append([]byte{byte(len(b)), typeNum - 7}, b...)
+}
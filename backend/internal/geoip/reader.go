@@ -0,0 +1,343 @@
+// Package geoip reads country data from MaxMind DB files such as GeoLite2-Country.mmdb.
+// It implements the subset of the MaxMind DB format needed for lookups; see
+// https://maxmind.github.io/MaxMind-DB/ for the specification.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree and the data section.
+const dataSectionSeparator = 16
+
+// Reader looks up addresses in a MaxMind DB loaded into memory.
+type Reader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint // Node of ::/96, where IPv4 lookups begin in an IPv6 tree
+	dbType     string
+}
+
+// Open reads and checks a MaxMind DB file.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a MaxMind DB held in memory.
+func FromBytes(buf []byte) (*Reader, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, errors.New("not a MaxMind DB file: metadata not found")
+	}
+	metaStart := uint(start + len(metadataMarker))
+	meta, _, err := (&decoder{buf: buf[metaStart:]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", err)
+	}
+	fields, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid metadata")
+	}
+
+	r := &Reader{buf: buf}
+	r.nodeCount = uintField(fields, "node_count")
+	r.recordSize = uintField(fields, "record_size")
+	r.ipVersion = uintField(fields, "ip_version")
+	r.dbType, _ = fields["database_type"].(string)
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip version %d", r.ipVersion)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	r.dataStart = treeSize + dataSectionSeparator
+	if r.dataStart > metaStart {
+		return nil, errors.New("invalid MaxMind DB: search tree exceeds file")
+	}
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType returns the type from the metadata, e.g. "GeoLite2-Country".
+func (r *Reader) DatabaseType() string {
+	return r.dbType
+}
+
+func uintField(fields map[string]interface{}, key string) uint {
+	v, _ := fields[key].(uint64)
+	return uint(v)
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a search tree node.
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// Lookup returns the data record for addr, or nil when the database has none.
+func (r *Reader) Lookup(addr netip.Addr) (interface{}, error) {
+	addr = addr.Unmap()
+	var bits []byte
+	node := uint(0)
+	switch {
+	case addr.Is4() && r.ipVersion == 6:
+		b := addr.As4()
+		bits, node = b[:], r.ipv4Start
+	case addr.Is4():
+		b := addr.As4()
+		bits = b[:]
+	case r.ipVersion == 4:
+		return nil, nil
+	default:
+		b := addr.As16()
+		bits = b[:]
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-i%8)) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, errors.New("invalid MaxMind DB: search tree ends inside the tree")
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	d := &decoder{buf: r.buf[r.dataStart:]}
+	value, _, err := d.decode(offset)
+	return value, err
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country addr is located in,
+// falling back to the country it is registered in, or "" when unknown.
+func (r *Reader) Country(addr netip.Addr) (string, error) {
+	value, err := r.Lookup(addr)
+	if err != nil {
+		return "", err
+	}
+	record, _ := value.(map[string]interface{})
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := record[key].(map[string]interface{})
+		if code, _ := country["iso_code"].(string); code != "" {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// Data section field types.
+const (
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// decoder decodes values of a data section; pointers are offsets into buf.
+type decoder struct {
+	buf []byte
+}
+
+var errTruncated = errors.New("invalid MaxMind DB: unexpected end of data")
+
+// decode returns the value at offset and the offset following it.
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeDepth(offset, 0)
+}
+
+func (d *decoder) decodeDepth(offset uint, depth int) (interface{}, uint, error) {
+	if depth > 64 {
+		return nil, 0, errors.New("invalid MaxMind DB: data nested too deeply")
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+
+	if typeNum == typePointer {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeDepth(target, depth+1)
+		return value, next, err
+	}
+
+	if typeNum == 0 {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		extra := uint(0)
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("invalid MaxMind DB: map key is not a string")
+			}
+			value, after, err := d.decodeDepth(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = after
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEnd:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid MaxMind DB: bad double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid MaxMind DB: bad float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		v := uint64(0)
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		v := uint32(0)
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case typeUint128:
+		// Not needed for country lookups; keep the raw big-endian bytes
+		return append([]byte(nil), b...), next, nil
+	}
+	return nil, 0, fmt.Errorf("invalid MaxMind DB: unknown data type %d", typeNum)
+}
+
+// pointer resolves a pointer whose control byte is ctrl and whose payload starts at offset.
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl>>3)&0x3 + 1
+	if offset+size > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	var target uint
+	switch size {
+	case 1:
+		target = uint(ctrl&0x7)<<8 | uint(b[0])
+	case 2:
+		target = (uint(ctrl&0x7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		target = (uint(ctrl&0x7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		target = uint(binary.BigEndian.Uint32(b))
+	}
+	return target, offset + size, nil
+}
@@ -0,0 +1,48 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/geoip"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/geoip/geoiptest"
+)
+
+func TestReader_Country(t *testing.T) {
+	path := geoiptest.WriteDatabase(t, map[string]string{
+		"81.2.69.0/24":  "GB",
+		"89.160.0.0/16": "SE",
+		"2001:db8::/32": "DE",
+		"1.2.3.4/32":    "AU",
+	})
+	reader, err := geoip.Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, "GeoLite2-Country", reader.DatabaseType())
+
+	tests := map[string]string{
+		"81.2.69.160":      "GB",
+		"::ffff:81.2.69.1": "GB",
+		"89.160.20.112":    "SE",
+		"2001:db8::1":      "DE",
+		"1.2.3.4":          "AU",
+		"1.2.3.5":          "",
+		"10.0.0.1":         "",
+		"2001:db9::1":      "",
+	}
+	for ip, want := range tests {
+		got, err := reader.Country(netip.MustParseAddr(ip))
+		require.NoError(t, err, ip)
+		assert.Equal(t, want, got, ip)
+	}
+}
+
+func TestOpen_RejectsOtherFiles(t *testing.T) {
+	_, err := geoip.FromBytes([]byte("definitely not a database"))
+	require.ErrorContains(t, err, "metadata not found")
+
+	_, err = geoip.Open("/nonexistent/GeoLite2-Country.mmdb")
+	require.Error(t, err)
+}
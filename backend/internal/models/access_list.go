@@ -2,18 +2,87 @@ package models
 
 import (
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // AccessList defines IP-based or auth-based access control rules
 // that can be applied to proxy hosts.
 type AccessList struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	UUID        string    `json:"uuid" gorm:"uniqueIndex"`
-	Name        string    `json:"name" gorm:"index"`
-	Description string    `json:"description"`
-	Type        string    `json:"type"`                   // "allow", "deny", "basic_auth", "forward_auth"
-	Rules       string    `json:"rules" gorm:"type:text"` // JSON array of rule definitions
-	Enabled     bool      `json:"enabled" gorm:"default:true"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	UUID           string           `json:"uuid" gorm:"uniqueIndex"`
+	Name           string           `json:"name" gorm:"index"`
+	Description    string           `json:"description"`
+	Type           string           `json:"type"`                                                             // "allow", "deny", "basic_auth", "forward_auth", "sso", "country_allow", "country_deny"
+	Rules          []string         `json:"rules" gorm:"type:text;serializer:json"`                           // Networks (CIDR or single IPs) of allow/deny lists, ISO country codes of country lists
+	Realm          string           `json:"realm"`                                                            // Shown in the basic_auth login prompt
+	ForwardAuthURL string           `json:"forward_auth_url"`                                                 // Endpoint of forward_auth lists, e.g. http://authelia:9091/api/authz/forward-auth
+	CopyHeaders    []string         `json:"copy_headers" gorm:"type:text;serializer:json"`                    // Response headers of the auth service passed on to the upstream
+	TrustedProxies []string         `json:"trusted_proxies" gorm:"type:text;serializer:json"`                 // Proxies in front of Caddy whose X-Forwarded-* headers reach the auth service
+	AllowedUsers   []string         `json:"allowed_users" gorm:"type:text;serializer:json"`                   // UUIDs of the CPM users an sso list admits
+	AllowedRoles   []string         `json:"allowed_roles" gorm:"type:text;serializer:json"`                   // Roles an sso list admits; with no users or roles every CPM user is admitted
+	Users          []AccessListUser `json:"users" gorm:"foreignKey:AccessListID;constraint:OnDelete:CASCADE"` // Accounts of basic_auth lists
+	Enabled        bool             `json:"enabled"`                                                          // The API defaults it to true
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// AccessListUser is an account of a basic_auth AccessList. Only the bcrypt hash
+// of its password is stored.
+type AccessListUser struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UUID         string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	AccessListID uint      `json:"access_list_id" gorm:"not null;index"`
+	Username     string    `json:"username" gorm:"not null"`
+	PasswordHash string    `json:"-"` // Never serialize password hash
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SetPassword hashes and sets the account's password.
+func (u *AccessListUser) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
 }
+
+// CheckPassword compares the provided password with the stored hash.
+func (u *AccessListUser) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// Access list types.
+const (
+	AccessListAllow        = "allow"         // Only the listed networks may connect
+	AccessListDeny         = "deny"          // The listed networks are refused
+	AccessListBasicAuth    = "basic_auth"    // Clients log in with HTTP basic auth
+	AccessListForwardAuth  = "forward_auth"  // An external service authorizes each request
+	AccessListSSO          = "sso"           // Clients log in with their CPM account
+	AccessListCountryAllow = "country_allow" // Only clients located in the listed countries may connect
+	AccessListCountryDeny  = "country_deny"  // Clients located in the listed countries are refused
+)
+
+// AccessListTypes lists every supported access list type.
+var AccessListTypes = []string{AccessListAllow, AccessListDeny, AccessListBasicAuth, AccessListForwardAuth, AccessListSSO, AccessListCountryAllow, AccessListCountryDeny}
+
+// IsIPList reports whether the list matches client networks rather than authenticating.
+func (a *AccessList) IsIPList() bool {
+	return a.Type == AccessListAllow || a.Type == AccessListDeny
+}
+
+// IsCountryList reports whether the list matches the country clients are located in.
+func (a *AccessList) IsCountryList() bool {
+	return a.Type == AccessListCountryAllow || a.Type == AccessListCountryDeny
+}
+
+// DefaultRealm is the basic_auth realm of lists that don't set one.
+const DefaultRealm = "Restricted"
+
+// How a host or location combines its IP lists with its auth lists.
+const (
+	AccessSatisfyAll = "all" // Clients must pass the IP lists and authenticate
+	AccessSatisfyAny = "any" // Allowed networks skip authentication; others must authenticate
+)
@@ -6,13 +6,31 @@ import (
 
 // Location represents a custom path-based proxy configuration within a ProxyHost.
 type Location struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	UUID          string    `json:"uuid" gorm:"uniqueIndex;not null"`
-	ProxyHostID   uint      `json:"proxy_host_id" gorm:"not null;index"`
-	Path          string    `json:"path" gorm:"not null"` // e.g., /api, /admin
-	ForwardScheme string    `json:"forward_scheme" gorm:"default:http"`
-	ForwardHost   string    `json:"forward_host" gorm:"not null"`
-	ForwardPort   int       `json:"forward_port" gorm:"not null"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	UUID             string            `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID      uint              `json:"proxy_host_id" gorm:"not null;index"`
+	Path             string            `json:"path" gorm:"not null"` // e.g., /api, /admin
+	PathRegexp       string            `json:"path_regexp"`          // RE2 pattern matched against the path instead of the Path prefix
+	ForwardScheme    string            `json:"forward_scheme" gorm:"default:http"`
+	ForwardHost      string            `json:"forward_host" gorm:"not null"`
+	ForwardPort      int               `json:"forward_port" gorm:"not null"`
+	WebsocketSupport *bool             `json:"websocket_support"`                                // nil inherits the host's setting
+	StripPathPrefix  bool              `json:"strip_path_prefix"`                                // Remove Path from the request before proxying
+	RewriteURI       string            `json:"rewrite_uri"`                                      // Replaces the URI after stripping; placeholders such as {http.request.uri} are allowed
+	RequestHeaders   map[string]string `json:"request_headers" gorm:"type:text;serializer:json"` // Set on the upstream request
+	AccessListIDs    []uint            `json:"access_list_ids" gorm:"type:text;serializer:json"` // Replace the host's access lists for this location when set
+	AccessSatisfy    string            `json:"access_satisfy"`                                   // "all" (default) or "any"
+	RateLimits       []RateLimitRule   `json:"rate_limits" gorm:"type:text;serializer:json"`     // Apply on top of the host's rules
+	MaxBodySize      int64             `json:"max_body_size"`                                    // Replaces the host's limit when set
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// Websocket reports whether the location proxies websocket upgrades, falling
+// back to the setting of its host.
+func (l *Location) Websocket(host *ProxyHost) bool {
+	if l.WebsocketSupport != nil {
+		return *l.WebsocketSupport
+	}
+	return host.WebsocketSupport
 }
@@ -29,6 +29,7 @@ type CaddyAccessLog struct {
 	Size        int                 `json:"size"`
 	Status      int                 `json:"status"`
 	RespHeaders map[string][]string `json:"resp_headers"`
+	Country     string              `json:"country,omitempty"` // ISO code of the client's country, when a GeoIP database is configured
 }
 
 // LogFilter defines criteria for filtering logs.
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// PageTemplate is an HTML page Caddy serves itself, as the maintenance page of
+// a ProxyHost or as one of its error pages. Content may use Caddy placeholders
+// such as {http.error.status_code}.
+type PageTemplate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UUID      string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
+	Content   string    `json:"content" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
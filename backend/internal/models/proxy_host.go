@@ -1,26 +1,241 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // ProxyHost represents a reverse proxy configuration.
 type ProxyHost struct {
-	ID               uint       `json:"id" gorm:"primaryKey"`
-	UUID             string     `json:"uuid" gorm:"uniqueIndex;not null"`
-	Name             string     `json:"name"`
-	DomainNames      string     `json:"domain_names" gorm:"not null"` // Comma-separated list
-	ForwardScheme    string     `json:"forward_scheme" gorm:"default:http"`
-	ForwardHost      string     `json:"forward_host" gorm:"not null"`
-	ForwardPort      int        `json:"forward_port" gorm:"not null"`
-	SSLForced        bool       `json:"ssl_forced" gorm:"default:false"`
-	HTTP2Support     bool       `json:"http2_support" gorm:"default:true"`
-	HSTSEnabled      bool       `json:"hsts_enabled" gorm:"default:false"`
-	HSTSSubdomains   bool       `json:"hsts_subdomains" gorm:"default:false"`
-	BlockExploits    bool       `json:"block_exploits" gorm:"default:true"`
-	WebsocketSupport bool       `json:"websocket_support" gorm:"default:false"`
-	Enabled          bool       `json:"enabled" gorm:"default:true"`
-	Locations        []Location `json:"locations" gorm:"foreignKey:ProxyHostID;constraint:OnDelete:CASCADE"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	ID                      uint              `json:"id" gorm:"primaryKey"`
+	UUID                    string            `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name                    string            `json:"name"`
+	DomainNames             string            `json:"domain_names" gorm:"not null"` // Comma-separated list
+	ForwardScheme           string            `json:"forward_scheme" gorm:"default:http"`
+	ForwardHost             string            `json:"forward_host" gorm:"not null"`
+	ForwardPort             int               `json:"forward_port" gorm:"not null"`
+	SSLForced               bool              `json:"ssl_forced" gorm:"default:false"` // Mirrors HTTPSMode == "https_redirect"
+	HTTPSMode               string            `json:"https_mode"`                      // "https_redirect", "https" or "http_only"
+	HTTP2Support            bool              `json:"http2_support"`                   // The API defaults it to true
+	HSTSEnabled             bool              `json:"hsts_enabled" gorm:"default:false"`
+	HSTSSubdomains          bool              `json:"hsts_subdomains" gorm:"default:false"`
+	BlockExploits           bool              `json:"block_exploits"`                // The API defaults it to true
+	CrowdSec                bool              `json:"crowdsec" gorm:"default:false"` // Refuse clients with a CrowdSec decision; needs the LAPI settings and the bouncer module
+	WebsocketSupport        bool              `json:"websocket_support" gorm:"default:false"`
+	Upstreams               []Upstream        `json:"upstreams" gorm:"type:text;serializer:json"` // Overrides ForwardHost/ForwardPort when set
+	LoadBalancing           string            `json:"load_balancing" gorm:"default:round_robin"`  // "round_robin", "least_conn", "ip_hash", "cookie", "first"
+	HealthCheck             HealthCheckConfig `json:"health_check" gorm:"embedded;embeddedPrefix:health_"`
+	UpstreamTLS             UpstreamTLSConfig `json:"upstream_tls" gorm:"embedded;embeddedPrefix:upstream_tls_"` // Used when ForwardScheme is https
+	CertificateID           *uint             `json:"certificate_id" gorm:"index"`                               // Custom SSLCertificate served instead of an ACME certificate
+	DNSProvider             string            `json:"dns_provider"`                                              // Solve ACME with DNS-01 through this provider instead of HTTP/TLS-ALPN
+	WildcardCert            bool              `json:"wildcard_certificate"`                                      // Share *.<parent> certificates with other hosts; requires DNSProvider unless CertIssuer is internal
+	CertIssuer              string            `json:"cert_issuer"`                                               // "" (global default), "letsencrypt", "letsencrypt_staging", "zerossl" or "internal"
+	HeaderRules             []HeaderRule      `json:"header_rules" gorm:"type:text;serializer:json"`             // Custom request/response header operations
+	SecurityHeaderProfileID *uint             `json:"security_header_profile_id" gorm:"index"`                   // Shared SecurityHeaderProfile applied to responses
+	CSPOverride             string            `json:"csp_override" gorm:"type:text"`                             // Replaces the profile's Content-Security-Policy for this host
+	AccessListIDs           []uint            `json:"access_list_ids" gorm:"type:text;serializer:json"`          // AccessLists guarding the host and its locations
+	AccessSatisfy           string            `json:"access_satisfy"`                                            // "all" (default) or "any"; see AccessSatisfyAll
+	RateLimits              []RateLimitRule   `json:"rate_limits" gorm:"type:text;serializer:json"`              // Throttle every request of the host, locations included
+	MaxBodySize             int64             `json:"max_body_size"`                                             // Largest request body in bytes; 0 means unlimited
+	LimitResponse           LimitResponse     `json:"limit_response" gorm:"embedded;embeddedPrefix:limit_response_"`
+	Maintenance             MaintenanceConfig `json:"maintenance" gorm:"embedded;embeddedPrefix:maintenance_"`
+	ErrorPages              []ErrorPage       `json:"error_pages" gorm:"type:text;serializer:json"` // Replace the errors Caddy raises for the host
+	InterceptErrors         bool              `json:"intercept_errors"`                             // Also replace upstream responses whose status has an error page
+	Advanced                AdvancedConfig    `json:"advanced" gorm:"embedded;embeddedPrefix:advanced_"`
+	Enabled                 bool              `json:"enabled"` // The API defaults it to true
+	Locations               []Location        `json:"locations" gorm:"foreignKey:ProxyHostID;constraint:OnDelete:CASCADE"`
+	CreatedAt               time.Time         `json:"created_at"`
+	UpdatedAt               time.Time         `json:"updated_at"`
+}
+
+// Load balancing policies selectable for a ProxyHost with several upstreams.
+const (
+	LoadBalancingRoundRobin = "round_robin"
+	LoadBalancingLeastConn  = "least_conn"
+	LoadBalancingIPHash     = "ip_hash"
+	LoadBalancingCookie     = "cookie"
+	LoadBalancingFirst      = "first"
+)
+
+// LoadBalancingPolicies lists every supported load balancing policy.
+var LoadBalancingPolicies = []string{
+	LoadBalancingRoundRobin,
+	LoadBalancingLeastConn,
+	LoadBalancingIPHash,
+	LoadBalancingCookie,
+	LoadBalancingFirst,
+}
+
+// Upstream is one backend replica of a load-balanced ProxyHost.
+type Upstream struct {
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	Weight int    `json:"weight,omitempty"` // Relative share for round_robin; 0 means 1
+}
+
+// EffectiveUpstreams returns the upstreams traffic is balanced across,
+// falling back to ForwardHost/ForwardPort when no explicit list is set.
+func (h *ProxyHost) EffectiveUpstreams() []Upstream {
+	if len(h.Upstreams) > 0 {
+		return h.Upstreams
+	}
+	return []Upstream{{Host: h.ForwardHost, Port: h.ForwardPort}}
+}
+
+// HealthCheckConfig configures the upstream health checks Caddy runs for a ProxyHost.
+// Durations are in seconds; zero values fall back to the generator's defaults.
+type HealthCheckConfig struct {
+	ActiveEnabled   bool   `json:"active_enabled" gorm:"default:false"`
+	ActivePath      string `json:"active_path"`     // e.g. /healthz
+	ActiveInterval  int    `json:"active_interval"` // seconds between probes
+	ActiveTimeout   int    `json:"active_timeout"`  // seconds before a probe counts as failed
+	ExpectStatus    int    `json:"expect_status"`   // e.g. 200; 0 accepts any 2xx
+	PassiveEnabled  bool   `json:"passive_enabled" gorm:"default:false"`
+	FailDuration    int    `json:"fail_duration"` // seconds a failed request is remembered
+	MaxFails        int    `json:"max_fails"`     // failures within FailDuration before marking unhealthy
+	UnhealthyStatus []int  `json:"unhealthy_status" gorm:"type:text;serializer:json"`
+}
+
+// UpstreamTLSConfig controls how Caddy verifies and authenticates to an HTTPS upstream.
+// It applies to the host's upstreams only; locations forwarding over https use
+// Caddy's defaults.
+type UpstreamTLSConfig struct {
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" gorm:"default:false"`
+	ServerName         string `json:"server_name"`                      // SNI sent upstream; defaults to the dialed host
+	TrustedCAPEM       string `json:"trusted_ca_pem" gorm:"type:text"`  // Replaces the system roots when set
+	ClientCertPEM      string `json:"client_cert_pem" gorm:"type:text"` // Presented for upstream mTLS
+	ClientKeyPEM       string `json:"client_key_pem" gorm:"type:text"`  // Write-only; never returned by the API
+}
+
+// HasClientCert reports whether a client certificate is configured for upstream mTLS.
+func (c UpstreamTLSConfig) HasClientCert() bool {
+	return c.ClientCertPEM != "" && c.ClientKeyPEM != ""
+}
+
+// MarshalJSON omits the client key so it never leaves the server.
+func (c UpstreamTLSConfig) MarshalJSON() ([]byte, error) {
+	type plain UpstreamTLSConfig
+	return json.Marshal(struct {
+		plain
+		ClientKeyPEM string `json:"client_key_pem,omitempty"`
+		HasClientKey bool   `json:"has_client_key"`
+	}{
+		plain:        plain(c),
+		HasClientKey: c.ClientKeyPEM != "",
+	})
+}
+
+// HTTPS modes of a ProxyHost.
+const (
+	HTTPSModeRedirect = "https_redirect" // HTTPS, plain HTTP redirects to it
+	HTTPSModeHTTPS    = "https"          // HTTPS and plain HTTP both serve the host
+	HTTPSModeHTTPOnly = "http_only"      // Plain HTTP only; no certificate is requested
+)
+
+// HTTPSModes lists every supported HTTPS mode.
+var HTTPSModes = []string{HTTPSModeRedirect, HTTPSModeHTTPS, HTTPSModeHTTPOnly}
+
+// EffectiveHTTPSMode returns HTTPSMode, deriving it from SSLForced for hosts saved before modes existed.
+func (h *ProxyHost) EffectiveHTTPSMode() string {
+	if h.HTTPSMode != "" {
+		return h.HTTPSMode
+	}
+	if h.SSLForced {
+		return HTTPSModeRedirect
+	}
+	return HTTPSModeHTTPS
+}
+
+// Certificate issuers selectable for a ProxyHost. The empty value keeps the
+// global default policy.
+const (
+	CertIssuerDefault            = ""
+	CertIssuerLetsEncrypt        = "letsencrypt"
+	CertIssuerLetsEncryptStaging = "letsencrypt_staging"
+	CertIssuerZeroSSL            = "zerossl"  // ACME with EAB credentials
+	CertIssuerInternal           = "internal" // Caddy's local CA, for LAN-only names
+)
+
+// CertIssuers lists every supported certificate issuer.
+var CertIssuers = []string{CertIssuerDefault, CertIssuerLetsEncrypt, CertIssuerLetsEncryptStaging, CertIssuerZeroSSL, CertIssuerInternal}
+
+// Directions and operations of a HeaderRule.
+const (
+	HeaderRuleRequest  = "request"  // Headers sent to the upstream
+	HeaderRuleResponse = "response" // Headers sent back to the client
+
+	HeaderOpSet    = "set"    // Replace any existing values
+	HeaderOpAdd    = "add"    // Append a value
+	HeaderOpDelete = "delete" // Remove the header
+)
+
+// HeaderRule is one header operation of a ProxyHost. Values may contain Caddy
+// placeholders such as {http.request.remote.host}.
+type HeaderRule struct {
+	Direction string `json:"direction"` // "request" or "response"
+	Operation string `json:"operation"` // "set", "add" or "delete"
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"` // Unused by delete
+}
+
+// Keys a RateLimitRule counts requests by.
+const (
+	RateLimitKeyIP     = "ip"     // Client address; honours caddy.trusted_proxies
+	RateLimitKeyHeader = "header" // Value of a request header such as an API key
+	RateLimitKeyPath   = "path"   // Request path, shared by every client
+)
+
+// RateLimitKeys lists every supported rate limit key.
+var RateLimitKeys = []string{RateLimitKeyIP, RateLimitKeyHeader, RateLimitKeyPath}
+
+// RateLimitRule allows Requests per Window for each distinct value of its key.
+// Clients over the limit get the host's LimitResponse.
+type RateLimitRule struct {
+	Key      string `json:"key"`              // "ip" (default), "header" or "path"
+	Header   string `json:"header,omitempty"` // Header counted by the header key
+	Requests int    `json:"requests"`
+	Window   int    `json:"window"` // Seconds
+}
+
+// LimitResponse replaces Caddy's empty 429 answer to clients over a rate limit.
+type LimitResponse struct {
+	StatusCode  int    `json:"status_code"` // 0 means 429
+	Body        string `json:"body" gorm:"type:text"`
+	ContentType string `json:"content_type"` // e.g. application/json; default text/plain
+}
+
+// MaintenanceCookie is the cookie holding a host's maintenance bypass secret.
+const MaintenanceCookie = "cpm_maintenance"
+
+// MaintenanceConfig takes a ProxyHost offline behind a maintenance page while
+// letting selected clients through.
+type MaintenanceConfig struct {
+	Enabled      bool     `json:"enabled" gorm:"default:false"`
+	TemplateID   *uint    `json:"template_id"`                                 // PageTemplate served with 503; nil uses the built-in page
+	BypassIPs    []string `json:"bypass_ips" gorm:"type:text;serializer:json"` // Networks that still reach the upstream
+	BypassCookie string   `json:"bypass_cookie"`                               // Secret value of MaintenanceCookie that still reaches the upstream
+}
+
+// ErrorPage serves a PageTemplate for an error status, given exactly ("404")
+// or as a class ("5xx").
+type ErrorPage struct {
+	Status     string `json:"status"`
+	TemplateID uint   `json:"template_id"`
+}
+
+// Formats of an AdvancedConfig.
+const (
+	AdvancedFormatJSON      = "json"      // Caddy JSON routes or handlers
+	AdvancedFormatCaddyfile = "caddyfile" // Caddyfile directives, adapted with `caddy adapt`
+)
+
+// AdvancedFormats lists every supported advanced config format.
+var AdvancedFormats = []string{AdvancedFormatJSON, AdvancedFormatCaddyfile}
+
+// AdvancedConfig is raw Caddy config merged into the main route of a ProxyHost,
+// for what CPM has no setting for.
+type AdvancedConfig struct {
+	Format  string `json:"format"` // "json" (default) or "caddyfile"
+	Content string `json:"content" gorm:"type:text"`
+	Routes  string `json:"routes" gorm:"type:text"` // Caddy JSON routes compiled from Content on save
 }
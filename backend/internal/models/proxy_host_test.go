@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamTLSConfig_MarshalOmitsClientKey(t *testing.T) {
+	host := ProxyHost{
+		UpstreamTLS: UpstreamTLSConfig{
+			ServerName:    "pve.internal",
+			ClientCertPEM: "cert",
+			ClientKeyPEM:  "secret-key",
+		},
+	}
+
+	raw, err := json.Marshal(host)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret-key")
+	assert.Contains(t, string(raw), `"has_client_key":true`)
+	assert.Contains(t, string(raw), `"server_name":"pve.internal"`)
+
+	// Binding a response back onto the stored host keeps the key
+	require.NoError(t, json.Unmarshal(raw, &host))
+	assert.Equal(t, "secret-key", host.UpstreamTLS.ClientKeyPEM)
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// RedirectionHost answers every request for its domains with a redirect to ForwardDomain.
+type RedirectionHost struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UUID          string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name          string    `json:"name"`
+	DomainNames   string    `json:"domain_names" gorm:"not null"`       // Comma-separated list
+	ForwardScheme string    `json:"forward_scheme" gorm:"default:auto"` // "auto" (keep request scheme), "http", "https"
+	ForwardDomain string    `json:"forward_domain" gorm:"not null"`     // Target host, optionally with :port
+	StatusCode    int       `json:"status_code" gorm:"default:301"`     // 301, 302, 307 or 308
+	PreservePath  bool      `json:"preserve_path"`                      // Append the original path and query; the API defaults it to true
+	Enabled       bool      `json:"enabled"`                            // The API defaults it to true
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
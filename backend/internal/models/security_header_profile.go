@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+// Presets of a SecurityHeaderProfile. Built-in presets fix the header values;
+// custom profiles use their own.
+const (
+	SecurityPresetStrict  = "strict"
+	SecurityPresetRelaxed = "relaxed"
+	SecurityPresetCustom  = "custom"
+)
+
+// SecurityPresets lists every supported preset.
+var SecurityPresets = []string{SecurityPresetStrict, SecurityPresetRelaxed, SecurityPresetCustom}
+
+// SecurityHeaderProfile is a named set of hardening response headers that
+// proxy hosts can share.
+type SecurityHeaderProfile struct {
+	ID                      uint      `json:"id" gorm:"primaryKey"`
+	UUID                    string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name                    string    `json:"name" gorm:"uniqueIndex;not null"`
+	Preset                  string    `json:"preset" gorm:"default:custom"` // "strict", "relaxed" or "custom"
+	ContentSecurityPolicy   string    `json:"content_security_policy" gorm:"type:text"`
+	XFrameOptions           string    `json:"x_frame_options"`      // "DENY" or "SAMEORIGIN"
+	ContentTypeNosniff      bool      `json:"content_type_nosniff"` // X-Content-Type-Options: nosniff
+	ReferrerPolicy          string    `json:"referrer_policy"`
+	PermissionsPolicy       string    `json:"permissions_policy" gorm:"type:text"`
+	CrossOriginOpenerPolicy string    `json:"cross_origin_opener_policy"`
+	RemoveServerHeader      bool      `json:"remove_server_header"` // Strip Server from upstream responses
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// securityPresetValues are the header values of the built-in presets.
+var securityPresetValues = map[string]SecurityHeaderProfile{
+	SecurityPresetStrict: {
+		ContentSecurityPolicy:   "default-src 'self'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'; object-src 'none'",
+		XFrameOptions:           "DENY",
+		ContentTypeNosniff:      true,
+		ReferrerPolicy:          "no-referrer",
+		PermissionsPolicy:       "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		CrossOriginOpenerPolicy: "same-origin",
+		RemoveServerHeader:      true,
+	},
+	SecurityPresetRelaxed: {
+		ContentSecurityPolicy: "upgrade-insecure-requests",
+		XFrameOptions:         "SAMEORIGIN",
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		RemoveServerHeader:    true,
+	},
+}
+
+// ApplyPreset overwrites the header values with those of the profile's
+// built-in preset. Custom profiles are left unchanged.
+func (p *SecurityHeaderProfile) ApplyPreset() {
+	values, ok := securityPresetValues[p.Preset]
+	if !ok {
+		return
+	}
+	p.ContentSecurityPolicy = values.ContentSecurityPolicy
+	p.XFrameOptions = values.XFrameOptions
+	p.ContentTypeNosniff = values.ContentTypeNosniff
+	p.ReferrerPolicy = values.ReferrerPolicy
+	p.PermissionsPolicy = values.PermissionsPolicy
+	p.CrossOriginOpenerPolicy = values.CrossOriginOpenerPolicy
+	p.RemoveServerHeader = values.RemoveServerHeader
+}
+
+// Headers returns the response headers the profile sets, keyed by name.
+func (p *SecurityHeaderProfile) Headers() map[string]string {
+	headers := map[string]string{}
+	if p.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = p.ContentSecurityPolicy
+	}
+	if p.XFrameOptions != "" {
+		headers["X-Frame-Options"] = p.XFrameOptions
+	}
+	if p.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if p.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = p.ReferrerPolicy
+	}
+	if p.PermissionsPolicy != "" {
+		headers["Permissions-Policy"] = p.PermissionsPolicy
+	}
+	if p.CrossOriginOpenerPolicy != "" {
+		headers["Cross-Origin-Opener-Policy"] = p.CrossOriginOpenerPolicy
+	}
+	return headers
+}
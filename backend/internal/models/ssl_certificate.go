@@ -13,7 +13,9 @@ type SSLCertificate struct {
 	Provider    string     `json:"provider"`                     // "letsencrypt", "custom", "self-signed"
 	Domains     string     `json:"domains"`                      // comma-separated list of domains
 	Certificate string     `json:"certificate" gorm:"type:text"` // PEM-encoded certificate
-	PrivateKey  string     `json:"private_key" gorm:"type:text"` // PEM-encoded private key
+	Chain       string     `json:"chain" gorm:"type:text"`       // PEM-encoded intermediates
+	PrivateKey  string     `json:"-" gorm:"type:text"`           // PEM-encoded private key, encrypted at rest
+	Issuer      string     `json:"issuer"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	AutoRenew   bool       `json:"auto_renew" gorm:"default:false"`
 	CreatedAt   time.Time  `json:"created_at"`
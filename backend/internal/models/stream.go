@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Stream protocols.
+const (
+	StreamProtocolTCP = "tcp"
+	StreamProtocolUDP = "udp"
+)
+
+// StreamLoadBalancingPolicies lists the selection policies supported by the layer4 proxy.
+var StreamLoadBalancingPolicies = []string{
+	LoadBalancingRoundRobin,
+	LoadBalancingLeastConn,
+	LoadBalancingIPHash,
+	LoadBalancingFirst,
+}
+
+// Stream forwards raw TCP or UDP traffic from a listen port to one or more upstreams
+// through Caddy's layer4 app.
+type Stream struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UUID          string     `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name          string     `json:"name"`
+	ListenPort    int        `json:"listen_port" gorm:"not null"`
+	Protocol      string     `json:"protocol" gorm:"default:tcp"` // "tcp" or "udp"
+	Upstreams     []Upstream `json:"upstreams" gorm:"type:text;serializer:json"`
+	LoadBalancing string     `json:"load_balancing" gorm:"default:round_robin"`
+	// TLSTermination decrypts TLS on the listen port using certificates for
+	// TLSServerNames; connections are routed by SNI so several TLS streams may share a port.
+	TLSTermination bool      `json:"tls_termination" gorm:"default:false"`
+	TLSServerNames []string  `json:"tls_server_names" gorm:"type:text;serializer:json"`
+	Enabled        bool      `json:"enabled"` // The API defaults it to true
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
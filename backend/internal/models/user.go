@@ -24,6 +24,9 @@ type User struct {
 	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
+// UserRoles lists the roles a user can have.
+var UserRoles = []string{"admin", "user", "viewer"}
+
 // SetPassword hashes and sets the user's password.
 func (u *User) SetPassword(password string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
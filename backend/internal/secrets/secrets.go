@@ -0,0 +1,126 @@
+// Package secrets encrypts sensitive values, such as private keys and DNS
+// provider credentials, before they are written to the database.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefix marks values produced by Encrypt and records the format version.
+const prefix = "enc:v1:"
+
+// KeySize is the length of the AES-256 key in bytes.
+const KeySize = 32
+
+// Cipher encrypts and decrypts values with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a 32-byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns plaintext sealed with a random nonce, encoded for storage in a text column.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("encrypted value is truncated")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("decrypt value: wrong key or corrupted data")
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// LoadKey returns the encryption key. A base64-encoded key passed in encoded takes
+// precedence; otherwise the key is read from path, generating it on first start.
+func LoadKey(encoded, path string) ([]byte, error) {
+	if encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode encryption key: %w", err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+		}
+		return key, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return LoadKey(strings.TrimSpace(string(data)), "")
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read encryption key: %w", err)
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate encryption key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("write encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadCipher loads the key as LoadKey does and returns a cipher for it.
+func LoadCipher(encoded, path string) (*Cipher, error) {
+	key, err := LoadKey(encoded, path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(key)
+}
@@ -0,0 +1,482 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// supportedAccessListTypes are the access list types GenerateConfig can enforce.
+var supportedAccessListTypes = []string{models.AccessListAllow, models.AccessListDeny, models.AccessListBasicAuth, models.AccessListForwardAuth, models.AccessListSSO, models.AccessListCountryAllow, models.AccessListCountryDeny}
+
+// AccessListService manages access lists and their assignment to hosts and locations.
+type AccessListService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+}
+
+// NewAccessListService creates a new access list service.
+func NewAccessListService(db *gorm.DB) *AccessListService {
+	return &AccessListService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *AccessListService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *AccessListService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validateAccessList checks the type and rules of a list, normalizing its
+// networks to canonical CIDR or IP form.
+func validateAccessList(list *models.AccessList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return errors.New("name is required")
+	}
+	if !slices.Contains(supportedAccessListTypes, list.Type) {
+		return fmt.Errorf("unsupported access list type: %s", list.Type)
+	}
+
+	if list.Type == models.AccessListBasicAuth {
+		if len(list.Rules) > 0 {
+			return errors.New("basic_auth list does not take rules")
+		}
+		list.Realm = strings.TrimSpace(list.Realm)
+		if list.Realm == "" {
+			list.Realm = models.DefaultRealm
+		}
+		if strings.ContainsAny(list.Realm, "\"\r\n") {
+			return errors.New("realm must not contain quotes or line breaks")
+		}
+		return nil
+	}
+	list.Realm = ""
+
+	if list.Type == models.AccessListForwardAuth {
+		return validateForwardAuth(list)
+	}
+	list.ForwardAuthURL, list.CopyHeaders, list.TrustedProxies = "", nil, nil
+
+	if list.Type == models.AccessListSSO {
+		return validateSSOList(list)
+	}
+	list.AllowedUsers, list.AllowedRoles = nil, nil
+
+	if list.IsCountryList() {
+		return validateCountryList(list)
+	}
+	if !list.IsIPList() {
+		return nil
+	}
+	if len(list.Rules) == 0 {
+		return fmt.Errorf("%s list requires at least one network", list.Type)
+	}
+	networks := make([]string, 0, len(list.Rules))
+	for _, rule := range list.Rules {
+		network, err := caddy.ParseNetwork(rule)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(networks, network) {
+			networks = append(networks, network)
+		}
+	}
+	list.Rules = networks
+	return nil
+}
+
+// validateForwardAuth checks the endpoint of a forward_auth list and normalizes
+// the headers it copies and the proxies it trusts.
+func validateForwardAuth(list *models.AccessList) error {
+	if len(list.Rules) > 0 {
+		return errors.New("forward_auth list does not take rules")
+	}
+	list.ForwardAuthURL = strings.TrimSpace(list.ForwardAuthURL)
+	if list.ForwardAuthURL == "" {
+		return errors.New("forward_auth_url is required")
+	}
+	if _, err := caddy.ParseForwardAuthURL(list.ForwardAuthURL); err != nil {
+		return err
+	}
+
+	if len(list.CopyHeaders) == 0 {
+		list.CopyHeaders = slices.Clone(caddy.DefaultForwardAuthHeaders)
+	}
+	headers := make([]string, 0, len(list.CopyHeaders))
+	for _, name := range list.CopyHeaders {
+		name = strings.TrimSpace(name)
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid header name: %q", name)
+		}
+		if caddy.IsHopByHopHeader(name) {
+			return fmt.Errorf("hop-by-hop header %s cannot be copied", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if !slices.Contains(headers, name) {
+			headers = append(headers, name)
+		}
+	}
+	list.CopyHeaders = headers
+
+	proxies := make([]string, 0, len(list.TrustedProxies))
+	for _, raw := range list.TrustedProxies {
+		network, err := caddy.ParseNetwork(raw)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(proxies, network) {
+			proxies = append(proxies, network)
+		}
+	}
+	list.TrustedProxies = proxies
+	return nil
+}
+
+// validateCountryList normalizes the country codes of a country list.
+func validateCountryList(list *models.AccessList) error {
+	if len(list.Rules) == 0 {
+		return fmt.Errorf("%s list requires at least one country", list.Type)
+	}
+	codes := make([]string, 0, len(list.Rules))
+	for _, rule := range list.Rules {
+		code, err := ParseCountryCode(rule)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	list.Rules = codes
+	return nil
+}
+
+// validateSSOList checks the roles of an sso list and removes duplicate users.
+func validateSSOList(list *models.AccessList) error {
+	if len(list.Rules) > 0 {
+		return errors.New("sso list does not take rules")
+	}
+	for _, role := range list.AllowedRoles {
+		if !slices.Contains(models.UserRoles, role) {
+			return fmt.Errorf("unknown role: %s", role)
+		}
+	}
+	list.AllowedRoles = slices.Compact(slices.Sorted(slices.Values(list.AllowedRoles)))
+	users := make([]string, 0, len(list.AllowedUsers))
+	for _, user := range list.AllowedUsers {
+		if !slices.Contains(users, user) {
+			users = append(users, user)
+		}
+	}
+	list.AllowedUsers = users
+	return nil
+}
+
+// checkAllowedUsers verifies that the users an sso list admits exist.
+func (s *AccessListService) checkAllowedUsers(list *models.AccessList) error {
+	for _, userUUID := range list.AllowedUsers {
+		var count int64
+		if err := s.db.Model(&models.User{}).Where("uuid = ?", userUUID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("user %s not found", userUUID)
+		}
+	}
+	return nil
+}
+
+// validateAccessAssignment normalizes the satisfy mode of a host or location and
+// checks that every referenced access list exists.
+func validateAccessAssignment(db *gorm.DB, ids []uint, satisfy *string) error {
+	if *satisfy == "" {
+		*satisfy = models.AccessSatisfyAll
+	}
+	if *satisfy != models.AccessSatisfyAll && *satisfy != models.AccessSatisfyAny {
+		return fmt.Errorf("unsupported access_satisfy: %s", *satisfy)
+	}
+
+	for i, id := range ids {
+		if slices.Contains(ids[:i], id) {
+			return fmt.Errorf("access list %d is assigned twice", id)
+		}
+		var list models.AccessList
+		if err := db.First(&list, id).Error; err != nil {
+			return fmt.Errorf("access list %d not found", id)
+		}
+	}
+	return nil
+}
+
+// Create validates and stores a new access list.
+func (s *AccessListService) Create(list *models.AccessList) error {
+	if err := validateAccessList(list); err != nil {
+		return err
+	}
+	if err := s.checkAllowedUsers(list); err != nil {
+		return err
+	}
+
+	// Accounts are managed through AddUser so their passwords get hashed.
+	if err := s.db.Omit("Users").Create(list).Error; err != nil {
+		return err
+	}
+
+	s.notify("access list created: " + list.Name)
+	return nil
+}
+
+// Update validates and saves an access list; hosts using it pick up the change.
+func (s *AccessListService) Update(list *models.AccessList) error {
+	if err := validateAccessList(list); err != nil {
+		return err
+	}
+	if err := s.checkAllowedUsers(list); err != nil {
+		return err
+	}
+
+	if err := s.db.Omit("Users").Save(list).Error; err != nil {
+		return err
+	}
+
+	s.notify("access list updated: " + list.Name)
+	return nil
+}
+
+// usage counts the hosts and locations referencing an access list.
+func (s *AccessListService) usage(id uint) (hosts, locations int, err error) {
+	var hostRows []models.ProxyHost
+	if err := s.db.Select("id", "access_list_ids").Find(&hostRows).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, host := range hostRows {
+		if slices.Contains(host.AccessListIDs, id) {
+			hosts++
+		}
+	}
+
+	var locRows []models.Location
+	if err := s.db.Select("id", "access_list_ids").Find(&locRows).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, loc := range locRows {
+		if slices.Contains(loc.AccessListIDs, id) {
+			locations++
+		}
+	}
+	return hosts, locations, nil
+}
+
+// Delete removes an access list that no host or location uses.
+func (s *AccessListService) Delete(id uint) error {
+	hosts, locations, err := s.usage(id)
+	if err != nil {
+		return err
+	}
+	if hosts > 0 || locations > 0 {
+		return fmt.Errorf("access list is used by %d proxy host(s) and %d location(s)", hosts, locations)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("access_list_id = ?", id).Delete(&models.AccessListUser{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.AccessList{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("access list deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds an access list by UUID.
+func (s *AccessListService) GetByUUID(uuid string) (*models.AccessList, error) {
+	var list models.AccessList
+	if err := s.db.Preload("Users").Where("uuid = ?", uuid).First(&list).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// List returns all access lists ordered by name.
+func (s *AccessListService) List() ([]models.AccessList, error) {
+	var lists []models.AccessList
+	if err := s.db.Preload("Users").Order("name asc").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// validateAccessListUser checks an account's username and password.
+func validateAccessListUser(username, password string) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
+	if strings.ContainsAny(username, ":\r\n") {
+		return errors.New("username must not contain colons or line breaks")
+	}
+	if password == "" {
+		return errors.New("password is required")
+	}
+	// bcrypt only considers the first 72 bytes.
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// AddUser adds an account to a basic_auth list, storing only its password hash.
+func (s *AccessListService) AddUser(list *models.AccessList, username, password string) (*models.AccessListUser, error) {
+	if list.Type != models.AccessListBasicAuth {
+		return nil, fmt.Errorf("%s list does not have users", list.Type)
+	}
+	username = strings.TrimSpace(username)
+	if err := validateAccessListUser(username, password); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.AccessListUser{}).
+		Where("access_list_id = ? AND username = ?", list.ID, username).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("user %s already exists", username)
+	}
+
+	user := &models.AccessListUser{
+		UUID:         uuid.NewString(),
+		AccessListID: list.ID,
+		Username:     username,
+	}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(user).Error; err != nil {
+		return nil, err
+	}
+
+	s.notify("access list user added: " + list.Name + "/" + username)
+	return user, nil
+}
+
+// GetUser finds an account of an access list by UUID.
+func (s *AccessListService) GetUser(list *models.AccessList, userUUID string) (*models.AccessListUser, error) {
+	var user models.AccessListUser
+	if err := s.db.Where("access_list_id = ? AND uuid = ?", list.ID, userUUID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserPassword replaces the password of an account.
+func (s *AccessListService) SetUserPassword(user *models.AccessListUser, password string) error {
+	if err := validateAccessListUser(user.Username, password); err != nil {
+		return err
+	}
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	if err := s.db.Save(user).Error; err != nil {
+		return err
+	}
+
+	s.notify("access list user updated: " + user.Username)
+	return nil
+}
+
+// DeleteUser removes an account from its access list.
+func (s *AccessListService) DeleteUser(user *models.AccessListUser) error {
+	if err := s.db.Delete(user).Error; err != nil {
+		return err
+	}
+
+	s.notify("access list user deleted: " + user.Username)
+	return nil
+}
+
+// ErrAuthServiceUnreachable is returned by CheckForwardAuth when no answer arrived.
+var ErrAuthServiceUnreachable = errors.New("auth service unreachable")
+
+// ForwardAuthCheck is the outcome of asking a forward_auth service about a request.
+type ForwardAuthCheck struct {
+	Status     int               `json:"status"`
+	Authorized bool              `json:"authorized"`
+	Location   string            `json:"location,omitempty"` // Where unauthenticated clients are sent
+	Headers    map[string]string `json:"headers"`            // Copied headers the service returned
+}
+
+// CheckForwardAuth asks the auth service of a forward_auth list whether it would
+// let a request for target through, the way Caddy does: a GET carrying the
+// original method, URI, host and protocol in X-Forwarded-* headers. headers are
+// passed along too, e.g. a session cookie.
+func (s *AccessListService) CheckForwardAuth(list *models.AccessList, method, target string, headers map[string]string) (*ForwardAuthCheck, error) {
+	if list.Type != models.AccessListForwardAuth {
+		return nil, fmt.Errorf("%s list has no auth service", list.Type)
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid target url %q", target)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(http.MethodGet, list.ForwardAuthURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Forwarded-Method", method)
+	req.Header.Set("X-Forwarded-Uri", u.RequestURI())
+	req.Header.Set("X-Forwarded-Host", u.Host)
+	req.Header.Set("X-Forwarded-Proto", u.Scheme)
+
+	// The login redirect is part of the answer, so it must not be followed
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthServiceUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	check := &ForwardAuthCheck{
+		Status:     resp.StatusCode,
+		Authorized: resp.StatusCode >= 200 && resp.StatusCode < 300,
+		Location:   resp.Header.Get("Location"),
+		Headers:    map[string]string{},
+	}
+	if check.Authorized {
+		for _, name := range list.CopyHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				check.Headers[name] = value
+			}
+		}
+	}
+	return check, nil
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestValidateAccessList(t *testing.T) {
+	list := &models.AccessList{Name: " Office ", Type: models.AccessListAllow, Rules: []string{"10.1.2.3/8", "10.0.0.0/8", "2001:db8::1"}}
+	require.NoError(t, validateAccessList(list))
+	assert.Equal(t, "Office", list.Name)
+	assert.Equal(t, []string{"10.0.0.0/8", "2001:db8::1"}, list.Rules)
+
+	invalid := []struct {
+		list    models.AccessList
+		wantErr string
+	}{
+		{models.AccessList{Type: models.AccessListAllow, Rules: []string{"10.0.0.1"}}, "name is required"},
+		{models.AccessList{Name: "A", Type: "geo"}, "unsupported access list type"},
+		{models.AccessList{Name: "A", Type: models.AccessListDeny}, "at least one network"},
+		{models.AccessList{Name: "A", Type: models.AccessListDeny, Rules: []string{"10.0.0.300"}}, "invalid network"},
+		{models.AccessList{Name: "A", Type: models.AccessListBasicAuth, Rules: []string{"10.0.0.1"}}, "does not take rules"},
+		{models.AccessList{Name: "A", Type: models.AccessListBasicAuth, Realm: `say "hi"`}, "realm must not contain"},
+	}
+	for _, tt := range invalid {
+		err := validateAccessList(&tt.list)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tt.wantErr)
+	}
+}
+
+func TestValidateAccessList_BasicAuth(t *testing.T) {
+	list := &models.AccessList{Name: "Staff", Type: models.AccessListBasicAuth}
+	require.NoError(t, validateAccessList(list))
+	assert.Equal(t, models.DefaultRealm, list.Realm)
+
+	ipList := &models.AccessList{Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}, Realm: "stale"}
+	require.NoError(t, validateAccessList(ipList))
+	assert.Empty(t, ipList.Realm)
+}
+
+func TestAccessListService_Users(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewAccessListService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	staff := &models.AccessList{UUID: "staff", Name: "Staff", Type: models.AccessListBasicAuth, Realm: "Staff only", Enabled: true}
+	require.NoError(t, service.Create(staff))
+
+	alice, err := service.AddUser(staff, " alice ", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", alice.Username)
+	assert.NotEqual(t, "s3cret", alice.PasswordHash)
+	assert.True(t, alice.CheckPassword("s3cret"))
+
+	_, err = service.AddUser(staff, "alice", "other")
+	require.ErrorContains(t, err, "already exists")
+	_, err = service.AddUser(staff, "bob:admin", "pw")
+	require.ErrorContains(t, err, "must not contain colons")
+	_, err = service.AddUser(staff, "bob", "")
+	require.ErrorContains(t, err, "password is required")
+
+	lan := &models.AccessList{UUID: "lan", Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}}
+	require.NoError(t, service.Create(lan))
+	_, err = service.AddUser(lan, "carol", "pw")
+	require.ErrorContains(t, err, "allow list does not have users")
+
+	fetched, err := service.GetByUUID("staff")
+	require.NoError(t, err)
+	require.Len(t, fetched.Users, 1)
+
+	user, err := service.GetUser(staff, alice.UUID)
+	require.NoError(t, err)
+	require.NoError(t, service.SetUserPassword(user, "n3w"))
+	user, err = service.GetUser(staff, alice.UUID)
+	require.NoError(t, err)
+	assert.True(t, user.CheckPassword("n3w"))
+	assert.False(t, user.CheckPassword("s3cret"))
+
+	_, err = service.GetUser(lan, alice.UUID)
+	require.Error(t, err)
+
+	// Users sent along with a list update are ignored
+	fetched.Users = append(fetched.Users, models.AccessListUser{UUID: "mallory", Username: "mallory"})
+	require.NoError(t, service.Update(fetched))
+	fetched, err = service.GetByUUID("staff")
+	require.NoError(t, err)
+	require.Len(t, fetched.Users, 1)
+
+	require.NoError(t, service.Delete(staff.ID))
+	var remaining int64
+	require.NoError(t, db.Model(&models.AccessListUser{}).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+
+	assert.Contains(t, notifier.reasons, "access list user added: Staff/alice")
+	assert.Contains(t, notifier.reasons, "access list user updated: alice")
+}
+
+func TestAccessListService_CRUD(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewAccessListService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	office := &models.AccessList{UUID: "office", Name: "Office", Type: models.AccessListAllow, Rules: []string{"192.168.0.0/16"}, Enabled: true}
+	require.NoError(t, service.Create(office))
+	blocked := &models.AccessList{UUID: "blocked", Name: "Blocked", Type: models.AccessListDeny, Rules: []string{"192.168.6.6"}, Enabled: true}
+	require.NoError(t, service.Create(blocked))
+
+	fetched, err := service.GetByUUID("office")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.0.0/16"}, fetched.Rules)
+
+	fetched.Rules = append(fetched.Rules, "fd00::/8")
+	require.NoError(t, service.Update(fetched))
+
+	hosts := NewProxyHostService(db)
+	host := &models.ProxyHost{
+		UUID:          "host",
+		DomainNames:   "app.example.com",
+		ForwardHost:   "app",
+		ForwardPort:   80,
+		AccessListIDs: []uint{office.ID},
+		Locations: []models.Location{
+			{UUID: "loc", Path: "/admin", ForwardHost: "admin", ForwardPort: 80, AccessListIDs: []uint{blocked.ID}},
+		},
+	}
+	require.NoError(t, hosts.Create(host))
+	assert.Equal(t, models.AccessSatisfyAll, host.AccessSatisfy)
+
+	err = service.Delete(office.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "used by 1 proxy host(s) and 0 location(s)")
+	err = service.Delete(blocked.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "used by 0 proxy host(s) and 1 location(s)")
+
+	lists, err := service.List()
+	require.NoError(t, err)
+	require.Len(t, lists, 2)
+	assert.Equal(t, "Blocked", lists[0].Name)
+
+	assert.Equal(t, []string{
+		"access list created: Office",
+		"access list created: Blocked",
+		"access list updated: Office",
+	}, notifier.reasons)
+}
+
+func TestProxyHostService_AccessAssignment(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	list := &models.AccessList{UUID: "lan", Name: "LAN", Type: models.AccessListAllow, Rules: []string{"10.0.0.0/8"}}
+	require.NoError(t, db.Create(list).Error)
+
+	tests := []struct {
+		name    string
+		host    models.ProxyHost
+		wantErr string
+	}{
+		{
+			name:    "missing list",
+			host:    models.ProxyHost{AccessListIDs: []uint{99}},
+			wantErr: "access list 99 not found",
+		},
+		{
+			name:    "assigned twice",
+			host:    models.ProxyHost{AccessListIDs: []uint{list.ID, list.ID}},
+			wantErr: "assigned twice",
+		},
+		{
+			name:    "unknown satisfy mode",
+			host:    models.ProxyHost{AccessListIDs: []uint{list.ID}, AccessSatisfy: "some"},
+			wantErr: "unsupported access_satisfy",
+		},
+		{
+			name: "location with missing list",
+			host: models.ProxyHost{Locations: []models.Location{
+				{UUID: "loc", Path: "/admin", ForwardHost: "admin", ForwardPort: 80, AccessListIDs: []uint{42}},
+			}},
+			wantErr: "location /admin: access list 42 not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := tt.host
+			host.UUID, host.DomainNames, host.ForwardHost, host.ForwardPort = "host", "app.example.com", "app", 80
+			err := service.Create(&host)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateForwardAuth(t *testing.T) {
+	list := &models.AccessList{
+		Name: "Authelia", Type: models.AccessListForwardAuth,
+		ForwardAuthURL: " http://authelia:9091/api/authz/forward-auth ",
+		TrustedProxies: []string{"172.16.5.0/12", "172.16.0.0/12"},
+	}
+	require.NoError(t, validateAccessList(list))
+	assert.Equal(t, "http://authelia:9091/api/authz/forward-auth", list.ForwardAuthURL)
+	assert.Equal(t, []string{"Remote-User", "Remote-Groups", "Remote-Name", "Remote-Email"}, list.CopyHeaders)
+	assert.Equal(t, []string{"172.16.0.0/12"}, list.TrustedProxies)
+
+	list.CopyHeaders = []string{"x-authentik-username", "X-Authentik-Username"}
+	require.NoError(t, validateAccessList(list))
+	assert.Equal(t, []string{"X-Authentik-Username"}, list.CopyHeaders)
+
+	invalid := []struct {
+		list    models.AccessList
+		wantErr string
+	}{
+		{models.AccessList{}, "forward_auth_url is required"},
+		{models.AccessList{ForwardAuthURL: "authelia:9091"}, "invalid forward auth url"},
+		{models.AccessList{ForwardAuthURL: "http://authelia", Rules: []string{"10.0.0.1"}}, "does not take rules"},
+		{models.AccessList{ForwardAuthURL: "http://authelia", CopyHeaders: []string{"Remote User"}}, "invalid header name"},
+		{models.AccessList{ForwardAuthURL: "http://authelia", CopyHeaders: []string{"Connection"}}, "hop-by-hop"},
+		{models.AccessList{ForwardAuthURL: "http://authelia", TrustedProxies: []string{"proxy"}}, "invalid network"},
+	}
+	for _, tt := range invalid {
+		tt.list.Name, tt.list.Type = "A", models.AccessListForwardAuth
+		err := validateAccessList(&tt.list)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tt.wantErr)
+	}
+}
+
+// stubAuthServer behaves like Authelia's forward-auth endpoint: requests with a
+// session cookie are approved with identity headers, others are sent to the portal.
+func stubAuthServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/authz/forward-auth" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "valid" {
+			rd := r.Header.Get("X-Forwarded-Proto") + "://" + r.Header.Get("X-Forwarded-Host") + r.Header.Get("X-Forwarded-Uri")
+			http.Redirect(w, r, "https://auth.example.com/?rd="+rd, http.StatusFound)
+			return
+		}
+		w.Header().Set("Remote-User", "alice")
+		w.Header().Set("Remote-Groups", "admins")
+		w.Header().Set("Remote-Secret", "not copied")
+		w.Header().Set("X-Method", r.Header.Get("X-Forwarded-Method"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAccessListService_CheckForwardAuth(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewAccessListService(db)
+	auth := stubAuthServer(t)
+
+	list := &models.AccessList{
+		UUID: "authelia", Name: "Authelia", Type: models.AccessListForwardAuth, Enabled: true,
+		ForwardAuthURL: auth.URL + "/api/authz/forward-auth",
+		CopyHeaders:    []string{"Remote-User", "Remote-Groups", "X-Method"},
+	}
+	require.NoError(t, service.Create(list))
+
+	check, err := service.CheckForwardAuth(list, "", "https://wiki.example.com/page?id=1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, check.Status)
+	assert.False(t, check.Authorized)
+	assert.Equal(t, "https://auth.example.com/?rd=https://wiki.example.com/page?id=1", check.Location)
+	assert.Empty(t, check.Headers)
+
+	check, err = service.CheckForwardAuth(list, http.MethodPost, "https://wiki.example.com/", map[string]string{"Cookie": "session=valid"})
+	require.NoError(t, err)
+	assert.True(t, check.Authorized)
+	assert.Equal(t, map[string]string{"Remote-User": "alice", "Remote-Groups": "admins", "X-Method": "POST"}, check.Headers)
+
+	_, err = service.CheckForwardAuth(list, "", "wiki.example.com", nil)
+	require.ErrorContains(t, err, "invalid target url")
+
+	list.ForwardAuthURL = "http://127.0.0.1:1/api/authz/forward-auth"
+	_, err = service.CheckForwardAuth(list, "", "https://wiki.example.com/", nil)
+	require.ErrorIs(t, err, ErrAuthServiceUnreachable)
+
+	lan := &models.AccessList{Name: "LAN", Type: models.AccessListAllow}
+	_, err = service.CheckForwardAuth(lan, "", "https://wiki.example.com/", nil)
+	require.ErrorContains(t, err, "allow list has no auth service")
+}
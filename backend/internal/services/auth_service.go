@@ -23,9 +23,14 @@ func NewAuthService(db *gorm.DB, cfg config.Config) *AuthService {
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Role   string `json:"role"`
+	Scope  string `json:"scope,omitempty"` // Empty for API tokens; SSOTokenScope for single sign-on sessions
 	jwt.RegisteredClaims
 }
 
+// SSOTokenScope marks tokens that only sign in to proxied apps. They are sent to
+// every host below the SSO cookie domain, so they must not grant API access.
+const SSOTokenScope = "sso"
+
 func (s *AuthService) Register(email, password, name string) (*models.User, error) {
 	var count int64
 	s.db.Model(&models.User{}).Count(&count)
@@ -91,10 +96,20 @@ func (s *AuthService) Login(email, password string) (string, error) {
 }
 
 func (s *AuthService) GenerateToken(user *models.User) (string, error) {
+	return s.generateToken(user, "")
+}
+
+// GenerateSSOToken issues a single sign-on session token for user.
+func (s *AuthService) GenerateSSOToken(user *models.User) (string, error) {
+	return s.generateToken(user, SSOTokenScope)
+}
+
+func (s *AuthService) generateToken(user *models.User, scope string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
 	claims := &Claims{
 		UserID: user.ID,
 		Role:   user.Role,
+		Scope:  scope,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			Issuer:    "cpmp",
@@ -138,3 +153,15 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ValidateSSOToken validates a single sign-on session token.
+func (s *AuthService) ValidateSSOToken(tokenString string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != SSOTokenScope {
+		return nil, errors.New("not a single sign-on token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,7 @@
+package services
+
+// ConfigNotifier is told whenever persisted state that feeds the generated Caddy config changes.
+// The caddy.Reconciler implements it; a nil notifier disables automatic applies.
+type ConfigNotifier interface {
+	Notify(reason string)
+}
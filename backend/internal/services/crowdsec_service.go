@@ -0,0 +1,417 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+)
+
+var (
+	// ErrCrowdSecNotConfigured means the LAPI URL or the bouncer key is missing.
+	ErrCrowdSecNotConfigured = errors.New("crowdsec is not configured")
+	// ErrCrowdSecNoMachine means manual decisions lack machine credentials.
+	ErrCrowdSecNoMachine = errors.New("manual decisions require crowdsec machine credentials")
+	// ErrCrowdSecUnreachable wraps failed or refused LAPI requests.
+	ErrCrowdSecUnreachable = errors.New("crowdsec lapi request failed")
+)
+
+// DefaultCrowdSecBanDuration applies to manual bans without a duration, as in cscli.
+const DefaultCrowdSecBanDuration = 4 * time.Hour
+
+// CrowdSecPollInterval is how often decisions are fetched while CrowdSec is configured.
+const CrowdSecPollInterval = 30 * time.Second
+
+// crowdSecOrigin marks the decisions CPM adds.
+const crowdSecOrigin = "cpm"
+
+// CrowdSecDecision is an active remediation, as returned by the LAPI.
+type CrowdSecDecision struct {
+	ID        int64  `json:"id"`
+	Origin    string `json:"origin"`   // e.g. crowdsec, CAPI, cscli or cpm
+	Type      string `json:"type"`     // e.g. ban or captcha
+	Scope     string `json:"scope"`    // e.g. Ip or Range
+	Value     string `json:"value"`    // Address or range the decision is about
+	Duration  string `json:"duration"` // Time left, e.g. 3h59m12s
+	Scenario  string `json:"scenario"`
+	Simulated bool   `json:"simulated"`
+}
+
+// CrowdSecStatus is the state of the CrowdSec integration with the decisions of the last poll.
+type CrowdSecStatus struct {
+	Configured       bool               `json:"configured"`        // LAPI URL and bouncer key are set
+	BouncerAvailable bool               `json:"bouncer_available"` // The running Caddy has the bouncer module
+	ManualDecisions  bool               `json:"manual_decisions"`  // Machine credentials are set
+	PolledAt         *time.Time         `json:"polled_at"`
+	Error            string             `json:"error,omitempty"` // Why the last poll failed
+	Decisions        []CrowdSecDecision `json:"decisions"`
+}
+
+// CrowdSecService polls the CrowdSec Local API (LAPI) for decisions and adds or
+// removes manual ones.
+type CrowdSecService struct {
+	db      *gorm.DB
+	cipher  *secrets.Cipher
+	client  *http.Client
+	modules []string
+
+	mu        sync.Mutex
+	decisions []CrowdSecDecision
+	polledAt  time.Time
+	pollErr   error
+
+	pollerMu     sync.Mutex
+	pollInterval time.Duration
+	stopPoller   context.CancelFunc
+}
+
+// NewCrowdSecService creates a new CrowdSec service. cipher decrypts the bouncer
+// key and machine credentials.
+func NewCrowdSecService(db *gorm.DB, cipher *secrets.Cipher) *CrowdSecService {
+	return &CrowdSecService{
+		db:     db,
+		cipher: cipher,
+		client: &http.Client{Timeout: 10 * time.Second},
+
+		pollInterval: CrowdSecPollInterval,
+	}
+}
+
+// SetModules sets the IDs of the modules in the running Caddy build.
+func (s *CrowdSecService) SetModules(modules []string) {
+	s.modules = modules
+}
+
+// ParseCrowdSecTarget turns an address or CIDR range into a decision scope and value.
+func ParseCrowdSecTarget(raw string) (string, string, error) {
+	if addr, err := netip.ParseAddr(raw); err == nil {
+		return "Ip", addr.Unmap().String(), nil
+	}
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid ip %q (expected an address or CIDR range)", raw)
+	}
+	if prefix.IsSingleIP() {
+		return "Ip", prefix.Addr().String(), nil
+	}
+	return "Range", prefix.Masked().String(), nil
+}
+
+// setting returns the plaintext of a setting, or "" when it is unset.
+func (s *CrowdSecService) setting(key string) (string, error) {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", key).Limit(1).Find(&setting).Error; err != nil {
+		return "", err
+	}
+	if !secrets.IsEncrypted(setting.Value) {
+		return setting.Value, nil
+	}
+	if s.cipher == nil {
+		return "", fmt.Errorf("%s: secret settings require an encryption key", key)
+	}
+	return s.cipher.Decrypt(setting.Value)
+}
+
+// lapi returns the LAPI URL and the bouncer key.
+func (s *CrowdSecService) lapi() (string, string, error) {
+	raw, err := s.setting(caddy.CrowdSecLAPIURLSettingKey)
+	if err != nil {
+		return "", "", err
+	}
+	apiURL, err := caddy.ParseCrowdSecLAPIURL(raw)
+	if err != nil {
+		return "", "", err
+	}
+	apiKey, err := s.setting(caddy.CrowdSecBouncerKeySettingKey)
+	if err != nil {
+		return "", "", err
+	}
+	if apiURL == "" || apiKey == "" {
+		return "", "", ErrCrowdSecNotConfigured
+	}
+	return apiURL, apiKey, nil
+}
+
+func (s *CrowdSecService) machine() (*caddy.CrowdSecMachine, error) {
+	raw, err := s.setting(caddy.CrowdSecMachineSettingKey)
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, ErrCrowdSecNoMachine
+	}
+	return caddy.ParseCrowdSecMachine(raw)
+}
+
+// do sends a LAPI request and decodes a 2xx JSON answer into out.
+func (s *CrowdSecService) do(req *http.Request, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCrowdSecUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%w: lapi returned status %d: %s", ErrCrowdSecUnreachable, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: decode response: %v", ErrCrowdSecUnreachable, err)
+	}
+	return nil
+}
+
+// Poll fetches the active decisions with the bouncer key and keeps them for Status.
+func (s *CrowdSecService) Poll(ctx context.Context) error {
+	apiURL, apiKey, err := s.lapi()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/v1/decisions", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", apiKey)
+
+	// The LAPI answers null when there are no decisions
+	var decisions []CrowdSecDecision
+	err = s.do(req, &decisions)
+	if ctx.Err() != nil {
+		// Canceled, e.g. because the poller stopped; keep the last result
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polledAt = time.Now()
+	s.pollErr = err
+	if err == nil {
+		s.decisions = decisions
+	}
+	return err
+}
+
+// SyncPoller starts polling decisions in the background once the LAPI URL and
+// bouncer key are set, and stops when they no longer are. Call it on startup
+// and after the CrowdSec settings change.
+func (s *CrowdSecService) SyncPoller() {
+	_, _, err := s.lapi()
+	configured := err == nil
+
+	s.pollerMu.Lock()
+	defer s.pollerMu.Unlock()
+	switch {
+	case configured && s.stopPoller == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopPoller = cancel
+		go s.pollEvery(ctx, s.pollInterval)
+	case !configured && s.stopPoller != nil:
+		s.stopPoller()
+		s.stopPoller = nil
+
+		s.mu.Lock()
+		s.decisions = nil
+		s.polledAt = time.Time{}
+		s.pollErr = nil
+		s.mu.Unlock()
+	}
+}
+
+// pollEvery polls right away and then every interval until ctx is done.
+func (s *CrowdSecService) pollEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the integration state and the decisions of the last successful poll.
+func (s *CrowdSecService) Status() CrowdSecStatus {
+	_, _, lapiErr := s.lapi()
+	_, machineErr := s.machine()
+	status := CrowdSecStatus{
+		Configured:       lapiErr == nil,
+		BouncerAvailable: slices.Contains(s.modules, caddy.CrowdSecModule),
+		ManualDecisions:  machineErr == nil,
+		Decisions:        []CrowdSecDecision{},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.polledAt.IsZero() {
+		polledAt := s.polledAt
+		status.PolledAt = &polledAt
+	}
+	if s.pollErr != nil {
+		status.Error = s.pollErr.Error()
+	}
+	if status.Configured && s.decisions != nil {
+		status.Decisions = s.decisions
+	}
+	return status
+}
+
+// Polled reports whether decisions were fetched at least once.
+func (s *CrowdSecService) Polled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.polledAt.IsZero()
+}
+
+// login signs in as the configured machine and returns its token.
+func (s *CrowdSecService) login(ctx context.Context, apiURL string) (string, error) {
+	machine, err := s.machine()
+	if err != nil {
+		return "", err
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"machine_id": machine.MachineID,
+		"password":   machine.Password,
+		"scenarios":  []string{},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/v1/watchers/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var answer struct {
+		Token string `json:"token"`
+	}
+	if err := s.do(req, &answer); err != nil {
+		return "", err
+	}
+	if answer.Token == "" {
+		return "", fmt.Errorf("%w: login returned no token", ErrCrowdSecUnreachable)
+	}
+	return answer.Token, nil
+}
+
+// Ban adds a ban decision for an address or CIDR range, the way `cscli decisions
+// add` does: as an alert of the configured machine. A zero duration means
+// DefaultCrowdSecBanDuration.
+func (s *CrowdSecService) Ban(ctx context.Context, target string, duration time.Duration, reason string) error {
+	scope, value, err := ParseCrowdSecTarget(target)
+	if err != nil {
+		return err
+	}
+	if duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+	if duration == 0 {
+		duration = DefaultCrowdSecBanDuration
+	}
+	if reason == "" {
+		reason = "manual ban from CPM"
+	}
+	apiURL, _, err := s.lapi()
+	if err != nil {
+		return err
+	}
+	token, err := s.login(ctx, apiURL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	alert := map[string]interface{}{
+		"scenario":         reason,
+		"scenario_hash":    "",
+		"scenario_version": "",
+		"message":          reason,
+		"events":           []interface{}{},
+		"events_count":     1,
+		"capacity":         0,
+		"leakspeed":        "0",
+		"simulated":        false,
+		"remediation":      true,
+		"start_at":         now,
+		"stop_at":          now,
+		"source":           map[string]string{"scope": scope, "value": value},
+		"decisions": []map[string]interface{}{{
+			"duration": duration.String(),
+			"origin":   crowdSecOrigin,
+			"scenario": reason,
+			"scope":    scope,
+			"type":     "ban",
+			"value":    value,
+		}},
+	}
+	body, _ := json.Marshal([]interface{}{alert})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/v1/alerts", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if err := s.do(req, nil); err != nil {
+		return err
+	}
+
+	// Show the new decision right away instead of after the next poll
+	s.Poll(ctx)
+	return nil
+}
+
+// Unban deletes every decision for an address or CIDR range and returns how
+// many there were.
+func (s *CrowdSecService) Unban(ctx context.Context, target string) (int, error) {
+	scope, value, err := ParseCrowdSecTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	apiURL, _, err := s.lapi()
+	if err != nil {
+		return 0, err
+	}
+	token, err := s.login(ctx, apiURL)
+	if err != nil {
+		return 0, err
+	}
+
+	query := url.Values{"ip": {value}}
+	if scope == "Range" {
+		query = url.Values{"range": {value}}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, apiURL+"/v1/decisions?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var answer struct {
+		Deleted string `json:"nbDeleted"`
+	}
+	if err := s.do(req, &answer); err != nil {
+		return 0, err
+	}
+	deleted, _ := strconv.Atoi(answer.Deleted)
+
+	s.Poll(ctx)
+	return deleted, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// stubLAPI is a CrowdSec Local API holding decisions in memory.
+type stubLAPI struct {
+	mu        sync.Mutex
+	nextID    int64
+	decisions []CrowdSecDecision
+}
+
+func (l *stubLAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bearer := r.Header.Get("Authorization") == "Bearer machine-token"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/decisions":
+		if r.Header.Get("X-Api-Key") != "bouncer-key" {
+			http.Error(w, `{"message":"access forbidden"}`, http.StatusForbidden)
+			return
+		}
+		if len(l.decisions) == 0 {
+			w.Write([]byte("null"))
+			return
+		}
+		json.NewEncoder(w).Encode(l.decisions)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/watchers/login":
+		var creds map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&creds)
+		if creds["machine_id"] != "cpm" || creds["password"] != "machine-password" {
+			http.Error(w, `{"code":401,"message":"incorrect Username or Password"}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "token": "machine-token"})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/alerts" && bearer:
+		var alerts []struct {
+			Decisions []CrowdSecDecision `json:"decisions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, alert := range alerts {
+			for _, decision := range alert.Decisions {
+				l.nextID++
+				decision.ID = l.nextID
+				l.decisions = append(l.decisions, decision)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]string{strconv.FormatInt(l.nextID, 10)})
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1/decisions" && bearer:
+		value := r.URL.Query().Get("ip") + r.URL.Query().Get("range")
+		kept := l.decisions[:0]
+		for _, decision := range l.decisions {
+			if decision.Value != value {
+				kept = append(kept, decision)
+			}
+		}
+		deleted := len(l.decisions) - len(kept)
+		l.decisions = kept
+		json.NewEncoder(w).Encode(map[string]string{"nbDeleted": strconv.Itoa(deleted)})
+	default:
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+	}
+}
+
+func setupCrowdSecService(t *testing.T, lapiURL string) (*CrowdSecService, *gorm.DB) {
+	db := setupProxyHostTestDB(t)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecLAPIURLSettingKey, Value: lapiURL}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecBouncerKeySettingKey, Value: "bouncer-key"}).Error)
+	return NewCrowdSecService(db, nil), db
+}
+
+func TestCrowdSecService_PollAndManualDecisions(t *testing.T) {
+	lapi := &stubLAPI{decisions: []CrowdSecDecision{
+		{ID: 7, Origin: "crowdsec", Type: "ban", Scope: "Ip", Value: "203.0.113.9", Duration: "3h12m", Scenario: "crowdsecurity/http-probing"},
+	}}
+	server := httptest.NewServer(lapi)
+	defer server.Close()
+	service, db := setupCrowdSecService(t, server.URL+"/")
+	service.SetModules([]string{caddy.CrowdSecModule})
+	ctx := context.Background()
+
+	require.False(t, service.Polled())
+	require.NoError(t, service.Poll(ctx))
+	status := service.Status()
+	assert.True(t, status.Configured)
+	assert.True(t, status.BouncerAvailable)
+	assert.False(t, status.ManualDecisions)
+	assert.NotNil(t, status.PolledAt)
+	require.Len(t, status.Decisions, 1)
+	assert.Equal(t, "crowdsecurity/http-probing", status.Decisions[0].Scenario)
+
+	// Manual decisions need machine credentials
+	require.ErrorIs(t, service.Ban(ctx, "198.51.100.4", 0, ""), ErrCrowdSecNoMachine)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecMachineSettingKey, Value: `{"machine_id": "cpm", "password": "machine-password"}`}).Error)
+
+	require.NoError(t, service.Ban(ctx, "198.51.100.4", 0, "scraping"))
+	require.NoError(t, service.Ban(ctx, "2001:db8::/64", 0, ""))
+	decisions := service.Status().Decisions
+	require.Len(t, decisions, 3)
+	assert.Equal(t, CrowdSecDecision{ID: 1, Origin: "cpm", Type: "ban", Scope: "Ip", Value: "198.51.100.4", Duration: "4h0m0s", Scenario: "scraping"}, decisions[1])
+	assert.Equal(t, "Range", decisions[2].Scope)
+	assert.Equal(t, "2001:db8::/64", decisions[2].Value)
+
+	deleted, err := service.Unban(ctx, "198.51.100.4")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	deleted, err = service.Unban(ctx, "2001:db8::1/64")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	require.Len(t, service.Status().Decisions, 1)
+
+	require.ErrorContains(t, service.Ban(ctx, "not-an-ip", 0, ""), "invalid ip")
+}
+
+func TestCrowdSecService_SyncPoller(t *testing.T) {
+	server := httptest.NewServer(&stubLAPI{decisions: []CrowdSecDecision{{ID: 1, Type: "ban", Scope: "Ip", Value: "203.0.113.9"}}})
+	defer server.Close()
+
+	// Nothing is polled until the LAPI is configured
+	db := setupProxyHostTestDB(t)
+	service := NewCrowdSecService(db, nil)
+	service.pollInterval = 10 * time.Millisecond
+	service.SyncPoller()
+	assert.Nil(t, service.stopPoller)
+
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecLAPIURLSettingKey, Value: server.URL}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.CrowdSecBouncerKeySettingKey, Value: "bouncer-key"}).Error)
+	service.SyncPoller()
+	require.Eventually(t, service.Polled, time.Second, 5*time.Millisecond)
+	assert.Len(t, service.Status().Decisions, 1)
+
+	// Deleting the LAPI URL stops the poller and drops its results
+	require.NoError(t, db.Where("key = ?", caddy.CrowdSecLAPIURLSettingKey).Delete(&models.Setting{}).Error)
+	service.SyncPoller()
+	assert.Nil(t, service.stopPoller)
+	assert.False(t, service.Polled())
+}
+
+func TestCrowdSecService_Errors(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewCrowdSecService(db, nil)
+	require.ErrorIs(t, service.Poll(context.Background()), ErrCrowdSecNotConfigured)
+	status := service.Status()
+	assert.False(t, status.Configured)
+	assert.False(t, status.BouncerAvailable)
+	assert.Empty(t, status.Decisions)
+
+	// A wrong bouncer key is reported and keeps the last decisions
+	server := httptest.NewServer(&stubLAPI{})
+	defer server.Close()
+	service, db = setupCrowdSecService(t, server.URL)
+	require.NoError(t, db.Model(&models.Setting{}).Where("key = ?", caddy.CrowdSecBouncerKeySettingKey).Update("value", "wrong").Error)
+	err := service.Poll(context.Background())
+	require.ErrorIs(t, err, ErrCrowdSecUnreachable)
+	assert.Contains(t, service.Status().Error, "status 403")
+
+	server.Close()
+	require.ErrorIs(t, service.Poll(context.Background()), ErrCrowdSecUnreachable)
+}
+
+func TestParseCrowdSecTarget(t *testing.T) {
+	tests := []struct {
+		raw, scope, value string
+	}{
+		{"203.0.113.9", "Ip", "203.0.113.9"},
+		{"::ffff:203.0.113.9", "Ip", "203.0.113.9"},
+		{"203.0.113.9/32", "Ip", "203.0.113.9"},
+		{"203.0.113.9/24", "Range", "203.0.113.0/24"},
+		{"2001:db8::1/48", "Range", "2001:db8::/48"},
+	}
+	for _, tt := range tests {
+		scope, value, err := ParseCrowdSecTarget(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.scope, scope, tt.raw)
+		assert.Equal(t, tt.value, value, tt.raw)
+	}
+
+	_, _, err := ParseCrowdSecTarget("example.com")
+	require.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// splitDomains parses a comma-separated domain list into normalized, non-empty names.
+func splitDomains(domainNames string) []string {
+	var domains []string
+	for _, d := range strings.Split(domainNames, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// checkDomainsAvailable ensures none of domainNames is already served by another proxy
+// or redirection host. The record being updated is skipped via its exclude ID.
+func checkDomainsAvailable(db *gorm.DB, domainNames string, excludeProxyHostID, excludeRedirectionHostID uint) error {
+	wanted := splitDomains(domainNames)
+	if len(wanted) == 0 {
+		return fmt.Errorf("at least one domain is required")
+	}
+
+	taken := make(map[string]bool)
+
+	var proxyHosts []models.ProxyHost
+	query := db.Select("id", "domain_names")
+	if excludeProxyHostID > 0 {
+		query = query.Where("id != ?", excludeProxyHostID)
+	}
+	if err := query.Find(&proxyHosts).Error; err != nil {
+		return fmt.Errorf("checking domain uniqueness: %w", err)
+	}
+	for _, h := range proxyHosts {
+		for _, d := range splitDomains(h.DomainNames) {
+			taken[d] = true
+		}
+	}
+
+	var redirectionHosts []models.RedirectionHost
+	query = db.Select("id", "domain_names")
+	if excludeRedirectionHostID > 0 {
+		query = query.Where("id != ?", excludeRedirectionHostID)
+	}
+	if err := query.Find(&redirectionHosts).Error; err != nil {
+		return fmt.Errorf("checking domain uniqueness: %w", err)
+	}
+	for _, h := range redirectionHosts {
+		for _, d := range splitDomains(h.DomainNames) {
+			taken[d] = true
+		}
+	}
+
+	for _, d := range wanted {
+		if taken[d] {
+			return fmt.Errorf("domain already exists: %s", d)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/geoip"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// GeoIPDatabaseSettingKey holds the path of a local MaxMind database with country
+// data, e.g. GeoLite2-Country.mmdb.
+const GeoIPDatabaseSettingKey = "geoip.database_path"
+
+// ErrGeoIPNotConfigured is returned by lookups while no database is set.
+var ErrGeoIPNotConfigured = errors.New("no GeoIP database configured")
+
+// GeoIPService resolves client addresses to countries. The database is loaded on
+// first use and reloaded when the setting or the file changes.
+type GeoIPService struct {
+	db *gorm.DB
+
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	reader  *geoip.Reader
+}
+
+// NewGeoIPService creates a new GeoIP service.
+func NewGeoIPService(db *gorm.DB) *GeoIPService {
+	return &GeoIPService{db: db}
+}
+
+// ValidateGeoIPDatabase checks that path holds a MaxMind database. An empty path
+// turns GeoIP off.
+func ValidateGeoIPDatabase(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := geoip.Open(path); err != nil {
+		return fmt.Errorf("invalid GeoIP database: %w", err)
+	}
+	return nil
+}
+
+// ParseCountryCode normalizes an ISO 3166-1 alpha-2 country code.
+func ParseCountryCode(raw string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(raw))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "", fmt.Errorf("invalid country code %q (expected two letters such as US)", raw)
+	}
+	return code, nil
+}
+
+func (s *GeoIPService) load() (*geoip.Reader, error) {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", GeoIPDatabaseSettingKey).First(&setting).Error; err != nil || setting.Value == "" {
+		return nil, ErrGeoIPNotConfigured
+	}
+	info, err := os.Stat(setting.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reader != nil && s.path == setting.Value && s.modTime.Equal(info.ModTime()) {
+		return s.reader, nil
+	}
+	reader, err := geoip.Open(setting.Value)
+	if err != nil {
+		return nil, err
+	}
+	s.reader, s.path, s.modTime = reader, setting.Value, info.ModTime()
+	return reader, nil
+}
+
+// Country returns the ISO code of the country ip is located in, or "" when the
+// database does not know it.
+func (s *GeoIPService) Country(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q", ip)
+	}
+	reader, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	return reader.Country(addr)
+}
+
+// Allowed decides whether a client at ip passes the country lists: it must be in
+// one of the allow countries, when there are any, and in none of the deny
+// countries. Private and loopback addresses have no country and always pass.
+func (s *GeoIPService) Allowed(ip string, allow, deny []string) (string, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid address %q", ip)
+	}
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return "", true, nil
+	}
+
+	country, err := s.Country(ip)
+	if err != nil {
+		return "", false, err
+	}
+	if len(allow) > 0 && !slices.Contains(allow, country) {
+		return country, false, nil
+	}
+	return country, !slices.Contains(deny, country), nil
+}
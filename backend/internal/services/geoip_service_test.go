@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/config"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/geoip/geoiptest"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func setupGeoIPService(t *testing.T) *GeoIPService {
+	db := setupProxyHostTestDB(t)
+	path := geoiptest.WriteDatabase(t, map[string]string{
+		"81.2.69.0/24":  "GB",
+		"89.160.0.0/16": "SE",
+		"2001:db8::/32": "DE",
+	})
+	require.NoError(t, db.Create(&models.Setting{Key: GeoIPDatabaseSettingKey, Value: path}).Error)
+	return NewGeoIPService(db)
+}
+
+func TestGeoIPService_Allowed(t *testing.T) {
+	service := setupGeoIPService(t)
+
+	tests := []struct {
+		ip          string
+		allow, deny []string
+		country     string
+		allowed     bool
+	}{
+		{"81.2.69.160", []string{"GB", "SE"}, nil, "GB", true},
+		{"89.160.20.112", []string{"GB"}, nil, "SE", false},
+		{"2001:db8::1", nil, []string{"DE"}, "DE", false},
+		{"81.2.69.160", nil, []string{"DE"}, "GB", true},
+		{"8.8.8.8", []string{"GB"}, nil, "", false},
+		{"8.8.8.8", nil, []string{"GB"}, "", true},
+		{"192.168.1.10", []string{"GB"}, nil, "", true},
+		{"::1", []string{"GB"}, nil, "", true},
+	}
+	for _, tt := range tests {
+		country, allowed, err := service.Allowed(tt.ip, tt.allow, tt.deny)
+		require.NoError(t, err, tt.ip)
+		assert.Equal(t, tt.country, country, tt.ip)
+		assert.Equal(t, tt.allowed, allowed, tt.ip)
+	}
+
+	_, _, err := service.Allowed("not-an-ip", nil, nil)
+	require.ErrorContains(t, err, "invalid address")
+}
+
+func TestGeoIPService_NotConfigured(t *testing.T) {
+	service := NewGeoIPService(setupProxyHostTestDB(t))
+	_, err := service.Country("81.2.69.160")
+	require.ErrorIs(t, err, ErrGeoIPNotConfigured)
+
+	require.NoError(t, ValidateGeoIPDatabase(""))
+	bogus := filepath.Join(t.TempDir(), "bogus.mmdb")
+	require.NoError(t, os.WriteFile(bogus, []byte("nope"), 0o600))
+	require.ErrorContains(t, ValidateGeoIPDatabase(bogus), "invalid GeoIP database")
+}
+
+func TestValidateAccessList_Countries(t *testing.T) {
+	list := &models.AccessList{Name: "EU", Type: models.AccessListCountryAllow, Rules: []string{" de", "FR", "DE"}}
+	require.NoError(t, validateAccessList(list))
+	assert.Equal(t, []string{"DE", "FR"}, list.Rules)
+
+	err := validateAccessList(&models.AccessList{Name: "Bad", Type: models.AccessListCountryDeny, Rules: []string{"Germany"}})
+	require.ErrorContains(t, err, "invalid country code")
+	err = validateAccessList(&models.AccessList{Name: "Empty", Type: models.AccessListCountryDeny})
+	require.ErrorContains(t, err, "at least one country")
+}
+
+func TestLogService_QueryLogsAddsCountries(t *testing.T) {
+	dataDir := t.TempDir()
+	logsDir := filepath.Join(dataDir, "logs")
+	require.NoError(t, os.MkdirAll(logsDir, 0o755))
+
+	var content []byte
+	for _, ip := range []string{"81.2.69.160", "2001:db8::1", "10.0.0.1"} {
+		var entry models.CaddyAccessLog
+		entry.Status = 200
+		entry.Request.RemoteIP = ip
+		line, err := json.Marshal(entry)
+		require.NoError(t, err)
+		content = append(append(content, line...), '\n')
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(logsDir, "access.log"), content, 0o644))
+
+	service := NewLogService(&config.Config{DatabasePath: filepath.Join(dataDir, "cpm.db")})
+	results, _, err := service.QueryLogs("access.log", models.LogFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, results[0].Country)
+
+	service.SetGeoIP(setupGeoIPService(t))
+	results, _, err = service.QueryLogs("access.log", models.LogFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "", results[0].Country)
+	assert.Equal(t, "DE", results[1].Country)
+	assert.Equal(t, "GB", results[2].Country)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// headerNamePattern matches an RFC 7230 header field name.
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// LocationService manages the custom locations of proxy hosts.
+type LocationService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+	modules  []string
+}
+
+// NewLocationService creates a new location service.
+func NewLocationService(db *gorm.DB) *LocationService {
+	return &LocationService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *LocationService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+// SetModules sets the IDs of the modules in the running Caddy build. When set,
+// rate limits are refused unless the build has the rate_limit handler.
+func (s *LocationService) SetModules(modules []string) {
+	s.modules = modules
+}
+
+func (s *LocationService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validateLocation normalizes and checks a single location.
+func validateLocation(loc *models.Location) error {
+	if loc.Path == "" && loc.PathRegexp == "" {
+		return errors.New("location requires a path or path_regexp")
+	}
+	if loc.Path != "" && !strings.HasPrefix(loc.Path, "/") {
+		return fmt.Errorf("location path %s must start with /", loc.Path)
+	}
+	if loc.PathRegexp != "" {
+		if _, err := regexp.Compile(loc.PathRegexp); err != nil {
+			return fmt.Errorf("location path_regexp: %w", err)
+		}
+	}
+
+	if loc.ForwardScheme == "" {
+		loc.ForwardScheme = "http"
+	}
+	if loc.ForwardScheme != "http" && loc.ForwardScheme != "https" {
+		return fmt.Errorf("location %s: unsupported forward scheme: %s", loc.Path, loc.ForwardScheme)
+	}
+	if loc.ForwardHost == "" {
+		return fmt.Errorf("location %s: forward host is required", loc.Path)
+	}
+	if loc.ForwardPort < 1 || loc.ForwardPort > 65535 {
+		return fmt.Errorf("location %s: invalid forward port %d", loc.Path, loc.ForwardPort)
+	}
+
+	if loc.StripPathPrefix && loc.Path == "" {
+		return errors.New("strip_path_prefix requires a path")
+	}
+	if loc.RewriteURI != "" && !strings.HasPrefix(loc.RewriteURI, "/") && !strings.HasPrefix(loc.RewriteURI, "{") {
+		return fmt.Errorf("rewrite_uri %s must start with / or a placeholder", loc.RewriteURI)
+	}
+
+	if err := validateRateLimits(loc.RateLimits); err != nil {
+		return fmt.Errorf("location %s: %w", loc.Path, err)
+	}
+	if loc.MaxBodySize < 0 {
+		return fmt.Errorf("location %s: max_body_size must not be negative", loc.Path)
+	}
+
+	for name, value := range loc.RequestHeaders {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid request header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("request header %s contains a line break", name)
+		}
+	}
+
+	return nil
+}
+
+// validateLocations checks every location of a host and rejects two locations
+// that would match the same requests.
+func validateLocations(locs []models.Location) error {
+	seen := make(map[string]bool, len(locs))
+	for i := range locs {
+		if err := validateLocation(&locs[i]); err != nil {
+			return err
+		}
+
+		// A regexp replaces the path prefix as the matcher
+		key := locs[i].Path
+		if locs[i].PathRegexp != "" {
+			key = "~" + locs[i].PathRegexp
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate location %s%s", locs[i].Path, locs[i].PathRegexp)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// siblings returns the other locations of the host together with loc, for
+// duplicate checks.
+func (s *LocationService) siblings(host *models.ProxyHost, loc *models.Location) ([]models.Location, error) {
+	var locs []models.Location
+	if err := s.db.Where("proxy_host_id = ? AND id <> ?", host.ID, loc.ID).Find(&locs).Error; err != nil {
+		return nil, err
+	}
+	return append(locs, *loc), nil
+}
+
+// Create validates and adds a location to host.
+func (s *LocationService) Create(host *models.ProxyHost, loc *models.Location) error {
+	loc.ProxyHostID = host.ID
+	if err := validateLocation(loc); err != nil {
+		return err
+	}
+	if err := requireRateLimitModule(s.modules, loc.RateLimits); err != nil {
+		return fmt.Errorf("location %s%s: %w", loc.Path, loc.PathRegexp, err)
+	}
+
+	locs, err := s.siblings(host, loc)
+	if err != nil {
+		return err
+	}
+	if err := validateLocations(locs); err != nil {
+		return err
+	}
+	if err := validateAccessAssignment(s.db, loc.AccessListIDs, &loc.AccessSatisfy); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(loc).Error; err != nil {
+		return err
+	}
+
+	s.notify("location created: " + host.DomainNames + " " + loc.Path + loc.PathRegexp)
+	return nil
+}
+
+// Update validates and saves a location of host.
+func (s *LocationService) Update(host *models.ProxyHost, loc *models.Location) error {
+	loc.ProxyHostID = host.ID
+	if err := validateLocation(loc); err != nil {
+		return err
+	}
+	if err := requireRateLimitModule(s.modules, loc.RateLimits); err != nil {
+		return fmt.Errorf("location %s%s: %w", loc.Path, loc.PathRegexp, err)
+	}
+
+	locs, err := s.siblings(host, loc)
+	if err != nil {
+		return err
+	}
+	if err := validateLocations(locs); err != nil {
+		return err
+	}
+	if err := validateAccessAssignment(s.db, loc.AccessListIDs, &loc.AccessSatisfy); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(loc).Error; err != nil {
+		return err
+	}
+
+	s.notify("location updated: " + host.DomainNames + " " + loc.Path + loc.PathRegexp)
+	return nil
+}
+
+// Delete removes a location.
+func (s *LocationService) Delete(id uint) error {
+	if err := s.db.Delete(&models.Location{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("location deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds a location of the host by UUID.
+func (s *LocationService) GetByUUID(hostID uint, uuid string) (*models.Location, error) {
+	var loc models.Location
+	if err := s.db.Where("proxy_host_id = ? AND uuid = ?", hostID, uuid).First(&loc).Error; err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// List returns the locations of a host ordered by path.
+func (s *LocationService) List(hostID uint) ([]models.Location, error) {
+	var locs []models.Location
+	if err := s.db.Where("proxy_host_id = ?", hostID).Order("path asc").Find(&locs).Error; err != nil {
+		return nil, err
+	}
+	return locs, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestValidateLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		loc     models.Location
+		wantErr string
+	}{
+		{
+			name: "prefix path",
+			loc:  models.Location{Path: "/api", ForwardHost: "api", ForwardPort: 9000},
+		},
+		{
+			name: "regexp with rewrite",
+			loc:  models.Location{PathRegexp: `^/v[0-9]+/`, ForwardHost: "api", ForwardPort: 9000, RewriteURI: "{http.request.uri}"},
+		},
+		{
+			name:    "missing path",
+			loc:     models.Location{ForwardHost: "api", ForwardPort: 9000},
+			wantErr: "requires a path",
+		},
+		{
+			name:    "relative path",
+			loc:     models.Location{Path: "api", ForwardHost: "api", ForwardPort: 9000},
+			wantErr: "must start with /",
+		},
+		{
+			name:    "invalid regexp",
+			loc:     models.Location{PathRegexp: "^/(", ForwardHost: "api", ForwardPort: 9000},
+			wantErr: "path_regexp",
+		},
+		{
+			name:    "unsupported scheme",
+			loc:     models.Location{Path: "/api", ForwardScheme: "ftp", ForwardHost: "api", ForwardPort: 9000},
+			wantErr: "unsupported forward scheme",
+		},
+		{
+			name:    "invalid port",
+			loc:     models.Location{Path: "/api", ForwardHost: "api", ForwardPort: 70000},
+			wantErr: "invalid forward port",
+		},
+		{
+			name:    "strip without path",
+			loc:     models.Location{PathRegexp: "^/api", ForwardHost: "api", ForwardPort: 9000, StripPathPrefix: true},
+			wantErr: "strip_path_prefix requires a path",
+		},
+		{
+			name:    "relative rewrite",
+			loc:     models.Location{Path: "/api", ForwardHost: "api", ForwardPort: 9000, RewriteURI: "v2"},
+			wantErr: "rewrite_uri",
+		},
+		{
+			name:    "invalid header name",
+			loc:     models.Location{Path: "/api", ForwardHost: "api", ForwardPort: 9000, RequestHeaders: map[string]string{"X Bad": "1"}},
+			wantErr: "invalid request header name",
+		},
+		{
+			name:    "header injection",
+			loc:     models.Location{Path: "/api", ForwardHost: "api", ForwardPort: 9000, RequestHeaders: map[string]string{"X-Ok": "1\r\nX-Evil: 1"}},
+			wantErr: "line break",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLocation(&tt.loc)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "http", tt.loc.ForwardScheme)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLocationService_CRUD(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewLocationService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	host := &models.ProxyHost{UUID: "host-1", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 8080}
+	require.NoError(t, db.Create(host).Error)
+
+	loc := &models.Location{UUID: "loc-1", Path: "/api", ForwardHost: "api", ForwardPort: 9000, RequestHeaders: map[string]string{"X-Gateway": "cpm"}}
+	require.NoError(t, service.Create(host, loc))
+	assert.Equal(t, host.ID, loc.ProxyHostID)
+
+	// The same prefix twice would never be reached
+	dup := &models.Location{UUID: "loc-2", Path: "/api", ForwardHost: "other", ForwardPort: 9000}
+	err := service.Create(host, dup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate location")
+
+	fetched, err := service.GetByUUID(host.ID, "loc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "cpm", fetched.RequestHeaders["X-Gateway"])
+
+	// Saving a location must not trip over itself
+	fetched.StripPathPrefix = true
+	require.NoError(t, service.Update(host, fetched))
+
+	_, err = service.GetByUUID(host.ID+1, "loc-1")
+	require.Error(t, err)
+
+	locs, err := service.List(host.ID)
+	require.NoError(t, err)
+	require.Len(t, locs, 1)
+	assert.True(t, locs[0].StripPathPrefix)
+
+	require.NoError(t, service.Delete(fetched.ID))
+	locs, err = service.List(host.ID)
+	require.NoError(t, err)
+	assert.Empty(t, locs)
+
+	assert.Equal(t, []string{
+		"location created: app.example.com /api",
+		"location updated: app.example.com /api",
+		"location deleted: 1",
+	}, notifier.reasons)
+}
+
+func TestProxyHostService_RejectsDuplicateLocations(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{
+		UUID:        "dup-locations",
+		DomainNames: "dup.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Locations: []models.Location{
+			{UUID: "a", PathRegexp: "^/api", ForwardHost: "api", ForwardPort: 9000},
+			{UUID: "b", PathRegexp: "^/api", ForwardHost: "api", ForwardPort: 9001},
+		},
+	}
+	err := service.Create(host)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate location")
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +18,7 @@ import (
 
 type LogService struct {
 	LogDir string
+	geoIP  *GeoIPService
 }
 
 func NewLogService(cfg *config.Config) *LogService {
@@ -25,6 +27,11 @@ func NewLogService(cfg *config.Config) *LogService {
 	return &LogService{LogDir: logDir}
 }
 
+// SetGeoIP enables country enrichment of queried log entries.
+func (s *LogService) SetGeoIP(geoIP *GeoIPService) {
+	s.geoIP = geoIP
+}
+
 type LogFile struct {
 	Name    string `json:"name"`
 	Size    int64  `json:"size"`
@@ -157,7 +164,36 @@ func (s *LogService) QueryLogs(filename string, filter models.LogFilter) ([]mode
 		end = len(logs)
 	}
 
-	return logs[start:end], totalMatches, nil
+	page := logs[start:end]
+	s.addCountries(page)
+	return page, totalMatches, nil
+}
+
+// addCountries sets the client country of each entry when a GeoIP database is configured.
+func (s *LogService) addCountries(logs []models.CaddyAccessLog) {
+	if s.geoIP == nil {
+		return
+	}
+	countries := map[string]string{}
+	for i := range logs {
+		ip := logs[i].Request.ClientIP
+		if ip == "" {
+			ip = logs[i].Request.RemoteIP
+		}
+		if ip == "" {
+			continue
+		}
+		country, ok := countries[ip]
+		if !ok {
+			var err error
+			country, err = s.geoIP.Country(ip)
+			if errors.Is(err, ErrGeoIPNotConfigured) {
+				return
+			}
+			countries[ip] = country
+		}
+		logs[i].Country = country
+	}
 }
 
 func (s *LogService) matchesFilter(entry models.CaddyAccessLog, filter models.LogFilter) bool {
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// PageTemplateService manages the HTML pages proxy hosts serve in maintenance
+// mode and on errors.
+type PageTemplateService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+}
+
+// NewPageTemplateService creates a new page template service.
+func NewPageTemplateService(db *gorm.DB) *PageTemplateService {
+	return &PageTemplateService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *PageTemplateService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *PageTemplateService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validateTemplate requires a unique name and some content.
+func (s *PageTemplateService) validateTemplate(template *models.PageTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return errors.New("name is required")
+	}
+	var existing int64
+	if err := s.db.Model(&models.PageTemplate{}).Where("name = ? AND id <> ?", template.Name, template.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return fmt.Errorf("page template %s already exists", template.Name)
+	}
+
+	// Content is not checked for placeholders: Caddy leaves unknown ones such
+	// as CSS blocks alone
+	if strings.TrimSpace(template.Content) == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+// Create validates and stores a new template.
+func (s *PageTemplateService) Create(template *models.PageTemplate) error {
+	if err := s.validateTemplate(template); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(template).Error; err != nil {
+		return err
+	}
+
+	s.notify("page template created: " + template.Name)
+	return nil
+}
+
+// Update validates and saves a template; hosts using it pick up the change.
+func (s *PageTemplateService) Update(template *models.PageTemplate) error {
+	if err := s.validateTemplate(template); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(template).Error; err != nil {
+		return err
+	}
+
+	s.notify("page template updated: " + template.Name)
+	return nil
+}
+
+// Delete removes a template that no proxy host uses.
+func (s *PageTemplateService) Delete(id uint) error {
+	var hosts []models.ProxyHost
+	if err := s.db.Select("id", "maintenance_template_id", "error_pages").Find(&hosts).Error; err != nil {
+		return err
+	}
+	inUse := 0
+	for _, host := range hosts {
+		if usesPageTemplate(&host, id) {
+			inUse++
+		}
+	}
+	if inUse > 0 {
+		return fmt.Errorf("page template is used by %d proxy host(s)", inUse)
+	}
+
+	if err := s.db.Delete(&models.PageTemplate{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("page template deleted: %d", id))
+	return nil
+}
+
+// usesPageTemplate reports whether a host serves the template as its
+// maintenance page or one of its error pages.
+func usesPageTemplate(host *models.ProxyHost, id uint) bool {
+	if host.Maintenance.TemplateID != nil && *host.Maintenance.TemplateID == id {
+		return true
+	}
+	for _, page := range host.ErrorPages {
+		if page.TemplateID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GetByUUID finds a template by UUID.
+func (s *PageTemplateService) GetByUUID(uuid string) (*models.PageTemplate, error) {
+	var template models.PageTemplate
+	if err := s.db.Where("uuid = ?", uuid).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// List returns all templates ordered by name.
+func (s *PageTemplateService) List() ([]models.PageTemplate, error) {
+	var templates []models.PageTemplate
+	if err := s.db.Order("name asc").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
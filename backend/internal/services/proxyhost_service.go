@@ -1,17 +1,29 @@
 package services
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 
 	"gorm.io/gorm"
 
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 )
 
 // ProxyHostService encapsulates business logic for proxy host management.
 type ProxyHostService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	notifier ConfigNotifier
+	adapter  caddy.SnippetAdapter
+	modules  []string
+	cipher   *secrets.Cipher
 }
 
 // NewProxyHostService creates a new proxy host service.
@@ -19,21 +31,512 @@ func NewProxyHostService(db *gorm.DB) *ProxyHostService {
 	return &ProxyHostService{db: db}
 }
 
-// ValidateUniqueDomain ensures no duplicate domains exist before creation/update.
+// SetNotifier registers the notifier informed after every successful write.
+func (s *ProxyHostService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+// SetAdapter registers the Caddy binary checking advanced configs, and the IDs
+// of the modules in its build. Without an adapter Caddyfile snippets are refused.
+func (s *ProxyHostService) SetAdapter(adapter caddy.SnippetAdapter, modules []string) {
+	s.adapter = adapter
+	s.modules = modules
+}
+
+// SetCipher sets the cipher encrypting upstream client keys before they are
+// stored. Without it hosts with a client certificate are refused.
+func (s *ProxyHostService) SetCipher(cipher *secrets.Cipher) {
+	s.cipher = cipher
+}
+
+func (s *ProxyHostService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// ValidateUniqueDomain ensures no domain is already used by another proxy or redirection host.
 func (s *ProxyHostService) ValidateUniqueDomain(domainNames string, excludeID uint) error {
-	var count int64
-	query := s.db.Model(&models.ProxyHost{}).Where("domain_names = ?", domainNames)
+	return checkDomainsAvailable(s.db, domainNames, excludeID, 0)
+}
+
+// normalizeUpstreams validates the upstream list and load balancing policy and mirrors
+// the first upstream into ForwardHost/ForwardPort for single-target consumers like uptime checks.
+func normalizeUpstreams(host *models.ProxyHost) error {
+	if host.LoadBalancing == "" {
+		host.LoadBalancing = models.LoadBalancingRoundRobin
+	}
+	if !slices.Contains(models.LoadBalancingPolicies, host.LoadBalancing) {
+		return fmt.Errorf("unsupported load balancing policy: %s", host.LoadBalancing)
+	}
+
+	for i, upstream := range host.Upstreams {
+		if upstream.Host == "" {
+			return fmt.Errorf("upstream %d has no host", i)
+		}
+		if upstream.Port < 1 || upstream.Port > 65535 {
+			return fmt.Errorf("upstream %d port %d out of range (1-65535)", i, upstream.Port)
+		}
+		if upstream.Weight < 0 {
+			return fmt.Errorf("upstream %d has negative weight", i)
+		}
+	}
+
+	if len(host.Upstreams) > 0 {
+		host.ForwardHost = host.Upstreams[0].Host
+		host.ForwardPort = host.Upstreams[0].Port
+	}
+
+	return nil
+}
+
+// validateHealthCheck rejects health check settings Caddy would refuse to load.
+func validateHealthCheck(hc models.HealthCheckConfig) error {
+	if hc.ActiveEnabled && !strings.HasPrefix(hc.ActivePath, "/") {
+		return errors.New("active health check path must start with /")
+	}
+	if hc.ActiveInterval < 0 || hc.ActiveTimeout < 0 || hc.FailDuration < 0 || hc.MaxFails < 0 {
+		return errors.New("health check durations and max fails cannot be negative")
+	}
+	if hc.ExpectStatus != 0 && (hc.ExpectStatus < 100 || hc.ExpectStatus > 599) {
+		return fmt.Errorf("invalid expected status %d", hc.ExpectStatus)
+	}
+	for _, code := range hc.UnhealthyStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid unhealthy status %d", code)
+		}
+	}
+	return nil
+}
 
-	if excludeID > 0 {
-		query = query.Where("id != ?", excludeID)
+// normalizeHTTPSMode resolves the host's HTTPS mode and keeps the legacy SSLForced
+// flag in step with it. Clients that only send SSLForced get the matching HTTPS mode.
+func normalizeHTTPSMode(host *models.ProxyHost) error {
+	host.HTTPSMode = host.EffectiveHTTPSMode()
+	if !slices.Contains(models.HTTPSModes, host.HTTPSMode) {
+		return fmt.Errorf("unsupported https mode: %s", host.HTTPSMode)
 	}
+	host.SSLForced = host.HTTPSMode == models.HTTPSModeRedirect
+	return nil
+}
+
+// validateHeaderRules rejects header rules Caddy would refuse or that would
+// break proxying, such as hop-by-hop headers or values injecting new lines.
+func validateHeaderRules(rules []models.HeaderRule) error {
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Direction != models.HeaderRuleRequest && rule.Direction != models.HeaderRuleResponse {
+			return fmt.Errorf("header rule %d: unsupported direction %q", i, rule.Direction)
+		}
+		if !headerNamePattern.MatchString(rule.Name) {
+			return fmt.Errorf("header rule %d: invalid header name %q", i, rule.Name)
+		}
+		if caddy.IsHopByHopHeader(rule.Name) {
+			return fmt.Errorf("header rule %d: %s is a hop-by-hop header and cannot be changed", i, rule.Name)
+		}
 
-	if err := query.Count(&count).Error; err != nil {
-		return fmt.Errorf("checking domain uniqueness: %w", err)
+		switch rule.Operation {
+		case models.HeaderOpSet, models.HeaderOpAdd:
+			if rule.Value == "" {
+				return fmt.Errorf("header rule %d: %s %s requires a value", i, rule.Operation, rule.Name)
+			}
+		case models.HeaderOpDelete:
+			if rule.Value != "" {
+				return fmt.Errorf("header rule %d: delete %s takes no value", i, rule.Name)
+			}
+		default:
+			return fmt.Errorf("header rule %d: unsupported operation %q", i, rule.Operation)
+		}
+
+		if strings.ContainsAny(rule.Value, "\r\n") {
+			return fmt.Errorf("header rule %d: %s contains a line break", i, rule.Name)
+		}
+		if err := checkPlaceholders(rule.Value); err != nil {
+			return fmt.Errorf("header rule %d: %s: %w", i, rule.Name, err)
+		}
+
+		// Two set or delete rules for one header would silently overwrite each other
+		if rule.Operation != models.HeaderOpAdd {
+			key := rule.Direction + " " + rule.Operation + " " + http.CanonicalHeaderKey(rule.Name)
+			if seen[key] {
+				return fmt.Errorf("header rule %d: duplicate %s %s rule for %s", i, rule.Direction, rule.Operation, rule.Name)
+			}
+			seen[key] = true
+		}
 	}
+	return nil
+}
 
-	if count > 0 {
-		return errors.New("domain already exists")
+// validateRateLimits normalizes rate limit rules and rejects ones the rate_limit
+// handler would refuse.
+func validateRateLimits(rules []models.RateLimitRule) error {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Key == "" {
+			rule.Key = models.RateLimitKeyIP
+		}
+		if !slices.Contains(models.RateLimitKeys, rule.Key) {
+			return fmt.Errorf("rate limit %d: unsupported key %q", i, rule.Key)
+		}
+		if rule.Key == models.RateLimitKeyHeader {
+			if !headerNamePattern.MatchString(rule.Header) {
+				return fmt.Errorf("rate limit %d: invalid header name %q", i, rule.Header)
+			}
+			rule.Header = http.CanonicalHeaderKey(rule.Header)
+		} else if rule.Header != "" {
+			return fmt.Errorf("rate limit %d: header is only used by the header key", i)
+		}
+		if rule.Requests < 1 {
+			return fmt.Errorf("rate limit %d: requests must be at least 1", i)
+		}
+		if rule.Window < 1 || rule.Window > 86400 {
+			return fmt.Errorf("rate limit %d: window must be between 1 and 86400 seconds", i)
+		}
+	}
+	return nil
+}
+
+// requireRateLimitModule rejects rate limits when the Caddy build is known to
+// lack the rate_limit handler; Caddy would refuse the config of every host.
+func requireRateLimitModule(modules []string, rules []models.RateLimitRule) error {
+	if len(rules) > 0 && len(modules) > 0 && !slices.Contains(modules, caddy.RateLimitModule) {
+		return fmt.Errorf("rate limits need %s, which the Caddy build lacks", caddy.RateLimitModule)
+	}
+	return nil
+}
+
+// validateLimits checks the rate limits, body size limit and limit response of a host.
+func (s *ProxyHostService) validateLimits(host *models.ProxyHost) error {
+	if err := validateRateLimits(host.RateLimits); err != nil {
+		return err
+	}
+	if err := requireRateLimitModule(s.modules, host.RateLimits); err != nil {
+		return err
+	}
+	for _, loc := range host.Locations {
+		if err := requireRateLimitModule(s.modules, loc.RateLimits); err != nil {
+			return fmt.Errorf("location %s%s: %w", loc.Path, loc.PathRegexp, err)
+		}
+	}
+	if host.MaxBodySize < 0 {
+		return errors.New("max_body_size must not be negative")
+	}
+
+	resp := host.LimitResponse
+	if resp.StatusCode != 0 && (resp.StatusCode < 400 || resp.StatusCode > 599) {
+		return fmt.Errorf("limit_response status_code %d must be a 4xx or 5xx status", resp.StatusCode)
+	}
+	if strings.ContainsAny(resp.ContentType, "\r\n") {
+		return errors.New("limit_response content_type contains a line break")
+	}
+	return nil
+}
+
+// errorPageStatusPattern matches the statuses of error pages: a 4xx or 5xx code, or its class.
+var errorPageStatusPattern = regexp.MustCompile(`^[45](\d\d|xx)$`)
+
+// bypassCookiePattern matches maintenance bypass secrets, which must be usable
+// both as a cookie value and in a query string.
+var bypassCookiePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// validatePages checks the maintenance settings and error pages of a host and
+// the page templates they use.
+func (s *ProxyHostService) validatePages(host *models.ProxyHost) error {
+	maintenance := &host.Maintenance
+	for i, network := range maintenance.BypassIPs {
+		normalized, err := caddy.ParseNetwork(network)
+		if err != nil {
+			return fmt.Errorf("maintenance bypass_ips: %w", err)
+		}
+		maintenance.BypassIPs[i] = normalized
+	}
+	if maintenance.BypassCookie != "" && !bypassCookiePattern.MatchString(maintenance.BypassCookie) {
+		return errors.New("maintenance bypass_cookie must be 16 to 128 letters, digits, '-' or '_'")
+	}
+	if maintenance.TemplateID != nil {
+		if err := s.requirePageTemplate(*maintenance.TemplateID); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool, len(host.ErrorPages))
+	for i := range host.ErrorPages {
+		page := &host.ErrorPages[i]
+		page.Status = strings.ToLower(page.Status)
+		if !errorPageStatusPattern.MatchString(page.Status) {
+			return fmt.Errorf("error page status %q must be a 4xx or 5xx code or class such as 5xx", page.Status)
+		}
+		if seen[page.Status] {
+			return fmt.Errorf("duplicate error page for %s", page.Status)
+		}
+		seen[page.Status] = true
+		if err := s.requirePageTemplate(page.TemplateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAdvanced compiles the host's advanced config into the Caddy JSON routes
+// used by the config generator, so snippet errors surface on save.
+func (s *ProxyHostService) validateAdvanced(host *models.ProxyHost) error {
+	advanced := &host.Advanced
+	if strings.TrimSpace(advanced.Content) == "" {
+		*advanced = models.AdvancedConfig{}
+		return nil
+	}
+	if advanced.Format == "" {
+		advanced.Format = models.AdvancedFormatJSON
+	}
+	if !slices.Contains(models.AdvancedFormats, advanced.Format) {
+		return fmt.Errorf("unsupported advanced format: %s", advanced.Format)
+	}
+
+	routes, err := caddy.CompileAdvanced(advanced.Format, advanced.Content, s.adapter, s.modules)
+	if err != nil {
+		return fmt.Errorf("advanced: %w", err)
+	}
+	advanced.Routes = string(routes)
+	return nil
+}
+
+// requirePageTemplate fails when the page template does not exist.
+func (s *ProxyHostService) requirePageTemplate(id uint) error {
+	var template models.PageTemplate
+	if err := s.db.First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("page template %d not found", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkPlaceholders rejects unbalanced braces, which Caddy would otherwise send
+// verbatim instead of expanding a placeholder. Escaped braces are allowed.
+func checkPlaceholders(value string) error {
+	open := false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '{':
+			if open {
+				return errors.New("nested placeholder")
+			}
+			open = true
+		case '}':
+			if !open {
+				return errors.New("unexpected } outside a placeholder")
+			}
+			open = false
+		}
+	}
+	if open {
+		return errors.New("unterminated placeholder")
+	}
+	return nil
+}
+
+// prepareForwardTLS checks the upstream schemes of the host and its locations,
+// makes sure any upstream TLS material parses and encrypts the client key.
+func (s *ProxyHostService) prepareForwardTLS(host *models.ProxyHost) error {
+	if host.ForwardScheme == "" {
+		host.ForwardScheme = "http"
+	}
+	if host.ForwardScheme != "http" && host.ForwardScheme != "https" {
+		return fmt.Errorf("unsupported forward scheme: %s", host.ForwardScheme)
+	}
+	if err := validateLocations(host.Locations); err != nil {
+		return err
+	}
+
+	cfg := host.UpstreamTLS
+	if cfg.TrustedCAPEM != "" {
+		if ok := x509.NewCertPool().AppendCertsFromPEM([]byte(cfg.TrustedCAPEM)); !ok {
+			return errors.New("trusted CA PEM contains no certificates")
+		}
+	}
+	if (cfg.ClientCertPEM == "") != (cfg.ClientKeyPEM == "") {
+		return errors.New("client certificate and key must be provided together")
+	}
+	if !cfg.HasClientCert() {
+		return nil
+	}
+	if s.cipher == nil {
+		return errors.New("upstream client certificates require an encryption key")
+	}
+
+	// Updates carry the key stored earlier unless a new one was sent
+	keyPEM := cfg.ClientKeyPEM
+	if secrets.IsEncrypted(keyPEM) {
+		plain, err := s.cipher.Decrypt(keyPEM)
+		if err != nil {
+			return fmt.Errorf("decrypt client key: %w", err)
+		}
+		keyPEM = plain
+	}
+	if _, err := tls.X509KeyPair([]byte(cfg.ClientCertPEM), []byte(keyPEM)); err != nil {
+		return fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	encryptedKey, err := s.cipher.Encrypt(keyPEM)
+	if err != nil {
+		return fmt.Errorf("encrypt client key: %w", err)
+	}
+	host.UpstreamTLS.ClientKeyPEM = encryptedKey
+	return nil
+}
+
+// validateSecurityHeaders ensures a selected security header profile exists and
+// the host's CSP override fits in a single header line.
+func (s *ProxyHostService) validateSecurityHeaders(host *models.ProxyHost) error {
+	if strings.ContainsAny(host.CSPOverride, "\r\n") {
+		return errors.New("csp_override contains a line break")
+	}
+	if host.SecurityHeaderProfileID == nil {
+		return nil
+	}
+
+	var profile models.SecurityHeaderProfile
+	if err := s.db.First(&profile, *host.SecurityHeaderProfileID).Error; err != nil {
+		return fmt.Errorf("security header profile %d not found", *host.SecurityHeaderProfileID)
+	}
+	return nil
+}
+
+// validateAccess checks the access lists assigned to the host and its locations.
+func (s *ProxyHostService) validateAccess(host *models.ProxyHost) error {
+	if err := validateAccessAssignment(s.db, host.AccessListIDs, &host.AccessSatisfy); err != nil {
+		return err
+	}
+	for i := range host.Locations {
+		loc := &host.Locations[i]
+		if err := validateAccessAssignment(s.db, loc.AccessListIDs, &loc.AccessSatisfy); err != nil {
+			return fmt.Errorf("location %s%s: %w", loc.Path, loc.PathRegexp, err)
+		}
+	}
+	return nil
+}
+
+// validateCertificate ensures a selected custom certificate exists and covers
+// every domain of the host.
+func (s *ProxyHostService) validateCertificate(host *models.ProxyHost) error {
+	if host.CertificateID == nil {
+		return nil
+	}
+
+	var cert models.SSLCertificate
+	if err := s.db.First(&cert, *host.CertificateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("certificate %d not found", *host.CertificateID)
+		}
+		return err
+	}
+
+	return checkCertificateCovers(&cert, host.DomainNames)
+}
+
+// validateIssuance checks the issuer and DNS-01 options: wildcard names can only
+// be issued through a configured DNS provider or the internal CA.
+func (s *ProxyHostService) validateIssuance(host *models.ProxyHost) error {
+	if !slices.Contains(models.CertIssuers, host.CertIssuer) {
+		return fmt.Errorf("unsupported certificate issuer %q", host.CertIssuer)
+	}
+
+	if host.CertIssuer == models.CertIssuerZeroSSL {
+		if err := s.requireSetting(caddy.ZeroSSLEABSettingKey, "zerossl issuer requires eab credentials"); err != nil {
+			return err
+		}
+	}
+
+	if host.CertIssuer == models.CertIssuerInternal {
+		if host.DNSProvider != "" {
+			return errors.New("the internal issuer does not use a dns provider")
+		}
+		return nil
+	}
+
+	if host.DNSProvider == "" {
+		if host.WildcardCert {
+			return errors.New("wildcard certificates require a dns provider")
+		}
+		for _, domain := range splitDomains(host.DomainNames) {
+			if strings.HasPrefix(domain, "*.") && host.CertificateID == nil && host.EffectiveHTTPSMode() != models.HTTPSModeHTTPOnly {
+				return fmt.Errorf("wildcard domain %s requires a dns provider", domain)
+			}
+		}
+		return nil
+	}
+
+	if _, ok := caddy.DNSProviders[host.DNSProvider]; !ok {
+		return fmt.Errorf("unsupported dns provider %q", host.DNSProvider)
+	}
+
+	return s.requireSetting(caddy.DNSProviderSettingKey(host.DNSProvider), fmt.Sprintf("dns provider %s has no credentials configured", host.DNSProvider))
+}
+
+// requireSetting fails with message when key is not set.
+func (s *ProxyHostService) requireSetting(key, message string) error {
+	var configured int64
+	if err := s.db.Model(&models.Setting{}).Where("key = ?", key).Count(&configured).Error; err != nil {
+		return err
+	}
+	if configured == 0 {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// validate normalizes defaults and checks every setting of a host before it is
+// created or updated.
+func (s *ProxyHostService) validate(host *models.ProxyHost) error {
+	if err := normalizeUpstreams(host); err != nil {
+		return err
+	}
+
+	if err := validateHealthCheck(host.HealthCheck); err != nil {
+		return err
+	}
+
+	if err := s.prepareForwardTLS(host); err != nil {
+		return err
+	}
+
+	if err := validateHeaderRules(host.HeaderRules); err != nil {
+		return err
+	}
+
+	if err := s.validateLimits(host); err != nil {
+		return err
+	}
+
+	if err := s.validateSecurityHeaders(host); err != nil {
+		return err
+	}
+
+	if err := s.validateAccess(host); err != nil {
+		return err
+	}
+
+	if err := s.validatePages(host); err != nil {
+		return err
+	}
+
+	if err := s.validateAdvanced(host); err != nil {
+		return err
+	}
+
+	if err := normalizeHTTPSMode(host); err != nil {
+		return err
+	}
+
+	if err := s.validateCertificate(host); err != nil {
+		return err
+	}
+
+	if err := s.validateIssuance(host); err != nil {
+		return err
 	}
 
 	return nil
@@ -45,7 +548,16 @@ func (s *ProxyHostService) Create(host *models.ProxyHost) error {
 		return err
 	}
 
-	return s.db.Create(host).Error
+	if err := s.validate(host); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(host).Error; err != nil {
+		return err
+	}
+
+	s.notify("proxy host created: " + host.DomainNames)
+	return nil
 }
 
 // Update validates and updates an existing proxy host.
@@ -54,12 +566,26 @@ func (s *ProxyHostService) Update(host *models.ProxyHost) error {
 		return err
 	}
 
-	return s.db.Save(host).Error
+	if err := s.validate(host); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(host).Error; err != nil {
+		return err
+	}
+
+	s.notify("proxy host updated: " + host.DomainNames)
+	return nil
 }
 
 // Delete removes a proxy host.
 func (s *ProxyHostService) Delete(id uint) error {
-	return s.db.Delete(&models.ProxyHost{}, id).Error
+	if err := s.db.Delete(&models.ProxyHost{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("proxy host deleted: %d", id))
+	return nil
 }
 
 // GetByID retrieves a proxy host by ID.
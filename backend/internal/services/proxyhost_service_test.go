@@ -1,10 +1,22 @@
 package services
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -15,7 +27,7 @@ func setupProxyHostTestDB(t *testing.T) *gorm.DB {
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.SSLCertificate{}, &models.SecurityHeaderProfile{}, &models.AccessList{}, &models.AccessListUser{}, &models.PageTemplate{}, &models.Setting{}))
 	return db
 }
 
@@ -138,3 +150,525 @@ func TestProxyHostService_CRUD(t *testing.T) {
 	_, err = service.GetByID(host.ID)
 	assert.Error(t, err)
 }
+
+type recordingNotifier struct {
+	reasons []string
+}
+
+func (n *recordingNotifier) Notify(reason string) {
+	n.reasons = append(n.reasons, reason)
+}
+
+func TestProxyHostService_NotifiesOnWrite(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-1",
+		DomainNames: "notify.example.com",
+		ForwardHost: "127.0.0.1",
+		ForwardPort: 8080,
+	}
+	require.NoError(t, service.Create(host))
+
+	// Rejected writes must not trigger an apply
+	dup := &models.ProxyHost{UUID: "uuid-2", DomainNames: "notify.example.com", ForwardHost: "x", ForwardPort: 1}
+	require.Error(t, service.Create(dup))
+
+	host.ForwardPort = 9090
+	require.NoError(t, service.Update(host))
+	require.NoError(t, service.Delete(host.ID))
+
+	assert.Equal(t, []string{
+		"proxy host created: notify.example.com",
+		"proxy host updated: notify.example.com",
+		fmt.Sprintf("proxy host deleted: %d", host.ID),
+	}, notifier.reasons)
+}
+
+func TestProxyHostService_Upstreams(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-lb",
+		DomainNames: "lb.example.com",
+		Upstreams: []models.Upstream{
+			{Host: "app1", Port: 8080, Weight: 2},
+			{Host: "app2", Port: 8080},
+		},
+		LoadBalancing: "least_conn",
+	}
+	require.NoError(t, service.Create(host))
+
+	fetched, err := service.GetByUUID("uuid-lb")
+	require.NoError(t, err)
+	assert.Equal(t, "app1", fetched.ForwardHost)
+	assert.Equal(t, 8080, fetched.ForwardPort)
+	assert.Equal(t, host.Upstreams, fetched.Upstreams)
+	assert.Equal(t, "least_conn", fetched.LoadBalancing)
+
+	fetched.LoadBalancing = "random"
+	assert.ErrorContains(t, service.Update(fetched), "unsupported load balancing policy")
+
+	fetched.LoadBalancing = ""
+	fetched.Upstreams = []models.Upstream{{Host: "app1", Port: 70000}}
+	assert.ErrorContains(t, service.Update(fetched), "out of range")
+}
+
+func TestProxyHostService_HealthCheck(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-hc",
+		DomainNames: "hc.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		HealthCheck: models.HealthCheckConfig{
+			ActiveEnabled:   true,
+			ActivePath:      "/healthz",
+			PassiveEnabled:  true,
+			UnhealthyStatus: []int{502},
+		},
+	}
+	require.NoError(t, service.Create(host))
+
+	fetched, err := service.GetByUUID("uuid-hc")
+	require.NoError(t, err)
+	assert.Equal(t, host.HealthCheck, fetched.HealthCheck)
+
+	fetched.HealthCheck.ActivePath = "healthz"
+	assert.ErrorContains(t, service.Update(fetched), "must start with /")
+
+	fetched.HealthCheck.ActivePath = "/healthz"
+	fetched.HealthCheck.UnhealthyStatus = []int{999}
+	assert.ErrorContains(t, service.Update(fetched), "invalid unhealthy status")
+}
+
+func TestProxyHostService_UpstreamTLS(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	service.SetCipher(cipher)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cpm-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	tests := []struct {
+		name    string
+		scheme  string
+		tls     models.UpstreamTLSConfig
+		wantErr string
+	}{
+		{name: "https with full options", scheme: "https", tls: models.UpstreamTLSConfig{TrustedCAPEM: certPEM, ClientCertPEM: certPEM, ClientKeyPEM: keyPEM}},
+		{name: "unknown scheme", scheme: "ftp", wantErr: "unsupported forward scheme"},
+		{name: "bad CA", scheme: "https", tls: models.UpstreamTLSConfig{TrustedCAPEM: "garbage"}, wantErr: "no certificates"},
+		{name: "cert without key", scheme: "https", tls: models.UpstreamTLSConfig{ClientCertPEM: certPEM}, wantErr: "provided together"},
+		{name: "mismatched key", scheme: "https", tls: models.UpstreamTLSConfig{ClientCertPEM: certPEM, ClientKeyPEM: "garbage"}, wantErr: "invalid client certificate"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := &models.ProxyHost{
+				UUID:          fmt.Sprintf("tls-%d", i),
+				DomainNames:   fmt.Sprintf("tls%d.example.com", i),
+				ForwardScheme: tt.scheme,
+				ForwardHost:   "10.0.0.5",
+				ForwardPort:   8006,
+				UpstreamTLS:   tt.tls,
+			}
+			err := service.Create(host)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	// The client key is stored encrypted and kept across updates that don't resend it
+	stored, err := service.GetByUUID("tls-0")
+	require.NoError(t, err)
+	require.True(t, secrets.IsEncrypted(stored.UpstreamTLS.ClientKeyPEM))
+	stored.UpstreamTLS.ServerName = "pve.internal"
+	require.NoError(t, service.Update(stored))
+	plain, err := cipher.Decrypt(stored.UpstreamTLS.ClientKeyPEM)
+	require.NoError(t, err)
+	require.Equal(t, keyPEM, plain)
+
+	// Without an encryption key the client key cannot be stored
+	service.SetCipher(nil)
+	host := &models.ProxyHost{UUID: "tls-plain", DomainNames: "plain.example.com", ForwardScheme: "https", ForwardHost: "10.0.0.5", ForwardPort: 8006,
+		UpstreamTLS: models.UpstreamTLSConfig{ClientCertPEM: certPEM, ClientKeyPEM: keyPEM}}
+	assert.ErrorContains(t, service.Create(host), "require an encryption key")
+}
+
+func TestProxyHostService_HTTPSMode(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	legacy := &models.ProxyHost{UUID: "legacy", DomainNames: "legacy.example.com", ForwardHost: "a", ForwardPort: 80, SSLForced: true}
+	require.NoError(t, service.Create(legacy))
+	assert.Equal(t, models.HTTPSModeRedirect, legacy.HTTPSMode)
+
+	plain := &models.ProxyHost{UUID: "plain", DomainNames: "plain.example.com", ForwardHost: "b", ForwardPort: 80}
+	require.NoError(t, service.Create(plain))
+	assert.Equal(t, models.HTTPSModeHTTPS, plain.HTTPSMode)
+
+	// The mode wins over a stale SSLForced flag
+	lan := &models.ProxyHost{UUID: "lan", DomainNames: "nas.lan", ForwardHost: "c", ForwardPort: 80, SSLForced: true, HTTPSMode: models.HTTPSModeHTTPOnly}
+	require.NoError(t, service.Create(lan))
+	assert.False(t, lan.SSLForced)
+
+	bad := &models.ProxyHost{UUID: "bad", DomainNames: "bad.example.com", ForwardHost: "d", ForwardPort: 80, HTTPSMode: "tls_only"}
+	assert.ErrorContains(t, service.Create(bad), "unsupported https mode")
+}
+
+func TestProxyHostService_CustomCertificate(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	certs := NewSSLCertificateService(db, newTestCipher(t))
+
+	leaf, _, key := issueTestCertificate(t, time.Now().Add(24*time.Hour), "*.example.com")
+	cert := &models.SSLCertificate{UUID: "wild", Certificate: leaf}
+	require.NoError(t, certs.Create(cert, key))
+
+	host := &models.ProxyHost{UUID: "c1", DomainNames: "app.example.com, api.example.com", ForwardHost: "10.0.0.1", ForwardPort: 80, CertificateID: &cert.ID}
+	require.NoError(t, service.Create(host))
+
+	host.DomainNames = "app.example.com, example.com"
+	assert.ErrorContains(t, service.Update(host), "does not cover example.com")
+
+	missing := uint(999)
+	host.DomainNames = "app.example.com"
+	host.CertificateID = &missing
+	assert.ErrorContains(t, service.Update(host), "certificate 999 not found")
+}
+
+func TestProxyHostService_CertificateIssuance(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	require.NoError(t, db.Create(&models.Setting{Key: "caddy.dns_provider.cloudflare", Value: `{"api_token":"t"}`}).Error)
+
+	tests := []struct {
+		name    string
+		host    models.ProxyHost
+		wantErr string
+	}{
+		{name: "wildcard domain", host: models.ProxyHost{DomainNames: "*.example.com", DNSProvider: "cloudflare"}},
+		{name: "shared wildcard", host: models.ProxyHost{DomainNames: "app.example.com", DNSProvider: "cloudflare", WildcardCert: true}},
+		{name: "wildcard domain without provider", host: models.ProxyHost{DomainNames: "*.example.org"}, wantErr: "requires a dns provider"},
+		{name: "shared wildcard without provider", host: models.ProxyHost{DomainNames: "app.example.org", WildcardCert: true}, wantErr: "require a dns provider"},
+		{name: "unknown provider", host: models.ProxyHost{DomainNames: "a.example.org", DNSProvider: "namecheap"}, wantErr: "unsupported dns provider"},
+		{name: "provider without credentials", host: models.ProxyHost{DomainNames: "b.example.org", DNSProvider: "hetzner"}, wantErr: "no credentials configured"},
+		{name: "internal wildcard", host: models.ProxyHost{DomainNames: "*.home.lan", CertIssuer: "internal"}},
+		{name: "internal with provider", host: models.ProxyHost{DomainNames: "nas.home.lan", CertIssuer: "internal", DNSProvider: "cloudflare"}, wantErr: "does not use a dns provider"},
+		{name: "staging", host: models.ProxyHost{DomainNames: "test.example.com", CertIssuer: "letsencrypt_staging"}},
+		{name: "zerossl without eab", host: models.ProxyHost{DomainNames: "z.example.com", CertIssuer: "zerossl"}, wantErr: "requires eab credentials"},
+		{name: "unknown issuer", host: models.ProxyHost{DomainNames: "u.example.com", CertIssuer: "buypass"}, wantErr: "unsupported certificate issuer"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := tt.host
+			host.UUID = fmt.Sprintf("dns-%d", i)
+			host.ForwardHost = "10.0.0.1"
+			host.ForwardPort = 80
+			err := service.Create(&host)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateHeaderRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []models.HeaderRule
+		wantErr string
+	}{
+		{
+			name: "valid rules",
+			rules: []models.HeaderRule{
+				{Direction: "request", Operation: "set", Name: "X-Real-IP", Value: "{http.request.remote.host}"},
+				{Direction: "request", Operation: "add", Name: "X-Tag", Value: "a"},
+				{Direction: "request", Operation: "add", Name: "X-Tag", Value: "b"},
+				{Direction: "response", Operation: "delete", Name: "Server"},
+				{Direction: "response", Operation: "set", Name: "X-Literal", Value: `\{not a placeholder\}`},
+			},
+		},
+		{
+			name:    "unknown direction",
+			rules:   []models.HeaderRule{{Direction: "both", Operation: "set", Name: "X-A", Value: "1"}},
+			wantErr: "unsupported direction",
+		},
+		{
+			name:    "unknown operation",
+			rules:   []models.HeaderRule{{Direction: "request", Operation: "replace", Name: "X-A", Value: "1"}},
+			wantErr: "unsupported operation",
+		},
+		{
+			name:    "invalid name",
+			rules:   []models.HeaderRule{{Direction: "request", Operation: "set", Name: "X A", Value: "1"}},
+			wantErr: "invalid header name",
+		},
+		{
+			name:    "hop-by-hop header",
+			rules:   []models.HeaderRule{{Direction: "request", Operation: "delete", Name: "connection"}},
+			wantErr: "hop-by-hop",
+		},
+		{
+			name:    "set without value",
+			rules:   []models.HeaderRule{{Direction: "response", Operation: "set", Name: "X-A"}},
+			wantErr: "requires a value",
+		},
+		{
+			name:    "delete with value",
+			rules:   []models.HeaderRule{{Direction: "response", Operation: "delete", Name: "Server", Value: "x"}},
+			wantErr: "takes no value",
+		},
+		{
+			name:    "header injection",
+			rules:   []models.HeaderRule{{Direction: "response", Operation: "set", Name: "X-A", Value: "1\r\nSet-Cookie: a=b"}},
+			wantErr: "line break",
+		},
+		{
+			name:    "unterminated placeholder",
+			rules:   []models.HeaderRule{{Direction: "request", Operation: "set", Name: "X-A", Value: "{http.request.host"}},
+			wantErr: "unterminated placeholder",
+		},
+		{
+			name: "duplicate set",
+			rules: []models.HeaderRule{
+				{Direction: "request", Operation: "set", Name: "X-A", Value: "1"},
+				{Direction: "request", Operation: "set", Name: "x-a", Value: "2"},
+			},
+			wantErr: "duplicate request set rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHeaderRules(tt.rules)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestProxyHostService_Limits(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-limits",
+		DomainNames: "limits.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		RateLimits: []models.RateLimitRule{
+			{Requests: 100, Window: 60},
+			{Key: models.RateLimitKeyHeader, Header: "x-api-key", Requests: 10, Window: 1},
+		},
+		MaxBodySize:   10 << 20,
+		LimitResponse: models.LimitResponse{StatusCode: 503, Body: "busy"},
+		Locations: []models.Location{
+			{UUID: "loc-login", Path: "/login", ForwardHost: "app", ForwardPort: 8080, RateLimits: []models.RateLimitRule{{Requests: 5, Window: 60}}},
+		},
+	}
+	require.NoError(t, service.Create(host))
+
+	fetched, err := service.GetByUUID("uuid-limits")
+	require.NoError(t, err)
+	assert.Equal(t, models.RateLimitKeyIP, fetched.RateLimits[0].Key)
+	assert.Equal(t, "X-Api-Key", fetched.RateLimits[1].Header)
+	assert.Equal(t, host.LimitResponse, fetched.LimitResponse)
+	assert.EqualValues(t, 10<<20, fetched.MaxBodySize)
+
+	tests := []struct {
+		name    string
+		change  func(h *models.ProxyHost)
+		wantErr string
+	}{
+		{"unknown key", func(h *models.ProxyHost) {
+			h.RateLimits = []models.RateLimitRule{{Key: "cookie", Requests: 1, Window: 1}}
+		}, "unsupported key"},
+		{"header key without header", func(h *models.ProxyHost) {
+			h.RateLimits = []models.RateLimitRule{{Key: models.RateLimitKeyHeader, Requests: 1, Window: 1}}
+		}, "invalid header name"},
+		{"header on ip key", func(h *models.ProxyHost) {
+			h.RateLimits = []models.RateLimitRule{{Header: "X-A", Requests: 1, Window: 1}}
+		}, "only used by the header key"},
+		{"no requests", func(h *models.ProxyHost) { h.RateLimits = []models.RateLimitRule{{Window: 60}} }, "at least 1"},
+		{"no window", func(h *models.ProxyHost) { h.RateLimits = []models.RateLimitRule{{Requests: 1}} }, "window must be"},
+		{"negative body size", func(h *models.ProxyHost) { h.MaxBodySize = -1 }, "must not be negative"},
+		{"success status", func(h *models.ProxyHost) { h.LimitResponse.StatusCode = 200 }, "4xx or 5xx"},
+		{"location rule", func(h *models.ProxyHost) { h.Locations[0].RateLimits[0].Window = 0 }, "location /login: rate limit 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := service.GetByUUID("uuid-limits")
+			require.NoError(t, err)
+			tt.change(h)
+			assert.ErrorContains(t, service.Update(h), tt.wantErr)
+		})
+	}
+}
+
+func TestProxyHostService_RateLimitsNeedModule(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	service.SetAdapter(nil, []string{"http.handlers.reverse_proxy"})
+	locations := NewLocationService(db)
+	locations.SetModules([]string{"http.handlers.reverse_proxy"})
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-no-ratelimit",
+		DomainNames: "limits.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		RateLimits:  []models.RateLimitRule{{Requests: 100, Window: 60}},
+	}
+	assert.ErrorContains(t, service.Create(host), "rate limits need http.handlers.rate_limit")
+
+	host.RateLimits = nil
+	host.Locations = []models.Location{{UUID: "loc-login", Path: "/login", ForwardHost: "app", ForwardPort: 8080, RateLimits: []models.RateLimitRule{{Requests: 5, Window: 60}}}}
+	assert.ErrorContains(t, service.Create(host), "location /login: rate limits need")
+
+	host.Locations = nil
+	require.NoError(t, service.Create(host))
+	loc := &models.Location{UUID: "loc-api", Path: "/api", ForwardHost: "app", ForwardPort: 8080, RateLimits: []models.RateLimitRule{{Requests: 5, Window: 60}}}
+	assert.ErrorContains(t, locations.Create(host, loc), "location /api: rate limits need")
+
+	// With the module in the build the same rules are accepted
+	locations.SetModules([]string{"http.handlers.reverse_proxy", caddy.RateLimitModule})
+	require.NoError(t, locations.Create(host, loc))
+}
+
+func TestProxyHostService_Pages(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	template := &models.PageTemplate{UUID: "tpl", Name: "Upgrade", Content: "<h1>Back soon</h1>"}
+	require.NoError(t, db.Create(template).Error)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-pages",
+		DomainNames: "pages.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Maintenance: models.MaintenanceConfig{
+			Enabled:      true,
+			TemplateID:   &template.ID,
+			BypassIPs:    []string{"192.168.1.7/24", "2001:db8::1"},
+			BypassCookie: "let-me-in-please-1",
+		},
+		ErrorPages: []models.ErrorPage{{Status: "5XX", TemplateID: template.ID}, {Status: "404", TemplateID: template.ID}},
+	}
+	require.NoError(t, service.Create(host))
+
+	fetched, err := service.GetByUUID("uuid-pages")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.0/24", "2001:db8::1"}, fetched.Maintenance.BypassIPs)
+	assert.Equal(t, "5xx", fetched.ErrorPages[0].Status)
+
+	missing := uint(99)
+	tests := []struct {
+		name    string
+		change  func(h *models.ProxyHost)
+		wantErr string
+	}{
+		{"bad bypass ip", func(h *models.ProxyHost) { h.Maintenance.BypassIPs = []string{"office"} }, "invalid network"},
+		{"short cookie", func(h *models.ProxyHost) { h.Maintenance.BypassCookie = "secret" }, "16 to 128"},
+		{"cookie separator", func(h *models.ProxyHost) { h.Maintenance.BypassCookie = "let-me-in-please;1" }, "16 to 128"},
+		{"unknown maintenance page", func(h *models.ProxyHost) { h.Maintenance.TemplateID = &missing }, "page template 99 not found"},
+		{"success status", func(h *models.ProxyHost) { h.ErrorPages[1].Status = "200" }, "4xx or 5xx"},
+		{"duplicate status", func(h *models.ProxyHost) { h.ErrorPages[1].Status = "5xx" }, "duplicate error page for 5xx"},
+		{"unknown error page", func(h *models.ProxyHost) { h.ErrorPages[1].TemplateID = missing }, "page template 99 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := service.GetByUUID("uuid-pages")
+			require.NoError(t, err)
+			tt.change(h)
+			assert.ErrorContains(t, service.Update(h), tt.wantErr)
+		})
+	}
+}
+
+// fakeAdapter stands in for the Caddy binary when checking advanced configs.
+type fakeAdapter struct {
+	routes json.RawMessage
+	err    error
+}
+
+func (a *fakeAdapter) AdaptSnippet(snippet string) (json.RawMessage, error) {
+	return a.routes, a.err
+}
+
+func (a *fakeAdapter) ValidateRoutes(routes json.RawMessage) error {
+	return a.err
+}
+
+func TestProxyHostService_Advanced(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{
+		UUID:        "uuid-advanced",
+		DomainNames: "advanced.example.com",
+		ForwardHost: "app",
+		ForwardPort: 8080,
+		Advanced:    models.AdvancedConfig{Content: `{"handler": "static_response", "body": "pong"}`},
+	}
+	require.NoError(t, service.Create(host))
+
+	fetched, err := service.GetByUUID("uuid-advanced")
+	require.NoError(t, err)
+	assert.Equal(t, models.AdvancedFormatJSON, fetched.Advanced.Format)
+	assert.JSONEq(t, `[{"handle": [{"handler": "static_response", "body": "pong"}]}]`, fetched.Advanced.Routes)
+
+	// Caddyfile snippets need the binary
+	fetched.Advanced = models.AdvancedConfig{Format: models.AdvancedFormatCaddyfile, Content: "respond 204"}
+	assert.ErrorContains(t, service.Update(fetched), "advanced: caddyfile snippets need the caddy binary")
+
+	adapter := &fakeAdapter{routes: json.RawMessage(`[{"handle": [{"handler": "static_response", "status_code": 204}]}]`)}
+	service.SetAdapter(adapter, nil)
+	require.NoError(t, service.Update(fetched))
+	assert.JSONEq(t, string(adapter.routes), fetched.Advanced.Routes)
+
+	adapter.err = errors.New("caddyfile: line 2: unrecognized directive: foo")
+	fetched.Advanced.Content = "respond 204\nfoo"
+	assert.EqualError(t, service.Update(fetched), "advanced: caddyfile: line 2: unrecognized directive: foo")
+
+	// Clearing the content drops the compiled routes
+	fetched.Advanced = models.AdvancedConfig{Format: models.AdvancedFormatCaddyfile, Routes: string(adapter.routes)}
+	require.NoError(t, service.Update(fetched))
+	fetched, err = service.GetByUUID("uuid-advanced")
+	require.NoError(t, err)
+	assert.Equal(t, models.AdvancedConfig{}, fetched.Advanced)
+
+	fetched.Advanced = models.AdvancedConfig{Format: "nginx", Content: "return 204;"}
+	assert.ErrorContains(t, service.Update(fetched), "unsupported advanced format: nginx")
+}
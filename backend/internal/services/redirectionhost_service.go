@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// redirectStatusCodes are the redirect codes a redirection host may answer with.
+var redirectStatusCodes = []int{301, 302, 307, 308}
+
+// RedirectionHostService encapsulates business logic for redirection host management.
+type RedirectionHostService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+}
+
+// NewRedirectionHostService creates a new redirection host service.
+func NewRedirectionHostService(db *gorm.DB) *RedirectionHostService {
+	return &RedirectionHostService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *RedirectionHostService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *RedirectionHostService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validate normalizes defaults and rejects redirects Caddy can't serve.
+func (s *RedirectionHostService) validate(host *models.RedirectionHost) error {
+	if host.ForwardScheme == "" {
+		host.ForwardScheme = "auto"
+	}
+	if host.StatusCode == 0 {
+		host.StatusCode = 301
+	}
+
+	if !slices.Contains([]string{"auto", "http", "https"}, host.ForwardScheme) {
+		return fmt.Errorf("unsupported forward scheme: %s", host.ForwardScheme)
+	}
+	if !slices.Contains(redirectStatusCodes, host.StatusCode) {
+		return fmt.Errorf("unsupported redirect status code: %d", host.StatusCode)
+	}
+
+	host.ForwardDomain = strings.TrimSpace(host.ForwardDomain)
+	if host.ForwardDomain == "" {
+		return fmt.Errorf("forward domain is required")
+	}
+	if strings.Contains(host.ForwardDomain, "://") || strings.ContainsAny(host.ForwardDomain, "/?# ") {
+		return fmt.Errorf("forward domain must be a bare host name, got %q", host.ForwardDomain)
+	}
+
+	return nil
+}
+
+// Create validates and creates a new redirection host.
+func (s *RedirectionHostService) Create(host *models.RedirectionHost) error {
+	if err := checkDomainsAvailable(s.db, host.DomainNames, 0, 0); err != nil {
+		return err
+	}
+
+	if err := s.validate(host); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(host).Error; err != nil {
+		return err
+	}
+
+	s.notify("redirection host created: " + host.DomainNames)
+	return nil
+}
+
+// Update validates and updates an existing redirection host.
+func (s *RedirectionHostService) Update(host *models.RedirectionHost) error {
+	if err := checkDomainsAvailable(s.db, host.DomainNames, 0, host.ID); err != nil {
+		return err
+	}
+
+	if err := s.validate(host); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(host).Error; err != nil {
+		return err
+	}
+
+	s.notify("redirection host updated: " + host.DomainNames)
+	return nil
+}
+
+// Delete removes a redirection host.
+func (s *RedirectionHostService) Delete(id uint) error {
+	if err := s.db.Delete(&models.RedirectionHost{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("redirection host deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds a redirection host by UUID.
+func (s *RedirectionHostService) GetByUUID(uuid string) (*models.RedirectionHost, error) {
+	var host models.RedirectionHost
+	if err := s.db.Where("uuid = ?", uuid).First(&host).Error; err != nil {
+		return nil, err
+	}
+	return &host, nil
+}
+
+// List returns all redirection hosts.
+func (s *RedirectionHostService) List() ([]models.RedirectionHost, error) {
+	var hosts []models.RedirectionHost
+	if err := s.db.Order("updated_at desc").Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestRedirectionHostService_CRUD(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewRedirectionHostService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	host := &models.RedirectionHost{
+		UUID:          "redirect-uuid",
+		DomainNames:   "old.example.com",
+		ForwardDomain: "new.example.com",
+		Enabled:       true,
+	}
+	require.NoError(t, service.Create(host))
+	assert.Equal(t, "auto", host.ForwardScheme)
+	assert.Equal(t, 301, host.StatusCode)
+
+	fetched, err := service.GetByUUID("redirect-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "new.example.com", fetched.ForwardDomain)
+
+	fetched.StatusCode = 308
+	require.NoError(t, service.Update(fetched))
+
+	hosts, err := service.List()
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, 308, hosts[0].StatusCode)
+
+	require.NoError(t, service.Delete(fetched.ID))
+	_, err = service.GetByUUID("redirect-uuid")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		"redirection host created: old.example.com",
+		"redirection host updated: old.example.com",
+		"redirection host deleted: 1",
+	}, notifier.reasons)
+}
+
+func TestRedirectionHostService_Validation(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewRedirectionHostService(db)
+
+	tests := []struct {
+		name string
+		host models.RedirectionHost
+	}{
+		{"missing forward domain", models.RedirectionHost{DomainNames: "a.example.com"}},
+		{"forward domain with scheme", models.RedirectionHost{DomainNames: "b.example.com", ForwardDomain: "https://new.example.com"}},
+		{"forward domain with path", models.RedirectionHost{DomainNames: "c.example.com", ForwardDomain: "new.example.com/path"}},
+		{"unsupported status", models.RedirectionHost{DomainNames: "d.example.com", ForwardDomain: "new.example.com", StatusCode: 200}},
+		{"unsupported scheme", models.RedirectionHost{DomainNames: "e.example.com", ForwardDomain: "new.example.com", ForwardScheme: "ftp"}},
+		{"no domains", models.RedirectionHost{ForwardDomain: "new.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := tt.host
+			assert.Error(t, service.Create(&host))
+		})
+	}
+}
+
+func TestRedirectionHostService_DomainsSharedWithProxyHosts(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	proxyService := NewProxyHostService(db)
+	redirectService := NewRedirectionHostService(db)
+
+	require.NoError(t, proxyService.Create(&models.ProxyHost{
+		UUID:        "proxy-uuid",
+		DomainNames: "app.example.com",
+		ForwardHost: "127.0.0.1",
+		ForwardPort: 8080,
+	}))
+
+	err := redirectService.Create(&models.RedirectionHost{
+		UUID:          "redirect-uuid",
+		DomainNames:   "www.example.com, APP.example.com",
+		ForwardDomain: "example.com",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app.example.com")
+
+	redirect := &models.RedirectionHost{
+		UUID:          "redirect-uuid",
+		DomainNames:   "www.example.com",
+		ForwardDomain: "example.com",
+	}
+	require.NoError(t, redirectService.Create(redirect))
+
+	// The reverse direction is rejected too
+	err = proxyService.Create(&models.ProxyHost{
+		UUID:        "proxy-uuid-2",
+		DomainNames: "www.example.com",
+		ForwardHost: "127.0.0.1",
+		ForwardPort: 8081,
+	})
+	assert.Error(t, err)
+
+	// Updating a redirect in place does not conflict with itself
+	redirect.StatusCode = 302
+	assert.NoError(t, redirectService.Update(redirect))
+}
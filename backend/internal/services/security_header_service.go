@@ -0,0 +1,325 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/caddy"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// referrerPolicies are the values browsers accept for Referrer-Policy.
+var referrerPolicies = []string{
+	"no-referrer",
+	"no-referrer-when-downgrade",
+	"origin",
+	"origin-when-cross-origin",
+	"same-origin",
+	"strict-origin",
+	"strict-origin-when-cross-origin",
+	"unsafe-url",
+}
+
+// crossOriginOpenerPolicies are the values browsers accept for Cross-Origin-Opener-Policy.
+var crossOriginOpenerPolicies = []string{"same-origin", "same-origin-allow-popups", "unsafe-none"}
+
+// SecurityHeaderService manages security header profiles and grades the
+// response headers of proxy hosts.
+type SecurityHeaderService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+}
+
+// NewSecurityHeaderService creates a new security header service.
+func NewSecurityHeaderService(db *gorm.DB) *SecurityHeaderService {
+	return &SecurityHeaderService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *SecurityHeaderService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *SecurityHeaderService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validateProfile normalizes a profile, filling in preset values, and rejects
+// header values browsers would ignore.
+func (s *SecurityHeaderService) validateProfile(profile *models.SecurityHeaderProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		return errors.New("name is required")
+	}
+	var existing int64
+	if err := s.db.Model(&models.SecurityHeaderProfile{}).Where("name = ? AND id <> ?", profile.Name, profile.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return fmt.Errorf("security header profile %s already exists", profile.Name)
+	}
+
+	if profile.Preset == "" {
+		profile.Preset = models.SecurityPresetCustom
+	}
+	if !slices.Contains(models.SecurityPresets, profile.Preset) {
+		return fmt.Errorf("unsupported preset: %s", profile.Preset)
+	}
+	profile.ApplyPreset()
+
+	profile.XFrameOptions = strings.ToUpper(profile.XFrameOptions)
+	if profile.XFrameOptions != "" && profile.XFrameOptions != "DENY" && profile.XFrameOptions != "SAMEORIGIN" {
+		return fmt.Errorf("unsupported x_frame_options: %s", profile.XFrameOptions)
+	}
+	if profile.ReferrerPolicy != "" && !slices.Contains(referrerPolicies, profile.ReferrerPolicy) {
+		return fmt.Errorf("unsupported referrer_policy: %s", profile.ReferrerPolicy)
+	}
+	if profile.CrossOriginOpenerPolicy != "" && !slices.Contains(crossOriginOpenerPolicies, profile.CrossOriginOpenerPolicy) {
+		return fmt.Errorf("unsupported cross_origin_opener_policy: %s", profile.CrossOriginOpenerPolicy)
+	}
+	for name, value := range profile.Headers() {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s contains a line break", name)
+		}
+	}
+
+	return nil
+}
+
+// Create validates and stores a new profile.
+func (s *SecurityHeaderService) Create(profile *models.SecurityHeaderProfile) error {
+	if err := s.validateProfile(profile); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(profile).Error; err != nil {
+		return err
+	}
+
+	s.notify("security header profile created: " + profile.Name)
+	return nil
+}
+
+// Update validates and saves a profile; hosts using it pick up the change.
+func (s *SecurityHeaderService) Update(profile *models.SecurityHeaderProfile) error {
+	if err := s.validateProfile(profile); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(profile).Error; err != nil {
+		return err
+	}
+
+	s.notify("security header profile updated: " + profile.Name)
+	return nil
+}
+
+// Delete removes a profile that no proxy host uses.
+func (s *SecurityHeaderService) Delete(id uint) error {
+	var inUse int64
+	if err := s.db.Model(&models.ProxyHost{}).Where("security_header_profile_id = ?", id).Count(&inUse).Error; err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("security header profile is used by %d proxy host(s)", inUse)
+	}
+
+	if err := s.db.Delete(&models.SecurityHeaderProfile{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("security header profile deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds a profile by UUID.
+func (s *SecurityHeaderService) GetByUUID(uuid string) (*models.SecurityHeaderProfile, error) {
+	var profile models.SecurityHeaderProfile
+	if err := s.db.Where("uuid = ?", uuid).First(&profile).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// List returns all profiles ordered by name.
+func (s *SecurityHeaderService) List() ([]models.SecurityHeaderProfile, error) {
+	var profiles []models.SecurityHeaderProfile
+	if err := s.db.Order("name asc").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// SecurityHeaderCheck is one graded aspect of a host's response headers.
+type SecurityHeaderCheck struct {
+	Header    string `json:"header"`
+	Value     string `json:"value,omitempty"`
+	Points    int    `json:"points"`
+	MaxPoints int    `json:"max_points"`
+	Note      string `json:"note,omitempty"`
+}
+
+// SecurityHeaderReport grades the effective response headers of one proxy host.
+type SecurityHeaderReport struct {
+	HostUUID    string                `json:"host_uuid"`
+	DomainNames string                `json:"domain_names"`
+	Enabled     bool                  `json:"enabled"`
+	Profile     string                `json:"profile,omitempty"`
+	Score       int                   `json:"score"`
+	Grade       string                `json:"grade"`
+	Checks      []SecurityHeaderCheck `json:"checks"`
+}
+
+// hstsMinMaxAge is the HSTS max-age (six months) below which a host loses points.
+const hstsMinMaxAge = 15552000
+
+// ScoreSecurityHeaders grades response headers out of 100 points. A header
+// mapped to "" counts as removed.
+func ScoreSecurityHeaders(headers map[string]string) (int, []SecurityHeaderCheck) {
+	checks := make([]SecurityHeaderCheck, 0, 7)
+	check := func(header string, max int, points func(value string) (int, string)) {
+		value := headers[header]
+		c := SecurityHeaderCheck{Header: header, Value: value, MaxPoints: max}
+		if value == "" {
+			c.Note = "missing"
+		} else {
+			c.Points, c.Note = points(value)
+		}
+		checks = append(checks, c)
+	}
+
+	check("Strict-Transport-Security", 25, func(value string) (int, string) {
+		for _, directive := range strings.Split(value, ";") {
+			age, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age=")
+			if !ok {
+				continue
+			}
+			if seconds, err := strconv.Atoi(age); err == nil && seconds >= hstsMinMaxAge {
+				return 25, ""
+			}
+		}
+		return 10, "max-age is below six months"
+	})
+	check("Content-Security-Policy", 25, func(value string) (int, string) {
+		if strings.Contains(value, "'unsafe-inline'") || strings.Contains(value, "'unsafe-eval'") {
+			return 15, "allows unsafe-inline or unsafe-eval"
+		}
+		return 25, ""
+	})
+
+	// frame-ancestors supersedes X-Frame-Options in browsers that support it
+	frameAncestors := strings.Contains(headers["Content-Security-Policy"], "frame-ancestors")
+	framing := SecurityHeaderCheck{Header: "X-Frame-Options", Value: headers["X-Frame-Options"], MaxPoints: 15}
+	switch {
+	case frameAncestors:
+		framing.Points, framing.Note = 15, "covered by CSP frame-ancestors"
+	case framing.Value == "DENY" || framing.Value == "SAMEORIGIN":
+		framing.Points = 15
+	case framing.Value == "":
+		framing.Note = "missing"
+	default:
+		framing.Note = "unsupported value"
+	}
+	checks = append(checks, framing)
+
+	check("X-Content-Type-Options", 10, func(value string) (int, string) {
+		if value != "nosniff" {
+			return 0, "should be nosniff"
+		}
+		return 10, ""
+	})
+	check("Referrer-Policy", 10, func(value string) (int, string) {
+		if value == "unsafe-url" || value == "no-referrer-when-downgrade" {
+			return 0, "leaks full URLs to other origins"
+		}
+		return 10, ""
+	})
+	check("Permissions-Policy", 10, func(string) (int, string) {
+		return 10, ""
+	})
+
+	server := SecurityHeaderCheck{Header: "Server", MaxPoints: 5}
+	if value, ok := headers["Server"]; ok && value == "" {
+		server.Points, server.Note = 5, "removed"
+	} else {
+		server.Note = "upstream Server header is passed through"
+	}
+	checks = append(checks, server)
+
+	score := 0
+	for _, c := range checks {
+		score += c.Points
+	}
+	return score, checks
+}
+
+// securityGrade converts a score into a letter grade.
+func securityGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// Report grades the effective response headers of every proxy host, worst first.
+func (s *SecurityHeaderService) Report() ([]SecurityHeaderReport, error) {
+	var hosts []models.ProxyHost
+	if err := s.db.Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	profiles, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.SecurityHeaderProfile, len(profiles))
+	for i := range profiles {
+		byID[profiles[i].ID] = &profiles[i]
+	}
+
+	reports := make([]SecurityHeaderReport, 0, len(hosts))
+	for i := range hosts {
+		host := &hosts[i]
+		var profile *models.SecurityHeaderProfile
+		if host.SecurityHeaderProfileID != nil {
+			profile = byID[*host.SecurityHeaderProfileID]
+		}
+
+		score, checks := ScoreSecurityHeaders(caddy.EffectiveResponseHeaders(host, profile))
+		report := SecurityHeaderReport{
+			HostUUID:    host.UUID,
+			DomainNames: host.DomainNames,
+			Enabled:     host.Enabled,
+			Score:       score,
+			Grade:       securityGrade(score),
+			Checks:      checks,
+		}
+		if profile != nil {
+			report.Profile = profile.Name
+		}
+		reports = append(reports, report)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].Score != reports[j].Score {
+			return reports[i].Score < reports[j].Score
+		}
+		return reports[i].DomainNames < reports[j].DomainNames
+	})
+	return reports, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func TestSecurityHeaderService_Profiles(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewSecurityHeaderService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	// Presets overwrite any values sent along
+	strict := &models.SecurityHeaderProfile{UUID: "strict", Name: "Strict", Preset: models.SecurityPresetStrict, XFrameOptions: "SAMEORIGIN"}
+	require.NoError(t, service.Create(strict))
+	assert.Equal(t, "DENY", strict.XFrameOptions)
+	assert.True(t, strict.RemoveServerHeader)
+
+	custom := &models.SecurityHeaderProfile{UUID: "custom", Name: "Embeddable", XFrameOptions: "sameorigin", ReferrerPolicy: "origin"}
+	require.NoError(t, service.Create(custom))
+	assert.Equal(t, models.SecurityPresetCustom, custom.Preset)
+	assert.Equal(t, "SAMEORIGIN", custom.XFrameOptions)
+
+	invalid := []struct {
+		profile models.SecurityHeaderProfile
+		wantErr string
+	}{
+		{models.SecurityHeaderProfile{UUID: "a"}, "name is required"},
+		{models.SecurityHeaderProfile{UUID: "b", Name: "Strict"}, "already exists"},
+		{models.SecurityHeaderProfile{UUID: "c", Name: "C", Preset: "paranoid"}, "unsupported preset"},
+		{models.SecurityHeaderProfile{UUID: "d", Name: "D", XFrameOptions: "ALLOW-FROM https://a.example"}, "x_frame_options"},
+		{models.SecurityHeaderProfile{UUID: "e", Name: "E", ReferrerPolicy: "sometimes"}, "referrer_policy"},
+		{models.SecurityHeaderProfile{UUID: "f", Name: "F", ContentSecurityPolicy: "default-src 'self'\r\nX-Evil: 1"}, "line break"},
+	}
+	for _, tt := range invalid {
+		err := service.Create(&tt.profile)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tt.wantErr)
+	}
+
+	host := &models.ProxyHost{UUID: "host", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, SecurityHeaderProfileID: &strict.ID}
+	require.NoError(t, db.Create(host).Error)
+
+	err := service.Delete(strict.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "used by 1 proxy host(s)")
+	require.NoError(t, service.Delete(custom.ID))
+
+	profiles, err := service.List()
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "Strict", profiles[0].Name)
+
+	assert.Equal(t, []string{
+		"security header profile created: Strict",
+		"security header profile created: Embeddable",
+		"security header profile deleted: 2",
+	}, notifier.reasons)
+}
+
+func TestScoreSecurityHeaders(t *testing.T) {
+	score, checks := ScoreSecurityHeaders(map[string]string{})
+	assert.Equal(t, 0, score)
+	assert.Len(t, checks, 7)
+	assert.Equal(t, "F", securityGrade(score))
+
+	score, _ = ScoreSecurityHeaders(map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'self'; frame-ancestors 'none'",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "no-referrer",
+		"Permissions-Policy":        "camera=()",
+		"Server":                    "",
+	})
+	assert.Equal(t, 100, score)
+	assert.Equal(t, "A", securityGrade(score))
+
+	score, checks = ScoreSecurityHeaders(map[string]string{
+		"Strict-Transport-Security": "max-age=300",
+		"Content-Security-Policy":   "default-src 'self' 'unsafe-inline'",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"Referrer-Policy":           "unsafe-url",
+	})
+	assert.Equal(t, 10+15+15, score)
+	assert.Equal(t, "max-age is below six months", checks[0].Note)
+	assert.Equal(t, "allows unsafe-inline or unsafe-eval", checks[1].Note)
+	assert.Equal(t, "D", securityGrade(score))
+}
+
+func TestSecurityHeaderService_Report(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewSecurityHeaderService(db)
+
+	strict := &models.SecurityHeaderProfile{UUID: "strict", Name: "Strict", Preset: models.SecurityPresetStrict}
+	require.NoError(t, service.Create(strict))
+
+	hosts := []models.ProxyHost{
+		{UUID: "hardened", DomainNames: "hardened.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, HSTSEnabled: true, SecurityHeaderProfileID: &strict.ID},
+		{UUID: "bare", DomainNames: "bare.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true},
+	}
+	require.NoError(t, db.Create(&hosts).Error)
+
+	reports, err := service.Report()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	// Worst hosts come first
+	assert.Equal(t, "bare", reports[0].HostUUID)
+	assert.Equal(t, "F", reports[0].Grade)
+	assert.Equal(t, "hardened", reports[1].HostUUID)
+	assert.Equal(t, "Strict", reports[1].Profile)
+	assert.Equal(t, 100, reports[1].Score)
+}
+
+func TestProxyHostService_SecurityHeaderProfile(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	missing := uint(42)
+	host := &models.ProxyHost{UUID: "host", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, SecurityHeaderProfileID: &missing}
+	err := service.Create(host)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "security header profile 42 not found")
+
+	host.SecurityHeaderProfileID = nil
+	host.CSPOverride = "default-src 'self'\nX-Evil: 1"
+	err = service.Create(host)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "csp_override")
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+)
+
+// ProviderCustom marks certificates uploaded by the user.
+const ProviderCustom = "custom"
+
+// SSLCertificateService manages custom certificates stored in the database.
+type SSLCertificateService struct {
+	db       *gorm.DB
+	cipher   *secrets.Cipher
+	notifier ConfigNotifier
+}
+
+// NewSSLCertificateService creates a new certificate service. Private keys are
+// encrypted with cipher before they are stored.
+func NewSSLCertificateService(db *gorm.DB, cipher *secrets.Cipher) *SSLCertificateService {
+	return &SSLCertificateService{db: db, cipher: cipher}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *SSLCertificateService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *SSLCertificateService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// prepare verifies that keyPEM belongs to the certificate, fills in the details
+// parsed from the leaf and encrypts the key.
+func (s *SSLCertificateService) prepare(cert *models.SSLCertificate, keyPEM string) error {
+	if s.cipher == nil {
+		return errors.New("certificate storage is not configured with an encryption key")
+	}
+
+	certs, err := parsePEMCertificates(cert.Certificate)
+	if err != nil {
+		return fmt.Errorf("certificate: %w", err)
+	}
+	leaf := certs[0]
+
+	// Intermediates pasted after the leaf are moved into the chain
+	chain := certs[1:]
+	if strings.TrimSpace(cert.Chain) != "" {
+		extra, err := parsePEMCertificates(cert.Chain)
+		if err != nil {
+			return fmt.Errorf("chain: %w", err)
+		}
+		chain = append(chain, extra...)
+	}
+
+	if _, err := tls.X509KeyPair(encodePEMCertificates(certs[:1]), []byte(keyPEM)); err != nil {
+		return fmt.Errorf("private key does not match certificate: %w", err)
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	domains := leaf.DNSNames
+	for _, ip := range leaf.IPAddresses {
+		domains = append(domains, ip.String())
+	}
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = []string{leaf.Subject.CommonName}
+	}
+	if len(domains) == 0 {
+		return errors.New("certificate has no subject alternative names")
+	}
+
+	encryptedKey, err := s.cipher.Encrypt(keyPEM)
+	if err != nil {
+		return fmt.Errorf("encrypt private key: %w", err)
+	}
+
+	expiresAt := leaf.NotAfter
+	cert.Provider = ProviderCustom
+	cert.Certificate = string(encodePEMCertificates(certs[:1]))
+	cert.Chain = string(encodePEMCertificates(chain))
+	cert.PrivateKey = encryptedKey
+	cert.Domains = strings.ToLower(strings.Join(domains, ","))
+	cert.Issuer = leaf.Issuer.CommonName
+	cert.ExpiresAt = &expiresAt
+	if cert.Name == "" {
+		cert.Name = domains[0]
+	}
+
+	return nil
+}
+
+// Create validates and stores an uploaded certificate.
+func (s *SSLCertificateService) Create(cert *models.SSLCertificate, keyPEM string) error {
+	if err := s.prepare(cert, keyPEM); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(cert).Error; err != nil {
+		return err
+	}
+
+	s.notify("certificate uploaded: " + cert.Name)
+	return nil
+}
+
+// Update replaces the certificate material, e.g. after a renewal. Hosts that
+// reference the certificate keep doing so.
+func (s *SSLCertificateService) Update(cert *models.SSLCertificate, keyPEM string) error {
+	if err := s.prepare(cert, keyPEM); err != nil {
+		return err
+	}
+
+	var hosts []models.ProxyHost
+	if err := s.db.Where("certificate_id = ?", cert.ID).Find(&hosts).Error; err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if err := checkCertificateCovers(cert, host.DomainNames); err != nil {
+			return fmt.Errorf("proxy host %s: %w", host.UUID, err)
+		}
+	}
+
+	if err := s.db.Save(cert).Error; err != nil {
+		return err
+	}
+
+	s.notify("certificate updated: " + cert.Name)
+	return nil
+}
+
+// Delete removes a certificate that no proxy host uses.
+func (s *SSLCertificateService) Delete(id uint) error {
+	var inUse int64
+	if err := s.db.Model(&models.ProxyHost{}).Where("certificate_id = ?", id).Count(&inUse).Error; err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("certificate is used by %d proxy host(s)", inUse)
+	}
+
+	if err := s.db.Delete(&models.SSLCertificate{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("certificate deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds a certificate by UUID.
+func (s *SSLCertificateService) GetByUUID(uuid string) (*models.SSLCertificate, error) {
+	var cert models.SSLCertificate
+	if err := s.db.Where("uuid = ?", uuid).First(&cert).Error; err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// List returns all custom certificates, soonest expiry first.
+func (s *SSLCertificateService) List() ([]models.SSLCertificate, error) {
+	var certs []models.SSLCertificate
+	if err := s.db.Where("provider = ?", ProviderCustom).Order("expires_at asc").Find(&certs).Error; err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// checkCertificateCovers ensures every domain of a host is valid for the certificate.
+func checkCertificateCovers(cert *models.SSLCertificate, domainNames string) error {
+	names := splitDomains(cert.Domains)
+	for _, domain := range splitDomains(domainNames) {
+		if !certificateNameMatches(names, domain) {
+			return fmt.Errorf("certificate %s does not cover %s", cert.Name, domain)
+		}
+	}
+	return nil
+}
+
+// certificateNameMatches applies TLS name matching: a wildcard covers exactly one label.
+func certificateNameMatches(names []string, domain string) bool {
+	for _, name := range names {
+		if name == domain {
+			return true
+		}
+		if rest, ok := strings.CutPrefix(name, "*."); ok {
+			if label, parent, found := strings.Cut(domain, "."); found && label != "" && parent == rest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parsePEMCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+func encodePEMCertificates(certs []*x509.Certificate) []byte {
+	var out []byte
+	for _, cert := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/secrets"
+)
+
+// issueTestCertificate returns a PEM leaf signed by a throwaway CA, the CA
+// certificate and the leaf's private key.
+func issueTestCertificate(t *testing.T, notAfter time.Time, names ...string) (leafPEM, caPEM, keyPEM string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CPM Test CA"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	leafPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	caPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return leafPEM, caPEM, keyPEM
+}
+
+func newTestCipher(t *testing.T) *secrets.Cipher {
+	t.Helper()
+	cipher, err := secrets.NewCipher(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	return cipher
+}
+
+func TestSSLCertificateService_Create(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	notifier := &recordingNotifier{}
+	service := NewSSLCertificateService(db, newTestCipher(t))
+	service.SetNotifier(notifier)
+
+	leaf, ca, key := issueTestCertificate(t, time.Now().Add(90*24*time.Hour), "example.com", "*.example.com")
+
+	// Intermediates pasted after the leaf end up in the chain
+	cert := &models.SSLCertificate{UUID: "cert-1", Certificate: leaf + ca}
+	require.NoError(t, service.Create(cert, key))
+
+	assert.Equal(t, ProviderCustom, cert.Provider)
+	assert.Equal(t, "example.com", cert.Name)
+	assert.Equal(t, "example.com,*.example.com", cert.Domains)
+	assert.Equal(t, "CPM Test CA", cert.Issuer)
+	assert.Equal(t, strings.TrimSpace(leaf), strings.TrimSpace(cert.Certificate))
+	assert.Equal(t, strings.TrimSpace(ca), strings.TrimSpace(cert.Chain))
+	require.NotNil(t, cert.ExpiresAt)
+	assert.Equal(t, []string{"certificate uploaded: example.com"}, notifier.reasons)
+
+	// The key is only stored encrypted
+	var stored models.SSLCertificate
+	require.NoError(t, db.First(&stored, cert.ID).Error)
+	assert.True(t, secrets.IsEncrypted(stored.PrivateKey))
+	assert.NotContains(t, stored.PrivateKey, "PRIVATE KEY")
+	decrypted, err := newTestCipher(t).Decrypt(stored.PrivateKey)
+	require.NoError(t, err)
+	assert.Equal(t, key, decrypted)
+}
+
+func TestSSLCertificateService_Rejects(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewSSLCertificateService(db, newTestCipher(t))
+
+	leaf, _, key := issueTestCertificate(t, time.Now().Add(24*time.Hour), "example.com")
+	_, _, otherKey := issueTestCertificate(t, time.Now().Add(24*time.Hour), "other.com")
+	expired, _, expiredKey := issueTestCertificate(t, time.Now().Add(-time.Hour), "old.example.com")
+
+	tests := []struct {
+		name    string
+		cert    string
+		chain   string
+		key     string
+		wantErr string
+	}{
+		{name: "not PEM", cert: "garbage", key: key, wantErr: "no PEM certificate"},
+		{name: "mismatched key", cert: leaf, key: otherKey, wantErr: "does not match"},
+		{name: "expired", cert: expired, key: expiredKey, wantErr: "expired"},
+		{name: "bad chain", cert: leaf, chain: "garbage", key: key, wantErr: "chain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.Create(&models.SSLCertificate{UUID: tt.name, Certificate: tt.cert, Chain: tt.chain}, tt.key)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	noCipher := NewSSLCertificateService(db, nil)
+	assert.ErrorContains(t, noCipher.Create(&models.SSLCertificate{UUID: "x", Certificate: leaf}, key), "encryption key")
+}
+
+func TestSSLCertificateService_UpdateAndDelete(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewSSLCertificateService(db, newTestCipher(t))
+	hosts := NewProxyHostService(db)
+
+	leaf, _, key := issueTestCertificate(t, time.Now().Add(24*time.Hour), "*.example.com")
+	cert := &models.SSLCertificate{UUID: "wild", Name: "wildcard", Certificate: leaf}
+	require.NoError(t, service.Create(cert, key))
+
+	host := &models.ProxyHost{UUID: "h1", DomainNames: "app.example.com", ForwardHost: "10.0.0.1", ForwardPort: 80, CertificateID: &cert.ID}
+	require.NoError(t, hosts.Create(host))
+
+	// A renewal that still covers the host keeps the UUID and ID
+	renewed, _, renewedKey := issueTestCertificate(t, time.Now().Add(90*24*time.Hour), "*.example.com", "example.com")
+	cert.Certificate = renewed
+	require.NoError(t, service.Update(cert, renewedKey))
+	fetched, err := service.GetByUUID("wild")
+	require.NoError(t, err)
+	assert.Equal(t, "*.example.com,example.com", fetched.Domains)
+
+	// A replacement that no longer covers the host is rejected
+	other, _, otherKey := issueTestCertificate(t, time.Now().Add(24*time.Hour), "other.com")
+	fetched.Certificate = other
+	assert.ErrorContains(t, service.Update(fetched, otherKey), "does not cover app.example.com")
+
+	assert.ErrorContains(t, service.Delete(cert.ID), "used by 1 proxy host")
+	require.NoError(t, hosts.Delete(host.ID))
+	require.NoError(t, service.Delete(cert.ID))
+
+	list, err := service.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestCertificateNameMatches(t *testing.T) {
+	names := []string{"example.com", "*.example.com"}
+
+	assert.True(t, certificateNameMatches(names, "example.com"))
+	assert.True(t, certificateNameMatches(names, "app.example.com"))
+	assert.False(t, certificateNameMatches(names, "a.b.example.com"))
+	assert.False(t, certificateNameMatches(names, "example.org"))
+}
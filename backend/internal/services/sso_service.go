@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// Single sign-on settings. CPM's login sets the session cookie for the cookie
+// domain, so CPM itself must be served below it.
+const (
+	SSOCookieDomainSettingKey = "sso.cookie_domain" // Parent domain of CPM and the protected hosts, e.g. example.com
+	SSOLoginURLSettingKey     = "sso.login_url"     // CPM login page clients are sent to, e.g. https://cpm.example.com/login
+)
+
+// SSOCookieName is the cookie holding the single sign-on session.
+const SSOCookieName = "cpm_sso"
+
+var (
+	// ErrSSOUnauthenticated means the request carries no valid session.
+	ErrSSOUnauthenticated = errors.New("not signed in")
+	// ErrSSOForbidden means the signed-in user may not use the host.
+	ErrSSOForbidden = errors.New("user is not allowed by the access list")
+)
+
+// SSOService lets CPM accounts sign in to proxied apps through forward auth.
+type SSOService struct {
+	db   *gorm.DB
+	auth *AuthService
+}
+
+// NewSSOService creates a new single sign-on service.
+func NewSSOService(db *gorm.DB, auth *AuthService) *SSOService {
+	return &SSOService{db: db, auth: auth}
+}
+
+// ParseSSOCookieDomain normalizes the cookie domain setting.
+func ParseSSOCookieDomain(raw string) (string, error) {
+	domain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(raw), "."))
+	if domain == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(domain, ":/ ") || !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("invalid cookie domain %q (expected a domain such as example.com)", raw)
+	}
+	return domain, nil
+}
+
+// ParseSSOLoginURL checks the login URL setting.
+func ParseSSOLoginURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid login url %q", raw)
+	}
+	return raw, nil
+}
+
+func (s *SSOService) setting(key string) string {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+// CookieDomain returns the configured cookie domain; empty disables single sign-on.
+func (s *SSOService) CookieDomain() string {
+	domain, err := ParseSSOCookieDomain(s.setting(SSOCookieDomainSettingKey))
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// SecureCookie reports whether the session cookie should only be sent over HTTPS.
+func (s *SSOService) SecureCookie() bool {
+	return !strings.HasPrefix(s.setting(SSOLoginURLSettingKey), "http://")
+}
+
+// SessionToken exchanges a freshly issued API token for a single sign-on token
+// of the same user.
+func (s *SSOService) SessionToken(apiToken string) (string, error) {
+	claims, err := s.auth.ValidateToken(apiToken)
+	if err != nil {
+		return "", err
+	}
+	var user models.User
+	if err := s.db.First(&user, claims.UserID).Error; err != nil {
+		return "", errors.New("user not found")
+	}
+	return s.auth.GenerateSSOToken(&user)
+}
+
+// AllowedRedirect reports whether target is a URL below the cookie domain, so a
+// login can return to it without becoming an open redirect.
+func (s *SSOService) AllowedRedirect(target string) bool {
+	domain := s.CookieDomain()
+	u, err := url.Parse(target)
+	if domain == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// LoginRedirect returns the login page URL that sends the client back to target
+// afterwards, or "" when no login page is configured.
+func (s *SSOService) LoginRedirect(target string) string {
+	loginURL, err := ParseSSOLoginURL(s.setting(SSOLoginURLSettingKey))
+	if err != nil || loginURL == "" {
+		return ""
+	}
+	u, _ := url.Parse(loginURL)
+	if s.AllowedRedirect(target) {
+		query := u.Query()
+		query.Set("rd", target)
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// Verify resolves the user of a session token and checks them against the sso
+// access list listUUID; an empty listUUID admits every enabled user.
+func (s *SSOService) Verify(token, listUUID string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrSSOUnauthenticated
+	}
+	claims, err := s.auth.ValidateSSOToken(token)
+	if err != nil {
+		return nil, ErrSSOUnauthenticated
+	}
+	// Disabled or deleted accounts lose access before their token expires
+	var user models.User
+	if err := s.db.First(&user, claims.UserID).Error; err != nil || !user.Enabled {
+		return nil, ErrSSOUnauthenticated
+	}
+
+	if listUUID == "" {
+		return &user, nil
+	}
+	var list models.AccessList
+	if err := s.db.Where("uuid = ? AND type = ?", listUUID, models.AccessListSSO).First(&list).Error; err != nil {
+		return nil, ErrSSOForbidden
+	}
+	if len(list.AllowedUsers) == 0 && len(list.AllowedRoles) == 0 {
+		return &user, nil
+	}
+	if slices.Contains(list.AllowedUsers, user.UUID) || slices.Contains(list.AllowedRoles, user.Role) {
+		return &user, nil
+	}
+	return nil, ErrSSOForbidden
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/config"
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func setupSSOService(t *testing.T) (*SSOService, *AuthService) {
+	db := setupAuthTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.AccessList{}, &models.Setting{}))
+	auth := NewAuthService(db, config.Config{JWTSecret: "test-secret"})
+	return NewSSOService(db, auth), auth
+}
+
+func TestParseSSOSettings(t *testing.T) {
+	domain, err := ParseSSOCookieDomain(" .Example.com ")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", domain)
+	for _, raw := range []string{"https://example.com", "localhost", "example.com:443"} {
+		_, err := ParseSSOCookieDomain(raw)
+		assert.Error(t, err, raw)
+	}
+
+	_, err = ParseSSOLoginURL("https://cpm.example.com/login")
+	require.NoError(t, err)
+	_, err = ParseSSOLoginURL("cpm.example.com/login")
+	require.Error(t, err)
+}
+
+func TestSSOService_Verify(t *testing.T) {
+	service, auth := setupSSOService(t)
+	db := service.db
+
+	admin, err := auth.Register("admin@example.com", "password123", "Admin")
+	require.NoError(t, err)
+	viewer, err := auth.Register("viewer@example.com", "password123", "Viewer")
+	require.NoError(t, err)
+
+	apiToken, err := auth.GenerateToken(admin)
+	require.NoError(t, err)
+	_, err = service.Verify(apiToken, "")
+	require.ErrorIs(t, err, ErrSSOUnauthenticated, "API tokens are not sessions")
+	_, err = service.Verify("", "")
+	require.ErrorIs(t, err, ErrSSOUnauthenticated)
+
+	adminSession, err := service.SessionToken(apiToken)
+	require.NoError(t, err)
+	user, err := service.Verify(adminSession, "")
+	require.NoError(t, err)
+	assert.Equal(t, admin.ID, user.ID)
+
+	viewerSession, err := auth.GenerateSSOToken(viewer)
+	require.NoError(t, err)
+
+	lists := NewAccessListService(db)
+	admins := &models.AccessList{UUID: "admins", Name: "Admins", Type: models.AccessListSSO, AllowedRoles: []string{"admin"}}
+	require.NoError(t, lists.Create(admins))
+	named := &models.AccessList{UUID: "named", Name: "Named", Type: models.AccessListSSO, AllowedUsers: []string{viewer.UUID}}
+	require.NoError(t, lists.Create(named))
+	everyone := &models.AccessList{UUID: "everyone", Name: "Everyone", Type: models.AccessListSSO}
+	require.NoError(t, lists.Create(everyone))
+
+	_, err = service.Verify(adminSession, "admins")
+	require.NoError(t, err)
+	_, err = service.Verify(viewerSession, "admins")
+	require.ErrorIs(t, err, ErrSSOForbidden)
+	_, err = service.Verify(viewerSession, "named")
+	require.NoError(t, err)
+	_, err = service.Verify(adminSession, "named")
+	require.ErrorIs(t, err, ErrSSOForbidden)
+	_, err = service.Verify(viewerSession, "everyone")
+	require.NoError(t, err)
+	_, err = service.Verify(viewerSession, "missing")
+	require.ErrorIs(t, err, ErrSSOForbidden)
+
+	require.NoError(t, db.Model(viewer).Update("enabled", false).Error)
+	_, err = service.Verify(viewerSession, "everyone")
+	require.ErrorIs(t, err, ErrSSOUnauthenticated)
+
+	err = lists.Create(&models.AccessList{UUID: "ghost", Name: "Ghost", Type: models.AccessListSSO, AllowedUsers: []string{"nobody"}})
+	require.ErrorContains(t, err, "user nobody not found")
+	err = lists.Create(&models.AccessList{UUID: "root", Name: "Root", Type: models.AccessListSSO, AllowedRoles: []string{"root"}})
+	require.ErrorContains(t, err, "unknown role: root")
+}
+
+func TestSSOService_LoginRedirect(t *testing.T) {
+	service, _ := setupSSOService(t)
+	assert.Empty(t, service.CookieDomain())
+	assert.Empty(t, service.LoginRedirect("https://app.example.com/"))
+
+	require.NoError(t, service.db.Create(&models.Setting{Key: SSOCookieDomainSettingKey, Value: "example.com"}).Error)
+	require.NoError(t, service.db.Create(&models.Setting{Key: SSOLoginURLSettingKey, Value: "https://cpm.example.com/login"}).Error)
+
+	assert.True(t, service.AllowedRedirect("https://app.example.com/page"))
+	assert.True(t, service.AllowedRedirect("https://example.com/"))
+	assert.False(t, service.AllowedRedirect("https://example.com.evil.net/"))
+	assert.False(t, service.AllowedRedirect("javascript:alert(1)"))
+	assert.True(t, service.SecureCookie())
+
+	assert.Equal(t, "https://cpm.example.com/login?rd=https%3A%2F%2Fapp.example.com%2Fpage%3Fid%3D1", service.LoginRedirect("https://app.example.com/page?id=1"))
+	assert.Equal(t, "https://cpm.example.com/login", service.LoginRedirect("https://evil.net/"))
+}
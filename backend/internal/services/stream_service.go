@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+// reservedStreamPorts are served by the HTTP server and can't be claimed by TCP streams.
+var reservedStreamPorts = map[string][]int{
+	models.StreamProtocolTCP: {80, 443},
+	models.StreamProtocolUDP: {443}, // HTTP/3
+}
+
+// StreamService encapsulates business logic for TCP/UDP stream management.
+type StreamService struct {
+	db       *gorm.DB
+	notifier ConfigNotifier
+}
+
+// NewStreamService creates a new stream service.
+func NewStreamService(db *gorm.DB) *StreamService {
+	return &StreamService{db: db}
+}
+
+// SetNotifier registers the notifier informed after every successful write.
+func (s *StreamService) SetNotifier(notifier ConfigNotifier) {
+	s.notifier = notifier
+}
+
+func (s *StreamService) notify(reason string) {
+	if s.notifier != nil {
+		s.notifier.Notify(reason)
+	}
+}
+
+// validate normalizes defaults and checks the stream in isolation.
+func (s *StreamService) validate(stream *models.Stream) error {
+	if stream.Protocol == "" {
+		stream.Protocol = models.StreamProtocolTCP
+	}
+	if stream.LoadBalancing == "" {
+		stream.LoadBalancing = models.LoadBalancingRoundRobin
+	}
+
+	if stream.Protocol != models.StreamProtocolTCP && stream.Protocol != models.StreamProtocolUDP {
+		return fmt.Errorf("unsupported stream protocol: %s", stream.Protocol)
+	}
+	if stream.ListenPort < 1 || stream.ListenPort > 65535 {
+		return fmt.Errorf("listen port %d out of range (1-65535)", stream.ListenPort)
+	}
+	if slices.Contains(reservedStreamPorts[stream.Protocol], stream.ListenPort) {
+		return fmt.Errorf("%s port %d is used by the HTTP server", stream.Protocol, stream.ListenPort)
+	}
+	if !slices.Contains(models.StreamLoadBalancingPolicies, stream.LoadBalancing) {
+		return fmt.Errorf("unsupported load balancing policy for streams: %s", stream.LoadBalancing)
+	}
+
+	if len(stream.Upstreams) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	for i, u := range stream.Upstreams {
+		if strings.TrimSpace(u.Host) == "" {
+			return fmt.Errorf("upstream %d: host is required", i)
+		}
+		if u.Port < 1 || u.Port > 65535 {
+			return fmt.Errorf("upstream %d: port %d out of range (1-65535)", i, u.Port)
+		}
+	}
+
+	names := make([]string, 0, len(stream.TLSServerNames))
+	for _, name := range stream.TLSServerNames {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	stream.TLSServerNames = names
+
+	if stream.TLSTermination {
+		if stream.Protocol != models.StreamProtocolTCP {
+			return fmt.Errorf("TLS termination is only supported for tcp streams")
+		}
+		if len(stream.TLSServerNames) == 0 {
+			return fmt.Errorf("TLS termination requires at least one server name")
+		}
+	}
+
+	return nil
+}
+
+// checkPortAvailable rejects streams that would share a listen port with another
+// stream. TLS-terminating streams may share a port when their server names differ.
+func (s *StreamService) checkPortAvailable(stream *models.Stream) error {
+	var others []models.Stream
+	if err := s.db.Where("listen_port = ? AND protocol = ? AND id <> ?", stream.ListenPort, stream.Protocol, stream.ID).
+		Find(&others).Error; err != nil {
+		return err
+	}
+
+	for _, other := range others {
+		if !stream.TLSTermination || !other.TLSTermination {
+			return fmt.Errorf("%s port %d is already used by stream %s", stream.Protocol, stream.ListenPort, other.UUID)
+		}
+		for _, name := range stream.TLSServerNames {
+			if slices.Contains(other.TLSServerNames, name) {
+				return fmt.Errorf("server name %s on port %d is already used by stream %s", name, stream.ListenPort, other.UUID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Create validates and creates a new stream.
+func (s *StreamService) Create(stream *models.Stream) error {
+	if err := s.validate(stream); err != nil {
+		return err
+	}
+	if err := s.checkPortAvailable(stream); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(stream).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("stream created: %s/%d", stream.Protocol, stream.ListenPort))
+	return nil
+}
+
+// Update validates and updates an existing stream.
+func (s *StreamService) Update(stream *models.Stream) error {
+	if err := s.validate(stream); err != nil {
+		return err
+	}
+	if err := s.checkPortAvailable(stream); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(stream).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("stream updated: %s/%d", stream.Protocol, stream.ListenPort))
+	return nil
+}
+
+// Delete removes a stream.
+func (s *StreamService) Delete(id uint) error {
+	if err := s.db.Delete(&models.Stream{}, id).Error; err != nil {
+		return err
+	}
+
+	s.notify(fmt.Sprintf("stream deleted: %d", id))
+	return nil
+}
+
+// GetByUUID finds a stream by UUID.
+func (s *StreamService) GetByUUID(uuid string) (*models.Stream, error) {
+	var stream models.Stream
+	if err := s.db.Where("uuid = ?", uuid).First(&stream).Error; err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// List returns all streams ordered by listen port.
+func (s *StreamService) List() ([]models.Stream, error) {
+	var streams []models.Stream
+	if err := s.db.Order("listen_port asc").Find(&streams).Error; err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
+)
+
+func setupStreamTestDB(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Stream{}))
+	return db
+}
+
+func TestStreamService_CRUD(t *testing.T) {
+	db := setupStreamTestDB(t)
+	service := NewStreamService(db)
+	notifier := &recordingNotifier{}
+	service.SetNotifier(notifier)
+
+	stream := &models.Stream{
+		UUID:       "postgres",
+		ListenPort: 5432,
+		Upstreams:  []models.Upstream{{Host: "db", Port: 5432}},
+		Enabled:    true,
+	}
+	require.NoError(t, service.Create(stream))
+	assert.Equal(t, models.StreamProtocolTCP, stream.Protocol)
+	assert.Equal(t, models.LoadBalancingRoundRobin, stream.LoadBalancing)
+
+	fetched, err := service.GetByUUID("postgres")
+	require.NoError(t, err)
+	require.Len(t, fetched.Upstreams, 1)
+
+	fetched.Upstreams = append(fetched.Upstreams, models.Upstream{Host: "db-replica", Port: 5432})
+	require.NoError(t, service.Update(fetched))
+
+	streams, err := service.List()
+	require.NoError(t, err)
+	require.Len(t, streams, 1)
+	assert.Len(t, streams[0].Upstreams, 2)
+
+	require.NoError(t, service.Delete(fetched.ID))
+	_, err = service.GetByUUID("postgres")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		"stream created: tcp/5432",
+		"stream updated: tcp/5432",
+		"stream deleted: 1",
+	}, notifier.reasons)
+}
+
+func TestStreamService_Validation(t *testing.T) {
+	db := setupStreamTestDB(t)
+	service := NewStreamService(db)
+	upstreams := []models.Upstream{{Host: "app", Port: 9000}}
+
+	tests := []struct {
+		name   string
+		stream models.Stream
+	}{
+		{"port out of range", models.Stream{ListenPort: 70000, Upstreams: upstreams}},
+		{"unknown protocol", models.Stream{ListenPort: 9000, Protocol: "sctp", Upstreams: upstreams}},
+		{"no upstreams", models.Stream{ListenPort: 9000}},
+		{"upstream without host", models.Stream{ListenPort: 9000, Upstreams: []models.Upstream{{Port: 9000}}}},
+		{"http port", models.Stream{ListenPort: 443, Upstreams: upstreams}},
+		{"http3 port", models.Stream{ListenPort: 443, Protocol: "udp", Upstreams: upstreams}},
+		{"cookie balancing", models.Stream{ListenPort: 9000, Upstreams: upstreams, LoadBalancing: models.LoadBalancingCookie}},
+		{"tls over udp", models.Stream{ListenPort: 9000, Protocol: "udp", Upstreams: upstreams, TLSTermination: true, TLSServerNames: []string{"a.example.com"}}},
+		{"tls without names", models.Stream{ListenPort: 9000, Upstreams: upstreams, TLSTermination: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := tt.stream
+			assert.Error(t, service.Create(&stream))
+		})
+	}
+}
+
+func TestStreamService_PortSharing(t *testing.T) {
+	db := setupStreamTestDB(t)
+	service := NewStreamService(db)
+	upstreams := []models.Upstream{{Host: "broker", Port: 1883}}
+
+	require.NoError(t, service.Create(&models.Stream{UUID: "plain", ListenPort: 1883, Upstreams: upstreams}))
+
+	// Same port over a different protocol is a different socket
+	require.NoError(t, service.Create(&models.Stream{UUID: "udp", ListenPort: 1883, Protocol: "udp", Upstreams: upstreams}))
+
+	err := service.Create(&models.Stream{UUID: "dup", ListenPort: 1883, Upstreams: upstreams})
+	require.ErrorContains(t, err, "already used by stream plain")
+
+	// TLS streams share a port when their SNI names differ
+	require.NoError(t, service.Create(&models.Stream{UUID: "tls-a", ListenPort: 8883, Upstreams: upstreams, TLSTermination: true, TLSServerNames: []string{"a.example.com"}}))
+	require.NoError(t, service.Create(&models.Stream{UUID: "tls-b", ListenPort: 8883, Upstreams: upstreams, TLSTermination: true, TLSServerNames: []string{"B.example.com"}}))
+
+	err = service.Create(&models.Stream{UUID: "tls-c", ListenPort: 8883, Upstreams: upstreams, TLSTermination: true, TLSServerNames: []string{"b.example.com"}})
+	require.ErrorContains(t, err, "server name b.example.com")
+
+	err = service.Create(&models.Stream{UUID: "plain-on-tls", ListenPort: 8883, Upstreams: upstreams})
+	require.Error(t, err)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/Wikid82/CaddyProxyManagerPlus/backend/internal/models"
@@ -24,7 +25,7 @@ func NewUptimeService(db *gorm.DB, ns *NotificationService) *UptimeService {
 // CheckHost checks a single host and creates a notification if it's down
 func (s *UptimeService) CheckHost(host string, port int) bool {
 	timeout := 5 * time.Second
-	target := fmt.Sprintf("%s:%d", host, port)
+	target := net.JoinHostPort(host, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", target, timeout)
 	if err != nil {
 		return false
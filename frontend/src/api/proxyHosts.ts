@@ -1,13 +1,100 @@
 import client from './client';
 
+export type AccessSatisfy = 'all' | 'any';
+
 export interface Location {
   uuid?: string;
   path: string;
+  path_regexp?: string;
   forward_scheme: string;
   forward_host: string;
   forward_port: number;
+  websocket_support?: boolean | null;
+  strip_path_prefix?: boolean;
+  rewrite_uri?: string;
+  request_headers?: Record<string, string>;
+  access_list_ids?: number[];
+  access_satisfy?: AccessSatisfy;
+  rate_limits?: RateLimitRule[];
+  max_body_size?: number;
+}
+
+export interface Upstream {
+  host: string;
+  port: number;
+  weight?: number;
+}
+
+export interface HeaderRule {
+  direction: 'request' | 'response';
+  operation: 'set' | 'add' | 'delete';
+  name: string;
+  value?: string;
+}
+
+export type RateLimitKey = 'ip' | 'header' | 'path';
+
+export interface RateLimitRule {
+  key: RateLimitKey;
+  header?: string;
+  requests: number;
+  window: number;
+}
+
+export interface LimitResponse {
+  status_code: number;
+  body: string;
+  content_type: string;
+}
+
+export interface MaintenanceConfig {
+  enabled: boolean;
+  template_id?: number | null;
+  bypass_ips?: string[];
+  bypass_cookie?: string;
 }
 
+export interface ErrorPage {
+  status: string;
+  template_id: number;
+}
+
+export type AdvancedFormat = 'json' | 'caddyfile';
+
+export interface AdvancedConfig {
+  format: AdvancedFormat;
+  content: string;
+  routes?: string;
+}
+
+export type LoadBalancingPolicy = 'round_robin' | 'least_conn' | 'ip_hash' | 'cookie' | 'first';
+
+export interface HealthCheckConfig {
+  active_enabled: boolean;
+  active_path: string;
+  active_interval: number;
+  active_timeout: number;
+  expect_status: number;
+  passive_enabled: boolean;
+  fail_duration: number;
+  max_fails: number;
+  unhealthy_status: number[] | null;
+}
+
+export type HTTPSMode = 'https_redirect' | 'https' | 'http_only';
+
+export interface UpstreamTLSConfig {
+  insecure_skip_verify: boolean;
+  server_name: string;
+  trusted_ca_pem: string;
+  client_cert_pem: string;
+  // Write-only: the API never returns the key, only has_client_key.
+  client_key_pem?: string;
+  has_client_key?: boolean;
+}
+
+export type CertIssuer = '' | 'letsencrypt' | 'letsencrypt_staging' | 'zerossl' | 'internal';
+
 export interface ProxyHost {
   uuid: string;
   domain_names: string;
@@ -15,13 +102,34 @@ export interface ProxyHost {
   forward_host: string;
   forward_port: number;
   ssl_forced: boolean;
+  https_mode?: HTTPSMode;
   http2_support: boolean;
   hsts_enabled: boolean;
   hsts_subdomains: boolean;
   block_exploits: boolean;
+  crowdsec?: boolean;
   websocket_support: boolean;
+  upstreams?: Upstream[];
+  load_balancing?: LoadBalancingPolicy;
+  health_check?: HealthCheckConfig;
+  upstream_tls?: UpstreamTLSConfig;
+  certificate_id?: number | null;
+  dns_provider?: string;
+  wildcard_certificate?: boolean;
+  cert_issuer?: CertIssuer;
+  header_rules?: HeaderRule[];
+  security_header_profile_id?: number | null;
+  csp_override?: string;
+  access_list_ids?: number[];
+  access_satisfy?: AccessSatisfy;
+  rate_limits?: RateLimitRule[];
+  max_body_size?: number;
+  limit_response?: LimitResponse;
+  maintenance?: MaintenanceConfig;
+  error_pages?: ErrorPage[];
+  intercept_errors?: boolean;
   locations: Location[];
-  advanced_config?: string;
+  advanced?: AdvancedConfig;
   enabled: boolean;
   created_at: string;
   updated_at: string;
@@ -1,5 +1,5 @@
 import { useState } from 'react'
-import type { ProxyHost } from '../api/proxyHosts'
+import type { AdvancedFormat, HTTPSMode, ProxyHost } from '../api/proxyHosts'
 import { useRemoteServers } from '../hooks/useRemoteServers'
 import { useDocker } from '../hooks/useDocker'
 
@@ -16,12 +16,14 @@ export default function ProxyHostForm({ host, onSubmit, onCancel }: ProxyHostFor
     forward_host: host?.forward_host || '',
     forward_port: host?.forward_port || 80,
     ssl_forced: host?.ssl_forced ?? false,
+    http_only: host?.https_mode === 'http_only',
     http2_support: host?.http2_support ?? false,
     hsts_enabled: host?.hsts_enabled ?? false,
     hsts_subdomains: host?.hsts_subdomains ?? false,
     block_exploits: host?.block_exploits ?? true,
     websocket_support: host?.websocket_support ?? false,
-    advanced_config: host?.advanced_config || '',
+    advanced_format: host?.advanced?.format || ('caddyfile' as AdvancedFormat),
+    advanced_content: host?.advanced?.content || '',
     enabled: host?.enabled ?? true,
   })
 
@@ -38,7 +40,10 @@ export default function ProxyHostForm({ host, onSubmit, onCancel }: ProxyHostFor
     setError(null)
 
     try {
-      await onSubmit(formData)
+      const { http_only, advanced_format, advanced_content, ...data } = formData
+      const https_mode: HTTPSMode = http_only ? 'http_only' : data.ssl_forced ? 'https_redirect' : 'https'
+      const advanced = { format: advanced_format, content: advanced_content }
+      await onSubmit({ ...data, ssl_forced: !http_only && data.ssl_forced, https_mode, advanced })
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to save proxy host')
     } finally {
@@ -228,6 +233,15 @@ export default function ProxyHostForm({ host, onSubmit, onCancel }: ProxyHostFor
               />
               <span className="text-sm text-gray-300">Force SSL</span>
             </label>
+            <label className="flex items-center gap-3">
+              <input
+                type="checkbox"
+                checked={formData.http_only}
+                onChange={e => setFormData({ ...formData, http_only: e.target.checked })}
+                className="w-4 h-4 text-blue-600 bg-gray-900 border-gray-700 rounded focus:ring-blue-500"
+              />
+              <span className="text-sm text-gray-300">HTTP Only (no certificate)</span>
+            </label>
             <label className="flex items-center gap-3">
               <input
                 type="checkbox"
@@ -286,14 +300,25 @@ export default function ProxyHostForm({ host, onSubmit, onCancel }: ProxyHostFor
 
           {/* Advanced Config */}
           <div>
-            <label htmlFor="advanced-config" className="block text-sm font-medium text-gray-300 mb-2">
-              Advanced Caddy Config (Optional)
-            </label>
+            <div className="flex items-center justify-between mb-2">
+              <label htmlFor="advanced-config" className="block text-sm font-medium text-gray-300">
+                Advanced Caddy Config (Optional)
+              </label>
+              <select
+                aria-label="Advanced config format"
+                value={formData.advanced_format}
+                onChange={e => setFormData({ ...formData, advanced_format: e.target.value as AdvancedFormat })}
+                className="bg-gray-900 border border-gray-700 rounded-lg px-2 py-1 text-sm text-white focus:outline-none focus:ring-2 focus:ring-blue-500"
+              >
+                <option value="caddyfile">Caddyfile</option>
+                <option value="json">JSON</option>
+              </select>
+            </div>
             <textarea
               id="advanced-config"
-              value={formData.advanced_config}
-              onChange={e => setFormData({ ...formData, advanced_config: e.target.value })}
-              placeholder="Additional Caddy directives..."
+              value={formData.advanced_content}
+              onChange={e => setFormData({ ...formData, advanced_content: e.target.value })}
+              placeholder={formData.advanced_format === 'json' ? '{"handler": "vars", "tenant": "acme"}' : 'header X-Served-By cpm'}
               rows={4}
               className="w-full bg-gray-900 border border-gray-700 rounded-lg px-4 py-2 text-white font-mono text-sm focus:outline-none focus:ring-2 focus:ring-blue-500"
             />
@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react'
-import { useNavigate } from 'react-router-dom'
+import { useNavigate, useSearchParams } from 'react-router-dom'
 import { useQuery, useQueryClient } from '@tanstack/react-query'
 import { Card } from '../components/ui/Card'
 import { Input } from '../components/ui/Input'
@@ -11,6 +11,7 @@ import { getSetupStatus } from '../api/setup'
 
 export default function Login() {
   const navigate = useNavigate()
+  const [searchParams] = useSearchParams()
   const queryClient = useQueryClient()
   const [email, setEmail] = useState('')
   const [password, setPassword] = useState('')
@@ -35,7 +36,13 @@ export default function Login() {
     setLoading(true)
 
     try {
-      await client.post('/auth/login', { email, password })
+      // rd is the proxied app that sent us here for single sign-on; the server
+      // only echoes it back when it is below the SSO cookie domain
+      const { data } = await client.post('/auth/login', { email, password, redirect: searchParams.get('rd') ?? '' })
+      if (data.redirect) {
+        window.location.assign(data.redirect)
+        return
+      }
       await login()
       await queryClient.invalidateQueries({ queryKey: ['setupStatus'] })
       toast.success('Logged in successfully')
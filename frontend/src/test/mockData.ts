@@ -15,7 +15,6 @@ export const mockProxyHosts: ProxyHost[] = [
     block_exploits: true,
     websocket_support: true,
     locations: [],
-    advanced_config: undefined,
     enabled: true,
     created_at: '2025-11-18T10:00:00Z',
     updated_at: '2025-11-18T10:00:00Z',
@@ -33,7 +32,6 @@ export const mockProxyHosts: ProxyHost[] = [
     block_exploits: true,
     websocket_support: false,
     locations: [],
-    advanced_config: undefined,
     enabled: true,
     created_at: '2025-11-18T10:00:00Z',
     updated_at: '2025-11-18T10:00:00Z',